@@ -5,11 +5,21 @@ import (
 	"os"
 
 	"prospect/internal/app"
+	"prospect/internal/cli"
 )
 
 func main() {
+	if len(os.Args) > 1 && cli.IsCommand(os.Args[1]) {
+		os.Exit(cli.Run(os.Args[1:], os.Stdout, os.Stderr))
+	}
+
+	openArgs, err := cli.ParseOpenArgs(os.Args[1:], os.Stderr)
+	if err != nil {
+		os.Exit(2)
+	}
+
 	application := app.New()
-	if err := application.Run(); err != nil {
+	if err := application.Run(openArgs); err != nil {
 		fmt.Fprintf(os.Stderr, "[ERROR] Ошибка запуска приложения: %v\n", err)
 		os.Exit(1)
 	}
@@ -2,7 +2,11 @@ package app
 
 import (
 	"fmt"
+	"io"
+	"log"
 
+	"prospect/internal/cli"
+	"prospect/internal/instance"
 	"prospect/internal/protobuf"
 	"prospect/internal/ui"
 
@@ -22,15 +26,51 @@ func New() *App {
 	}
 }
 
-func (a *App) Run() error {
+// Run показывает главное окно с файлами из args. Если приложение уже запущено, args
+// передаются его окну, а Run сразу возвращает управление
+func (a *App) Run(args *cli.OpenArgs) error {
+	instanceDir, err := instance.DefaultDir()
+	if err == nil && instance.Forward(instanceDir, args.Args()) {
+		log.Printf("Files passed to the running instance")
+		return nil
+	}
+
 	if err := protobuf.CheckProtoc(); err != nil {
 		return fmt.Errorf("protoc not found: %w", err)
 	}
-	a.window = ui.NewMainWindow(a.fyneApp)
+
+	window, open := ui.NewMainWindow(a.fyneApp, openRequest(args))
+	a.window = window
+
+	if instanceDir != "" {
+		server, err := instance.Listen(instanceDir, func(forwarded []string) {
+			forwardedArgs, err := cli.ParseOpenArgs(forwarded, io.Discard)
+			if err != nil {
+				log.Printf("Invalid arguments from another instance: %v", err)
+				return
+			}
+			open(openRequest(forwardedArgs))
+			window.RequestFocus()
+		})
+		if err != nil {
+			log.Printf("Single instance mode disabled: %v", err)
+		} else {
+			defer server.Close()
+		}
+	}
+
 	a.window.ShowAndRun()
 	return nil
 }
 
+func openRequest(args *cli.OpenArgs) ui.OpenRequest {
+	return ui.OpenRequest{
+		Files:       args.Files,
+		SchemaPath:  args.SchemaPath,
+		MessageName: args.MessageName,
+	}
+}
+
 func (a *App) GetApp() fyne.App {
 	return a.fyneApp
 }
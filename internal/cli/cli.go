@@ -0,0 +1,124 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"prospect/internal/protobuf"
+)
+
+type command struct {
+	name    string
+	summary string
+	run     func(args []string, stdout, stderr io.Writer) int
+}
+
+const (
+	exitOK    = 0
+	exitDiff  = 1
+	exitError = 2
+)
+
+func commands() []command {
+	return []command{
+		{name: "diff", summary: "structural diff between two protobuf binaries", run: runDiff},
+		{name: "infer", summary: "infer a .proto schema from a directory of sample binaries", run: runInfer},
+		{name: "compat", summary: "check wire compatibility between two versions of a .proto schema", run: runCompat},
+		{name: "validate", summary: "strictly validate protobuf binaries against a .proto schema", run: runValidate},
+	}
+}
+
+// IsCommand сообщает, является ли аргумент именем подкоманды CLI
+func IsCommand(name string) bool {
+	for _, cmd := range commands() {
+		if cmd.name == name {
+			return true
+		}
+	}
+	return name == "help" || name == "-h" || name == "--help"
+}
+
+// Run выполняет подкоманду и возвращает код завершения процесса
+func Run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		printUsage(stdout)
+		return exitOK
+	}
+
+	for _, cmd := range commands() {
+		if cmd.name == args[0] {
+			return cmd.run(args[1:], stdout, stderr)
+		}
+	}
+
+	fmt.Fprintf(stderr, "unknown command: %s\n", args[0])
+	printUsage(stderr)
+	return exitError
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: prospect [command] [arguments]")
+	fmt.Fprintln(w, "       prospect [file.bin...] [--schema s.proto] [--message M]")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Without a command the desktop application is started with the given files.")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands() {
+		fmt.Fprintf(w, "  %-10s %s\n", cmd.name, cmd.summary)
+	}
+}
+
+// parseInterspersed разбирает флаги, допуская их после позиционных аргументов
+// (prospect diff a.bin b.bin --schema s.proto)
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	positional := make([]string, 0)
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// multiFlag собирает значения флага, указанного несколько раз
+type multiFlag []string
+
+func (m *multiFlag) String() string {
+	return strings.Join(*m, ",")
+}
+
+func (m *multiFlag) Set(value string) error {
+	*m = append(*m, value)
+	return nil
+}
+
+// loadTree декодирует бинарный файл и, если указана схема, применяет ее
+func loadTree(parser *protobuf.Parser, path string, schemaPath string, messageName string) (*protobuf.TreeNode, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	tree, err := parser.ParseRaw(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+
+	if schemaPath == "" {
+		return tree, nil
+	}
+
+	tree, _, err = parser.ApplySchemaWithMessage(tree, schemaPath, messageName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply schema to %s: %w", path, err)
+	}
+	return tree, nil
+}
@@ -0,0 +1,177 @@
+package cli
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseInterspersed(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	schema := fs.String("schema", "", "")
+	message := fs.String("message", "", "")
+
+	positional, err := parseInterspersed(fs, []string{"a.bin", "--schema", "s.proto", "b.bin", "--message", "M"})
+	if err != nil {
+		t.Fatalf("parseInterspersed failed: %v", err)
+	}
+
+	if len(positional) != 2 || positional[0] != "a.bin" || positional[1] != "b.bin" {
+		t.Errorf("Expected positional [a.bin b.bin], got %v", positional)
+	}
+	if *schema != "s.proto" || *message != "M" {
+		t.Errorf("Expected schema=s.proto message=M, got schema=%s message=%s", *schema, *message)
+	}
+}
+
+func TestParseRepeatedKeys(t *testing.T) {
+	keys, err := parseRepeatedKeys([]string{"items=1", "orders.lines=3"})
+	if err != nil {
+		t.Fatalf("parseRepeatedKeys failed: %v", err)
+	}
+	if keys["items"] != 1 || keys["orders.lines"] != 3 {
+		t.Errorf("Unexpected keys: %v", keys)
+	}
+
+	if _, err := parseRepeatedKeys([]string{"items"}); err == nil {
+		t.Error("Expected error for key without field number")
+	}
+	if _, err := parseRepeatedKeys([]string{"items=x"}); err == nil {
+		t.Error("Expected error for non-numeric field number")
+	}
+}
+
+func TestRunUnknownCommand(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := Run([]string{"bogus"}, &stdout, &stderr); code != exitError {
+		t.Errorf("Expected exit code %d, got %d", exitError, code)
+	}
+	if !strings.Contains(stderr.String(), "unknown command") {
+		t.Errorf("Expected 'unknown command' in stderr, got %q", stderr.String())
+	}
+}
+
+func TestRunDiffRequiresTwoFiles(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := Run([]string{"diff", "only.bin"}, &stdout, &stderr); code != exitError {
+		t.Errorf("Expected exit code %d, got %d", exitError, code)
+	}
+}
+
+func TestRunInferRequiresDirectory(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := Run([]string{"infer"}, &stdout, &stderr); code != exitError {
+		t.Errorf("Expected exit code %d, got %d", exitError, code)
+	}
+	if !strings.Contains(stderr.String(), "Usage: prospect infer") {
+		t.Errorf("Expected usage in stderr, got %q", stderr.String())
+	}
+}
+
+func TestRunCompatReportsIncompatibleChanges(t *testing.T) {
+	dir := t.TempDir()
+	oldPath := filepath.Join(dir, "old.proto")
+	newPath := filepath.Join(dir, "new.proto")
+	if err := os.WriteFile(oldPath, []byte("message M {\n  optional int32 a = 1;\n}\n"), 0644); err != nil {
+		t.Fatalf("Failed to write schema: %v", err)
+	}
+	if err := os.WriteFile(newPath, []byte("message M {\n  optional string a = 1;\n}\n"), 0644); err != nil {
+		t.Fatalf("Failed to write schema: %v", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := Run([]string{"compat", oldPath, newPath}, &stdout, &stderr); code != exitDiff {
+		t.Errorf("Expected exit code %d, got %d (stderr: %s)", exitDiff, code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "M.a: type changed from int32") {
+		t.Errorf("Expected wire type change in output, got %q", stdout.String())
+	}
+
+	stdout.Reset()
+	if code := Run([]string{"compat", oldPath, oldPath}, &stdout, &stderr); code != exitOK {
+		t.Errorf("Expected exit code %d for identical schemas, got %d", exitOK, code)
+	}
+}
+
+func TestRunValidateRequiresSchema(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := Run([]string{"validate", "a.bin"}, &stdout, &stderr); code != exitError {
+		t.Errorf("Expected exit code %d, got %d", exitError, code)
+	}
+	if !strings.Contains(stderr.String(), "Usage: prospect validate") {
+		t.Errorf("Expected usage in stderr, got %q", stderr.String())
+	}
+}
+
+func TestExpandInputFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.bin", "b.bin", ".hidden"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte{0x08, 0x01}, 0644); err != nil {
+			t.Fatalf("Failed to write sample: %v", err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "nested"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	single := filepath.Join(dir, "a.bin")
+
+	files, err := expandInputFiles([]string{dir, single})
+	if err != nil {
+		t.Fatalf("expandInputFiles failed: %v", err)
+	}
+	if len(files) != 3 || files[2] != single {
+		t.Errorf("Expected a.bin, b.bin from directory plus explicit file, got %v", files)
+	}
+	if _, err := expandInputFiles([]string{filepath.Join(dir, "missing.bin")}); err == nil {
+		t.Error("Expected error for missing file")
+	}
+}
+
+func TestRunValidateRejectsInvalidRules(t *testing.T) {
+	dir := t.TempDir()
+	rulesPath := filepath.Join(dir, "rules.json")
+	if err := os.WriteFile(rulesPath, []byte(`{"M": {"a": {"pattern": "("}}}`), 0644); err != nil {
+		t.Fatalf("Failed to write rules: %v", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	code := Run([]string{"validate", "--schema", "s.proto", "--rules", rulesPath, dir}, &stdout, &stderr)
+	if code != exitError {
+		t.Errorf("Expected exit code %d, got %d", exitError, code)
+	}
+	if !strings.Contains(stderr.String(), "invalid pattern for M.a") {
+		t.Errorf("Expected invalid pattern error, got %q", stderr.String())
+	}
+}
+
+func TestParseOpenArgs(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	open, err := ParseOpenArgs([]string{"a.bin", "--schema", "s.proto", "/tmp/b.bin", "--message", "M"}, io.Discard)
+	if err != nil {
+		t.Fatalf("ParseOpenArgs failed: %v", err)
+	}
+	if len(open.Files) != 2 || open.Files[0] != filepath.Join(wd, "a.bin") || open.Files[1] != "/tmp/b.bin" {
+		t.Errorf("Expected absolute file paths, got %v", open.Files)
+	}
+	if open.SchemaPath != filepath.Join(wd, "s.proto") || open.MessageName != "M" {
+		t.Errorf("Expected absolute schema path and message M, got %s %s", open.SchemaPath, open.MessageName)
+	}
+
+	again, err := ParseOpenArgs(open.Args(), io.Discard)
+	if err != nil || strings.Join(again.Args(), " ") != strings.Join(open.Args(), " ") {
+		t.Errorf("Expected Args to round-trip, got %v (%v)", again, err)
+	}
+
+	if _, err := ParseOpenArgs([]string{"a.bin", "--message", "M"}, io.Discard); err == nil {
+		t.Error("Expected error for --message without --schema")
+	}
+}
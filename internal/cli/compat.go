@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"prospect/internal/protobuf"
+)
+
+func runCompat(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("compat", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	format := fs.String("format", "text", "output format: json or text")
+	corpus := fs.String("corpus", "", "directory of binaries to validate against the new schema")
+	messageName := fs.String("message", "", "top-level message name used for --corpus")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: prospect compat old.proto new.proto [--format json|text] [--corpus dir --message M]")
+		fs.PrintDefaults()
+	}
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return exitError
+	}
+	if len(positional) != 2 {
+		fs.Usage()
+		return exitError
+	}
+
+	// Проверка схем не требует protoc, он нужен только для декодирования корпуса
+	parser := &protobuf.Parser{}
+	if *corpus != "" {
+		parser, err = protobuf.NewParser()
+		if err != nil {
+			fmt.Fprintf(stderr, "error: %v\n", err)
+			return exitError
+		}
+	}
+
+	issues, err := parser.CheckSchemaCompatibility(positional[0], positional[1])
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return exitError
+	}
+
+	var results []protobuf.CorpusResult
+	if *corpus != "" {
+		results, err = parser.ValidateCorpus(*corpus, positional[1], *messageName)
+		if err != nil {
+			fmt.Fprintf(stderr, "error: %v\n", err)
+			return exitError
+		}
+	}
+
+	switch *format {
+	case "json":
+		jsonStr, err := protobuf.CompatToJSONString(issues, results)
+		if err != nil {
+			fmt.Fprintf(stderr, "error: %v\n", err)
+			return exitError
+		}
+		fmt.Fprintln(stdout, jsonStr)
+	case "text":
+		fmt.Fprint(stdout, protobuf.CompatToText(issues, results))
+	default:
+		fmt.Fprintf(stderr, "error: unknown format %q\n", *format)
+		return exitError
+	}
+
+	if protobuf.CompatHasErrors(issues) || protobuf.CorpusHasFailures(results) {
+		return exitDiff
+	}
+	return exitOK
+}
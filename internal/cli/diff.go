@@ -0,0 +1,95 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"prospect/internal/protobuf"
+)
+
+func runDiff(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	schemaPath := fs.String("schema", "", "path to .proto schema applied to both files")
+	messageName := fs.String("message", "", "top-level message name in the schema")
+	format := fs.String("format", "json", "output format: json or text")
+	var keys multiFlag
+	fs.Var(&keys, "key", "match repeated elements by key field: path=fieldNum (can be repeated)")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: prospect diff a.bin b.bin [--schema s.proto] [--message M] [--key path=N] [--format json|text]")
+		fs.PrintDefaults()
+	}
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return exitError
+	}
+	if len(positional) != 2 {
+		fs.Usage()
+		return exitError
+	}
+
+	repeatedKeys, err := parseRepeatedKeys(keys)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return exitError
+	}
+
+	parser, err := protobuf.NewParser()
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return exitError
+	}
+
+	oldTree, err := loadTree(parser, positional[0], *schemaPath, *messageName)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return exitError
+	}
+	newTree, err := loadTree(parser, positional[1], *schemaPath, *messageName)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return exitError
+	}
+
+	entries := protobuf.DiffTrees(oldTree, newTree, &protobuf.DiffOptions{RepeatedKeys: repeatedKeys})
+
+	switch *format {
+	case "json":
+		jsonStr, err := protobuf.DiffToJSONString(entries)
+		if err != nil {
+			fmt.Fprintf(stderr, "error: %v\n", err)
+			return exitError
+		}
+		fmt.Fprintln(stdout, jsonStr)
+	case "text":
+		fmt.Fprint(stdout, protobuf.DiffToText(entries))
+	default:
+		fmt.Fprintf(stderr, "error: unknown format %q\n", *format)
+		return exitError
+	}
+
+	if len(entries) > 0 {
+		return exitDiff
+	}
+	return exitOK
+}
+
+func parseRepeatedKeys(values []string) (map[string]int, error) {
+	keys := make(map[string]int)
+	for _, value := range values {
+		parts := strings.SplitN(value, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid --key %q, expected path=fieldNum", value)
+		}
+		fieldNum, err := strconv.Atoi(parts[1])
+		if err != nil || fieldNum <= 0 {
+			return nil, fmt.Errorf("invalid field number in --key %q", value)
+		}
+		keys[parts[0]] = fieldNum
+	}
+	return keys, nil
+}
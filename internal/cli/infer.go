@@ -0,0 +1,57 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"prospect/internal/protobuf"
+)
+
+func runInfer(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("infer", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	outPath := fs.String("out", "", "write the inferred .proto to this file instead of stdout")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: prospect infer samples_dir [--out schema.proto]")
+		fs.PrintDefaults()
+	}
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return exitError
+	}
+	if len(positional) != 1 {
+		fs.Usage()
+		return exitError
+	}
+
+	parser, err := protobuf.NewParser()
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return exitError
+	}
+
+	inference, err := parser.InferSchemaFromDir(positional[0])
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return exitError
+	}
+	for _, skipped := range inference.Skipped {
+		fmt.Fprintf(stderr, "skipped %s\n", skipped)
+	}
+
+	protoContent := inference.GenerateProto()
+	if *outPath == "" {
+		fmt.Fprint(stdout, protoContent)
+		return exitOK
+	}
+
+	if err := os.WriteFile(*outPath, []byte(protoContent), 0644); err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return exitError
+	}
+	fmt.Fprintf(stderr, "inferred schema from %d sample(s) written to %s\n", inference.Samples(), *outPath)
+	return exitOK
+}
@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+)
+
+// OpenArgs - файлы, которые приложение открывает при запуске: prospect file.bin --schema s.proto --message M
+type OpenArgs struct {
+	Files       []string
+	SchemaPath  string
+	MessageName string
+}
+
+// ParseOpenArgs разбирает аргументы запуска приложения без подкоманды. Пути приводятся к
+// абсолютным, потому что аргументы могут быть переданы уже запущенному экземпляру с другим
+// рабочим каталогом
+func ParseOpenArgs(args []string, stderr io.Writer) (*OpenArgs, error) {
+	fs := flag.NewFlagSet("prospect", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	schemaPath := fs.String("schema", "", "path to .proto schema applied to the opened files")
+	messageName := fs.String("message", "", "top-level message name in the schema")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: prospect [file.bin...] [--schema s.proto] [--message M]")
+		fs.PrintDefaults()
+	}
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return nil, err
+	}
+	if *messageName != "" && *schemaPath == "" {
+		return nil, fmt.Errorf("--message requires --schema")
+	}
+
+	open := &OpenArgs{Files: make([]string, 0, len(positional)), MessageName: *messageName}
+	for _, path := range positional {
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+		open.Files = append(open.Files, absPath)
+	}
+	if *schemaPath != "" {
+		if open.SchemaPath, err = filepath.Abs(*schemaPath); err != nil {
+			return nil, err
+		}
+	}
+	return open, nil
+}
+
+// Args возвращает аргументы, которые ParseOpenArgs разберет в те же OpenArgs
+func (o *OpenArgs) Args() []string {
+	args := append([]string{}, o.Files...)
+	if o.SchemaPath != "" {
+		args = append(args, "--schema", o.SchemaPath)
+	}
+	if o.MessageName != "" {
+		args = append(args, "--message", o.MessageName)
+	}
+	return args
+}
@@ -0,0 +1,182 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"prospect/internal/protobuf"
+)
+
+// validationResult - результат проверки одного сообщения
+type validationResult struct {
+	Source string                 `json:"source"`
+	Error  string                 `json:"error,omitempty"`
+	Issues []protobuf.SchemaIssue `json:"issues"`
+}
+
+func runValidate(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	schemaPath := fs.String("schema", "", "path to .proto schema file")
+	messageName := fs.String("message", "", "top-level message name in the schema")
+	format := fs.String("format", "text", "output format: json or text")
+	rulesPath := fs.String("rules", "", "JSON file with constraint rules in addition to buf.validate options")
+	delimited := fs.Bool("delimited", false, "treat each file as a stream of varint length-prefixed messages")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: prospect validate --schema s.proto [--message M] [--rules rules.json] [--delimited] [--format json|text] file|dir...")
+		fs.PrintDefaults()
+	}
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return exitError
+	}
+	if len(positional) == 0 || *schemaPath == "" {
+		fs.Usage()
+		return exitError
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(stderr, "error: unknown format %q\n", *format)
+		return exitError
+	}
+
+	files, err := expandInputFiles(positional)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return exitError
+	}
+
+	var rules protobuf.ConstraintRules
+	if *rulesPath != "" {
+		rules, err = protobuf.LoadConstraintRules(*rulesPath)
+		if err != nil {
+			fmt.Fprintf(stderr, "error: %v\n", err)
+			return exitError
+		}
+	}
+
+	parser, err := protobuf.NewParser()
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return exitError
+	}
+	// Ошибка в схеме или имени сообщения относится ко всем файлам сразу
+	if _, err := parser.ParseSchemaFile(*schemaPath); err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return exitError
+	}
+
+	results := make([]validationResult, 0, len(files))
+	for _, file := range files {
+		results = append(results, validateFile(parser, file, *schemaPath, *messageName, rules, *delimited)...)
+	}
+
+	failed := false
+	for _, result := range results {
+		if result.Error != "" || len(result.Issues) > 0 {
+			failed = true
+		}
+	}
+
+	if *format == "json" {
+		jsonBytes, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			fmt.Fprintf(stderr, "error: %v\n", err)
+			return exitError
+		}
+		fmt.Fprintln(stdout, string(jsonBytes))
+	} else {
+		writeValidationText(stdout, results)
+	}
+
+	if failed {
+		return exitDiff
+	}
+	return exitOK
+}
+
+// validateFile проверяет файл целиком или, для потока с префиксами длины, каждое сообщение отдельно
+func validateFile(parser *protobuf.Parser, path string, schemaPath string, messageName string, rules protobuf.ConstraintRules, delimited bool) []validationResult {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return []validationResult{{Source: path, Error: err.Error()}}
+	}
+
+	if !delimited {
+		return []validationResult{validateMessage(parser, path, data, schemaPath, messageName, rules)}
+	}
+
+	messages, err := protobuf.SplitDelimited(data)
+	if err != nil {
+		return []validationResult{{Source: path, Error: err.Error()}}
+	}
+	results := make([]validationResult, 0, len(messages))
+	for i, message := range messages {
+		source := fmt.Sprintf("%s#%d", path, i)
+		results = append(results, validateMessage(parser, source, message, schemaPath, messageName, rules))
+	}
+	return results
+}
+
+func validateMessage(parser *protobuf.Parser, source string, data []byte, schemaPath string, messageName string, rules protobuf.ConstraintRules) validationResult {
+	result := validationResult{Source: source, Issues: []protobuf.SchemaIssue{}}
+	report, err := parser.ValidateData(data, schemaPath, messageName, rules)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Issues = report.Issues
+	return result
+}
+
+func writeValidationText(w io.Writer, results []validationResult) {
+	failed := 0
+	for _, result := range results {
+		switch {
+		case result.Error != "":
+			failed++
+			fmt.Fprintf(w, "FAIL %s: %s\n", result.Source, result.Error)
+		case len(result.Issues) > 0:
+			failed++
+			fmt.Fprintf(w, "FAIL %s: %d issue(s)\n", result.Source, len(result.Issues))
+			for _, issue := range result.Issues {
+				fmt.Fprintf(w, "  %s: %s\n", issue.Path, issue.Message())
+			}
+		default:
+			fmt.Fprintf(w, "OK   %s\n", result.Source)
+		}
+	}
+	fmt.Fprintf(w, "\n%d of %d message(s) failed validation\n", failed, len(results))
+}
+
+// expandInputFiles раскрывает каталоги в список файлов (без вложенных каталогов и скрытых файлов)
+func expandInputFiles(paths []string) ([]string, error) {
+	files := make([]string, 0, len(paths))
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+				continue
+			}
+			files = append(files, filepath.Join(path, entry.Name()))
+		}
+	}
+	return files, nil
+}
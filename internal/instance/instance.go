@@ -0,0 +1,152 @@
+// Package instance оставляет запущенным одно окно приложения: следующий запуск передает свои
+// аргументы уже запущенному экземпляру и завершается. Экземпляр слушает локальный TCP-порт,
+// адрес и токен записываются в файл каталога настроек, доступный только пользователю
+package instance
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	endpointFile = "instance.json"
+	dialTimeout  = time.Second
+	replyOK      = "ok"
+)
+
+// endpoint - содержимое файла instance.json
+type endpoint struct {
+	Address string `json:"address"`
+	Token   string `json:"token"`
+}
+
+// request - аргументы, переданные следующим запуском
+type request struct {
+	Token string   `json:"token"`
+	Args  []string `json:"args"`
+}
+
+// DefaultDir возвращает каталог настроек приложения
+func DefaultDir() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "prospect"), nil
+}
+
+// Forward передает args запущенному экземпляру. Возвращает false, если экземпляр не запущен
+// или не ответил; тогда запуск продолжается как обычно
+func Forward(dir string, args []string) bool {
+	data, err := os.ReadFile(filepath.Join(dir, endpointFile))
+	if err != nil {
+		return false
+	}
+	var target endpoint
+	if err := json.Unmarshal(data, &target); err != nil {
+		return false
+	}
+
+	conn, err := net.DialTimeout("tcp", target.Address, dialTimeout)
+	if err != nil {
+		return false
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(dialTimeout))
+
+	if err := json.NewEncoder(conn).Encode(request{Token: target.Token, Args: args}); err != nil {
+		return false
+	}
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	return err == nil && reply == replyOK+"\n"
+}
+
+// Server принимает аргументы следующих запусков
+type Server struct {
+	listener net.Listener
+	path     string
+	token    string
+	handle   func(args []string)
+}
+
+// Listen начинает принимать аргументы следующих запусков. handle вызывается из отдельной
+// горутины
+func Listen(dir string, handle func(args []string)) (*Server, error) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		listener: listener,
+		path:     filepath.Join(dir, endpointFile),
+		token:    hex.EncodeToString(token),
+		handle:   handle,
+	}
+
+	data, err := json.Marshal(endpoint{Address: listener.Addr().String(), Token: s.token})
+	if err == nil {
+		err = os.WriteFile(s.path, data, 0600)
+	}
+	if err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to write %s: %w", s.path, err)
+	}
+
+	go s.serve()
+	return s, nil
+}
+
+func (s *Server) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.serveConn(conn)
+	}
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(dialTimeout))
+
+	var req request
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		log.Printf("Invalid request from another instance: %v", err)
+		return
+	}
+	if req.Token != s.token {
+		log.Printf("Rejected request from another instance: invalid token")
+		return
+	}
+	fmt.Fprintln(conn, replyOK)
+	s.handle(req.Args)
+}
+
+// Close перестает принимать аргументы и удаляет файл instance.json, если его не заменил
+// другой экземпляр
+func (s *Server) Close() error {
+	if data, err := os.ReadFile(s.path); err == nil {
+		var current endpoint
+		if json.Unmarshal(data, &current) == nil && current.Token == s.token {
+			os.Remove(s.path)
+		}
+	}
+	return s.listener.Close()
+}
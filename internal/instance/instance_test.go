@@ -0,0 +1,68 @@
+package instance
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestForward_DeliversArgsToRunningInstance(t *testing.T) {
+	dir := t.TempDir()
+	received := make(chan []string, 1)
+	server, err := Listen(dir, func(args []string) { received <- args })
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+
+	if !Forward(dir, []string{"/tmp/a.bin", "--schema", "/tmp/s.proto"}) {
+		t.Fatal("Expected Forward to reach the running instance")
+	}
+	select {
+	case args := <-received:
+		if strings.Join(args, " ") != "/tmp/a.bin --schema /tmp/s.proto" {
+			t.Errorf("Unexpected args: %v", args)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected args to be delivered")
+	}
+
+	if info, err := os.Stat(filepath.Join(dir, endpointFile)); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected endpoint file readable only by the user, got %v (%v)", info, err)
+	}
+
+	server.Close()
+	if _, err := os.Stat(filepath.Join(dir, endpointFile)); !os.IsNotExist(err) {
+		t.Error("Expected Close to remove the endpoint file")
+	}
+	if Forward(dir, nil) {
+		t.Error("Expected Forward to fail after the instance exited")
+	}
+}
+
+func TestForward_RejectsWrongToken(t *testing.T) {
+	dir := t.TempDir()
+	received := make(chan []string, 1)
+	server, err := Listen(dir, func(args []string) { received <- args })
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer server.Close()
+
+	path := filepath.Join(dir, endpointFile)
+	data, _ := os.ReadFile(path)
+	forged := strings.Replace(string(data), server.token, strings.Repeat("0", len(server.token)), 1)
+	if err := os.WriteFile(path, []byte(forged), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if Forward(dir, []string{"/tmp/a.bin"}) {
+		t.Error("Expected Forward with a wrong token to fail")
+	}
+	select {
+	case args := <-received:
+		t.Errorf("Expected no args to be delivered, got %v", args)
+	default:
+	}
+}
@@ -1,6 +1,7 @@
 package protobuf
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
@@ -588,7 +589,7 @@ message Person {
 		Children: make([]*TreeNode, 0),
 	}
 
-	tree, err := parser.ApplySchemaWithMessage(root, schemaFile, "")
+	tree, _, err := parser.ApplySchemaWithMessage(root, schemaFile, "")
 	if err != nil {
 		t.Fatalf("Failed to apply schema: %v", err)
 	}
@@ -632,7 +633,7 @@ message ThirdMessage {
 		Children: make([]*TreeNode, 0),
 	}
 
-	tree, err := parser.ApplySchemaWithMessage(root, schemaFile, "SecondMessage")
+	tree, _, err := parser.ApplySchemaWithMessage(root, schemaFile, "SecondMessage")
 	if err != nil {
 		t.Fatalf("Failed to apply schema: %v", err)
 	}
@@ -668,7 +669,7 @@ message Person {
 		Children: make([]*TreeNode, 0),
 	}
 
-	_, err = parser.ApplySchemaWithMessage(root, schemaFile, "NonExistentMessage")
+	_, _, err = parser.ApplySchemaWithMessage(root, schemaFile, "NonExistentMessage")
 	if err == nil {
 		t.Fatal("Expected error when message not found, got nil")
 	}
@@ -677,3 +678,187 @@ message Person {
 		t.Errorf("Expected error about message not found, got: %v", err)
 	}
 }
+
+func TestApplySchema_Groups(t *testing.T) {
+	parser := &Parser{}
+	schemaFile := writeTestSchema(t, `syntax = "proto2";
+
+message SearchResponse {
+  repeated group Result = 1 {
+    required string url = 2;
+    optional group Meta = 3 {
+      optional int32 rank = 1;
+    }
+  }
+  optional Result other = 4;
+}`)
+
+	root := &TreeNode{Name: "root", Type: "message"}
+	result := &TreeNode{Name: "field_1", Type: "message_1", FieldNum: 1, IsGroup: true}
+	result.AddChild(&TreeNode{Name: "field_2", Type: "string", FieldNum: 2, Value: "a"})
+	meta := &TreeNode{Name: "field_3", Type: "message_2", FieldNum: 3, IsGroup: true}
+	meta.AddChild(&TreeNode{Name: "field_1", Type: "int64", FieldNum: 1, Value: "5"})
+	result.AddChild(meta)
+	other := &TreeNode{Name: "field_4", Type: "message_3", FieldNum: 4, IsGroup: true}
+	other.AddChild(&TreeNode{Name: "field_2", Type: "string", FieldNum: 2, Value: "b"})
+	root.AddChild(result)
+	root.AddChild(other)
+
+	_, report, err := parser.ApplySchemaWithMessage(root, schemaFile, "SearchResponse")
+	if err != nil {
+		t.Fatalf("ApplySchemaWithMessage failed: %v", err)
+	}
+
+	if result.Name != "result" || result.Type != "Result" || !result.IsRepeated {
+		t.Errorf("Expected repeated group 'result' of type Result, got %+v", result)
+	}
+	if result.Children[0].Name != "url" || meta.Name != "meta" || meta.Children[0].Name != "rank" {
+		t.Errorf("Expected group bodies to be named from the schema, got %s, %s, %s",
+			result.Children[0].Name, meta.Name, meta.Children[0].Name)
+	}
+
+	if len(report.Issues) != 1 {
+		t.Fatalf("Expected 1 issue, got %+v", report.Issues)
+	}
+	issue := report.Issues[0]
+	if issue.Kind != SchemaIssueWireTypeMismatch || issue.FieldName != "other" || issue.Actual != wireTypeGroup {
+		t.Errorf("Expected wire type mismatch for group encoded 'other', got %+v", issue)
+	}
+}
+
+func TestApplySchema_EnumFieldsAsInt32(t *testing.T) {
+	// Перечисления кодируются как varint: поле с типом перечисления получает тип int32, а не
+	// имя перечисления, которое раньше принималось за имя сообщения
+	parser := &Parser{}
+	schemaFile := writeTestSchema(t, `syntax = "proto2";
+package shop;
+
+enum Status {
+  NEW = 0;
+  PAID = 1;
+}
+
+message Order {
+  enum Priority {
+    LOW = 0;
+    HIGH = 1;
+  }
+  optional Status status = 1;
+  optional Priority priority = 2;
+  repeated .shop.Status history = 3;
+}`)
+
+	root := &TreeNode{Name: "root", Type: "message"}
+	status := &TreeNode{Name: "field_1", Type: "int64", FieldNum: 1, Value: "1"}
+	priority := &TreeNode{Name: "field_2", Type: "int64", FieldNum: 2, Value: "1"}
+	history := &TreeNode{Name: "field_3", Type: "int64", FieldNum: 3, Value: "0"}
+	root.AddChild(status)
+	root.AddChild(priority)
+	root.AddChild(history)
+
+	_, report, err := parser.ApplySchemaWithMessage(root, schemaFile, "Order")
+	if err != nil {
+		t.Fatalf("ApplySchemaWithMessage failed: %v", err)
+	}
+
+	for _, node := range []*TreeNode{status, priority, history} {
+		if node.Type != "int32" {
+			t.Errorf("Expected enum field %s to be int32, got %s", node.Name, node.Type)
+		}
+	}
+	if status.Name != "status" || priority.Name != "priority" || history.Name != "history" || !history.IsRepeated {
+		t.Errorf("Expected enum fields to be named from the schema, got %s, %s, %s", status.Name, priority.Name, history.Name)
+	}
+	if report.HasIssues() {
+		t.Errorf("Expected no issues for enum values in range, got %+v", report.Issues)
+	}
+}
+
+func TestApplySchema_SameShortNamesResolveByScope(t *testing.T) {
+	// Сообщения с одинаковыми короткими именами в разных сообщениях и пакетах различаются
+	// по полному имени
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "other.proto"), []byte(`syntax = "proto2";
+package other;
+
+message Foo {
+  optional int64 count = 1;
+}
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	schemaFile := filepath.Join(dir, "root.proto")
+	if err := os.WriteFile(schemaFile, []byte(`syntax = "proto2";
+package main;
+
+import "other.proto";
+
+message Foo {
+  optional string title = 1;
+}
+
+message Outer1 {
+  message Inner {
+    optional string text = 1;
+  }
+  optional Inner inner = 1;
+}
+
+message Outer2 {
+  message Inner {
+    optional int64 number = 1;
+  }
+  optional Inner inner = 1;
+}
+
+message Root {
+  optional Foo own = 1;
+  optional other.Foo imported = 2;
+  optional Outer1 first = 3;
+  optional Outer2 second = 4;
+}
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	message := func(fieldNum int, children ...*TreeNode) *TreeNode {
+		node := &TreeNode{Name: fmt.Sprintf("field_%d", fieldNum), Type: "message_1", FieldNum: fieldNum}
+		for _, child := range children {
+			node.AddChild(child)
+		}
+		return node
+	}
+	scalar := func(value string) *TreeNode {
+		return &TreeNode{Name: "field_1", Type: "string", FieldNum: 1, Value: value}
+	}
+	root := &TreeNode{Name: "root", Type: "message"}
+	root.AddChild(message(1, scalar("a")))
+	root.AddChild(message(2, scalar("5")))
+	root.AddChild(message(3, message(1, scalar("b"))))
+	root.AddChild(message(4, message(1, scalar("7"))))
+
+	if _, _, err := (&Parser{}).ApplySchemaWithMessage(root, schemaFile, "Root"); err != nil {
+		t.Fatalf("ApplySchemaWithMessage failed: %v", err)
+	}
+
+	expected := []string{"title", "count", "text", "number"}
+	got := []string{
+		root.Children[0].Children[0].Name,
+		root.Children[1].Children[0].Name,
+		root.Children[2].Children[0].Children[0].Name,
+		root.Children[3].Children[0].Children[0].Name,
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("Field %d: expected %s, got %s", i, expected[i], got[i])
+		}
+	}
+
+	parser := &Parser{}
+	if _, _, err := parser.ApplySchemaWithMessage(&TreeNode{Name: "root", Type: "message"}, schemaFile, "Outer2.Inner"); err != nil {
+		t.Errorf("Expected a nested message to be selectable by its relative name: %v", err)
+	}
+	if _, _, err := parser.ApplySchemaWithMessage(&TreeNode{Name: "root", Type: "message"}, schemaFile, "Inner"); err == nil {
+		t.Error("Expected an ambiguous short name to be rejected")
+	}
+}
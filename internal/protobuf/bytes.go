@@ -0,0 +1,74 @@
+package protobuf
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// BytesFormat - представление значения bytes поля при отображении и редактировании
+type BytesFormat string
+
+const (
+	BytesHex     BytesFormat = "hex"
+	BytesBase64  BytesFormat = "base64"
+	BytesEscaped BytesFormat = "escaped"
+)
+
+// BytesFormats перечисляет доступные представления в порядке переключения в редакторе
+var BytesFormats = []BytesFormat{BytesHex, BytesBase64, BytesEscaped}
+
+// BytesValue возвращает сырые байты значения bytes поля. Строка считается уже
+// декодированной и преобразуется без разбора escape-последовательностей
+func BytesValue(value interface{}) []byte {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return v
+	case string:
+		return []byte(v)
+	default:
+		return []byte(fmt.Sprintf("%v", v))
+	}
+}
+
+// FormatBytes представляет байты в выбранном формате
+func FormatBytes(data []byte, format BytesFormat) string {
+	switch format {
+	case BytesHex:
+		return hex.EncodeToString(data)
+	case BytesBase64:
+		return base64.StdEncoding.EncodeToString(data)
+	default:
+		return EscapeTextBytes(data)
+	}
+}
+
+// ParseBytes разбирает значение, введенное в выбранном формате. Для hex допускаются
+// пробелы между байтами, для base64 - вариант без дополнения '='
+func ParseBytes(text string, format BytesFormat) ([]byte, error) {
+	switch format {
+	case BytesHex:
+		cleaned := strings.Join(strings.Fields(text), "")
+		cleaned = strings.TrimPrefix(strings.TrimPrefix(cleaned, "0x"), "0X")
+		data, err := hex.DecodeString(cleaned)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hex value: %w", err)
+		}
+		return data, nil
+	case BytesBase64:
+		trimmed := strings.TrimSpace(text)
+		data, err := base64.StdEncoding.DecodeString(trimmed)
+		if err != nil {
+			data, err = base64.RawStdEncoding.DecodeString(trimmed)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64 value: %w", err)
+		}
+		return data, nil
+	default:
+		return UnescapeText(text)
+	}
+}
@@ -0,0 +1,82 @@
+package protobuf
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestFormatAndParseBytes(t *testing.T) {
+	data := []byte{0x00, 0xff, 'A', '"', '\n'}
+
+	for _, format := range BytesFormats {
+		text := FormatBytes(data, format)
+		parsed, err := ParseBytes(text, format)
+		if err != nil {
+			t.Fatalf("ParseBytes(%s) failed: %v", format, err)
+		}
+		if !bytes.Equal(parsed, data) {
+			t.Errorf("Round trip through %s produced %v, want %v", format, parsed, data)
+		}
+	}
+
+	if text := FormatBytes(data, BytesHex); text != "00ff41220a" {
+		t.Errorf("Unexpected hex: %s", text)
+	}
+	if text := FormatBytes(data, BytesEscaped); text != `\000\377A\"\n` {
+		t.Errorf("Unexpected escaped text: %s", text)
+	}
+	if parsed, err := ParseBytes("de ad BE EF", BytesHex); err != nil || !bytes.Equal(parsed, []byte{0xde, 0xad, 0xbe, 0xef}) {
+		t.Errorf("Expected spaced hex to parse, got %v, %v", parsed, err)
+	}
+	if parsed, err := ParseBytes("AAE", BytesBase64); err != nil || !bytes.Equal(parsed, []byte{0x00, 0x01}) {
+		t.Errorf("Expected unpadded base64 to parse, got %v, %v", parsed, err)
+	}
+	if _, err := ParseBytes("abc", BytesHex); err == nil {
+		t.Error("Expected error for odd-length hex")
+	}
+}
+
+func TestApplySchema_BytesField(t *testing.T) {
+	schemaFile := writeTestSchema(t, `syntax = "proto2";
+
+message Blob {
+  optional bytes payload = 1;
+}`)
+	tree := newTestRoot(&TreeNode{Name: "field_1", Type: "string", FieldNum: 1, Value: "\x01\"x"})
+
+	parser := &Parser{}
+	if _, _, err := parser.ApplySchemaWithMessage(tree, schemaFile, "Blob"); err != nil {
+		t.Fatalf("ApplySchemaWithMessage failed: %v", err)
+	}
+
+	payload := tree.Children[0]
+	if payload.Type != "bytes" {
+		t.Fatalf("Expected bytes type, got %s", payload.Type)
+	}
+	if data, ok := payload.Value.([]byte); !ok || !bytes.Equal(data, []byte{0x01, '"', 'x'}) {
+		t.Errorf("Expected raw bytes, got %#v", payload.Value)
+	}
+
+	clone := tree.Clone()
+	clone.Children[0].Value.([]byte)[0] = 0x02
+	if payload.Value.([]byte)[0] != 0x01 {
+		t.Error("Expected clone to copy the byte slice")
+	}
+}
+
+func TestTreeToTextFormat_EscapesBytes(t *testing.T) {
+	tree := newTestRoot(&TreeNode{Name: "payload", Type: "bytes", FieldNum: 1, Value: []byte{0x00, '"', '\\', 0xc8}})
+
+	serializer := &Serializer{}
+	expected := `"\000\"\\\310"`
+	if text := serializer.TreeToTextFormat(tree); !strings.Contains(text, "1: "+expected) {
+		t.Errorf("Expected escaped bytes in text format, got %q", text)
+	}
+	if text := serializer.TreeToTextFormatWithNames(tree); !strings.Contains(text, expected) {
+		t.Errorf("Expected escaped bytes in named text format, got %q", text)
+	}
+	if schema := serializer.GenerateProtoSchema(tree); !strings.Contains(schema, "optional bytes") {
+		t.Errorf("Expected bytes field in generated schema, got:\n%s", schema)
+	}
+}
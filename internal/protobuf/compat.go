@@ -0,0 +1,436 @@
+package protobuf
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// CompatIssueKind описывает вид изменения схемы, влияющего на совместимость
+type CompatIssueKind string
+
+const (
+	CompatFieldNumberReused CompatIssueKind = "field_number_reused"
+	CompatWireTypeChanged   CompatIssueKind = "wire_type_changed"
+	CompatTypeChanged       CompatIssueKind = "type_changed"
+	CompatLabelChanged      CompatIssueKind = "label_changed"
+	CompatRequiredRemoved   CompatIssueKind = "required_removed"
+	CompatRequiredAdded     CompatIssueKind = "required_added"
+	CompatFieldRemoved      CompatIssueKind = "field_removed"
+	CompatFieldRenamed      CompatIssueKind = "field_renamed"
+	CompatMessageRemoved    CompatIssueKind = "message_removed"
+	CompatEnumValueRemoved  CompatIssueKind = "enum_value_removed"
+)
+
+// CompatSeverity: ошибки ломают чтение данных на проводе, предупреждения требуют внимания
+type CompatSeverity string
+
+const (
+	CompatError   CompatSeverity = "error"
+	CompatWarning CompatSeverity = "warning"
+)
+
+// CompatIssue - одно несовместимое или подозрительное изменение между версиями схемы
+type CompatIssue struct {
+	Kind     CompatIssueKind `json:"kind"`
+	Severity CompatSeverity  `json:"severity"`
+	Path     string          `json:"path"`
+	Message  string          `json:"message"`
+}
+
+// CheckSchemaCompatibility сравнивает две версии .proto файла и возвращает изменения,
+// нарушающие совместимость на уровне wire format
+func (p *Parser) CheckSchemaCompatibility(oldPath, newPath string) ([]CompatIssue, error) {
+	oldSchema, err := p.loadSchema(oldPath)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", oldPath, err)
+	}
+	newSchema, err := p.loadSchema(newPath)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", newPath, err)
+	}
+	return p.compareSchemas(oldSchema, newSchema), nil
+}
+
+func (p *Parser) compareSchemas(oldSchema, newSchema *protoSchema) []CompatIssue {
+	issues := make([]CompatIssue, 0)
+
+	// Типы сопоставляются по полному имени, пути в отчете записываются относительно пакета
+	for _, name := range sortedKeys(oldSchema.messages) {
+		oldMessage := oldSchema.messages[name]
+		path := oldSchema.relativeName(name)
+		newMessage, ok := newSchema.messages[name]
+		if !ok {
+			issues = append(issues, CompatIssue{
+				Kind:     CompatMessageRemoved,
+				Severity: CompatWarning,
+				Path:     path,
+				Message:  fmt.Sprintf("message %s was removed", path),
+			})
+			continue
+		}
+		issues = append(issues, p.compareMessages(path, oldMessage, oldSchema, newMessage, newSchema)...)
+	}
+
+	for _, name := range sortedKeys(oldSchema.enums) {
+		newEnum, ok := newSchema.enums[name]
+		if !ok {
+			continue
+		}
+		issues = append(issues, compareEnums(oldSchema.relativeName(name), oldSchema.enums[name], newEnum)...)
+	}
+	return issues
+}
+
+func (p *Parser) compareMessages(path string, oldMessage *schemaMessageInfo, oldSchema *protoSchema, newMessage *schemaMessageInfo, newSchema *protoSchema) []CompatIssue {
+	issues := make([]CompatIssue, 0)
+
+	newFields := make(map[int]*schemaFieldInfo)
+	for _, field := range newMessage.fields {
+		newFields[field.fieldNum] = field
+	}
+	oldFields := make(map[int]*schemaFieldInfo)
+
+	for _, oldField := range oldMessage.fields {
+		oldFields[oldField.fieldNum] = oldField
+		fieldPath := fmt.Sprintf("%s.%s", path, oldField.fieldName)
+
+		newField, ok := newFields[oldField.fieldNum]
+		if !ok {
+			if oldField.isRequired {
+				issues = append(issues, CompatIssue{
+					Kind:     CompatRequiredRemoved,
+					Severity: CompatError,
+					Path:     fieldPath,
+					Message:  fmt.Sprintf("required field %d was removed, old readers will reject new messages", oldField.fieldNum),
+				})
+			} else {
+				issues = append(issues, CompatIssue{
+					Kind:     CompatFieldRemoved,
+					Severity: CompatWarning,
+					Path:     fieldPath,
+					Message:  fmt.Sprintf("field %d was removed, reserve its number to prevent reuse", oldField.fieldNum),
+				})
+			}
+			continue
+		}
+
+		oldType := p.compatFieldType(oldField, oldMessage, oldSchema)
+		newType := p.compatFieldType(newField, newMessage, newSchema)
+		oldWire := wireTypeOfProtoTypeName(oldType)
+		newWire := wireTypeOfProtoTypeName(newType)
+
+		switch {
+		case oldField.fieldName != newField.fieldName && oldWire != newWire:
+			issues = append(issues, CompatIssue{
+				Kind:     CompatFieldNumberReused,
+				Severity: CompatError,
+				Path:     fieldPath,
+				Message: fmt.Sprintf("field number %d is reused: %s %s -> %s %s",
+					oldField.fieldNum, oldField.fieldType, oldField.fieldName, newField.fieldType, newField.fieldName),
+			})
+			continue
+		case oldWire != newWire:
+			issues = append(issues, CompatIssue{
+				Kind:     CompatWireTypeChanged,
+				Severity: CompatError,
+				Path:     fieldPath,
+				Message:  fmt.Sprintf("type changed from %s (%s) to %s (%s)", oldField.fieldType, oldWire, newField.fieldType, newWire),
+			})
+		case oldType != newType && !compatibleSameWireTypes(oldType, newType):
+			severity := CompatWarning
+			// zigzag кодирование sint32/sint64 несовместимо с обычными varint
+			if strings.HasPrefix(oldType, "sint") != strings.HasPrefix(newType, "sint") {
+				severity = CompatError
+			}
+			issues = append(issues, CompatIssue{
+				Kind:     CompatTypeChanged,
+				Severity: severity,
+				Path:     fieldPath,
+				Message:  fmt.Sprintf("type changed from %s to %s with the same wire type, values will be reinterpreted", oldField.fieldType, newField.fieldType),
+			})
+		}
+
+		if oldField.fieldName != newField.fieldName {
+			issues = append(issues, CompatIssue{
+				Kind:     CompatFieldRenamed,
+				Severity: CompatWarning,
+				Path:     fieldPath,
+				Message:  fmt.Sprintf("field %d renamed to %s, JSON and text format are affected", oldField.fieldNum, newField.fieldName),
+			})
+		}
+
+		if oldField.isRepeated != newField.isRepeated {
+			// optional и repeated совместимы на проводе: при чтении одиночным полем остается
+			// последнее значение. Упакованные числа одиночное поле прочитать не может
+			severity := CompatWarning
+			message := fmt.Sprintf("label changed from %s to %s, singular readers keep only the last value", compatLabel(oldField), compatLabel(newField))
+			if oldField.isRequired || newField.isRequired {
+				severity = CompatError
+				message = fmt.Sprintf("label changed from %s to %s", compatLabel(oldField), compatLabel(newField))
+			} else if compatPacked(oldField, oldWire, oldSchema) || compatPacked(newField, newWire, newSchema) {
+				severity = CompatError
+				message = fmt.Sprintf("label changed from %s to %s, packed values cannot be read as a singular field", compatLabel(oldField), compatLabel(newField))
+			}
+			issues = append(issues, CompatIssue{
+				Kind:     CompatLabelChanged,
+				Severity: severity,
+				Path:     fieldPath,
+				Message:  message,
+			})
+		} else if oldField.isRequired && !newField.isRequired {
+			issues = append(issues, CompatIssue{
+				Kind:     CompatLabelChanged,
+				Severity: CompatWarning,
+				Path:     fieldPath,
+				Message:  "field is no longer required, old readers reject messages without it",
+			})
+		} else if !oldField.isRequired && newField.isRequired {
+			issues = append(issues, CompatIssue{
+				Kind:     CompatRequiredAdded,
+				Severity: CompatError,
+				Path:     fieldPath,
+				Message:  "field became required, existing messages without it are invalid",
+			})
+		}
+	}
+
+	for _, newField := range newMessage.fields {
+		if _, existed := oldFields[newField.fieldNum]; existed || !newField.isRequired {
+			continue
+		}
+		issues = append(issues, CompatIssue{
+			Kind:     CompatRequiredAdded,
+			Severity: CompatError,
+			Path:     fmt.Sprintf("%s.%s", path, newField.fieldName),
+			Message:  fmt.Sprintf("new required field %d, existing messages without it are invalid", newField.fieldNum),
+		})
+	}
+	return issues
+}
+
+func compareEnums(path string, oldEnum, newEnum *schemaEnumInfo) []CompatIssue {
+	issues := make([]CompatIssue, 0)
+
+	newValues := make(map[int]string)
+	for _, value := range newEnum.values {
+		newValues[value.number] = value.name
+	}
+
+	for _, value := range oldEnum.values {
+		newName, ok := newValues[value.number]
+		if !ok {
+			issues = append(issues, CompatIssue{
+				Kind:     CompatEnumValueRemoved,
+				Severity: CompatError,
+				Path:     fmt.Sprintf("%s.%s", path, value.name),
+				Message:  fmt.Sprintf("enum value %s = %d was removed", value.name, value.number),
+			})
+		} else if newName != value.name {
+			issues = append(issues, CompatIssue{
+				Kind:     CompatFieldRenamed,
+				Severity: CompatWarning,
+				Path:     fmt.Sprintf("%s.%s", path, value.name),
+				Message:  fmt.Sprintf("enum value %d renamed to %s", value.number, newName),
+			})
+		}
+	}
+	return issues
+}
+
+// compatFieldType приводит тип поля к виду для сравнения: перечисления считаются enum,
+// proto2 группы - group, сообщения записываются полным именем с ведущей точкой (.pkg.Foo),
+// чтобы разные записи одного типа совпадали, а замена сообщения другим была видна
+func (p *Parser) compatFieldType(field *schemaFieldInfo, scope *schemaMessageInfo, schema *protoSchema) string {
+	if field.isGroup {
+		return "group"
+	}
+	if schema.findEnum(field.fieldType, scope.fullName) != nil {
+		return "enum"
+	}
+	if p.isMessageTypeName(field.fieldType) {
+		if message := schema.findMessage(field.fieldType, scope.fullName); message != nil {
+			return "." + message.fullName
+		}
+		return "." + strings.TrimPrefix(field.fieldType, ".")
+	}
+	return field.fieldType
+}
+
+func wireTypeOfProtoTypeName(protoType string) string {
+	switch {
+	case strings.HasPrefix(protoType, "."):
+		return wireTypeLen
+	case protoType == "group":
+		return wireTypeGroup
+	case protoType == "enum":
+		return wireTypeVarint
+	default:
+		return wireTypeOfProtoType(protoType)
+	}
+}
+
+// compatPacked сообщает, что repeated поле кодируется упакованно: числовые поля proto3
+// по умолчанию и поля с опцией packed = true
+func compatPacked(field *schemaFieldInfo, wire string, schema *protoSchema) bool {
+	if !field.isRepeated || wire == wireTypeLen || wire == wireTypeGroup {
+		return false
+	}
+	if packed, ok := field.options["packed"]; ok {
+		return packed == "true"
+	}
+	return schema.syntax == "proto3"
+}
+
+// compatibleSameWireTypes перечисляет замены типов, которые protobuf явно допускает
+func compatibleSameWireTypes(oldType, newType string) bool {
+	groups := [][]string{
+		{"int32", "uint32", "int64", "uint64", "bool", "enum"},
+		{"string", "bytes"},
+		{"fixed32", "sfixed32"},
+		{"fixed64", "sfixed64"},
+	}
+	for _, group := range groups {
+		hasOld, hasNew := false, false
+		for _, t := range group {
+			hasOld = hasOld || t == oldType
+			hasNew = hasNew || t == newType
+		}
+		if hasOld && hasNew {
+			return true
+		}
+	}
+	return false
+}
+
+func compatLabel(field *schemaFieldInfo) string {
+	switch {
+	case field.isRepeated:
+		return "repeated"
+	case field.isRequired:
+		return "required"
+	default:
+		return "optional"
+	}
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// CompatHasErrors сообщает, есть ли среди изменений нарушения совместимости
+func CompatHasErrors(issues []CompatIssue) bool {
+	for _, issue := range issues {
+		if issue.Severity == CompatError {
+			return true
+		}
+	}
+	return false
+}
+
+// CompatToText форматирует результат проверки по строке на изменение и на файл корпуса,
+// не прошедший проверку новой схемой
+func CompatToText(issues []CompatIssue, corpus []CorpusResult) string {
+	var builder strings.Builder
+	if len(issues) == 0 {
+		builder.WriteString("Schemas are wire compatible\n")
+	}
+	for _, issue := range issues {
+		builder.WriteString(fmt.Sprintf("%-7s %s: %s\n", issue.Severity, issue.Path, issue.Message))
+	}
+
+	failed := 0
+	for _, result := range corpus {
+		switch {
+		case result.Err != nil:
+			failed++
+			builder.WriteString(fmt.Sprintf("%-7s %s: %v\n", CompatError, result.File, result.Err))
+		case result.Report.HasIssues():
+			failed++
+			builder.WriteString(fmt.Sprintf("%-7s %s: %s\n", CompatError, result.File, result.Report.Summary()))
+		}
+	}
+	if len(corpus) > 0 {
+		builder.WriteString(fmt.Sprintf("%d of %d corpus file(s) failed\n", failed, len(corpus)))
+	}
+	return builder.String()
+}
+
+// CompatToJSONString сериализует результат проверки в JSON. Файлы корпуса выводятся
+// в поле corpus, только если корпус проверялся
+func CompatToJSONString(issues []CompatIssue, corpus []CorpusResult) (string, error) {
+	result := map[string]interface{}{
+		"compatible": !CompatHasErrors(issues) && !CorpusHasFailures(corpus),
+		"issues":     issues,
+	}
+	if corpus != nil {
+		result["corpus"] = corpus
+	}
+	jsonBytes, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("error marshaling to JSON: %w", err)
+	}
+	return string(jsonBytes), nil
+}
+
+// CorpusHasFailures сообщает, есть ли в корпусе файлы, не прошедшие проверку новой схемой
+func CorpusHasFailures(corpus []CorpusResult) bool {
+	for _, result := range corpus {
+		if result.Err != nil || result.Report.HasIssues() {
+			return true
+		}
+	}
+	return false
+}
+
+// CorpusResult - результат применения схемы к одному файлу корпуса
+type CorpusResult struct {
+	File   string
+	Report *SchemaReport
+	Err    error
+}
+
+// MarshalJSON выводит ошибку файла текстом: значение error в JSON не сериализуется
+func (r CorpusResult) MarshalJSON() ([]byte, error) {
+	result := struct {
+		File   string        `json:"file"`
+		Error  string        `json:"error,omitempty"`
+		Report *SchemaReport `json:"report,omitempty"`
+	}{File: r.File, Report: r.Report}
+	if r.Err != nil {
+		result.Error = r.Err.Error()
+	}
+	return json.Marshal(result)
+}
+
+// ValidateCorpus применяет схему ко всем файлам каталога и возвращает отчеты по каждому файлу
+func (p *Parser) ValidateCorpus(dir string, schemaPath string, messageName string) ([]CorpusResult, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	results := make([]CorpusResult, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		result := CorpusResult{File: filepath.Join(dir, entry.Name())}
+		data, err := os.ReadFile(result.File)
+		if err == nil {
+			result.Report, err = p.ValidateData(data, schemaPath, messageName, nil)
+		}
+		result.Err = err
+		results = append(results, result)
+	}
+	return results, nil
+}
@@ -0,0 +1,264 @@
+package protobuf
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const compatOldSchema = `syntax = "proto2";
+
+enum Status {
+  UNKNOWN = 0;
+  ACTIVE = 1;
+  BLOCKED = 2;
+}
+
+message User {
+  required string id = 1;
+  optional int32 age = 2;
+  optional string email = 3;
+  repeated string tags = 4;
+  optional Status status = 5;
+  optional int64 score = 6;
+  optional string nick = 7;
+  optional int32 level = 8;
+  required int32 version = 9;
+}`
+
+const compatNewSchema = `syntax = "proto2";
+
+enum Status {
+  UNKNOWN = 0;
+  ACTIVE = 1;
+}
+
+message User {
+  optional int32 age = 2;
+  optional Address email = 3;
+  optional string tags = 4;
+  optional Status status = 5;
+  optional sint64 score = 6;
+  optional bytes nickname = 7;
+  optional double rating = 8;
+  optional int32 version = 9;
+  required string region = 10;
+}
+
+message Address {
+  optional string city = 1;
+}`
+
+func findCompatIssue(issues []CompatIssue, kind CompatIssueKind, path string) *CompatIssue {
+	for i := range issues {
+		if issues[i].Kind == kind && issues[i].Path == path {
+			return &issues[i]
+		}
+	}
+	return nil
+}
+
+func TestCheckSchemaCompatibility(t *testing.T) {
+	oldFile := writeTestSchema(t, compatOldSchema)
+	newFile := writeTestSchema(t, compatNewSchema)
+
+	parser := &Parser{}
+	issues, err := parser.CheckSchemaCompatibility(oldFile, newFile)
+	if err != nil {
+		t.Fatalf("CheckSchemaCompatibility failed: %v", err)
+	}
+
+	expected := []struct {
+		kind     CompatIssueKind
+		path     string
+		severity CompatSeverity
+	}{
+		{CompatRequiredRemoved, "User.id", CompatError},
+		{CompatLabelChanged, "User.tags", CompatWarning},
+		{CompatTypeChanged, "User.score", CompatError},
+		{CompatFieldRenamed, "User.nick", CompatWarning},
+		{CompatFieldNumberReused, "User.level", CompatError},
+		{CompatLabelChanged, "User.version", CompatWarning},
+		{CompatRequiredAdded, "User.region", CompatError},
+		{CompatEnumValueRemoved, "Status.BLOCKED", CompatError},
+	}
+	for _, e := range expected {
+		issue := findCompatIssue(issues, e.kind, e.path)
+		if issue == nil {
+			t.Errorf("Expected %s issue for %s, got:\n%s", e.kind, e.path, CompatToText(issues, nil))
+			continue
+		}
+		if issue.Severity != e.severity {
+			t.Errorf("Expected %s severity for %s %s, got %s", e.severity, e.kind, e.path, issue.Severity)
+		}
+	}
+
+	// string -> сообщение: тот же wire type, поэтому не ошибка проводного формата
+	if findCompatIssue(issues, CompatWireTypeChanged, "User.email") != nil {
+		t.Errorf("Did not expect wire type change for string -> message")
+	}
+	if findCompatIssue(issues, CompatTypeChanged, "User.email") == nil {
+		t.Errorf("Expected type change warning for string -> message")
+	}
+	if findCompatIssue(issues, CompatTypeChanged, "User.nick") != nil {
+		t.Errorf("Did not expect type change issue for string -> bytes")
+	}
+	if !CompatHasErrors(issues) {
+		t.Error("Expected incompatible result")
+	}
+}
+
+func TestCheckSchemaCompatibility_Compatible(t *testing.T) {
+	oldFile := writeTestSchema(t, compatOldSchema)
+
+	parser := &Parser{}
+	issues, err := parser.CheckSchemaCompatibility(oldFile, oldFile)
+	if err != nil {
+		t.Fatalf("CheckSchemaCompatibility failed: %v", err)
+	}
+	if len(issues) != 0 {
+		t.Errorf("Expected no issues for identical schemas, got:\n%s", CompatToText(issues, nil))
+	}
+
+	jsonStr, err := CompatToJSONString(issues, nil)
+	if err != nil {
+		t.Fatalf("CompatToJSONString failed: %v", err)
+	}
+	if !strings.Contains(jsonStr, `"compatible": true`) {
+		t.Errorf("Expected compatible=true in JSON, got %s", jsonStr)
+	}
+}
+
+func TestCheckSchemaCompatibility_GroupToMessage(t *testing.T) {
+	oldFile := writeTestSchema(t, `syntax = "proto2";
+
+message Search {
+  repeated group Result = 1 {
+    optional string url = 1;
+  }
+}`)
+	newFile := writeTestSchema(t, `syntax = "proto2";
+
+message Search {
+  repeated Result result = 1;
+  message Result {
+    optional string url = 1;
+  }
+}`)
+
+	parser := &Parser{}
+	issues, err := parser.CheckSchemaCompatibility(oldFile, newFile)
+	if err != nil {
+		t.Fatalf("CheckSchemaCompatibility failed: %v", err)
+	}
+	if findCompatIssue(issues, CompatWireTypeChanged, "Search.result") == nil {
+		t.Errorf("Expected wire type change for group -> message, got:\n%s", CompatToText(issues, nil))
+	}
+}
+
+func TestCheckSchemaCompatibility_FixedWidthsMessagesAndPacked(t *testing.T) {
+	oldFile := writeTestSchema(t, `syntax = "proto3";
+
+package shop;
+
+message Order {
+  float ratio = 1;
+  Foo item = 2;
+  repeated int32 codes = 3;
+  repeated string tags = 4;
+  .shop.Foo same = 5;
+}
+
+message Foo {
+  string id = 1;
+}`)
+	newFile := writeTestSchema(t, `syntax = "proto3";
+
+package shop;
+
+message Order {
+  double ratio = 1;
+  Bar item = 2;
+  int32 codes = 3;
+  string tags = 4;
+  Foo same = 5;
+}
+
+message Foo {
+  string id = 1;
+}
+
+message Bar {
+  string id = 1;
+}`)
+
+	parser := &Parser{}
+	issues, err := parser.CheckSchemaCompatibility(oldFile, newFile)
+	if err != nil {
+		t.Fatalf("CheckSchemaCompatibility failed: %v", err)
+	}
+
+	expected := []struct {
+		kind     CompatIssueKind
+		path     string
+		severity CompatSeverity
+	}{
+		{CompatWireTypeChanged, "Order.ratio", CompatError},
+		{CompatTypeChanged, "Order.item", CompatWarning},
+		{CompatLabelChanged, "Order.codes", CompatError},
+		{CompatLabelChanged, "Order.tags", CompatWarning},
+	}
+	for _, e := range expected {
+		issue := findCompatIssue(issues, e.kind, e.path)
+		if issue == nil {
+			t.Errorf("Expected %s issue for %s, got:\n%s", e.kind, e.path, CompatToText(issues, nil))
+			continue
+		}
+		if issue.Severity != e.severity {
+			t.Errorf("Expected %s severity for %s %s, got %s", e.severity, e.kind, e.path, issue.Severity)
+		}
+	}
+	for _, issue := range issues {
+		if issue.Path == "Order.same" {
+			t.Errorf("Did not expect issues for the same message written differently, got %+v", issue)
+		}
+	}
+}
+
+func TestCompatOutput_IncludesCorpus(t *testing.T) {
+	report := newSchemaReport()
+	report.Issues = append(report.Issues, SchemaIssue{Kind: SchemaIssueUnknownField, Path: "field_9", FieldNum: 9})
+	corpus := []CorpusResult{
+		{File: "ok.bin", Report: newSchemaReport()},
+		{File: "unknown.bin", Report: report},
+		{File: "broken.bin", Err: errors.New("failed to decode")},
+	}
+
+	jsonStr, err := CompatToJSONString(nil, corpus)
+	if err != nil {
+		t.Fatalf("CompatToJSONString failed: %v", err)
+	}
+	var result struct {
+		Compatible bool `json:"compatible"`
+		Corpus     []struct {
+			File   string        `json:"file"`
+			Error  string        `json:"error"`
+			Report *SchemaReport `json:"report"`
+		} `json:"corpus"`
+	}
+	if err := json.Unmarshal([]byte(jsonStr), &result); err != nil {
+		t.Fatalf("Failed to parse JSON: %v\n%s", err, jsonStr)
+	}
+	if result.Compatible || len(result.Corpus) != 3 {
+		t.Fatalf("Expected incompatible result with 3 corpus files, got %s", jsonStr)
+	}
+	if result.Corpus[1].Report == nil || len(result.Corpus[1].Report.Issues) != 1 || result.Corpus[2].Error != "failed to decode" {
+		t.Errorf("Expected corpus reports and errors in JSON, got %s", jsonStr)
+	}
+
+	text := CompatToText(nil, corpus)
+	if !strings.Contains(text, "broken.bin: failed to decode") || !strings.Contains(text, "2 of 3 corpus file(s) failed") {
+		t.Errorf("Expected corpus failures in text output, got:\n%s", text)
+	}
+}
@@ -0,0 +1,98 @@
+package protobuf
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// confluentMagic - первый байт Confluent wire format. Сообщение protobuf не может начинаться
+// с нулевого байта (номер поля 0 недопустим), поэтому заголовок распознается однозначно
+const confluentMagic = 0
+
+// ConfluentHeader - заголовок Confluent wire format: magic byte, 4-байтовый ID схемы в реестре
+// (big-endian) и индексы сообщения в файле схемы. Индексы - путь от сообщения верхнего уровня
+// через вложенные, [0] означает первое сообщение файла
+type ConfluentHeader struct {
+	SchemaID       int
+	MessageIndexes []int
+}
+
+// SplitConfluentHeader отделяет заголовок Confluent от тела сообщения. Если данные не начинаются
+// с magic byte, заголовок равен nil, а тело - исходные данные
+func SplitConfluentHeader(data []byte) (*ConfluentHeader, []byte, error) {
+	if len(data) == 0 || data[0] != confluentMagic {
+		return nil, data, nil
+	}
+	if len(data) < 5 {
+		return nil, nil, fmt.Errorf("truncated Confluent header")
+	}
+
+	header := &ConfluentHeader{SchemaID: int(binary.BigEndian.Uint32(data[1:5]))}
+	rest := data[5:]
+	count, n := binary.Varint(rest)
+	if n <= 0 || count < 0 || count > int64(len(rest)) {
+		return nil, nil, fmt.Errorf("invalid message indexes in Confluent header")
+	}
+	rest = rest[n:]
+	// Частый случай [0] кодируется одним нулем вместо длины и индекса
+	if count == 0 {
+		header.MessageIndexes = []int{0}
+	}
+	for i := int64(0); i < count; i++ {
+		index, n := binary.Varint(rest)
+		if n <= 0 || index < 0 {
+			return nil, nil, fmt.Errorf("invalid message indexes in Confluent header")
+		}
+		header.MessageIndexes = append(header.MessageIndexes, int(index))
+		rest = rest[n:]
+	}
+	return header, rest, nil
+}
+
+// Bytes кодирует заголовок обратно в wire format
+func (h *ConfluentHeader) Bytes() []byte {
+	data := []byte{confluentMagic, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(data[1:], uint32(h.SchemaID))
+	if len(h.MessageIndexes) == 0 || (len(h.MessageIndexes) == 1 && h.MessageIndexes[0] == 0) {
+		return append(data, 0)
+	}
+	data = binary.AppendVarint(data, int64(len(h.MessageIndexes)))
+	for _, index := range h.MessageIndexes {
+		data = binary.AppendVarint(data, int64(index))
+	}
+	return data
+}
+
+func (h *ConfluentHeader) String() string {
+	return fmt.Sprintf("schema ID %d, message indexes %v", h.SchemaID, h.MessageIndexes)
+}
+
+// MessageAtIndexes возвращает имя сообщения схемы по индексам из заголовка Confluent; имя
+// вложенного сообщения записывается относительно пакета: Outer.Inner
+func (p *Parser) MessageAtIndexes(schemaPath string, indexes []int) (string, error) {
+	schema, err := p.loadSchema(schemaPath)
+	if err != nil {
+		return "", err
+	}
+	if len(indexes) == 0 {
+		indexes = []int{0}
+	}
+
+	names := schema.topLevelMessages
+	var message *schemaMessageInfo
+	for _, index := range indexes {
+		if index >= len(names) {
+			return "", fmt.Errorf("message index %v is out of range in %s", indexes, schemaPath)
+		}
+		if message == nil {
+			message = schema.lookupMessage(names[index])
+		} else {
+			message = message.messages[names[index]]
+		}
+		if message == nil {
+			return "", fmt.Errorf("message index %v is out of range in %s", indexes, schemaPath)
+		}
+		names = message.nestedOrder
+	}
+	return schema.relativeName(message.fullName), nil
+}
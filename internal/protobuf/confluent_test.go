@@ -0,0 +1,82 @@
+package protobuf
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSplitConfluentHeader(t *testing.T) {
+	payload := []byte{0x08, 0x01}
+
+	// Схема 42, сообщение [0] в короткой форме
+	header, body, err := SplitConfluentHeader(append([]byte{0, 0, 0, 0, 42, 0}, payload...))
+	if err != nil || header == nil || header.SchemaID != 42 || !reflect.DeepEqual(header.MessageIndexes, []int{0}) || !bytes.Equal(body, payload) {
+		t.Fatalf("Unexpected result: %+v %v %v", header, body, err)
+	}
+	if encoded := header.Bytes(); !bytes.Equal(encoded, []byte{0, 0, 0, 0, 42, 0}) {
+		t.Errorf("Expected short form for [0], got %v", encoded)
+	}
+
+	// Схема 258, сообщение [1, 2]: длина и индексы в zigzag varint
+	data := append([]byte{0, 0, 0, 1, 2, 4, 2, 4}, payload...)
+	header, body, err = SplitConfluentHeader(data)
+	if err != nil || header.SchemaID != 258 || !reflect.DeepEqual(header.MessageIndexes, []int{1, 2}) || !bytes.Equal(body, payload) {
+		t.Fatalf("Unexpected result: %+v %v %v", header, body, err)
+	}
+	if encoded := append(header.Bytes(), payload...); !bytes.Equal(encoded, data) {
+		t.Errorf("Expected header to round-trip, got %v", encoded)
+	}
+
+	if header, body, err := SplitConfluentHeader(payload); header != nil || err != nil || !bytes.Equal(body, payload) {
+		t.Errorf("Expected plain protobuf to pass through, got %+v %v %v", header, body, err)
+	}
+	for _, invalid := range [][]byte{{0, 0, 1}, {0, 0, 0, 0, 1, 0x7f}, {0, 0, 0, 0, 1, 2, 1}} {
+		if _, _, err := SplitConfluentHeader(invalid); err == nil {
+			t.Errorf("Expected an error for %v", invalid)
+		}
+	}
+}
+
+func TestMessageAtIndexes(t *testing.T) {
+	schemaPath := filepath.Join(t.TempDir(), "events.proto")
+	schema := `syntax = "proto3";
+message Envelope {
+  message Meta {
+    string id = 1;
+  }
+  message Body {
+    message Line {
+      int32 n = 1;
+    }
+  }
+}
+message Event {
+  string name = 1;
+}
+`
+	if err := os.WriteFile(schemaPath, []byte(schema), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	parser := &Parser{}
+	cases := []struct {
+		indexes []int
+		want    string
+	}{
+		{nil, "Envelope"},
+		{[]int{1}, "Event"},
+		{[]int{0, 1}, "Envelope.Body"},
+		{[]int{0, 1, 0}, "Envelope.Body.Line"},
+	}
+	for _, c := range cases {
+		if got, err := parser.MessageAtIndexes(schemaPath, c.indexes); err != nil || got != c.want {
+			t.Errorf("MessageAtIndexes(%v) = %q, %v; want %q", c.indexes, got, err, c.want)
+		}
+	}
+	if _, err := parser.MessageAtIndexes(schemaPath, []int{2}); err == nil {
+		t.Error("Expected an error for an index out of range")
+	}
+}
@@ -0,0 +1,355 @@
+package protobuf
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// buf.validate опции поля, из которых читаются ограничения:
+// [(buf.validate.field).int32.gte = 0] или [(buf.validate.field).string = {min_len: 1}]
+const bufValidateFieldOption = "(buf.validate.field)"
+
+// FieldConstraints - бизнес-правила для одного поля. Числовые границы применяются к
+// скалярным значениям, длины - к строкам (в символах) и bytes (в байтах),
+// min_items/max_items - к количеству элементов repeated поля
+type FieldConstraints struct {
+	Required bool     `json:"required,omitempty"`
+	GT       *float64 `json:"gt,omitempty"`
+	GTE      *float64 `json:"gte,omitempty"`
+	LT       *float64 `json:"lt,omitempty"`
+	LTE      *float64 `json:"lte,omitempty"`
+	Pattern  string   `json:"pattern,omitempty"`
+	MinLen   *int     `json:"min_len,omitempty"`
+	MaxLen   *int     `json:"max_len,omitempty"`
+	MinItems *int     `json:"min_items,omitempty"`
+	MaxItems *int     `json:"max_items,omitempty"`
+
+	pattern *regexp.Regexp
+}
+
+// ConstraintRules - ограничения по сообщениям и полям: имя сообщения -> имя поля -> правила.
+// В файле правил используется тот же формат в JSON:
+//
+//	{"Order": {"id": {"pattern": "^A-"}, "items": {"min_items": 1}}}
+type ConstraintRules map[string]map[string]*FieldConstraints
+
+// LoadConstraintRules читает JSON файл правил, хранящийся рядом со схемой
+func LoadConstraintRules(path string) (ConstraintRules, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rules file: %w", err)
+	}
+
+	var rules ConstraintRules
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse rules file: %w", err)
+	}
+	if err := rules.compile(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// Merge добавляет правила other поверх текущих; правила одного поля объединяются,
+// при совпадении значения из other имеют приоритет
+func (r ConstraintRules) Merge(other ConstraintRules) ConstraintRules {
+	result := make(ConstraintRules)
+	for _, rules := range []ConstraintRules{r, other} {
+		for messageName, fields := range rules {
+			if result[messageName] == nil {
+				result[messageName] = make(map[string]*FieldConstraints)
+			}
+			for fieldName, constraints := range fields {
+				if constraints == nil {
+					continue
+				}
+				if existing := result[messageName][fieldName]; existing != nil {
+					merged := *existing
+					merged.merge(constraints)
+					result[messageName][fieldName] = &merged
+				} else {
+					copied := *constraints
+					result[messageName][fieldName] = &copied
+				}
+			}
+		}
+	}
+	return result
+}
+
+func (c *FieldConstraints) merge(other *FieldConstraints) {
+	c.Required = c.Required || other.Required
+	if other.GT != nil {
+		c.GT = other.GT
+	}
+	if other.GTE != nil {
+		c.GTE = other.GTE
+	}
+	if other.LT != nil {
+		c.LT = other.LT
+	}
+	if other.LTE != nil {
+		c.LTE = other.LTE
+	}
+	if other.Pattern != "" {
+		c.Pattern = other.Pattern
+		c.pattern = other.pattern
+	}
+	if other.MinLen != nil {
+		c.MinLen = other.MinLen
+	}
+	if other.MaxLen != nil {
+		c.MaxLen = other.MaxLen
+	}
+	if other.MinItems != nil {
+		c.MinItems = other.MinItems
+	}
+	if other.MaxItems != nil {
+		c.MaxItems = other.MaxItems
+	}
+}
+
+// compile проверяет регулярные выражения всех правил
+func (r ConstraintRules) compile() error {
+	for messageName, fields := range r {
+		for fieldName, constraints := range fields {
+			if constraints == nil || constraints.Pattern == "" {
+				continue
+			}
+			pattern, err := regexp.Compile(constraints.Pattern)
+			if err != nil {
+				return fmt.Errorf("invalid pattern for %s.%s: %w", messageName, fieldName, err)
+			}
+			constraints.pattern = pattern
+		}
+	}
+	return nil
+}
+
+// constraintsFromSchema собирает ограничения из buf.validate опций полей схемы
+func constraintsFromSchema(schema *protoSchema) (ConstraintRules, error) {
+	rules := make(ConstraintRules)
+	for messageName, message := range schema.messages {
+		for _, field := range message.fields {
+			constraints := &FieldConstraints{}
+			found := false
+			for name, value := range field.options {
+				if !strings.HasPrefix(name, bufValidateFieldOption) {
+					continue
+				}
+				if err := constraints.applyOption(strings.TrimPrefix(name, bufValidateFieldOption), value); err != nil {
+					return nil, fmt.Errorf("field %s.%s: %w", messageName, field.fieldName, err)
+				}
+				found = true
+			}
+			if !found {
+				continue
+			}
+			if rules[messageName] == nil {
+				rules[messageName] = make(map[string]*FieldConstraints)
+			}
+			rules[messageName][field.fieldName] = constraints
+		}
+	}
+	if err := rules.compile(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// applyOption разбирает одну buf.validate опцию. path - часть имени после
+// (buf.validate.field): ".required", ".int32.gte" или ".string" со значением {min_len: 1}
+func (c *FieldConstraints) applyOption(path string, value string) error {
+	value = strings.TrimSpace(value)
+	segments := strings.Split(strings.TrimPrefix(path, "."), ".")
+	rule := segments[len(segments)-1]
+
+	if strings.HasPrefix(value, "{") && strings.HasSuffix(value, "}") {
+		for _, entry := range splitOutsideQuotes(value[1:len(value)-1], ',') {
+			parts := strings.SplitN(entry, ":", 2)
+			if len(parts) != 2 {
+				return fmt.Errorf("invalid constraint %q", strings.TrimSpace(entry))
+			}
+			if err := c.setRule(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return c.setRule(rule, value)
+}
+
+func (c *FieldConstraints) setRule(rule string, value string) error {
+	parseFloat := func() (*float64, error) {
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %q for %s", value, rule)
+		}
+		return &number, nil
+	}
+	parseInt := func() (*int, error) {
+		number, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %q for %s", value, rule)
+		}
+		return &number, nil
+	}
+
+	var err error
+	switch rule {
+	case "required":
+		c.Required = value == "true"
+	case "gt":
+		c.GT, err = parseFloat()
+	case "gte":
+		c.GTE, err = parseFloat()
+	case "lt":
+		c.LT, err = parseFloat()
+	case "lte":
+		c.LTE, err = parseFloat()
+	case "pattern":
+		c.Pattern = unquoteOptionValue(value)
+	case "min_len", "min_bytes":
+		c.MinLen, err = parseInt()
+	case "max_len", "max_bytes":
+		c.MaxLen, err = parseInt()
+	case "min_items":
+		c.MinItems, err = parseInt()
+	case "max_items":
+		c.MaxItems, err = parseInt()
+	default:
+		return fmt.Errorf("unsupported constraint %q", rule)
+	}
+	return err
+}
+
+// evaluateConstraints проверяет ограничения для сообщения и рекурсивно для вложенных сообщений.
+// Нарушения добавляются в отчет и привязываются к узлам дерева
+func (p *Parser) evaluateConstraints(tree *TreeNode, message *schemaMessageInfo, schema *protoSchema, rules ConstraintRules, path string, report *SchemaReport) {
+	// Правила ленивого узла проверяются вместе с отложенной схемой при его разборе
+	if tree.IsLazy() {
+		if tree.schema != nil {
+			tree.schema.rules = rules
+		}
+		return
+	}
+
+	fieldRules := rules[message.messageName]
+	for _, field := range message.fields {
+		constraints := fieldRules[field.fieldName]
+		if constraints == nil {
+			continue
+		}
+		nodes := make([]*TreeNode, 0)
+		for _, child := range tree.Children {
+			if child.FieldNum == field.fieldNum {
+				nodes = append(nodes, child)
+			}
+		}
+		report.checkConstraints(tree, field, nodes, constraints, path)
+	}
+
+	fieldMap := make(map[int]*schemaFieldInfo)
+	for _, field := range message.fields {
+		fieldMap[field.fieldNum] = field
+	}
+	repeatedIndexes := make(map[int]int)
+	for _, child := range tree.Children {
+		field, ok := fieldMap[child.FieldNum]
+		if !ok {
+			continue
+		}
+		if nested := schema.findMessage(field.fieldType, message.fullName); nested != nil && child.IsMessage() {
+			childPath := joinDiffPath(path, field.fieldName)
+			if field.isRepeated {
+				childPath = fmt.Sprintf("%s[%d]", childPath, repeatedIndexes[child.FieldNum])
+			}
+			p.evaluateConstraints(child, nested, schema, rules, childPath, report)
+		}
+		repeatedIndexes[child.FieldNum]++
+	}
+}
+
+// checkConstraints проверяет правила одного поля. parent - сообщение, содержащее поле,
+// nodes - все вхождения поля в этом сообщении
+func (r *SchemaReport) checkConstraints(parent *TreeNode, field *schemaFieldInfo, nodes []*TreeNode, constraints *FieldConstraints, messagePath string) {
+	addViolation := func(node *TreeNode, rule string, actual string) {
+		r.Issues = append(r.Issues, SchemaIssue{
+			Kind:        SchemaIssueConstraintViolation,
+			Path:        joinDiffPath(messagePath, field.fieldName),
+			MessagePath: messagePath,
+			FieldNum:    field.fieldNum,
+			FieldName:   field.fieldName,
+			Expected:    rule,
+			Actual:      actual,
+			Node:        node,
+		})
+	}
+
+	if len(nodes) == 0 {
+		if constraints.Required {
+			addViolation(parent, "required", "not set")
+		}
+		// Как и в buf.validate, остальные правила к неустановленному полю не применяются,
+		// кроме минимального количества элементов repeated поля
+		if constraints.MinItems != nil && *constraints.MinItems > 0 {
+			addViolation(parent, fmt.Sprintf("min_items %d", *constraints.MinItems), "0")
+		}
+		return
+	}
+
+	count := len(nodes)
+	if constraints.MinItems != nil && count < *constraints.MinItems {
+		addViolation(nodes[0], fmt.Sprintf("min_items %d", *constraints.MinItems), strconv.Itoa(count))
+	}
+	if constraints.MaxItems != nil && count > *constraints.MaxItems {
+		addViolation(nodes[0], fmt.Sprintf("max_items %d", *constraints.MaxItems), strconv.Itoa(count))
+	}
+
+	for _, node := range nodes {
+		if node.IsMessage() {
+			continue
+		}
+		value := fmt.Sprintf("%v", node.Value)
+
+		if number, err := strconv.ParseFloat(value, 64); err == nil && node.Type != "string" && node.Type != "bytes" {
+			checkBound := func(bound *float64, name string, ok func(float64) bool) {
+				if bound != nil && !ok(*bound) {
+					addViolation(node, fmt.Sprintf("%s %s", name, strconv.FormatFloat(*bound, 'g', -1, 64)), value)
+				}
+			}
+			checkBound(constraints.GT, "gt", func(b float64) bool { return number > b })
+			checkBound(constraints.GTE, "gte", func(b float64) bool { return number >= b })
+			checkBound(constraints.LT, "lt", func(b float64) bool { return number < b })
+			checkBound(constraints.LTE, "lte", func(b float64) bool { return number <= b })
+		}
+
+		var raw []byte
+		switch node.Type {
+		case "string":
+			raw = []byte(value)
+		case "bytes":
+			raw = BytesValue(node.Value)
+		default:
+			continue
+		}
+		length := len(raw)
+		if field.fieldType == "string" {
+			length = utf8.RuneCount(raw)
+		}
+		if constraints.MinLen != nil && length < *constraints.MinLen {
+			addViolation(node, fmt.Sprintf("min_len %d", *constraints.MinLen), strconv.Itoa(length))
+		}
+		if constraints.MaxLen != nil && length > *constraints.MaxLen {
+			addViolation(node, fmt.Sprintf("max_len %d", *constraints.MaxLen), strconv.Itoa(length))
+		}
+		if constraints.pattern != nil && !constraints.pattern.Match(raw) {
+			addViolation(node, fmt.Sprintf("pattern %q", constraints.Pattern), strconv.Quote(string(raw)))
+		}
+	}
+}
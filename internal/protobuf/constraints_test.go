@@ -0,0 +1,118 @@
+package protobuf
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+const constraintsTestSchema = `syntax = "proto2";
+
+import "buf/validate/validate.proto";
+
+message Order {
+  optional string id = 1 [(buf.validate.field).string.pattern = "^A-[0-9]+$"];
+  optional int32 count = 2 [(buf.validate.field).int32 = {gte: 1, lte: 10}];
+  repeated Item items = 3 [(buf.validate.field).repeated.min_items = 1];
+  optional string note = 4;
+}
+
+message Item {
+  optional string sku = 1 [(buf.validate.field).required = true];
+}`
+
+func constraintViolations(report *SchemaReport) []string {
+	violations := make([]string, 0)
+	for _, issue := range report.Issues {
+		if issue.Kind == SchemaIssueConstraintViolation {
+			violations = append(violations, issue.Path+" "+issue.Expected)
+		}
+	}
+	sort.Strings(violations)
+	return violations
+}
+
+func TestApplySchemaWithRules_SchemaAnnotations(t *testing.T) {
+	schemaFile := writeTestSchema(t, constraintsTestSchema)
+	tree := newTestRoot(
+		&TreeNode{Name: "field_1", Type: "string", FieldNum: 1, Value: "B-1"},
+		&TreeNode{Name: "field_2", Type: "int64", FieldNum: 2, Value: "42"},
+		&TreeNode{
+			Name: "field_3", Type: "message_1", FieldNum: 3,
+			Children: []*TreeNode{
+				{Name: "field_2", Type: "int64", FieldNum: 2, Value: "5"},
+			},
+		},
+	)
+
+	parser := &Parser{}
+	_, report, err := parser.ApplySchemaWithRules(tree, schemaFile, "Order", nil)
+	if err != nil {
+		t.Fatalf("ApplySchemaWithRules failed: %v", err)
+	}
+
+	expected := []string{`count lte 10`, `id pattern "^A-[0-9]+$"`, `items[0].sku required`}
+	if got := constraintViolations(report); strings.Join(got, "|") != strings.Join(expected, "|") {
+		t.Errorf("Expected violations %v, got %v", expected, got)
+	}
+
+	countNode := tree.Children[1]
+	if issues := report.IssuesFor(countNode); len(issues) != 1 || issues[0].Actual != "42" {
+		t.Errorf("Expected violation attached to count node, got %+v", issues)
+	}
+	if !strings.Contains(report.Summary(), "3 constraint violation(s)") {
+		t.Errorf("Unexpected summary: %s", report.Summary())
+	}
+}
+
+func TestApplySchemaWithRules_SidecarRules(t *testing.T) {
+	schemaFile := writeTestSchema(t, constraintsTestSchema)
+	rulesFile := filepath.Join(t.TempDir(), "rules.json")
+	rules := `{"Order": {"note": {"min_len": 3, "max_len": 5}, "count": {"gte": 0}}}`
+	if err := os.WriteFile(rulesFile, []byte(rules), 0644); err != nil {
+		t.Fatalf("Failed to write rules: %v", err)
+	}
+
+	loaded, err := LoadConstraintRules(rulesFile)
+	if err != nil {
+		t.Fatalf("LoadConstraintRules failed: %v", err)
+	}
+
+	tree := newTestRoot(
+		&TreeNode{Name: "field_1", Type: "string", FieldNum: 1, Value: "A-7"},
+		&TreeNode{Name: "field_2", Type: "int64", FieldNum: 2, Value: "0"},
+		&TreeNode{Name: "field_4", Type: "string", FieldNum: 4, Value: "привет!"},
+	)
+
+	parser := &Parser{}
+	_, report, err := parser.ApplySchemaWithRules(tree, schemaFile, "Order", loaded)
+	if err != nil {
+		t.Fatalf("ApplySchemaWithRules failed: %v", err)
+	}
+
+	// Правило файла gte 0 заменяет gte 1 из схемы, lte 10 из схемы сохраняется.
+	// Длина строки считается в символах, а не байтах
+	expected := []string{`items min_items 1`, `note max_len 5`}
+	if got := constraintViolations(report); strings.Join(got, "|") != strings.Join(expected, "|") {
+		t.Errorf("Expected violations %v, got %v", expected, got)
+	}
+}
+
+func TestLoadConstraintRules_InvalidPattern(t *testing.T) {
+	rulesFile := filepath.Join(t.TempDir(), "rules.json")
+	if err := os.WriteFile(rulesFile, []byte(`{"Order": {"id": {"pattern": "("}}}`), 0644); err != nil {
+		t.Fatalf("Failed to write rules: %v", err)
+	}
+	if _, err := LoadConstraintRules(rulesFile); err == nil || !strings.Contains(err.Error(), "Order.id") {
+		t.Errorf("Expected invalid pattern error for Order.id, got %v", err)
+	}
+}
+
+func TestParseFieldOptions_Braces(t *testing.T) {
+	options := parseFieldOptions(`(buf.validate.field).int32 = {gte: 1, lte: 10}, packed = true`)
+	if options["(buf.validate.field).int32"] != "{gte: 1, lte: 10}" || options["packed"] != "true" {
+		t.Errorf("Unexpected options: %v", options)
+	}
+}
@@ -0,0 +1,190 @@
+package protobuf
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// ProgressFunc получает долю выполненной работы от 0 до 1
+type ProgressFunc func(fraction float64)
+
+// progressStep - минимальный прирост доли, о котором сообщается ProgressFunc
+const progressStep = 0.01
+
+// ParseRawContext декодирует данные через protoc --decode_raw. Вывод protoc читается и
+// разбирается построчно, без накопления всего текста в памяти. При отмене ctx protoc
+// завершается, а функция возвращает ctx.Err()
+func (p *Parser) ParseRawContext(ctx context.Context, data []byte, progress ProgressFunc) (*TreeNode, error) {
+	cmd := exec.CommandContext(ctx, p.protocPath, "--decode_raw")
+	cmd.Stdin = bytes.NewReader(data)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("ошибка декодирования protobuf: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("ошибка декодирования protobuf: %w", err)
+	}
+
+	tree, readErr := p.parseProtocStream(ctx, stdout, data, progress)
+	if readErr != nil {
+		cmd.Process.Kill()
+	}
+	waitErr := cmd.Wait()
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if readErr != nil {
+		return nil, readErr
+	}
+	if waitErr != nil {
+		if stderr.Len() > 0 {
+			return nil, fmt.Errorf("ошибка декодирования protobuf: %w: %s", waitErr, strings.TrimSpace(stderr.String()))
+		}
+		return nil, fmt.Errorf("ошибка декодирования protobuf: %w", waitErr)
+	}
+
+	markGroups(tree, data)
+	return tree, nil
+}
+
+// parseProtocStream строит дерево из вывода --decode_raw. Прогресс считается по полям
+// верхнего уровня: когда начинается k-е поле, предыдущие уже разобраны, и их доля в
+// исходных данных известна из разметки wire формата
+func (p *Parser) parseProtocStream(ctx context.Context, output io.Reader, data []byte, progress ProgressFunc) (*TreeNode, error) {
+	var fieldEnds []int
+	if progress != nil {
+		if fields, err := scanWireFields(data); err == nil {
+			for _, field := range fields {
+				fieldEnds = append(fieldEnds, field.end)
+			}
+		}
+		progress(0)
+	}
+
+	builder := newRawTextBuilder(p)
+	reported := 0.0
+	reader := bufio.NewReader(output)
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			builder.addLine(strings.TrimRight(line, "\r\n"))
+
+			if done := len(builder.root.Children) - 1; progress != nil && done > 0 && done <= len(fieldEnds) && len(data) > 0 {
+				if fraction := float64(fieldEnds[done-1]) / float64(len(data)); fraction-reported >= progressStep {
+					reported = fraction
+					progress(fraction)
+				}
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения вывода protoc: %w", err)
+		}
+	}
+
+	if progress != nil {
+		progress(1)
+	}
+	return builder.finish(), nil
+}
+
+// rawTextBuilder собирает дерево из строк вывода protoc --decode_raw по мере их поступления
+type rawTextBuilder struct {
+	parser       *Parser
+	root         *TreeNode
+	stack        []*TreeNode
+	stackIndents []int
+	fieldCounts  map[string]int
+}
+
+func newRawTextBuilder(p *Parser) *rawTextBuilder {
+	root := &TreeNode{
+		Name:     "root",
+		Type:     "message",
+		Children: make([]*TreeNode, 0),
+	}
+	return &rawTextBuilder{
+		parser:       p,
+		root:         root,
+		stack:        []*TreeNode{root},
+		stackIndents: []int{-1},
+		fieldCounts:  make(map[string]int),
+	}
+}
+
+func (b *rawTextBuilder) addLine(originalLine string) {
+	line := strings.TrimSpace(originalLine)
+	if line == "" {
+		return
+	}
+
+	indent := getIndentLevel(originalLine)
+	trimmedLine := line
+
+	if trimmedLine == "}" {
+		if len(b.stack) > 1 {
+			b.stack = b.stack[:len(b.stack)-1]
+			b.stackIndents = b.stackIndents[:len(b.stackIndents)-1]
+		}
+		return
+	}
+
+	for len(b.stack) > 1 && indent <= b.stackIndents[len(b.stackIndents)-1] {
+		b.stack = b.stack[:len(b.stack)-1]
+		b.stackIndents = b.stackIndents[:len(b.stackIndents)-1]
+	}
+
+	if strings.HasSuffix(trimmedLine, "{") {
+		fieldPart := strings.TrimSpace(strings.TrimSuffix(trimmedLine, "{"))
+		fieldNum := parseInt(fieldPart)
+		node := &TreeNode{
+			Name:       fmt.Sprintf("field_%d", fieldNum),
+			Type:       "message",
+			FieldNum:   fieldNum,
+			Children:   make([]*TreeNode, 0),
+			IsRepeated: false,
+		}
+
+		b.stack[len(b.stack)-1].AddChild(node)
+		b.stack = append(b.stack, node)
+		b.stackIndents = append(b.stackIndents, indent)
+		return
+	}
+
+	node := b.parser.parseLine(trimmedLine)
+	if node == nil {
+		return
+	}
+	parent := b.stack[len(b.stack)-1]
+	fieldKey := fmt.Sprintf("%p_%d", parent, node.FieldNum)
+	b.fieldCounts[fieldKey]++
+	if b.fieldCounts[fieldKey] > 1 {
+		node.IsRepeated = true
+		for _, child := range parent.Children {
+			if child.FieldNum == node.FieldNum {
+				child.IsRepeated = true
+			}
+		}
+	}
+	parent.AddChild(node)
+}
+
+// finish завершает разбор и нумерует типы сообщений
+func (b *rawTextBuilder) finish() *TreeNode {
+	renumberMessages(b.root)
+	return b.root
+}
@@ -0,0 +1,52 @@
+package protobuf
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseProtocStream_MatchesTextParserAndReportsProgress(t *testing.T) {
+	parser := &Parser{}
+	// 1: 150, 2: { 1: "hi" }, 3: 7
+	data := []byte{0x08, 0x96, 0x01, 0x12, 0x04, 0x0a, 0x02, 'h', 'i', 0x18, 0x07}
+	output := "1: 150\n2 {\n  1: \"hi\"\n}\n3: 7\n"
+
+	var fractions []float64
+	tree, err := parser.parseProtocStream(context.Background(), strings.NewReader(output), data, func(fraction float64) {
+		fractions = append(fractions, fraction)
+	})
+	if err != nil {
+		t.Fatalf("parseProtocStream failed: %v", err)
+	}
+
+	expected, _ := parser.parseProtocOutput(output)
+	serializer := &Serializer{}
+	if got, want := serializer.TreeToTextFormat(tree), serializer.TreeToTextFormat(expected); got != want {
+		t.Errorf("Expected streamed tree to match text parser, got:\n%s\nwant:\n%s", got, want)
+	}
+	if tree.Children[1].Type != "message_1" {
+		t.Errorf("Expected messages to be renumbered, got %s", tree.Children[1].Type)
+	}
+
+	if len(fractions) < 3 || fractions[0] != 0 || fractions[len(fractions)-1] != 1 {
+		t.Fatalf("Expected progress from 0 to 1, got %v", fractions)
+	}
+	for i := 1; i < len(fractions); i++ {
+		if fractions[i] < fractions[i-1] {
+			t.Errorf("Expected monotonic progress, got %v", fractions)
+		}
+	}
+}
+
+func TestParseProtocStream_Cancelled(t *testing.T) {
+	parser := &Parser{}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := parser.parseProtocStream(ctx, strings.NewReader("1: 1\n"), []byte{0x08, 0x01}, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,246 @@
+package protobuf
+
+import (
+	"encoding/binary"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// descriptorTypeNames - имена скалярных типов FieldDescriptorProto.Type по номеру
+var descriptorTypeNames = map[int]string{
+	1: "double", 2: "float", 3: "int64", 4: "uint64", 5: "int32", 6: "fixed64", 7: "fixed32",
+	8: "bool", 9: "string", 12: "bytes", 13: "uint32", 15: "sfixed32", 16: "sfixed64",
+	17: "sint32", 18: "sint64",
+}
+
+const (
+	descriptorTypeGroup     = 10
+	descriptorLabelRequired = 2
+	descriptorLabelRepeated = 3
+)
+
+// IsDescriptorSetPath сообщает, что файл - набор дескрипторов (protoc --descriptor_set_out),
+// а не текст .proto
+func IsDescriptorSetPath(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".pb", ".desc", ".protoset", ".binpb":
+		return true
+	}
+	return false
+}
+
+// parseDescriptorSet разбирает FileDescriptorSet в ту же модель, что и текст .proto, поэтому
+// набор дескрипторов можно применять как обычную схему. Сообщения всех файлов набора считаются
+// сообщениями верхнего уровня одной схемы; расширения и опции полей не читаются
+func parseDescriptorSet(data []byte) (*protoSchema, error) {
+	schema := &protoSchema{
+		messages:         make(map[string]*schemaMessageInfo),
+		topLevelMessages: make([]string, 0),
+		enums:            make(map[string]*schemaEnumInfo),
+	}
+
+	files, err := scanWireFields(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid descriptor set: %w", err)
+	}
+	for _, file := range files {
+		if file.number != 1 || file.wireType != wireBytes {
+			continue
+		}
+		fileFields, err := scanWireFields(file.payload)
+		if err != nil {
+			return nil, fmt.Errorf("invalid file descriptor: %w", err)
+		}
+		// Типы каждого файла получают полные имена в пакете этого файла
+		filePackage := ""
+		var messages []*schemaMessageInfo
+		var enums []*schemaEnumInfo
+		for _, field := range fileFields {
+			if field.wireType != wireBytes {
+				continue
+			}
+			switch field.number {
+			case 2:
+				filePackage = string(field.payload)
+				if schema.packageName == "" {
+					schema.packageName = filePackage
+				}
+			case 4:
+				message, err := parseMessageDescriptor(field.payload)
+				if err != nil {
+					return nil, err
+				}
+				messages = append(messages, message)
+				schema.topLevelMessages = append(schema.topLevelMessages, message.messageName)
+			case 5:
+				enum, err := parseEnumDescriptor(field.payload)
+				if err != nil {
+					return nil, err
+				}
+				enums = append(enums, enum)
+				schema.topLevelEnums = append(schema.topLevelEnums, enum.enumName)
+			case 12:
+				schema.syntax = string(field.payload)
+			}
+		}
+		schema.indexTypes(filePackage, messages, enums)
+	}
+	return schema, nil
+}
+
+// parseMessageDescriptor разбирает DescriptorProto. Вложенные типы попадают в общие словари
+// схемы при индексации файла (см. protoSchema.indexTypes)
+func parseMessageDescriptor(data []byte) (*schemaMessageInfo, error) {
+	fields, err := scanWireFields(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid message descriptor: %w", err)
+	}
+
+	message := &schemaMessageInfo{
+		fields:   make([]*schemaFieldInfo, 0),
+		messages: make(map[string]*schemaMessageInfo),
+		enums:    make(map[string]*schemaEnumInfo),
+	}
+	oneofs := make([]string, 0)
+	oneofIndexes := make(map[*schemaFieldInfo]int)
+	for _, field := range fields {
+		if field.wireType != wireBytes {
+			continue
+		}
+		switch field.number {
+		case 1:
+			message.messageName = string(field.payload)
+		case 2:
+			info, oneofIndex, err := parseFieldDescriptor(field.payload)
+			if err != nil {
+				return nil, err
+			}
+			message.fields = append(message.fields, info)
+			if oneofIndex >= 0 {
+				oneofIndexes[info] = oneofIndex
+			}
+		case 3:
+			nested, err := parseMessageDescriptor(field.payload)
+			if err != nil {
+				return nil, err
+			}
+			message.messages[nested.messageName] = nested
+			message.nestedOrder = append(message.nestedOrder, nested.messageName)
+		case 4:
+			enum, err := parseEnumDescriptor(field.payload)
+			if err != nil {
+				return nil, err
+			}
+			message.enums[enum.enumName] = enum
+			message.enumOrder = append(message.enumOrder, enum.enumName)
+		case 8:
+			oneofFields, err := scanWireFields(field.payload)
+			if err != nil {
+				return nil, fmt.Errorf("invalid oneof descriptor: %w", err)
+			}
+			name := ""
+			for _, oneofField := range oneofFields {
+				if oneofField.number == 1 && oneofField.wireType == wireBytes {
+					name = string(oneofField.payload)
+				}
+			}
+			oneofs = append(oneofs, name)
+		}
+	}
+
+	for info, index := range oneofIndexes {
+		if index < len(oneofs) {
+			info.oneof = oneofs[index]
+		}
+	}
+	return message, nil
+}
+
+// parseFieldDescriptor разбирает FieldDescriptorProto и возвращает индекс oneof поля или -1
+func parseFieldDescriptor(data []byte) (*schemaFieldInfo, int, error) {
+	fields, err := scanWireFields(data)
+	if err != nil {
+		return nil, -1, fmt.Errorf("invalid field descriptor: %w", err)
+	}
+
+	info := &schemaFieldInfo{options: make(map[string]string)}
+	typeNumber, label, oneofIndex := 0, 0, -1
+	typeName := ""
+	for _, field := range fields {
+		switch {
+		case field.wireType == wireVarint:
+			value, _ := binary.Uvarint(field.payload)
+			switch field.number {
+			case 3:
+				info.fieldNum = int(value)
+			case 4:
+				label = int(value)
+			case 5:
+				typeNumber = int(value)
+			case 9:
+				oneofIndex = int(value)
+			case 17:
+				info.isOptional = value != 0
+			}
+		case field.wireType == wireBytes:
+			switch field.number {
+			case 1:
+				info.fieldName = string(field.payload)
+			case 6:
+				typeName = strings.TrimPrefix(string(field.payload), ".")
+			case 7:
+				info.defaultValue = string(field.payload)
+				info.hasDefault = true
+			}
+		}
+	}
+
+	info.isRepeated = label == descriptorLabelRepeated
+	info.isRequired = label == descriptorLabelRequired
+	info.isGroup = typeNumber == descriptorTypeGroup
+	if scalar, ok := descriptorTypeNames[typeNumber]; ok {
+		info.fieldType = scalar
+	} else {
+		info.fieldType = typeName
+	}
+	if info.fieldName == "" || info.fieldNum <= 0 || info.fieldType == "" {
+		return nil, -1, fmt.Errorf("incomplete field descriptor %q", info.fieldName)
+	}
+	return info, oneofIndex, nil
+}
+
+func parseEnumDescriptor(data []byte) (*schemaEnumInfo, error) {
+	fields, err := scanWireFields(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid enum descriptor: %w", err)
+	}
+
+	enum := &schemaEnumInfo{values: make([]*schemaEnumValue, 0)}
+	for _, field := range fields {
+		if field.wireType != wireBytes {
+			continue
+		}
+		switch field.number {
+		case 1:
+			enum.enumName = string(field.payload)
+		case 2:
+			valueFields, err := scanWireFields(field.payload)
+			if err != nil {
+				return nil, fmt.Errorf("invalid enum value descriptor: %w", err)
+			}
+			value := &schemaEnumValue{}
+			for _, valueField := range valueFields {
+				switch {
+				case valueField.number == 1 && valueField.wireType == wireBytes:
+					value.name = string(valueField.payload)
+				case valueField.number == 2 && valueField.wireType == wireVarint:
+					number, _ := binary.Uvarint(valueField.payload)
+					value.number = int(int32(number))
+				}
+			}
+			enum.values = append(enum.values, value)
+		}
+	}
+	return enum, nil
+}
@@ -0,0 +1,115 @@
+package protobuf
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func appendBytesField(data []byte, number int, payload []byte) []byte {
+	data = binary.AppendUvarint(data, uint64(number)<<3|wireBytes)
+	data = binary.AppendUvarint(data, uint64(len(payload)))
+	return append(data, payload...)
+}
+
+func appendVarintField(data []byte, number int, value uint64) []byte {
+	data = binary.AppendUvarint(data, uint64(number)<<3|wireVarint)
+	return binary.AppendUvarint(data, value)
+}
+
+func testFieldDescriptor(name string, number int, label int, fieldType int, typeName string) []byte {
+	field := appendBytesField(nil, 1, []byte(name))
+	field = appendVarintField(field, 3, uint64(number))
+	field = appendVarintField(field, 4, uint64(label))
+	field = appendVarintField(field, 5, uint64(fieldType))
+	if typeName != "" {
+		field = appendBytesField(field, 6, []byte(typeName))
+	}
+	return field
+}
+
+// testDescriptorSet описывает файл пакета shop:
+// message Order { required string id = 1; repeated Item items = 2; Status status = 3; message Item { int64 sku = 1; } }
+// enum Status { NEW = 0; PAID = 1; }
+func testDescriptorSet() []byte {
+	item := appendBytesField(nil, 1, []byte("Item"))
+	item = appendBytesField(item, 2, testFieldDescriptor("sku", 1, 1, 3, ""))
+
+	order := appendBytesField(nil, 1, []byte("Order"))
+	order = appendBytesField(order, 2, testFieldDescriptor("id", 1, 2, 9, ""))
+	order = appendBytesField(order, 2, testFieldDescriptor("items", 2, 3, 11, ".shop.Order.Item"))
+	order = appendBytesField(order, 2, testFieldDescriptor("status", 3, 1, 14, ".shop.Status"))
+	order = appendBytesField(order, 3, item)
+
+	status := appendBytesField(nil, 1, []byte("Status"))
+	status = appendBytesField(status, 2, appendVarintField(appendBytesField(nil, 1, []byte("NEW")), 2, 0))
+	status = appendBytesField(status, 2, appendVarintField(appendBytesField(nil, 1, []byte("PAID")), 2, 1))
+
+	file := appendBytesField(nil, 1, []byte("shop.proto"))
+	file = appendBytesField(file, 2, []byte("shop"))
+	file = appendBytesField(file, 4, order)
+	file = appendBytesField(file, 5, status)
+	file = appendBytesField(file, 12, []byte("proto2"))
+
+	return appendBytesField(nil, 1, file)
+}
+
+func TestParseDescriptorSet(t *testing.T) {
+	schema, err := parseDescriptorSet(testDescriptorSet())
+	if err != nil {
+		t.Fatalf("parseDescriptorSet failed: %v", err)
+	}
+
+	if schema.packageName != "shop" || schema.syntax != "proto2" {
+		t.Errorf("Expected package shop and proto2 syntax, got %q %q", schema.packageName, schema.syntax)
+	}
+	if len(schema.topLevelMessages) != 1 || schema.topLevelMessages[0] != "Order" {
+		t.Fatalf("Expected Order as the only top-level message, got %v", schema.topLevelMessages)
+	}
+	order := schema.messages["shop.Order"]
+	if len(order.fields) != 3 || schema.messages["shop.Order.Item"] == nil || order.messages["Item"] == nil {
+		t.Fatalf("Expected Order with 3 fields and nested Item, got %+v", order)
+	}
+	if id := order.fields[0]; id.fieldType != "string" || !id.isRequired {
+		t.Errorf("Expected required string id, got %+v", id)
+	}
+	if items := order.fields[1]; items.fieldType != "shop.Order.Item" || !items.isRepeated {
+		t.Errorf("Expected repeated shop.Order.Item items, got %+v", items)
+	}
+	if status := schema.enums["shop.Status"]; status == nil || len(status.values) != 2 || status.values[1].name != "PAID" || status.values[1].number != 1 {
+		t.Errorf("Expected Status enum with two values, got %+v", status)
+	}
+
+	if _, err := parseDescriptorSet([]byte{0x0a, 0x05, 0x01}); err == nil {
+		t.Error("Expected an error for truncated descriptor set")
+	}
+}
+
+func TestApplySchema_DescriptorSet(t *testing.T) {
+	schemaPath := filepath.Join(t.TempDir(), "shop.desc")
+	if err := os.WriteFile(schemaPath, testDescriptorSet(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	parser := &Parser{}
+	messages, err := parser.ParseSchemaFile(schemaPath)
+	if err != nil || len(messages) != 1 || messages[0] != "Order" {
+		t.Fatalf("Expected Order from descriptor set, got %v, %v", messages, err)
+	}
+
+	root := newTestRoot(
+		&TreeNode{Name: "field_1", Type: "string", FieldNum: 1, Value: "A-1"},
+		&TreeNode{Name: "field_3", Type: "int64", FieldNum: 3, Value: "1"},
+	)
+	tree, report, err := parser.ApplySchemaWithMessage(root, schemaPath, "Order")
+	if err != nil {
+		t.Fatalf("ApplySchemaWithMessage failed: %v", err)
+	}
+	if tree.Children[0].Name != "id" || tree.Children[1].Name != "status" {
+		t.Errorf("Expected field names from the descriptor set, got %s, %s", tree.Children[0].Name, tree.Children[1].Name)
+	}
+	if len(report.Issues) != 0 {
+		t.Errorf("Expected no schema issues, got %+v", report.Issues)
+	}
+}
@@ -0,0 +1,454 @@
+package protobuf
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+type DiffKind string
+
+const (
+	DiffAdded       DiffKind = "added"
+	DiffRemoved     DiffKind = "removed"
+	DiffChanged     DiffKind = "changed"
+	DiffTypeChanged DiffKind = "type_changed"
+)
+
+// DiffEntry описывает одно различие между двумя деревьями.
+// Path строится из имен полей (или field_N), элементы repeated полей помечаются индексом: items[2].name.
+// При сопоставлении по ключу индекс берется из нового дерева, а удаленный элемент помечается
+// значением ключа (items[id=42]) или, если ключа у него нет, прежним индексом (items[old 3])
+type DiffEntry struct {
+	Path     string      `json:"path"`
+	Kind     DiffKind    `json:"kind"`
+	FieldNum int         `json:"fieldNum"`
+	OldType  string      `json:"oldType,omitempty"`
+	NewType  string      `json:"newType,omitempty"`
+	OldValue interface{} `json:"oldValue,omitempty"`
+	NewValue interface{} `json:"newValue,omitempty"`
+}
+
+type DiffOptions struct {
+	// RepeatedKeys задает для repeated полей-сообщений номер поля, по значению которого
+	// сопоставляются элементы. Ключ - путь поля без индексов (например "orders.items").
+	// Для полей без ключа элементы сопоставляются по позиции.
+	RepeatedKeys map[string]int
+}
+
+// DiffTrees сравнивает два дерева: поля сопоставляются по номеру, элементы repeated полей -
+// по позиции или по ключевому полю из opts. Ленивые узлы раскрываются в копиях деревьев,
+// переданные деревья не изменяются.
+func DiffTrees(oldTree, newTree *TreeNode, opts *DiffOptions) []DiffEntry {
+	oldTree = oldTree.Clone()
+	newTree = newTree.Clone()
+	ExpandAll(oldTree)
+	ExpandAll(newTree)
+	if opts == nil {
+		opts = &DiffOptions{}
+	}
+
+	d := &treeDiffer{opts: opts, entries: make([]DiffEntry, 0)}
+	d.diffMessage("", oldTree, newTree)
+	return d.entries
+}
+
+// RepeatedKeyCandidate - repeated поле-сообщение, элементы которого можно сопоставить по ключу
+type RepeatedKeyCandidate struct {
+	// Path - путь поля без индексов, как в DiffOptions.RepeatedKeys
+	Path string
+	// Fields - скалярные поля элементов, пригодные в качестве ключа, в порядке появления
+	Fields []RepeatedKeyField
+}
+
+type RepeatedKeyField struct {
+	FieldNum int
+	Name     string
+}
+
+// RepeatedKeyCandidates возвращает repeated поля-сообщения обоих деревьев с полями, по которым
+// можно сопоставлять их элементы. Как и DiffTrees, раскрывает ленивые узлы только в копиях
+func RepeatedKeyCandidates(trees ...*TreeNode) []RepeatedKeyCandidate {
+	collector := &keyCandidateCollector{byPath: make(map[string]int), seen: make(map[string]bool)}
+	for _, tree := range trees {
+		tree = tree.Clone()
+		ExpandAll(tree)
+		collector.collect("", tree)
+	}
+	return collector.candidates
+}
+
+type keyCandidateCollector struct {
+	candidates []RepeatedKeyCandidate
+	byPath     map[string]int
+	// seen - пары "путь/номер поля", уже добавленные в Fields
+	seen map[string]bool
+}
+
+func (c *keyCandidateCollector) collect(path string, node *TreeNode) {
+	if node == nil {
+		return
+	}
+	groups, order := groupChildrenByFieldNum(node, nil)
+	for _, fieldNum := range order {
+		list := groups[fieldNum]
+		fieldPath := joinDiffPath(path, fieldPathName(list[0]))
+
+		if len(list) > 1 || list[0].IsRepeated {
+			c.addElements(stripPathIndexes(fieldPath), list)
+		}
+		for _, child := range list {
+			if child.IsMessage() {
+				c.collect(fieldPath, child)
+			}
+		}
+	}
+}
+
+func (c *keyCandidateCollector) addElements(path string, elements []*TreeNode) {
+	for _, element := range elements {
+		if !element.IsMessage() {
+			continue
+		}
+		index, ok := c.byPath[path]
+		if !ok {
+			index = len(c.candidates)
+			c.byPath[path] = index
+			c.candidates = append(c.candidates, RepeatedKeyCandidate{Path: path})
+		}
+		for _, child := range element.Children {
+			key := fmt.Sprintf("%s/%d", path, child.FieldNum)
+			if child.IsMessage() || c.seen[key] {
+				continue
+			}
+			c.seen[key] = true
+			c.candidates[index].Fields = append(c.candidates[index].Fields, RepeatedKeyField{FieldNum: child.FieldNum, Name: fieldPathName(child)})
+		}
+	}
+}
+
+type treeDiffer struct {
+	opts    *DiffOptions
+	entries []DiffEntry
+}
+
+func (d *treeDiffer) diffMessage(path string, oldNode, newNode *TreeNode) {
+	oldFields, order := groupChildrenByFieldNum(oldNode, nil)
+	newFields, order := groupChildrenByFieldNum(newNode, order)
+
+	for _, fieldNum := range order {
+		oldList := oldFields[fieldNum]
+		newList := newFields[fieldNum]
+
+		repeated := len(oldList) > 1 || len(newList) > 1
+		for _, n := range append(append([]*TreeNode{}, oldList...), newList...) {
+			if n.IsRepeated {
+				repeated = true
+			}
+		}
+
+		name := fieldPathName(firstNode(newList, oldList))
+		fieldPath := joinDiffPath(path, name)
+
+		if !repeated {
+			d.diffPair(fieldPath, firstNode(oldList, nil), firstNode(newList, nil))
+			continue
+		}
+
+		if keyField, ok := d.opts.RepeatedKeys[stripPathIndexes(fieldPath)]; ok {
+			d.diffRepeatedByKey(fieldPath, oldList, newList, keyField)
+		} else {
+			d.diffRepeatedByPosition(fieldPath, oldList, newList)
+		}
+	}
+}
+
+func (d *treeDiffer) diffRepeatedByPosition(fieldPath string, oldList, newList []*TreeNode) {
+	count := len(oldList)
+	if len(newList) > count {
+		count = len(newList)
+	}
+
+	for i := 0; i < count; i++ {
+		var oldNode, newNode *TreeNode
+		if i < len(oldList) {
+			oldNode = oldList[i]
+		}
+		if i < len(newList) {
+			newNode = newList[i]
+		}
+		d.diffPair(fmt.Sprintf("%s[%d]", fieldPath, i), oldNode, newNode)
+	}
+}
+
+func (d *treeDiffer) diffRepeatedByKey(fieldPath string, oldList, newList []*TreeNode, keyField int) {
+	oldByKey := make(map[string]int)
+	for i, n := range oldList {
+		if key, ok := repeatedElementKey(n, keyField); ok {
+			if _, exists := oldByKey[key]; !exists {
+				oldByKey[key] = i
+			}
+		}
+	}
+
+	matchedOld := make(map[int]bool)
+	for i, newNode := range newList {
+		key, ok := repeatedElementKey(newNode, keyField)
+		if oldIdx, found := oldByKey[key]; ok && found && !matchedOld[oldIdx] {
+			matchedOld[oldIdx] = true
+			d.diffPair(fmt.Sprintf("%s[%d]", fieldPath, i), oldList[oldIdx], newNode)
+			continue
+		}
+		d.diffPair(fmt.Sprintf("%s[%d]", fieldPath, i), nil, newNode)
+	}
+
+	// Индексы удаленных элементов относятся к старому дереву и совпали бы с индексами новых
+	for i, oldNode := range oldList {
+		if matchedOld[i] {
+			continue
+		}
+		if key, ok := repeatedElementKey(oldNode, keyField); ok {
+			d.diffPair(fmt.Sprintf("%s[%s=%s]", fieldPath, repeatedKeyName(oldNode, keyField), key), oldNode, nil)
+		} else {
+			d.diffPair(fmt.Sprintf("%s[old %d]", fieldPath, i), oldNode, nil)
+		}
+	}
+}
+
+func (d *treeDiffer) diffPair(path string, oldNode, newNode *TreeNode) {
+	switch {
+	case oldNode == nil && newNode == nil:
+		return
+	case oldNode == nil:
+		d.add(DiffEntry{Path: path, Kind: DiffAdded, FieldNum: newNode.FieldNum, NewType: newNode.Type, NewValue: diffValue(newNode)})
+		return
+	case newNode == nil:
+		d.add(DiffEntry{Path: path, Kind: DiffRemoved, FieldNum: oldNode.FieldNum, OldType: oldNode.Type, OldValue: diffValue(oldNode)})
+		return
+	}
+
+	oldIsMessage := oldNode.IsMessage()
+	newIsMessage := newNode.IsMessage()
+
+	if oldIsMessage && newIsMessage {
+		d.diffMessage(path, oldNode, newNode)
+		return
+	}
+
+	if oldIsMessage != newIsMessage || oldNode.Type != newNode.Type {
+		d.add(DiffEntry{
+			Path:     path,
+			Kind:     DiffTypeChanged,
+			FieldNum: newNode.FieldNum,
+			OldType:  oldNode.Type,
+			NewType:  newNode.Type,
+			OldValue: diffValue(oldNode),
+			NewValue: diffValue(newNode),
+		})
+		return
+	}
+
+	if valueToDiffString(oldNode.Value) != valueToDiffString(newNode.Value) {
+		d.add(DiffEntry{
+			Path:     path,
+			Kind:     DiffChanged,
+			FieldNum: newNode.FieldNum,
+			OldType:  oldNode.Type,
+			NewType:  newNode.Type,
+			OldValue: oldNode.Value,
+			NewValue: newNode.Value,
+		})
+	}
+}
+
+func (d *treeDiffer) add(entry DiffEntry) {
+	d.entries = append(d.entries, entry)
+}
+
+// groupChildrenByFieldNum группирует дочерние узлы по номеру поля, сохраняя порядок первого появления.
+// Номера, которых еще нет в order, дописываются в конец.
+func groupChildrenByFieldNum(node *TreeNode, order []int) (map[int][]*TreeNode, []int) {
+	groups := make(map[int][]*TreeNode)
+	seen := make(map[int]bool)
+	for _, fieldNum := range order {
+		seen[fieldNum] = true
+	}
+
+	if node == nil {
+		return groups, order
+	}
+
+	for _, child := range node.Children {
+		groups[child.FieldNum] = append(groups[child.FieldNum], child)
+		if !seen[child.FieldNum] {
+			seen[child.FieldNum] = true
+			order = append(order, child.FieldNum)
+		}
+	}
+	return groups, order
+}
+
+func firstNode(primary, fallback []*TreeNode) *TreeNode {
+	if len(primary) > 0 {
+		return primary[0]
+	}
+	if len(fallback) > 0 {
+		return fallback[0]
+	}
+	return nil
+}
+
+func fieldPathName(node *TreeNode) string {
+	if node == nil {
+		return ""
+	}
+	if node.Name == "" {
+		return fmt.Sprintf("field_%d", node.FieldNum)
+	}
+	return node.Name
+}
+
+func joinDiffPath(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
+
+// stripPathIndexes убирает индексы repeated элементов: "a[1].b[0]" -> "a.b"
+func stripPathIndexes(path string) string {
+	var builder strings.Builder
+	depth := 0
+	for _, r := range path {
+		switch {
+		case r == '[':
+			depth++
+		case r == ']':
+			if depth > 0 {
+				depth--
+			}
+		case depth == 0:
+			builder.WriteRune(r)
+		}
+	}
+	return builder.String()
+}
+
+func repeatedElementKey(node *TreeNode, keyField int) (string, bool) {
+	if node == nil {
+		return "", false
+	}
+	for _, child := range node.Children {
+		if child.FieldNum == keyField && !child.IsMessage() {
+			return valueToDiffString(child.Value), true
+		}
+	}
+	return "", false
+}
+
+// repeatedKeyName возвращает имя ключевого поля элемента для пути различия
+func repeatedKeyName(node *TreeNode, keyField int) string {
+	for _, child := range node.Children {
+		if child.FieldNum == keyField {
+			return fieldPathName(child)
+		}
+	}
+	return fmt.Sprintf("field_%d", keyField)
+}
+
+// diffValue возвращает значение узла для отчета: для сообщений - JSON представление
+func diffValue(node *TreeNode) interface{} {
+	if node == nil {
+		return nil
+	}
+	if node.IsMessage() {
+		value, err := TreeNodeToJSON(node)
+		if err != nil {
+			return nil
+		}
+		return value
+	}
+	return node.Value
+}
+
+func valueToDiffString(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	if data, ok := value.([]byte); ok {
+		return EscapeTextBytes(data)
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// DiffSummary считает количество различий каждого вида
+func DiffSummary(entries []DiffEntry) map[DiffKind]int {
+	summary := map[DiffKind]int{
+		DiffAdded:       0,
+		DiffRemoved:     0,
+		DiffChanged:     0,
+		DiffTypeChanged: 0,
+	}
+	for _, entry := range entries {
+		summary[entry.Kind]++
+	}
+	return summary
+}
+
+// DiffToJSONString сериализует результат сравнения в JSON с итоговой сводкой
+func DiffToJSONString(entries []DiffEntry) (string, error) {
+	if entries == nil {
+		entries = make([]DiffEntry, 0)
+	}
+
+	report := struct {
+		Equal   bool             `json:"equal"`
+		Summary map[DiffKind]int `json:"summary"`
+		Changes []DiffEntry      `json:"changes"`
+	}{
+		Equal:   len(entries) == 0,
+		Summary: DiffSummary(entries),
+		Changes: entries,
+	}
+
+	jsonBytes, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("error marshaling diff to JSON: %w", err)
+	}
+	return string(jsonBytes), nil
+}
+
+// DiffToText форматирует различия построчно в стиле unified diff
+func DiffToText(entries []DiffEntry) string {
+	var builder strings.Builder
+	for _, entry := range entries {
+		switch entry.Kind {
+		case DiffAdded:
+			builder.WriteString(fmt.Sprintf("+ %s: %s\n", entry.Path, formatDiffValue(entry.NewValue)))
+		case DiffRemoved:
+			builder.WriteString(fmt.Sprintf("- %s: %s\n", entry.Path, formatDiffValue(entry.OldValue)))
+		case DiffChanged:
+			builder.WriteString(fmt.Sprintf("~ %s: %s -> %s\n", entry.Path, formatDiffValue(entry.OldValue), formatDiffValue(entry.NewValue)))
+		case DiffTypeChanged:
+			builder.WriteString(fmt.Sprintf("! %s: %s(%s) -> %s(%s)\n", entry.Path, entry.OldType, formatDiffValue(entry.OldValue), entry.NewType, formatDiffValue(entry.NewValue)))
+		}
+	}
+	return builder.String()
+}
+
+func formatDiffValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case map[string]interface{}:
+		jsonBytes, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprintf("%v", v)
+		}
+		return string(jsonBytes)
+	case []byte:
+		return fmt.Sprintf("\"%s\"", EscapeTextBytes(v))
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
@@ -0,0 +1,258 @@
+package protobuf
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func findDiffEntry(entries []DiffEntry, path string) *DiffEntry {
+	for i := range entries {
+		if entries[i].Path == path {
+			return &entries[i]
+		}
+	}
+	return nil
+}
+
+func TestDiffTrees_EqualTrees(t *testing.T) {
+	oldTree := newTestRoot(
+		&TreeNode{Name: "name", Type: "string", FieldNum: 1, Value: "alice"},
+		&TreeNode{Name: "age", Type: "int32", FieldNum: 2, Value: "30"},
+	)
+	newTree := newTestRoot(
+		&TreeNode{Name: "name", Type: "string", FieldNum: 1, Value: "alice"},
+		&TreeNode{Name: "age", Type: "int32", FieldNum: 2, Value: "30"},
+	)
+
+	entries := DiffTrees(oldTree, newTree, nil)
+	if len(entries) != 0 {
+		t.Fatalf("Expected no differences, got %d: %+v", len(entries), entries)
+	}
+}
+
+func TestDiffTrees_AddedRemovedChanged(t *testing.T) {
+	oldTree := newTestRoot(
+		&TreeNode{Name: "name", Type: "string", FieldNum: 1, Value: "alice"},
+		&TreeNode{Name: "age", Type: "int32", FieldNum: 2, Value: "30"},
+	)
+	newTree := newTestRoot(
+		&TreeNode{Name: "name", Type: "string", FieldNum: 1, Value: "bob"},
+		&TreeNode{Name: "email", Type: "string", FieldNum: 3, Value: "bob@example.com"},
+	)
+
+	entries := DiffTrees(oldTree, newTree, nil)
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 differences, got %d: %+v", len(entries), entries)
+	}
+
+	if e := findDiffEntry(entries, "name"); e == nil || e.Kind != DiffChanged || e.OldValue != "alice" || e.NewValue != "bob" {
+		t.Errorf("Expected 'name' changed alice -> bob, got %+v", e)
+	}
+	if e := findDiffEntry(entries, "age"); e == nil || e.Kind != DiffRemoved {
+		t.Errorf("Expected 'age' removed, got %+v", e)
+	}
+	if e := findDiffEntry(entries, "email"); e == nil || e.Kind != DiffAdded || e.FieldNum != 3 {
+		t.Errorf("Expected 'email' added, got %+v", e)
+	}
+}
+
+func TestDiffTrees_TypeChanged(t *testing.T) {
+	oldTree := newTestRoot(&TreeNode{Name: "field_1", Type: "int64", FieldNum: 1, Value: "42"})
+	newTree := newTestRoot(&TreeNode{Name: "field_1", Type: "string", FieldNum: 1, Value: "42"})
+
+	entries := DiffTrees(oldTree, newTree, nil)
+	if len(entries) != 1 || entries[0].Kind != DiffTypeChanged {
+		t.Fatalf("Expected one type change, got %+v", entries)
+	}
+	if entries[0].OldType != "int64" || entries[0].NewType != "string" {
+		t.Errorf("Expected int64 -> string, got %s -> %s", entries[0].OldType, entries[0].NewType)
+	}
+}
+
+func TestDiffTrees_NestedMessage(t *testing.T) {
+	oldTree := newTestRoot(&TreeNode{
+		Name: "address", Type: "message_1", FieldNum: 4,
+		Children: []*TreeNode{
+			{Name: "city", Type: "string", FieldNum: 1, Value: "Moscow"},
+		},
+	})
+	newTree := newTestRoot(&TreeNode{
+		Name: "address", Type: "message_1", FieldNum: 4,
+		Children: []*TreeNode{
+			{Name: "city", Type: "string", FieldNum: 1, Value: "Kazan"},
+		},
+	})
+
+	entries := DiffTrees(oldTree, newTree, nil)
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 difference, got %d: %+v", len(entries), entries)
+	}
+	if entries[0].Path != "address.city" {
+		t.Errorf("Expected path 'address.city', got '%s'", entries[0].Path)
+	}
+}
+
+func TestDiffTrees_MessageReplacedByScalar(t *testing.T) {
+	oldTree := newTestRoot(&TreeNode{
+		Name: "field_2", Type: "message_1", FieldNum: 2,
+		Children: []*TreeNode{{Name: "field_1", Type: "string", FieldNum: 1, Value: "x"}},
+	})
+	newTree := newTestRoot(&TreeNode{Name: "field_2", Type: "string", FieldNum: 2, Value: "x"})
+
+	entries := DiffTrees(oldTree, newTree, nil)
+	if len(entries) != 1 || entries[0].Kind != DiffTypeChanged {
+		t.Fatalf("Expected one type change, got %+v", entries)
+	}
+	if _, ok := entries[0].OldValue.(map[string]interface{}); !ok {
+		t.Errorf("Expected old message value to be reported as JSON object, got %T", entries[0].OldValue)
+	}
+}
+
+func TestDiffTrees_RepeatedByPosition(t *testing.T) {
+	oldTree := newTestRoot(
+		&TreeNode{Name: "tags", Type: "string", FieldNum: 5, Value: "a", IsRepeated: true},
+		&TreeNode{Name: "tags", Type: "string", FieldNum: 5, Value: "b", IsRepeated: true},
+	)
+	newTree := newTestRoot(
+		&TreeNode{Name: "tags", Type: "string", FieldNum: 5, Value: "a", IsRepeated: true},
+		&TreeNode{Name: "tags", Type: "string", FieldNum: 5, Value: "c", IsRepeated: true},
+		&TreeNode{Name: "tags", Type: "string", FieldNum: 5, Value: "d", IsRepeated: true},
+	)
+
+	entries := DiffTrees(oldTree, newTree, nil)
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 differences, got %d: %+v", len(entries), entries)
+	}
+	if e := findDiffEntry(entries, "tags[1]"); e == nil || e.Kind != DiffChanged {
+		t.Errorf("Expected tags[1] changed, got %+v", e)
+	}
+	if e := findDiffEntry(entries, "tags[2]"); e == nil || e.Kind != DiffAdded {
+		t.Errorf("Expected tags[2] added, got %+v", e)
+	}
+}
+
+func TestDiffTrees_RepeatedByKey(t *testing.T) {
+	item := func(id, qty string) *TreeNode {
+		return &TreeNode{
+			Name: "items", Type: "message_1", FieldNum: 3, IsRepeated: true,
+			Children: []*TreeNode{
+				{Name: "id", Type: "string", FieldNum: 1, Value: id},
+				{Name: "qty", Type: "int32", FieldNum: 2, Value: qty},
+			},
+		}
+	}
+
+	oldTree := newTestRoot(item("a", "1"), item("b", "2"), item("c", "3"))
+	newTree := newTestRoot(item("c", "3"), item("a", "5"))
+
+	byPosition := DiffTrees(oldTree, newTree, nil)
+	byKey := DiffTrees(oldTree, newTree, &DiffOptions{RepeatedKeys: map[string]int{"items": 1}})
+
+	if len(byKey) >= len(byPosition) {
+		t.Errorf("Expected key matching to produce fewer differences than positional (%d vs %d)", len(byKey), len(byPosition))
+	}
+
+	if e := findDiffEntry(byKey, "items[1].qty"); e == nil || e.Kind != DiffChanged || e.OldValue != "1" || e.NewValue != "5" {
+		t.Errorf("Expected items[1].qty changed 1 -> 5, got %+v", e)
+	}
+	// Удаленный элемент помечается ключом, а не прежним индексом, который занят новым элементом
+	if e := findDiffEntry(byKey, "items[id=b]"); e == nil || e.Kind != DiffRemoved {
+		t.Errorf("Expected old element id=b removed, got %+v", e)
+	}
+	if e := findDiffEntry(byKey, "items[1]"); e != nil {
+		t.Errorf("Expected no entry for items[1] itself, got %+v", e)
+	}
+	if len(byKey) != 2 {
+		t.Errorf("Expected 2 differences with key matching, got %d: %+v", len(byKey), byKey)
+	}
+
+	// Элемент без ключевого поля помечается прежним индексом
+	keyless := &TreeNode{Name: "items", Type: "message_1", FieldNum: 3, IsRepeated: true,
+		Children: []*TreeNode{{Name: "qty", Type: "int32", FieldNum: 2, Value: "7"}}}
+	entries := DiffTrees(newTestRoot(item("a", "1"), keyless), newTestRoot(item("a", "1")), &DiffOptions{RepeatedKeys: map[string]int{"items": 1}})
+	if e := findDiffEntry(entries, "items[old 1]"); e == nil || e.Kind != DiffRemoved || len(entries) != 1 {
+		t.Errorf("Expected the keyless element removed under its old index, got %+v", entries)
+	}
+}
+
+func TestDiffTrees_KeepsLazyInputs(t *testing.T) {
+	oldTree, err := DecodeLazy(context.Background(), lazyTestData, nil)
+	if err != nil {
+		t.Fatalf("DecodeLazy failed: %v", err)
+	}
+	newTree, err := DecodeLazy(context.Background(), lazyTestData, nil)
+	if err != nil {
+		t.Fatalf("DecodeLazy failed: %v", err)
+	}
+
+	if entries := DiffTrees(oldTree, newTree, nil); len(entries) != 0 {
+		t.Errorf("Expected equal trees, got %+v", entries)
+	}
+	RepeatedKeyCandidates(oldTree, newTree)
+	if !oldTree.Children[1].IsLazy() || !newTree.Children[1].IsLazy() {
+		t.Error("Expected comparison not to decode lazy nodes of the caller's trees")
+	}
+}
+
+func TestRepeatedKeyCandidates(t *testing.T) {
+	item := func(id string) *TreeNode {
+		return &TreeNode{
+			Name: "items", Type: "message_1", FieldNum: 3, IsRepeated: true,
+			Children: []*TreeNode{
+				{Name: "id", Type: "string", FieldNum: 1, Value: id},
+				{Name: "tags", Type: "message_2", FieldNum: 2, Children: []*TreeNode{{Name: "field_1", Type: "string", FieldNum: 1, Value: "x"}}},
+			},
+		}
+	}
+	order := &TreeNode{Name: "orders", Type: "message_3", FieldNum: 1, Children: []*TreeNode{item("a"), item("b")}}
+	oldTree := newTestRoot(order, &TreeNode{Name: "codes", Type: "int32", FieldNum: 2, IsRepeated: true, Value: "1"})
+	newTree := newTestRoot(order.Clone(), &TreeNode{Name: "extra", Type: "message_4", FieldNum: 5, IsRepeated: true,
+		Children: []*TreeNode{{Name: "key", Type: "int32", FieldNum: 7, Value: "1"}}})
+
+	candidates := RepeatedKeyCandidates(oldTree, newTree)
+	if len(candidates) != 2 {
+		t.Fatalf("Expected repeated message fields from both trees, got %+v", candidates)
+	}
+	if candidates[0].Path != "orders.items" || len(candidates[0].Fields) != 1 || candidates[0].Fields[0] != (RepeatedKeyField{FieldNum: 1, Name: "id"}) {
+		t.Errorf("Expected scalar fields of orders.items as keys, got %+v", candidates[0])
+	}
+	if candidates[1].Path != "extra" || candidates[1].Fields[0].FieldNum != 7 {
+		t.Errorf("Expected repeated field of the new tree, got %+v", candidates[1])
+	}
+}
+
+func TestDiffToJSONString(t *testing.T) {
+	oldTree := newTestRoot(&TreeNode{Name: "name", Type: "string", FieldNum: 1, Value: "alice"})
+	newTree := newTestRoot(&TreeNode{Name: "name", Type: "string", FieldNum: 1, Value: "bob"})
+
+	jsonStr, err := DiffToJSONString(DiffTrees(oldTree, newTree, nil))
+	if err != nil {
+		t.Fatalf("DiffToJSONString failed: %v", err)
+	}
+
+	var report struct {
+		Equal   bool           `json:"equal"`
+		Summary map[string]int `json:"summary"`
+		Changes []DiffEntry    `json:"changes"`
+	}
+	if err := json.Unmarshal([]byte(jsonStr), &report); err != nil {
+		t.Fatalf("Invalid JSON: %v\n%s", err, jsonStr)
+	}
+
+	if report.Equal {
+		t.Error("Expected equal=false")
+	}
+	if report.Summary["changed"] != 1 {
+		t.Errorf("Expected summary.changed=1, got %d", report.Summary["changed"])
+	}
+	if len(report.Changes) != 1 || report.Changes[0].Path != "name" {
+		t.Errorf("Unexpected changes: %+v", report.Changes)
+	}
+}
+
+func TestStripPathIndexes(t *testing.T) {
+	if got := stripPathIndexes("orders[1].items[0]"); got != "orders.items" {
+		t.Errorf("Expected 'orders.items', got '%s'", got)
+	}
+}
@@ -0,0 +1,165 @@
+package protobuf
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// schemaExtension - поле, объявленное в блоке extend. Имя поля в field имеет вид
+// "[pkg.name]", как в text format, чтобы расширения отличались от обычных полей
+type schemaExtension struct {
+	// extendee - имя расширяемого сообщения в том виде, в каком оно записано в схеме
+	extendee string
+	name     string
+	field    *schemaFieldInfo
+	// scope - сообщение, внутри которого объявлен extend (nil для верхнего уровня)
+	scope *schemaMessageInfo
+	// scopeName - полное имя области extend (сообщения или пакета), от которой разрешаются
+	// имена типов: у расширений из импортированных файлов пакет свой
+	scopeName string
+	// block - порядковый номер блока extend в файле
+	block    int
+	imported bool
+}
+
+func newSchemaExtension(packageName string, extendee string, block int, field *schemaFieldInfo, current *schemaMessageInfo, stack []*schemaMessageInfo) *schemaExtension {
+	// Полное имя расширения включает пакет и сообщения, внутри которых объявлен extend
+	parts := make([]string, 0, len(stack)+3)
+	if packageName != "" {
+		parts = append(parts, packageName)
+	}
+	if current != nil {
+		for _, parent := range stack {
+			if parent != nil {
+				parts = append(parts, parent.messageName)
+			}
+		}
+		parts = append(parts, current.messageName)
+	}
+	scopeName := strings.Join(parts, ".")
+	parts = append(parts, field.fieldName)
+
+	extension := &schemaExtension{
+		extendee:  extendee,
+		name:      field.fieldName,
+		field:     field,
+		scope:     current,
+		scopeName: scopeName,
+		block:     block,
+	}
+	field.fieldName = "[" + strings.Join(parts, ".") + "]"
+	return extension
+}
+
+// extensionsFor возвращает расширения сообщения по номерам полей
+func (s *protoSchema) extensionsFor(message *schemaMessageInfo) map[int]*schemaExtension {
+	result := make(map[int]*schemaExtension)
+	for _, extension := range s.extensions {
+		if s.findMessage(extension.extendee, extension.scopeName) != message {
+			continue
+		}
+		if _, exists := result[extension.field.fieldNum]; !exists {
+			result[extension.field.fieldNum] = extension
+		}
+	}
+	return result
+}
+
+// importPath извлекает путь из объявления `import "a/b.proto";`, `import public "x.proto";`
+func importPath(line string) string {
+	start := strings.IndexAny(line, `"'`)
+	if start < 0 {
+		return ""
+	}
+	end := strings.IndexByte(line[start+1:], line[start])
+	if end < 0 {
+		return ""
+	}
+	return line[start+1 : start+1+end]
+}
+
+// loadImports загружает импортированные файлы и добавляет в схему их расширения, сообщения и
+// перечисления. Типы основного файла имеют приоритет. Импорты ищутся относительно каталога
+// импортирующего файла, затем в include paths парсера и, наконец, в каталогах выше основной
+// схемы; ненайденные (например, стандартные google/protobuf) пропускаются
+func (p *Parser) loadImports(schema *protoSchema, schemaPath string) error {
+	rootDir, err := filepath.Abs(filepath.Dir(schemaPath))
+	if err != nil {
+		return fmt.Errorf("failed to resolve schema directory: %w", err)
+	}
+	visited := make(map[string]bool)
+	if absPath, err := filepath.Abs(schemaPath); err == nil {
+		visited[absPath] = true
+	}
+	return p.mergeImports(schema, schema, filepath.Dir(schemaPath), rootDir, visited)
+}
+
+func (p *Parser) mergeImports(target *protoSchema, current *protoSchema, currentDir string, rootDir string, visited map[string]bool) error {
+	for _, line := range current.imports {
+		path := importPath(line)
+		if path == "" {
+			continue
+		}
+		resolved := resolveImport(path, currentDir, p.includePaths, rootDir)
+		if resolved == "" || visited[resolved] {
+			continue
+		}
+		visited[resolved] = true
+
+		content, err := os.ReadFile(resolved)
+		if err != nil {
+			return fmt.Errorf("failed to read import %s: %w", path, err)
+		}
+		imported, err := p.parseProtoSchema(string(content))
+		if err != nil {
+			return fmt.Errorf("failed to parse import %s: %w", path, err)
+		}
+
+		// Ключи - полные имена, поэтому одноименные сообщения разных пакетов не смешиваются
+		for name, message := range imported.messages {
+			if _, exists := target.messages[name]; !exists {
+				target.messages[name] = message
+			}
+		}
+		for name, enum := range imported.enums {
+			if _, exists := target.enums[name]; !exists {
+				target.enums[name] = enum
+			}
+		}
+		for _, extension := range imported.extensions {
+			extension.imported = true
+			target.extensions = append(target.extensions, extension)
+		}
+
+		if err := p.mergeImports(target, imported, filepath.Dir(resolved), rootDir, visited); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveImport ищет импортированный файл и возвращает его абсолютный путь или "", если файл не найден
+func resolveImport(path string, currentDir string, includePaths []string, rootDir string) string {
+	candidates := []string{filepath.Join(currentDir, path)}
+	for _, dir := range includePaths {
+		candidates = append(candidates, filepath.Join(dir, path))
+	}
+	for dir := rootDir; ; dir = filepath.Dir(dir) {
+		candidates = append(candidates, filepath.Join(dir, path))
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+	for _, candidate := range candidates {
+		info, err := os.Stat(candidate)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		if absPath, err := filepath.Abs(candidate); err == nil {
+			return absPath
+		}
+	}
+	return ""
+}
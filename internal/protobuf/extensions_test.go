@@ -0,0 +1,197 @@
+package protobuf
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeExtensionSchemas(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"base.proto": `syntax = "proto2";
+package shop;
+
+import "ext/audit.proto";
+import "google/protobuf/descriptor.proto";
+
+message Order {
+  optional string id = 1;
+  extensions 100 to 199;
+}
+
+extend Order {
+  optional int32 priority = 100;
+}
+`,
+		"ext/audit.proto": `syntax = "proto2";
+package audit;
+
+import "base.proto";
+
+message Audit {
+  optional string user = 1;
+
+  extend shop.Order {
+    optional Audit audit = 101;
+    repeated Level levels = 102;
+  }
+
+  enum Level {
+    LOW = 0;
+    HIGH = 1;
+  }
+}
+`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write schema file: %v", err)
+		}
+	}
+	return filepath.Join(dir, "base.proto")
+}
+
+func TestApplySchema_Extensions(t *testing.T) {
+	parser := &Parser{}
+	schemaFile := writeExtensionSchemas(t)
+
+	root := &TreeNode{Name: "root", Type: "message"}
+	root.AddChild(&TreeNode{Name: "field_1", Type: "string", FieldNum: 1, Value: "A-1"})
+	root.AddChild(&TreeNode{Name: "field_100", Type: "int64", FieldNum: 100, Value: "3"})
+	audit := &TreeNode{Name: "field_101", Type: "message_1", FieldNum: 101}
+	audit.AddChild(&TreeNode{Name: "field_1", Type: "string", FieldNum: 1, Value: "bob"})
+	root.AddChild(audit)
+	root.AddChild(&TreeNode{Name: "field_102", Type: "int64", FieldNum: 102, Value: "1"})
+	root.AddChild(&TreeNode{Name: "field_150", Type: "int64", FieldNum: 150, Value: "1"})
+
+	_, report, err := parser.ApplySchemaWithMessage(root, schemaFile, "Order")
+	if err != nil {
+		t.Fatalf("ApplySchemaWithMessage failed: %v", err)
+	}
+
+	expected := []struct {
+		name     string
+		nodeType string
+	}{
+		{"id", "string"},
+		{"[shop.priority]", "int32"},
+		{"[audit.Audit.audit]", "Audit"},
+		{"[audit.Audit.levels]", "int32"},
+		{"field_150", "int64"},
+	}
+	for i, e := range expected {
+		if root.Children[i].Name != e.name || root.Children[i].Type != e.nodeType {
+			t.Errorf("Child %d: expected %s (%s), got %s (%s)", i, e.name, e.nodeType, root.Children[i].Name, root.Children[i].Type)
+		}
+	}
+	if audit.Children[0].Name != "user" {
+		t.Errorf("Expected extension message body to be named from the imported schema, got %s", audit.Children[0].Name)
+	}
+	if !root.Children[3].IsRepeated {
+		t.Error("Expected repeated extension to be marked as repeated")
+	}
+
+	if len(report.Issues) != 1 || report.Issues[0].Kind != SchemaIssueUnknownField || report.Issues[0].FieldNum != 150 {
+		t.Errorf("Expected only field 150 to be unknown, got %+v", report.Issues)
+	}
+}
+
+func TestApplySchema_ImportFromIncludePath(t *testing.T) {
+	schemaDir := t.TempDir()
+	includeDir := t.TempDir()
+	schemaFile := filepath.Join(schemaDir, "order.proto")
+	if err := os.WriteFile(schemaFile, []byte(`syntax = "proto2";
+package shop;
+
+import "common/money.proto";
+
+message Order {
+  optional string id = 1;
+  optional common.Money total = 2;
+}
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(includeDir, "common"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(includeDir, "common", "money.proto"), []byte(`syntax = "proto2";
+package common;
+
+message Money {
+  optional int64 units = 1;
+}
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	newTree := func() (*TreeNode, *TreeNode) {
+		root := &TreeNode{Name: "root", Type: "message"}
+		total := &TreeNode{Name: "field_2", Type: "message_1", FieldNum: 2}
+		total.AddChild(&TreeNode{Name: "field_1", Type: "int64", FieldNum: 1, Value: "5"})
+		root.AddChild(total)
+		return root, total
+	}
+
+	root, total := newTree()
+	if _, _, err := (&Parser{}).ApplySchemaWithMessage(root, schemaFile, "Order"); err != nil {
+		t.Fatalf("ApplySchemaWithMessage failed: %v", err)
+	}
+	if total.Children[0].Name == "units" {
+		t.Fatal("Expected the import not to be found without include paths")
+	}
+
+	parser := &Parser{}
+	parser.SetIncludePaths([]string{includeDir})
+	root, total = newTree()
+	if _, _, err := parser.ApplySchemaWithMessage(root, schemaFile, "Order"); err != nil {
+		t.Fatalf("ApplySchemaWithMessage failed: %v", err)
+	}
+	if total.Children[0].Name != "units" {
+		t.Errorf("Expected the imported message to be resolved from the include path, got %s", total.Children[0].Name)
+	}
+}
+
+func TestSchemaModel_Extensions(t *testing.T) {
+	parser := &Parser{}
+	schemaFile := writeExtensionSchemas(t)
+
+	model, err := parser.LoadSchemaModel(schemaFile)
+	if err != nil {
+		t.Fatalf("LoadSchemaModel failed: %v", err)
+	}
+	if model.FindMessage("Audit") != nil {
+		t.Error("Expected imported messages not to be part of the model")
+	}
+	if len(model.Extensions) != 1 || model.Extensions[0].Extendee != "Order" || model.Extensions[0].Fields[0].Name != "priority" {
+		t.Fatalf("Expected local extend block, got %+v", model.Extensions)
+	}
+
+	if err := model.RenameMessage(model.FindMessage("Order"), "Purchase"); err != nil {
+		t.Fatalf("RenameMessage failed: %v", err)
+	}
+	rendered := model.Render()
+	if !strings.Contains(rendered, "  extensions 100 to 199;\n") {
+		t.Errorf("Expected extension range to be kept, got:\n%s", rendered)
+	}
+	if !strings.Contains(rendered, "extend Purchase {\n  optional int32 priority = 100;\n}\n") {
+		t.Errorf("Expected extend block to follow the rename, got:\n%s", rendered)
+	}
+}
+
+func TestProtoFieldName_Extension(t *testing.T) {
+	node := &TreeNode{Name: "[audit.Audit.audit]"}
+	if name := protoFieldName(node); name != "ext_audit_Audit_audit" {
+		t.Errorf("Expected sanitized extension name, got %s", name)
+	}
+	if name := protoFieldName(&TreeNode{Name: "id"}); name != "id" {
+		t.Errorf("Expected regular names to be kept, got %s", name)
+	}
+}
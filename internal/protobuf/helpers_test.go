@@ -0,0 +1,30 @@
+package protobuf
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeTestSchema записывает схему во временный файл теста и возвращает путь к нему
+func writeTestSchema(t *testing.T, content string) string {
+	t.Helper()
+	schemaFile := filepath.Join(t.TempDir(), "test.proto")
+	if err := os.WriteFile(schemaFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write schema file: %v", err)
+	}
+	return schemaFile
+}
+
+// newTestRoot создает корневое сообщение с указанными дочерними полями
+func newTestRoot(children ...*TreeNode) *TreeNode {
+	root := &TreeNode{
+		Name:     "root",
+		Type:     "message",
+		Children: make([]*TreeNode, 0),
+	}
+	for _, child := range children {
+		root.AddChild(child)
+	}
+	return root
+}
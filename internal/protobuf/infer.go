@@ -0,0 +1,477 @@
+package protobuf
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// SchemaInference накапливает наблюдения о полях по нескольким образцам сообщений
+// одного типа и строит по ним сводную схему
+type SchemaInference struct {
+	root    *inferredMessage
+	samples int
+	// Skipped содержит файлы, которые не удалось декодировать, с причиной
+	Skipped []string
+}
+
+type inferredMessage struct {
+	instances int
+	fields    map[int]*inferredField
+}
+
+type inferredField struct {
+	fieldNum int
+	name     string
+
+	presentIn     int
+	maxPerMessage int
+
+	varints   int
+	fixed     int
+	strings   int
+	messages  int
+	groups    int
+	emptyLen  int
+	binary    int
+	packable  int
+	hasVarint bool
+	minVarint int64
+	maxVarint int64
+
+	nested *inferredMessage
+}
+
+// inferredType - итоговый вывод о поле
+type inferredType struct {
+	protoType  string
+	nested     *inferredMessage
+	repeated   bool
+	packed     bool
+	confidence string
+	notes      []string
+}
+
+func newInferredMessage() *inferredMessage {
+	return &inferredMessage{fields: make(map[int]*inferredField)}
+}
+
+// NewSchemaInference создает пустой набор наблюдений
+func NewSchemaInference() *SchemaInference {
+	return &SchemaInference{root: newInferredMessage()}
+}
+
+// Samples возвращает количество учтенных образцов
+func (si *SchemaInference) Samples() int {
+	return si.samples
+}
+
+// AddSample учитывает дерево очередного образца
+func (si *SchemaInference) AddSample(tree *TreeNode) {
+	ExpandAll(tree)
+	si.samples++
+	si.root.observe(tree)
+}
+
+// InferSchemaFromDir декодирует все файлы каталога как образцы одного сообщения.
+// Файлы, которые не удалось декодировать, пропускаются и перечисляются в Skipped.
+func (p *Parser) InferSchemaFromDir(dir string) (*SchemaInference, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	inference := NewSchemaInference()
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			inference.Skipped = append(inference.Skipped, fmt.Sprintf("%s: %v", entry.Name(), err))
+			continue
+		}
+
+		tree, err := p.ParseRaw(data)
+		if err != nil {
+			inference.Skipped = append(inference.Skipped, fmt.Sprintf("%s: %v", entry.Name(), err))
+			continue
+		}
+		inference.AddSample(tree)
+	}
+
+	if inference.samples == 0 {
+		return nil, fmt.Errorf("no decodable samples found in %s", dir)
+	}
+	return inference, nil
+}
+
+func (m *inferredMessage) observe(node *TreeNode) {
+	m.instances++
+
+	counts := make(map[int]int)
+	for _, child := range node.Children {
+		counts[child.FieldNum]++
+
+		field, ok := m.fields[child.FieldNum]
+		if !ok {
+			field = &inferredField{fieldNum: child.FieldNum, name: fieldPathName(child)}
+			m.fields[child.FieldNum] = field
+		}
+		field.observe(child)
+	}
+
+	for fieldNum, count := range counts {
+		field := m.fields[fieldNum]
+		field.presentIn++
+		if count > field.maxPerMessage {
+			field.maxPerMessage = count
+		}
+	}
+}
+
+func (f *inferredField) observe(node *TreeNode) {
+	if len(node.Children) > 0 || isMessageType(node.Type) || !isScalarUIType(node.Type) {
+		f.messages++
+		if node.IsGroup {
+			f.groups++
+		}
+		if f.nested == nil {
+			f.nested = newInferredMessage()
+		}
+		f.nested.observe(node)
+		return
+	}
+
+	switch node.Type {
+	case "string":
+		value := fmt.Sprintf("%v", node.Value)
+		if value == "" {
+			f.emptyLen++
+			return
+		}
+		f.strings++
+		data := []byte(value)
+		if isBinaryData(data) {
+			f.binary++
+		}
+		if values, ok := decodeVarints(data); ok {
+			f.packable++
+			for _, v := range values {
+				f.addVarint(v)
+			}
+		}
+	case "float", "double":
+		f.fixed++
+	case "bool":
+		f.varints++
+		if node.Value == true {
+			f.addVarint(1)
+		} else {
+			f.addVarint(0)
+		}
+	default:
+		f.varints++
+		if v, err := strconv.ParseInt(fmt.Sprintf("%v", node.Value), 10, 64); err == nil {
+			f.addVarint(v)
+		} else if u, err := strconv.ParseUint(fmt.Sprintf("%v", node.Value), 10, 64); err == nil {
+			f.addVarint(int64(u))
+		}
+	}
+}
+
+func (f *inferredField) addVarint(v int64) {
+	if !f.hasVarint {
+		f.minVarint, f.maxVarint = v, v
+		f.hasVarint = true
+		return
+	}
+	if v < f.minVarint {
+		f.minVarint = v
+	}
+	if v > f.maxVarint {
+		f.maxVarint = v
+	}
+}
+
+func isScalarUIType(t string) bool {
+	switch t {
+	case "string", "bool", "float", "double", "int32", "int64", "uint32", "uint64", "sint32", "sint64":
+		return true
+	default:
+		return false
+	}
+}
+
+// resolve сводит наблюдения поля в тип, метку и уровень уверенности
+func (f *inferredField) resolve(instances int) inferredType {
+	result := inferredType{repeated: f.maxPerMessage > 1, confidence: "high"}
+
+	kinds := 0
+	for _, count := range []int{f.varints, f.fixed, f.strings, f.messages} {
+		if count > 0 {
+			kinds++
+		}
+	}
+
+	// Непечатные length-delimited значения, целиком разбираемые как varint, вместе
+	// с varint-наблюдениями означают упакованное repeated поле
+	packed := f.strings > 0 && f.packable == f.strings && f.binary == f.strings && f.messages == 0 && f.fixed == 0
+
+	switch {
+	case packed:
+		result.protoType = varintProtoType(f)
+		result.repeated = true
+		result.packed = true
+		result.notes = append(result.notes, fmt.Sprintf("packed varints in %d sample(s)", f.strings))
+		if f.varints == 0 {
+			result.confidence = "medium"
+			result.notes = append(result.notes, "could also be bytes")
+		}
+	case f.strings > 0:
+		result.protoType = "string"
+		if f.binary > 0 {
+			result.protoType = "bytes"
+		}
+		if f.messages > 0 {
+			result.notes = append(result.notes, fmt.Sprintf("decoded as submessage in %d occurrence(s)", f.messages))
+		}
+	case f.messages > 0:
+		result.protoType = "message"
+		result.nested = f.nested
+		// Сгенерированные сообщения объявляются отдельно, поэтому группа выводится
+		// обычным сообщением с пометкой: ее wire type отличается
+		if f.groups > 0 {
+			result.confidence = "low"
+			result.notes = append(result.notes, fmt.Sprintf("encoded as group in %d occurrence(s), declare it with the group keyword", f.groups))
+		}
+	case f.varints > 0:
+		result.protoType = varintProtoType(f)
+		result.notes = append(result.notes, fmt.Sprintf("varint values %d..%d", f.minVarint, f.maxVarint))
+	case f.fixed > 0:
+		result.protoType = "double"
+		result.notes = append(result.notes, "fixed-width value, could also be fixed64/sfixed64/float")
+	default:
+		result.protoType = "bytes"
+		result.confidence = "low"
+		result.notes = append(result.notes, "only empty values observed")
+	}
+
+	if kinds > 1 && !packed {
+		result.confidence = "low"
+		result.notes = append(result.notes, fmt.Sprintf("conflicting wire types: %d varint, %d fixed, %d length-delimited, %d message",
+			f.varints, f.fixed, f.strings, f.messages))
+	} else if f.presentIn < 2 && instances > 1 && result.confidence == "high" {
+		result.confidence = "medium"
+	}
+
+	if result.repeated && !result.packed {
+		result.notes = append(result.notes, fmt.Sprintf("up to %d per message", f.maxPerMessage))
+	}
+	return result
+}
+
+func varintProtoType(f *inferredField) string {
+	switch {
+	case f.minVarint >= 0 && f.maxVarint <= 1:
+		return "bool"
+	case f.minVarint >= math.MinInt32 && f.maxVarint <= math.MaxInt32:
+		return "int32"
+	default:
+		return "int64"
+	}
+}
+
+// GenerateProto строит proto2 схему. Структурно одинаковые сообщения объявляются один раз.
+func (si *SchemaInference) GenerateProto() string {
+	g := &inferenceGenerator{
+		names:      make(map[string]string),
+		usedNames:  map[string]bool{"Message": true},
+		signatures: make(map[*inferredMessage]string),
+	}
+
+	g.names[g.signature(si.root)] = "Message"
+	g.writeMessage("Message", si.root)
+
+	var builder strings.Builder
+	builder.WriteString("syntax = \"proto2\";\n\n")
+	builder.WriteString(fmt.Sprintf("// Inferred from %d sample(s)\n", si.samples))
+	builder.WriteString(strings.Join(g.definitions, "\n"))
+	return builder.String()
+}
+
+type inferenceGenerator struct {
+	names       map[string]string
+	usedNames   map[string]bool
+	signatures  map[*inferredMessage]string
+	definitions []string
+	counter     int
+}
+
+// signature описывает структуру сообщения: номера, метки и типы полей, включая вложенные
+func (g *inferenceGenerator) signature(m *inferredMessage) string {
+	if sig, ok := g.signatures[m]; ok {
+		return sig
+	}
+
+	parts := make([]string, 0, len(m.fields))
+	for _, fieldNum := range sortedFieldNums(m) {
+		field := m.fields[fieldNum]
+		resolved := field.resolve(m.instances)
+		typeSig := resolved.protoType
+		if resolved.nested != nil {
+			typeSig = "{" + g.signature(resolved.nested) + "}"
+		}
+		parts = append(parts, fmt.Sprintf("%d:%t:%t:%s", fieldNum, resolved.repeated, resolved.packed, typeSig))
+	}
+
+	sig := strings.Join(parts, ";")
+	g.signatures[m] = sig
+	return sig
+}
+
+func (g *inferenceGenerator) messageName(m *inferredMessage, field *inferredField) (string, bool) {
+	sig := g.signature(m)
+	if name, ok := g.names[sig]; ok {
+		return name, false
+	}
+
+	name := ""
+	if !strings.HasPrefix(field.name, "field_") {
+		name = camelCaseName(field.name)
+	}
+	if name == "" || g.usedNames[name] {
+		for {
+			g.counter++
+			name = fmt.Sprintf("Message%d", g.counter)
+			if !g.usedNames[name] {
+				break
+			}
+		}
+	}
+
+	g.usedNames[name] = true
+	g.names[sig] = name
+	return name, true
+}
+
+func (g *inferenceGenerator) writeMessage(name string, m *inferredMessage) {
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("message %s {\n", name))
+
+	type pendingMessage struct {
+		name    string
+		message *inferredMessage
+	}
+	var pending []pendingMessage
+
+	for _, fieldNum := range sortedFieldNums(m) {
+		field := m.fields[fieldNum]
+		resolved := field.resolve(m.instances)
+
+		protoType := resolved.protoType
+		if resolved.nested != nil {
+			nestedName, isNew := g.messageName(resolved.nested, field)
+			protoType = nestedName
+			if isNew {
+				pending = append(pending, pendingMessage{name: nestedName, message: resolved.nested})
+			}
+		}
+
+		label := "optional"
+		if resolved.repeated {
+			label = "repeated"
+		}
+		options := ""
+		if resolved.packed {
+			options = " [packed = true]"
+		}
+
+		comment := fmt.Sprintf("confidence: %s; present in %d/%d", resolved.confidence, field.presentIn, m.instances)
+		if len(resolved.notes) > 0 {
+			comment += "; " + strings.Join(resolved.notes, "; ")
+		}
+		builder.WriteString(fmt.Sprintf("  // %s\n", comment))
+		builder.WriteString(fmt.Sprintf("  %s %s %s = %d%s;\n", label, protoType, field.name, field.fieldNum, options))
+	}
+	builder.WriteString("}\n")
+	g.definitions = append(g.definitions, builder.String())
+
+	for _, p := range pending {
+		g.writeMessage(p.name, p.message)
+	}
+}
+
+func sortedFieldNums(m *inferredMessage) []int {
+	nums := make([]int, 0, len(m.fields))
+	for fieldNum := range m.fields {
+		nums = append(nums, fieldNum)
+	}
+	sort.Ints(nums)
+	return nums
+}
+
+func camelCaseName(name string) string {
+	var builder strings.Builder
+	upper := true
+	for _, r := range name {
+		if r == '_' || r == '.' || r == '-' {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}
+
+func isBinaryData(data []byte) bool {
+	if !utf8.Valid(data) {
+		return true
+	}
+	for _, b := range data {
+		if b < 0x20 && b != '\n' && b != '\r' && b != '\t' {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeVarints разбирает байты как последовательность varint; ok=false, если байты
+// не образуют целое число varint
+func decodeVarints(data []byte) ([]int64, bool) {
+	if len(data) == 0 {
+		return nil, false
+	}
+
+	values := make([]int64, 0)
+	var value uint64
+	shift := uint(0)
+	for i, b := range data {
+		if shift >= 64 {
+			return nil, false
+		}
+		value |= uint64(b&0x7f) << shift
+		if b&0x80 == 0 {
+			values = append(values, int64(value))
+			value, shift = 0, 0
+			continue
+		}
+		shift += 7
+		if i == len(data)-1 {
+			return nil, false
+		}
+	}
+	return values, true
+}
@@ -0,0 +1,106 @@
+package protobuf
+
+import (
+	"strings"
+	"testing"
+)
+
+func newInferSample(count string, tags []string, cities ...string) *TreeNode {
+	root := newTestRoot(&TreeNode{Name: "field_1", Type: "int64", FieldNum: 1, Value: count})
+	for _, tag := range tags {
+		root.AddChild(&TreeNode{Name: "field_2", Type: "string", FieldNum: 2, Value: tag})
+	}
+	for _, city := range cities {
+		root.AddChild(&TreeNode{
+			Name: "field_3", Type: "message_1", FieldNum: 3,
+			Children: []*TreeNode{
+				{Name: "field_1", Type: "string", FieldNum: 1, Value: city},
+			},
+		})
+		root.AddChild(&TreeNode{
+			Name: "field_4", Type: "message_2", FieldNum: 4,
+			Children: []*TreeNode{
+				{Name: "field_1", Type: "string", FieldNum: 1, Value: city},
+			},
+		})
+	}
+	return root
+}
+
+func TestSchemaInference_MergesObservations(t *testing.T) {
+	inference := NewSchemaInference()
+	inference.AddSample(newInferSample("5", []string{"a"}, "Moscow"))
+	inference.AddSample(newInferSample("70000", []string{"a", "b"}, "Kazan"))
+	inference.AddSample(newInferSample("-3", nil))
+
+	proto := inference.GenerateProto()
+
+	if !strings.Contains(proto, "// Inferred from 3 sample(s)") {
+		t.Errorf("Expected sample count comment, got:\n%s", proto)
+	}
+	if !strings.Contains(proto, "optional int32 field_1 = 1;") {
+		t.Errorf("Expected signed varint range to give int32, got:\n%s", proto)
+	}
+	if !strings.Contains(proto, "varint values -3..70000") {
+		t.Errorf("Expected varint range comment, got:\n%s", proto)
+	}
+	if !strings.Contains(proto, "repeated string field_2 = 2;") {
+		t.Errorf("Expected field 2 to be repeated string, got:\n%s", proto)
+	}
+	if !strings.Contains(proto, "confidence: high; present in 2/3; up to 2 per message") {
+		t.Errorf("Expected confidence comment for field 2, got:\n%s", proto)
+	}
+
+	// Поля 3 и 4 имеют одинаковую структуру и должны ссылаться на одно сообщение
+	if !strings.Contains(proto, "optional Message1 field_3 = 3;") || !strings.Contains(proto, "optional Message1 field_4 = 4;") {
+		t.Errorf("Expected structurally identical messages to be deduplicated, got:\n%s", proto)
+	}
+	if strings.Count(proto, "message Message1 {") != 1 || strings.Contains(proto, "Message2") {
+		t.Errorf("Expected a single Message1 definition, got:\n%s", proto)
+	}
+}
+
+func TestSchemaInference_PackedAndConflicts(t *testing.T) {
+	inference := NewSchemaInference()
+	inference.AddSample(newTestRoot(
+		&TreeNode{Name: "field_1", Type: "string", FieldNum: 1, Value: "\x01\x02\xac\x02"},
+		&TreeNode{Name: "field_2", Type: "string", FieldNum: 2, Value: "text"},
+	))
+	inference.AddSample(newTestRoot(
+		&TreeNode{Name: "field_1", Type: "int64", FieldNum: 1, Value: "7"},
+		&TreeNode{Name: "field_2", Type: "double", FieldNum: 2, Value: "1.5"},
+	))
+
+	proto := inference.GenerateProto()
+
+	if !strings.Contains(proto, "repeated int32 field_1 = 1 [packed = true];") {
+		t.Errorf("Expected packed repeated field 1, got:\n%s", proto)
+	}
+	if !strings.Contains(proto, "confidence: low") || !strings.Contains(proto, "conflicting wire types") {
+		t.Errorf("Expected low confidence for conflicting field 2, got:\n%s", proto)
+	}
+}
+
+func TestSchemaInference_StringDecodedAsMessage(t *testing.T) {
+	inference := NewSchemaInference()
+	inference.AddSample(newTestRoot(&TreeNode{Name: "field_1", Type: "string", FieldNum: 1, Value: "hello world"}))
+	inference.AddSample(newTestRoot(&TreeNode{
+		Name: "field_1", Type: "message_1", FieldNum: 1,
+		Children: []*TreeNode{{Name: "field_13", Type: "int64", FieldNum: 13, Value: "100"}},
+	}))
+
+	proto := inference.GenerateProto()
+	if !strings.Contains(proto, "optional string field_1 = 1;") || !strings.Contains(proto, "decoded as submessage in 1 occurrence(s)") {
+		t.Errorf("Expected string field with submessage note, got:\n%s", proto)
+	}
+}
+
+func TestDecodeVarints(t *testing.T) {
+	values, ok := decodeVarints([]byte{0x01, 0xac, 0x02})
+	if !ok || len(values) != 2 || values[0] != 1 || values[1] != 300 {
+		t.Errorf("Expected [1 300], got %v (ok=%v)", values, ok)
+	}
+	if _, ok := decodeVarints([]byte{0x01, 0x80}); ok {
+		t.Error("Expected truncated varint to fail")
+	}
+}
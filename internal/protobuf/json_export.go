@@ -12,6 +12,7 @@ func TreeNodeToJSON(node *TreeNode) (map[string]interface{}, error) {
 	if node == nil {
 		return nil, fmt.Errorf("node is nil")
 	}
+	ExpandAll(node)
 
 	result := make(map[string]interface{})
 
@@ -0,0 +1,269 @@
+package protobuf
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"sync/atomic"
+)
+
+// LazyDecodeThreshold - размер данных, начиная с которого файл декодируется лениво
+const LazyDecodeThreshold = 32 << 20
+
+// lazyCheckInterval - через сколько полей проверяется отмена и сообщается прогресс
+const lazyCheckInterval = 1024
+
+// lazyPayload - еще не разобранное тело вложенного сообщения или группы. Срез ссылается на
+// исходные данные и не копируется
+type lazyPayload struct {
+	data []byte
+	// messageCounter общий для всего дерева и его копий (Clone): типы message_N остаются
+	// уникальными при раскрытии в любом порядке. Копию могут раскрывать в фоне, пока
+	// интерфейс раскрывает оригинал, поэтому счетчик атомарный
+	messageCounter *atomic.Int64
+}
+
+// DecodeLazy декодирует сообщение без protoc. Разбирается только верхний уровень, вложенные
+// сообщения и группы остаются в виде ссылок на исходные данные и разбираются при первом
+// обращении через Expand. Типы значений определяются так же, как по выводу --decode_raw;
+// сообщения нумеруются в порядке разбора, а не обхода дерева
+func DecodeLazy(ctx context.Context, data []byte, progress ProgressFunc) (*TreeNode, error) {
+	root := &TreeNode{Name: "root", Type: "message", Children: make([]*TreeNode, 0)}
+	messageCounter := &atomic.Int64{}
+	if progress != nil {
+		progress(0)
+	}
+	if err := decodeLazyFields(ctx, root, data, messageCounter, progress); err != nil {
+		return nil, err
+	}
+	if progress != nil {
+		progress(1)
+	}
+	return root, nil
+}
+
+func decodeLazyFields(ctx context.Context, node *TreeNode, data []byte, messageCounter *atomic.Int64, progress ProgressFunc) error {
+	fields, err := scanWireFields(data)
+	if err != nil {
+		return fmt.Errorf("ошибка декодирования protobuf: %w", err)
+	}
+
+	counts := make(map[int]int)
+	for _, field := range fields {
+		counts[field.number]++
+	}
+
+	node.Children = make([]*TreeNode, 0, len(fields))
+	for i, field := range fields {
+		if i%lazyCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if progress != nil && len(data) > 0 {
+				progress(float64(field.end) / float64(len(data)))
+			}
+		}
+
+		child := lazyFieldNode(field, messageCounter)
+		child.IsRepeated = counts[field.number] > 1
+		node.AddChild(child)
+	}
+	return nil
+}
+
+// lazyFieldNode создает узел поля. Скалярные значения приводятся к тексту, который вывел бы
+// protoc --decode_raw, и разбираются тем же parseLine, поэтому типы совпадают с обычным декодированием
+func lazyFieldNode(field wireField, messageCounter *atomic.Int64) *TreeNode {
+	var text string
+	switch field.wireType {
+	case wireVarint:
+		value, _ := binary.Uvarint(field.payload)
+		text = fmt.Sprintf("%d", value)
+	case wireFixed64:
+		text = fmt.Sprintf("0x%016x", binary.LittleEndian.Uint64(field.payload))
+	case wireFixed32:
+		text = fmt.Sprintf("0x%08x", binary.LittleEndian.Uint32(field.payload))
+	case wireStartGroup:
+		node := newLazyMessage(field, messageCounter)
+		node.IsGroup = true
+		node.wireType = wireTypeGroup
+		return node
+	case wireBytes:
+		// Как и protoc, непустое значение, которое разбирается как сообщение, считается сообщением
+		if len(field.payload) > 0 {
+			if _, err := scanWireFields(field.payload); err == nil {
+				return newLazyMessage(field, messageCounter)
+			}
+		}
+		return &TreeNode{
+			Name:     fmt.Sprintf("field_%d", field.number),
+			Type:     "string",
+			FieldNum: field.number,
+			Value:    string(field.payload),
+			wireType: wireTypeLen,
+		}
+	}
+
+	var parser Parser
+	return parser.parseLine(fmt.Sprintf("%d: %s", field.number, text))
+}
+
+func newLazyMessage(field wireField, messageCounter *atomic.Int64) *TreeNode {
+	return &TreeNode{
+		Name:     fmt.Sprintf("field_%d", field.number),
+		Type:     fmt.Sprintf("message_%d", messageCounter.Add(1)),
+		FieldNum: field.number,
+		Children: make([]*TreeNode, 0),
+		lazy:     &lazyPayload{data: field.payload, messageCounter: messageCounter},
+		wireType: wireTypeLen,
+	}
+}
+
+// lazySchema - применение схемы, отложенное до разбора ленивого узла: применение схемы не
+// раскрывает дерево, а привязывает сообщение схемы к еще не разобранным узлам
+type lazySchema struct {
+	parser  *Parser
+	message *schemaMessageInfo
+	schema  *protoSchema
+	path    string
+	// rules - бизнес-правила, которые проверяются после применения схемы; nil, если
+	// применение шло без их проверки
+	rules ConstraintRules
+	// report получает расхождения, найденные при разборе. У копий дерева он не задан: отчет
+	// относится к дереву, к которому применялась схема, а копии раскрываются в фоне
+	report *SchemaReport
+}
+
+// detached возвращает привязку схемы для копии узла, без отчета
+func (s *lazySchema) detached() *lazySchema {
+	if s == nil {
+		return nil
+	}
+	copied := *s
+	copied.report = nil
+	return &copied
+}
+
+// apply применяет отложенную схему к только что разобранным полям узла
+func (s *lazySchema) apply(node *TreeNode) {
+	report := s.report
+	if report == nil {
+		report = newSchemaReport()
+	} else if report.Deferred > 0 {
+		report.Deferred--
+	}
+	s.parser.applySchemaToTree(node, s.message, s.schema, s.path, report)
+	if s.rules != nil {
+		s.parser.evaluateConstraints(node, s.message, s.schema, s.rules, s.path, report)
+	}
+}
+
+// forgetSchema снимает с ленивых узлов поддерева схему, привязанную прежним применением:
+// новая схема может не дойти до них, если их поле в ней не описано
+func forgetSchema(node *TreeNode) {
+	node.schema = nil
+	for _, child := range node.Children {
+		forgetSchema(child)
+	}
+}
+
+// IsLazy сообщает, что дочерние поля узла еще не разобраны
+func (n *TreeNode) IsLazy() bool {
+	return n != nil && n.lazy != nil
+}
+
+// Expand разбирает дочерние поля ленивого узла и применяет к ним схему, привязанную к узлу
+// при применении схемы. Для остальных узлов ничего не делает. Тело проверено при создании
+// узла, поэтому разбор не может завершиться ошибкой
+func (n *TreeNode) Expand() {
+	if !n.IsLazy() {
+		return
+	}
+	lazy := n.lazy
+	n.lazy = nil
+	if err := decodeLazyFields(context.Background(), n, lazy.data, lazy.messageCounter, nil); err != nil {
+		n.lazy = lazy
+		return
+	}
+	if schema := n.schema; schema != nil {
+		n.schema = nil
+		schema.apply(n)
+	}
+}
+
+// fields возвращает дочерние поля узла. Поля ленивого узла разбираются во временную копию
+// и в дереве не остаются, поэтому обход всего дерева не держит его разобранным целиком
+func (n *TreeNode) fields() []*TreeNode {
+	if !n.IsLazy() {
+		return n.Children
+	}
+	decoded := *n
+	decoded.schema = n.schema.detached()
+	decoded.Expand()
+	return decoded.Children
+}
+
+// expandedCopy возвращает копию поддерева с разобранными ленивыми узлами. Нужна операциям,
+// которые выводят все поля (текстовый формат): исходное дерево при этом не раскрывается
+func expandedCopy(node *TreeNode) *TreeNode {
+	if node == nil {
+		return nil
+	}
+	copied := *node
+	copied.schema = nil
+	copied.Children = make([]*TreeNode, 0, len(node.Children))
+	for _, child := range node.fields() {
+		copied.Children = append(copied.Children, expandedCopy(child))
+	}
+	copied.lazy = nil
+	return &copied
+}
+
+// lazyAsBytes возвращает копию поддерева для кодирования, в которой неразобранные сообщения
+// записаны как bytes с исходным телом: на проводе они кодируются так же. Разбираются только
+// группы и сообщения, у которых есть разобранные соседи с тем же номером поля, - в генерируемой
+// для protoc схеме у всех вхождений поля один тип
+func lazyAsBytes(node *TreeNode) *TreeNode {
+	if node == nil {
+		return nil
+	}
+	children := node.fields()
+	decoded := make(map[int]bool)
+	for _, child := range children {
+		if !child.IsLazy() || child.IsGroup {
+			decoded[child.FieldNum] = true
+		}
+	}
+
+	copied := *node
+	copied.schema = nil
+	copied.lazy = nil
+	copied.Children = make([]*TreeNode, 0, len(children))
+	for _, child := range children {
+		if child.IsLazy() && !decoded[child.FieldNum] {
+			copied.Children = append(copied.Children, &TreeNode{
+				Name:       child.Name,
+				Type:       "bytes",
+				Value:      child.lazy.data,
+				FieldNum:   child.FieldNum,
+				IsRepeated: child.IsRepeated,
+				wireType:   wireTypeLen,
+			})
+			continue
+		}
+		copied.Children = append(copied.Children, lazyAsBytes(child))
+	}
+	return &copied
+}
+
+// ExpandAll раскрывает все ленивые узлы поддерева. Вызывается операциями, которым нужно
+// сопоставить деревья целиком: сравнением, слиянием и выводом схемы по образцам
+func ExpandAll(node *TreeNode) {
+	if node == nil {
+		return
+	}
+	node.Expand()
+	for _, child := range node.Children {
+		ExpandAll(child)
+	}
+}
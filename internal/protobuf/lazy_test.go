@@ -0,0 +1,238 @@
+package protobuf
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// 1: 150, 2: { 1: "hi!", 3: { 1: 1 } }, 3: "a\xff", 4: 0x00000001 (fixed32), 5: group { 1: 2 }, 1: 7
+var lazyTestData = []byte{
+	0x08, 0x96, 0x01,
+	0x12, 0x09, 0x0a, 0x03, 'h', 'i', '!', 0x1a, 0x02, 0x08, 0x01,
+	0x1a, 0x02, 'a', 0xff,
+	0x25, 0x01, 0x00, 0x00, 0x00,
+	0x2b, 0x08, 0x02, 0x2c,
+	0x08, 0x07,
+}
+
+func TestDecodeLazy_DecodesOnExpand(t *testing.T) {
+	tree, err := DecodeLazy(context.Background(), lazyTestData, nil)
+	if err != nil {
+		t.Fatalf("DecodeLazy failed: %v", err)
+	}
+	if len(tree.Children) != 6 {
+		t.Fatalf("Expected 6 top-level fields, got %d", len(tree.Children))
+	}
+
+	message := tree.Children[1]
+	if !message.IsLazy() || len(message.Children) != 0 || !message.IsMessage() {
+		t.Fatalf("Expected nested message to stay undecoded, got %+v", message)
+	}
+	if !tree.Children[0].IsRepeated || !tree.Children[5].IsRepeated {
+		t.Error("Expected field 1 to be marked repeated")
+	}
+	if tree.Children[2].Type != "string" || tree.Children[2].Value != "a\xff" {
+		t.Errorf("Expected non-message bytes to decode as string, got %s %#v", tree.Children[2].Type, tree.Children[2].Value)
+	}
+	if !tree.Children[4].IsGroup || !tree.Children[4].IsLazy() {
+		t.Error("Expected group to be lazy and marked as group")
+	}
+
+	message.Expand()
+	if message.IsLazy() || len(message.Children) != 2 || message.Children[0].Value != "hi!" {
+		t.Fatalf("Expected Expand to decode children, got %+v", message.Children)
+	}
+	if !message.Children[1].IsLazy() {
+		t.Error("Expected deeper messages to stay lazy after expanding the parent")
+	}
+
+	clone := tree.Clone()
+	if !clone.Children[4].IsLazy() {
+		t.Error("Expected Clone to keep lazy nodes")
+	}
+}
+
+func TestDecodeLazy_MatchesDecodeRawText(t *testing.T) {
+	parser := &Parser{}
+	expected, _ := parser.parseProtocOutput(`1: 150
+2 {
+  1: "hi!"
+  3 {
+    1: 1
+  }
+}
+3: "a\377"
+4: 0x00000001
+5 {
+  1: 2
+}
+1: 7`)
+
+	tree, err := DecodeLazy(context.Background(), lazyTestData, nil)
+	if err != nil {
+		t.Fatalf("DecodeLazy failed: %v", err)
+	}
+
+	serializer := &Serializer{}
+	if got, want := serializer.TreeToTextFormat(tree), serializer.TreeToTextFormat(expected); got != want {
+		t.Errorf("Expected fully expanded lazy tree to match decode_raw, got:\n%s\nwant:\n%s", got, want)
+	}
+	if tree.Children[3].Type != expected.Children[3].Type || tree.Children[0].Type != expected.Children[0].Type {
+		t.Errorf("Expected scalar types to match decode_raw, got %s and %s", tree.Children[3].Type, tree.Children[0].Type)
+	}
+}
+
+func TestDecodeLazy_CloneExpandsConcurrently(t *testing.T) {
+	tree, err := DecodeLazy(context.Background(), lazyTestData, nil)
+	if err != nil {
+		t.Fatalf("DecodeLazy failed: %v", err)
+	}
+
+	// Копия раскрывается в фоне (например, при определении типа), пока интерфейс
+	// раскрывает оригинал
+	clone := tree.Clone()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ExpandAll(clone)
+	}()
+	ExpandAll(tree)
+	wg.Wait()
+
+	// Сообщения, разобранные после копирования, нумеруются общим счетчиком и не совпадают
+	treeType, cloneType := tree.Children[1].Children[1].Type, clone.Children[1].Children[1].Type
+	if treeType == cloneType || !strings.HasPrefix(treeType, "message_") || !strings.HasPrefix(cloneType, "message_") {
+		t.Errorf("Expected distinct message types for messages expanded after Clone, got %s and %s", treeType, cloneType)
+	}
+	if clone.Children[1].Type != tree.Children[1].Type {
+		t.Errorf("Expected Clone to keep message types, got %s and %s", clone.Children[1].Type, tree.Children[1].Type)
+	}
+}
+
+func TestDecodeLazy_Errors(t *testing.T) {
+	if _, err := DecodeLazy(context.Background(), []byte{0x0b, 0x08}, nil); err == nil {
+		t.Error("Expected error for malformed data")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := DecodeLazy(ctx, lazyTestData, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestApplySchemaWithMessage_AppliesToLazyMessagesOnExpand(t *testing.T) {
+	parser, err := NewParser()
+	if err != nil {
+		t.Fatalf("Failed to create parser: %v", err)
+	}
+	schemaPath := writeTestSchema(t, `syntax = "proto2";
+message Root {
+  optional int64 id = 1;
+  optional Inner inner = 2;
+}
+message Inner {
+  optional string name = 1;
+  required int64 count = 4;
+}
+`)
+
+	// 1: 150, 2: { 1: "hi!" }
+	data := []byte{0x08, 0x96, 0x01, 0x12, 0x05, 0x0a, 0x03, 'h', 'i', '!'}
+	tree, err := DecodeLazy(context.Background(), data, nil)
+	if err != nil {
+		t.Fatalf("DecodeLazy failed: %v", err)
+	}
+
+	tree, report, err := parser.ApplySchemaWithMessage(tree, schemaPath, "Root")
+	if err != nil {
+		t.Fatalf("ApplySchemaWithMessage failed: %v", err)
+	}
+
+	inner := tree.Children[1]
+	if !inner.IsLazy() {
+		t.Fatal("Expected applying a schema to keep the nested message lazy")
+	}
+	if inner.Name != "inner" || inner.Type != "Inner" {
+		t.Errorf("Expected the lazy node to be named by the schema, got %s (%s)", inner.Name, inner.Type)
+	}
+	if report.Deferred != 1 || report.HasIssues() {
+		t.Fatalf("Expected one deferred message and no issues, got %d deferred and %v", report.Deferred, report.Issues)
+	}
+
+	// Копия раскрывается со схемой, но не пишет в отчет оригинала
+	clone := tree.Clone()
+	clone.Children[1].Expand()
+	if name := clone.Children[1].Children[0].Name; name != "name" {
+		t.Errorf("Expected the clone to apply the schema on expand, got field %s", name)
+	}
+	if report.Deferred != 1 || report.HasIssues() {
+		t.Errorf("Expected expanding a clone to leave the report unchanged, got %d deferred and %v", report.Deferred, report.Issues)
+	}
+
+	inner.Expand()
+	if name, typ := inner.Children[0].Name, inner.Children[0].Type; name != "name" || typ != "string" {
+		t.Errorf("Expected field 'name' of type string after expand, got %s (%s)", name, typ)
+	}
+	if report.Deferred != 0 {
+		t.Errorf("Expected no deferred messages after expand, got %d", report.Deferred)
+	}
+	if len(report.Issues) != 1 || report.Issues[0].Kind != SchemaIssueMissingRequired || report.Issues[0].Node != inner {
+		t.Errorf("Expected the missing required field of the expanded message in the report, got %v", report.Issues)
+	}
+}
+
+func TestLazyAsBytes_KeepsUndecodedMessagesRaw(t *testing.T) {
+	tree, err := DecodeLazy(context.Background(), lazyTestData, nil)
+	if err != nil {
+		t.Fatalf("DecodeLazy failed: %v", err)
+	}
+
+	encoded := lazyAsBytes(tree)
+	message := encoded.Children[1]
+	if message.IsLazy() || message.Type != "bytes" || len(message.Children) != 0 {
+		t.Fatalf("Expected the undecoded message to become a bytes node, got %s with %d children", message.Type, len(message.Children))
+	}
+	if got, ok := message.Value.([]byte); !ok || string(got) != string(lazyTestData[5:14]) {
+		t.Errorf("Expected the bytes node to carry the raw message payload, got %v", message.Value)
+	}
+	if !tree.Children[1].IsLazy() {
+		t.Error("Expected lazyAsBytes to leave the original tree lazy")
+	}
+
+	// Раскрытое сообщение остается сообщением
+	tree.Children[1].Expand()
+	encoded = lazyAsBytes(tree)
+	if len(encoded.Children[1].Children) != 2 || encoded.Children[1].Children[1].Type != "bytes" {
+		t.Errorf("Expected the expanded message to keep its fields with nested messages as bytes, got %v", encoded.Children[1].Children)
+	}
+	if !tree.Children[1].Children[1].IsLazy() {
+		t.Error("Expected lazyAsBytes to leave nested messages of the original tree lazy")
+	}
+}
+
+func TestSchemaModelForTree_LeavesTreeLazy(t *testing.T) {
+	parser, err := NewParser()
+	if err != nil {
+		t.Fatalf("Failed to create parser: %v", err)
+	}
+	tree, err := DecodeLazy(context.Background(), lazyTestData, nil)
+	if err != nil {
+		t.Fatalf("DecodeLazy failed: %v", err)
+	}
+
+	model, err := parser.SchemaModelForTree(tree, "", "")
+	if err != nil {
+		t.Fatalf("SchemaModelForTree failed: %v", err)
+	}
+	if !tree.Children[1].IsLazy() {
+		t.Error("Expected building a schema model to leave the tree lazy")
+	}
+	if source := model.Render(); strings.Count(source, "message ") < 3 {
+		t.Errorf("Expected the model to describe the nested messages of lazy nodes, got:\n%s", source)
+	}
+}
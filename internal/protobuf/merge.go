@@ -0,0 +1,215 @@
+package protobuf
+
+import (
+	"fmt"
+)
+
+type MergeSide string
+
+const (
+	MergeBase   MergeSide = "base"
+	MergeOurs   MergeSide = "ours"
+	MergeTheirs MergeSide = "theirs"
+)
+
+// MergeConflict - поле, которое изменено по-разному в обеих версиях.
+// До разрешения в результирующем дереве используется вариант Ours.
+type MergeConflict struct {
+	Path     string
+	FieldNum int
+	Base     []*TreeNode
+	Ours     []*TreeNode
+	Theirs   []*TreeNode
+	Resolved bool
+	Choice   MergeSide
+
+	parent *TreeNode
+}
+
+type MergeResult struct {
+	Tree      *TreeNode
+	Conflicts []*MergeConflict
+}
+
+// MergeTrees выполняет трехстороннее слияние: изменения, сделанные только в одной из версий
+// относительно base, применяются автоматически, а поля, измененные в обеих версиях по-разному,
+// попадают в Conflicts. Элементы repeated полей сопоставляются по позиции. Ленивые узлы
+// раскрываются в копиях версий, переданные деревья не изменяются.
+func MergeTrees(base, ours, theirs *TreeNode) *MergeResult {
+	base, ours, theirs = base.Clone(), ours.Clone(), theirs.Clone()
+	ExpandAll(base)
+	ExpandAll(ours)
+	ExpandAll(theirs)
+	result := &MergeResult{Conflicts: make([]*MergeConflict, 0)}
+	result.Tree = mergeMessage("", base, ours, theirs, result)
+	return result
+}
+
+func mergeMessage(path string, base, ours, theirs *TreeNode, result *MergeResult) *TreeNode {
+	template := ours
+	if template == nil {
+		template = theirs
+	}
+	if template == nil {
+		template = base
+	}
+
+	merged := &TreeNode{
+		Name:       template.Name,
+		Type:       template.Type,
+		FieldNum:   template.FieldNum,
+		IsRepeated: template.IsRepeated,
+		Children:   make([]*TreeNode, 0),
+	}
+
+	oursFields, order := groupChildrenByFieldNum(ours, nil)
+	theirsFields, order := groupChildrenByFieldNum(theirs, order)
+	baseFields, order := groupChildrenByFieldNum(base, order)
+
+	for _, fieldNum := range order {
+		baseList := baseFields[fieldNum]
+		oursList := oursFields[fieldNum]
+		theirsList := theirsFields[fieldNum]
+
+		fieldNode := firstNode(oursList, theirsList)
+		if fieldNode == nil {
+			fieldNode = firstNode(baseList, nil)
+		}
+		fieldPath := joinDiffPath(path, fieldPathName(fieldNode))
+
+		switch {
+		case nodeListsEqual(oursList, theirsList):
+			merged.Children = append(merged.Children, cloneNodes(oursList)...)
+		case nodeListsEqual(baseList, oursList):
+			merged.Children = append(merged.Children, cloneNodes(theirsList)...)
+		case nodeListsEqual(baseList, theirsList):
+			merged.Children = append(merged.Children, cloneNodes(oursList)...)
+		case canMergeElementwise(baseList, oursList, theirsList):
+			for i := range oursList {
+				elementPath := fieldPath
+				if len(oursList) > 1 || oursList[i].IsRepeated {
+					elementPath = fmt.Sprintf("%s[%d]", fieldPath, i)
+				}
+				merged.Children = append(merged.Children, mergeMessage(elementPath, baseList[i], oursList[i], theirsList[i], result))
+			}
+		default:
+			merged.Children = append(merged.Children, cloneNodes(oursList)...)
+			result.Conflicts = append(result.Conflicts, &MergeConflict{
+				Path:     fieldPath,
+				FieldNum: fieldNum,
+				Base:     baseList,
+				Ours:     oursList,
+				Theirs:   theirsList,
+				parent:   merged,
+			})
+		}
+	}
+
+	return merged
+}
+
+// canMergeElementwise - все три версии содержат одинаковое число вложенных сообщений,
+// поэтому слияние можно продолжить внутри каждого элемента
+func canMergeElementwise(baseList, oursList, theirsList []*TreeNode) bool {
+	if len(oursList) == 0 || len(baseList) != len(oursList) || len(theirsList) != len(oursList) {
+		return false
+	}
+	for i := range oursList {
+		if !baseList[i].IsMessage() || !oursList[i].IsMessage() || !theirsList[i].IsMessage() {
+			return false
+		}
+	}
+	return true
+}
+
+// Resolve разрешает конфликт, подставляя в результирующее дерево выбранную версию поля
+func (r *MergeResult) Resolve(index int, choice MergeSide) error {
+	if index < 0 || index >= len(r.Conflicts) {
+		return fmt.Errorf("conflict index %d out of range", index)
+	}
+
+	conflict := r.Conflicts[index]
+	var chosen []*TreeNode
+	switch choice {
+	case MergeBase:
+		chosen = conflict.Base
+	case MergeOurs:
+		chosen = conflict.Ours
+	case MergeTheirs:
+		chosen = conflict.Theirs
+	default:
+		return fmt.Errorf("unknown merge side %q", choice)
+	}
+
+	replaceFieldNodes(conflict.parent, conflict.FieldNum, cloneNodes(chosen))
+	conflict.Resolved = true
+	conflict.Choice = choice
+	return nil
+}
+
+// UnresolvedCount возвращает число конфликтов, которые еще не разрешены
+func (r *MergeResult) UnresolvedCount() int {
+	count := 0
+	for _, conflict := range r.Conflicts {
+		if !conflict.Resolved {
+			count++
+		}
+	}
+	return count
+}
+
+// replaceFieldNodes заменяет все узлы с номером поля fieldNum на replacement,
+// сохраняя позицию первого из них среди остальных полей
+func replaceFieldNodes(parent *TreeNode, fieldNum int, replacement []*TreeNode) {
+	children := make([]*TreeNode, 0, len(parent.Children)+len(replacement))
+	inserted := false
+	for _, child := range parent.Children {
+		if child.FieldNum != fieldNum {
+			children = append(children, child)
+			continue
+		}
+		if !inserted {
+			children = append(children, replacement...)
+			inserted = true
+		}
+	}
+	if !inserted {
+		children = append(children, replacement...)
+	}
+	parent.Children = children
+}
+
+func nodeListsEqual(a, b []*TreeNode) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !nodesEqual(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// nodesEqual сравнивает узлы по содержимому. Номера сгенерированных типов сообщений
+// (message_N) не учитываются, так как зависят от порядка декодирования.
+func nodesEqual(a, b *TreeNode) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if a.FieldNum != b.FieldNum || a.IsMessage() != b.IsMessage() {
+		return false
+	}
+	if a.IsMessage() {
+		return nodeListsEqual(a.Children, b.Children)
+	}
+	return a.Type == b.Type && valueToDiffString(a.Value) == valueToDiffString(b.Value)
+}
+
+func cloneNodes(nodes []*TreeNode) []*TreeNode {
+	cloned := make([]*TreeNode, 0, len(nodes))
+	for _, node := range nodes {
+		cloned = append(cloned, node.Clone())
+	}
+	return cloned
+}
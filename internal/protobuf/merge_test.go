@@ -0,0 +1,170 @@
+package protobuf
+
+import (
+	"context"
+	"testing"
+)
+
+func newMergeTestTree(name, city string, tags ...string) *TreeNode {
+	root := newTestRoot(
+		&TreeNode{Name: "name", Type: "string", FieldNum: 1, Value: name},
+		&TreeNode{
+			Name: "address", Type: "message_1", FieldNum: 2,
+			Children: []*TreeNode{
+				{Name: "city", Type: "string", FieldNum: 1, Value: city},
+			},
+		},
+	)
+	for _, tag := range tags {
+		root.AddChild(&TreeNode{Name: "tags", Type: "string", FieldNum: 3, Value: tag, IsRepeated: true})
+	}
+	return root
+}
+
+func findChildByFieldNum(node *TreeNode, fieldNum int) *TreeNode {
+	for _, child := range node.Children {
+		if child.FieldNum == fieldNum {
+			return child
+		}
+	}
+	return nil
+}
+
+func TestMergeTrees_NonConflictingChanges(t *testing.T) {
+	base := newMergeTestTree("alice", "Moscow", "a")
+	ours := newMergeTestTree("bob", "Moscow", "a")
+	theirs := newMergeTestTree("alice", "Kazan", "a", "b")
+
+	result := MergeTrees(base, ours, theirs)
+	if len(result.Conflicts) != 0 {
+		t.Fatalf("Expected no conflicts, got %d: %+v", len(result.Conflicts), result.Conflicts[0])
+	}
+
+	if name := findChildByFieldNum(result.Tree, 1); name == nil || name.Value != "bob" {
+		t.Errorf("Expected name from ours ('bob'), got %+v", name)
+	}
+	address := findChildByFieldNum(result.Tree, 2)
+	if address == nil || len(address.Children) != 1 || address.Children[0].Value != "Kazan" {
+		t.Errorf("Expected address.city from theirs ('Kazan'), got %+v", address)
+	}
+
+	tagCount := 0
+	for _, child := range result.Tree.Children {
+		if child.FieldNum == 3 {
+			tagCount++
+		}
+	}
+	if tagCount != 2 {
+		t.Errorf("Expected 2 tags from theirs, got %d", tagCount)
+	}
+}
+
+func TestMergeTrees_DoesNotModifyInputs(t *testing.T) {
+	base := newMergeTestTree("alice", "Moscow")
+	ours := newMergeTestTree("bob", "Moscow")
+	theirs := newMergeTestTree("alice", "Kazan")
+
+	result := MergeTrees(base, ours, theirs)
+	result.Tree.Children[0].Value = "changed"
+
+	if ours.Children[0].Value != "bob" {
+		t.Errorf("Expected merge result to be independent from inputs, ours name is now %v", ours.Children[0].Value)
+	}
+
+	lazy, err := DecodeLazy(context.Background(), lazyTestData, nil)
+	if err != nil {
+		t.Fatalf("DecodeLazy failed: %v", err)
+	}
+	MergeTrees(lazy, lazy, lazy)
+	if !lazy.Children[1].IsLazy() {
+		t.Error("Expected merge not to decode lazy nodes of the inputs")
+	}
+}
+
+func TestMergeTrees_ConflictInNestedField(t *testing.T) {
+	base := newMergeTestTree("alice", "Moscow")
+	ours := newMergeTestTree("alice", "Kazan")
+	theirs := newMergeTestTree("alice", "Omsk")
+
+	result := MergeTrees(base, ours, theirs)
+	if len(result.Conflicts) != 1 {
+		t.Fatalf("Expected 1 conflict, got %d", len(result.Conflicts))
+	}
+
+	conflict := result.Conflicts[0]
+	if conflict.Path != "address.city" {
+		t.Errorf("Expected conflict path 'address.city', got '%s'", conflict.Path)
+	}
+	if result.UnresolvedCount() != 1 {
+		t.Errorf("Expected 1 unresolved conflict, got %d", result.UnresolvedCount())
+	}
+
+	address := findChildByFieldNum(result.Tree, 2)
+	if address.Children[0].Value != "Kazan" {
+		t.Errorf("Expected unresolved conflict to keep ours value, got %v", address.Children[0].Value)
+	}
+
+	if err := result.Resolve(0, MergeTheirs); err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if address.Children[0].Value != "Omsk" {
+		t.Errorf("Expected resolved value 'Omsk', got %v", address.Children[0].Value)
+	}
+	if result.UnresolvedCount() != 0 {
+		t.Errorf("Expected all conflicts resolved, got %d", result.UnresolvedCount())
+	}
+}
+
+func TestMergeTrees_RepeatedConflictResolvedWithBase(t *testing.T) {
+	base := newMergeTestTree("alice", "Moscow", "a")
+	ours := newMergeTestTree("alice", "Moscow", "a", "b")
+	theirs := newMergeTestTree("alice", "Moscow", "c")
+
+	result := MergeTrees(base, ours, theirs)
+	if len(result.Conflicts) != 1 || result.Conflicts[0].FieldNum != 3 {
+		t.Fatalf("Expected a conflict on field 3, got %+v", result.Conflicts)
+	}
+
+	if err := result.Resolve(0, MergeBase); err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+
+	tags := make([]interface{}, 0)
+	for _, child := range result.Tree.Children {
+		if child.FieldNum == 3 {
+			tags = append(tags, child.Value)
+		}
+	}
+	if len(tags) != 1 || tags[0] != "a" {
+		t.Errorf("Expected tags [a] after resolving with base, got %v", tags)
+	}
+}
+
+func TestMergeTrees_DeleteVersusModifyConflict(t *testing.T) {
+	base := newMergeTestTree("alice", "Moscow")
+	ours := newMergeTestTree("alice", "Moscow")
+	ours.Children = ours.Children[1:]
+	theirs := newMergeTestTree("carol", "Moscow")
+
+	result := MergeTrees(base, ours, theirs)
+	if len(result.Conflicts) != 1 {
+		t.Fatalf("Expected 1 conflict, got %d", len(result.Conflicts))
+	}
+	if findChildByFieldNum(result.Tree, 1) != nil {
+		t.Error("Expected deleted field to stay deleted until conflict is resolved")
+	}
+
+	if err := result.Resolve(0, MergeTheirs); err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if name := findChildByFieldNum(result.Tree, 1); name == nil || name.Value != "carol" {
+		t.Errorf("Expected name 'carol' after resolution, got %+v", name)
+	}
+}
+
+func TestMergeResult_ResolveInvalid(t *testing.T) {
+	result := MergeTrees(newMergeTestTree("a", "b"), newMergeTestTree("a", "b"), newMergeTestTree("a", "b"))
+	if err := result.Resolve(0, MergeOurs); err == nil {
+		t.Error("Expected error for out of range conflict index")
+	}
+}
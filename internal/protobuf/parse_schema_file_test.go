@@ -143,3 +143,90 @@ message AnotherMessage {
 	}
 }
 
+
+func TestParseProtoSchema_NestedBlocksOptionsAndComments(t *testing.T) {
+	// Закрывающие скобки enum и oneof не завершают сообщение, комментарии в конце строки и
+	// опции поля в квадратных скобках не мешают разбору поля
+	parser := &Parser{}
+	schema, err := parser.parseProtoSchema(`syntax = "proto2";
+
+message Order {
+  enum Status {
+    NEW = 0; // created
+    PAID = 1 [deprecated = true];
+  }
+  oneof payment {
+    string card = 1;
+    string iban = 2;
+  }
+  optional Status status = 3 [default = PAID]; // "quoted // text" is part of the comment
+  optional string note = 4 [default = "a, b // c"];
+  repeated int32 codes = 5 [packed = true];
+  required string id = 6;
+}
+
+message Empty {
+}
+`)
+	if err != nil {
+		t.Fatalf("parseProtoSchema failed: %v", err)
+	}
+
+	if len(schema.topLevelMessages) != 2 || schema.topLevelMessages[0] != "Order" || schema.topLevelMessages[1] != "Empty" {
+		t.Fatalf("Expected top-level messages Order and Empty, got %v", schema.topLevelMessages)
+	}
+	order := schema.messages["Order"]
+	if order == nil || len(order.fields) != 6 {
+		t.Fatalf("Expected fields after enum and oneof blocks to belong to Order, got %d", len(order.fields))
+	}
+
+	status := schema.findEnum("Status", order.fullName)
+	if status == nil || len(status.values) != 2 || status.values[1].name != "PAID" || status.values[1].number != 1 {
+		t.Errorf("Expected nested enum values with comments and options stripped, got %+v", status)
+	}
+
+	fields := make(map[string]*schemaFieldInfo)
+	for _, field := range order.fields {
+		fields[field.fieldName] = field
+	}
+	if fields["card"] == nil || fields["card"].fieldNum != 1 || fields["iban"] == nil {
+		t.Error("Expected oneof fields to belong to the message")
+	}
+	if field := fields["status"]; field == nil || !field.hasDefault || field.defaultValue != "PAID" {
+		t.Errorf("Expected default enum value, got %+v", field)
+	}
+	if field := fields["note"]; field == nil || field.defaultValue != "a, b // c" {
+		t.Errorf("Expected quoted default to keep commas and slashes, got %+v", field)
+	}
+	if field := fields["codes"]; field == nil || !field.isRepeated || field.options["packed"] != "true" {
+		t.Errorf("Expected packed repeated field, got %+v", field)
+	}
+	if field := fields["id"]; field == nil || !field.isRequired {
+		t.Errorf("Expected required field, got %+v", field)
+	}
+}
+
+func TestParseProtoSchema_QualifiedTypeNames(t *testing.T) {
+	// Полные имена типов (.pkg.Outer.Inner) разрешаются по короткому имени среди вложенных
+	// типов текущего сообщения
+	parser := &Parser{}
+	schema, err := parser.parseProtoSchema(`syntax = "proto3";
+package shop;
+
+message Order {
+  message Item {
+    int64 line = 1;
+  }
+  .shop.Order.Item line = 1;
+}
+`)
+	if err != nil {
+		t.Fatalf("parseProtoSchema failed: %v", err)
+	}
+
+	order := schema.messages["shop.Order"]
+	nested := schema.findMessage(".shop.Order.Item", order.fullName)
+	if nested == nil || len(nested.fields) != 1 || nested.fields[0].fieldName != "line" {
+		t.Errorf("Expected qualified name to resolve to the nested message, got %+v", nested)
+	}
+}
@@ -1,7 +1,7 @@
 package protobuf
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -14,6 +14,8 @@ import (
 
 type Parser struct {
 	protocPath string
+	// includePaths - дополнительные каталоги для поиска импортов схем, как -I у protoc
+	includePaths []string
 }
 
 func NewParser() (*Parser, error) {
@@ -27,6 +29,12 @@ func NewParser() (*Parser, error) {
 	}, nil
 }
 
+// SetIncludePaths задает каталоги, в которых ищутся импорты схем. Вызывается до начала
+// работы с парсером
+func (p *Parser) SetIncludePaths(paths []string) {
+	p.includePaths = append([]string(nil), paths...)
+}
+
 func findProtoc() (string, error) {
 	cmd := exec.Command("protoc", "--version")
 	if err := cmd.Run(); err == nil {
@@ -65,102 +73,17 @@ func CheckProtoc() error {
 }
 
 func (p *Parser) ParseRaw(data []byte) (*TreeNode, error) {
-	cmd := exec.Command(p.protocPath, "--decode_raw")
-	cmd.Stdin = bytes.NewReader(data)
-
-	output, err := cmd.Output()
-	if err != nil {
-		return nil, fmt.Errorf("ошибка декодирования protobuf: %w", err)
-	}
-
-	outputStr := string(output)
-	return p.parseProtocOutput(outputStr)
+	return p.ParseRawContext(context.Background(), data, nil)
 }
 
 func (p *Parser) parseProtocOutput(output string) (*TreeNode, error) {
-	lines := strings.Split(strings.TrimSpace(output), "\n")
-	if len(lines) == 0 || strings.TrimSpace(output) == "" {
-		return &TreeNode{
-			Name:     "root",
-			Type:     "message",
-			Children: make([]*TreeNode, 0),
-		}, nil
-	}
-
-	root := &TreeNode{
-		Name:     "root",
-		Type:     "message",
-		Children: make([]*TreeNode, 0),
-	}
-
-	stack := []*TreeNode{root}
-	stackIndents := []int{-1}
-
-	fieldCounts := make(map[string]int)
-
-	for _, line := range lines {
-		originalLine := line
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
-		}
-
-		indent := getIndentLevel(originalLine)
-		trimmedLine := line
-
-		if trimmedLine == "}" {
-			if len(stack) > 1 {
-				stack = stack[:len(stack)-1]
-				stackIndents = stackIndents[:len(stackIndents)-1]
-			}
-			continue
-		}
-
-		for len(stack) > 1 && indent <= stackIndents[len(stackIndents)-1] {
-			stack = stack[:len(stack)-1]
-			stackIndents = stackIndents[:len(stackIndents)-1]
-		}
-
-		if strings.HasSuffix(trimmedLine, "{") {
-			fieldPart := strings.TrimSpace(strings.TrimSuffix(trimmedLine, "{"))
-			fieldNum := parseInt(fieldPart)
-			node := &TreeNode{
-				Name:       fmt.Sprintf("field_%d", fieldNum),
-				Type:       "message",
-				FieldNum:   fieldNum,
-				Children:   make([]*TreeNode, 0),
-				IsRepeated: false,
-			}
-
-			if len(stack) > 0 {
-				stack[len(stack)-1].AddChild(node)
-			}
-			stack = append(stack, node)
-			stackIndents = append(stackIndents, indent)
-			continue
-		}
-
-		node := p.parseLine(trimmedLine)
-		if node != nil {
-			if len(stack) > 0 {
-				parent := stack[len(stack)-1]
-				fieldKey := fmt.Sprintf("%p_%d", parent, node.FieldNum)
-				fieldCounts[fieldKey]++
-				if fieldCounts[fieldKey] > 1 {
-					node.IsRepeated = true
-					for _, child := range parent.Children {
-						if child.FieldNum == node.FieldNum {
-							child.IsRepeated = true
-						}
-					}
-				}
-				parent.AddChild(node)
-			}
+	builder := newRawTextBuilder(p)
+	if strings.TrimSpace(output) != "" {
+		for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+			builder.addLine(line)
 		}
 	}
-
-	renumberMessages(root)
-	return root, nil
+	return builder.finish(), nil
 }
 
 func renumberMessages(root *TreeNode) {
@@ -214,17 +137,32 @@ func (p *Parser) parseLine(line string) *TreeNode {
 		Name:     fmt.Sprintf("field_%d", fieldNum),
 	}
 
-	if strings.HasPrefix(valueStr, "\"") && strings.HasSuffix(valueStr, "\"") {
+	if len(valueStr) >= 2 && strings.HasPrefix(valueStr, "\"") && strings.HasSuffix(valueStr, "\"") {
 		node.Type = "string"
-		node.Value = strings.Trim(valueStr, "\"")
+		node.wireType = wireTypeLen
+		// Значение хранится в виде исходных байтов; если экранирование некорректно,
+		// оставляем текст как есть, чтобы не потерять данные
+		quoted := valueStr[1 : len(valueStr)-1]
+		if data, err := UnescapeText(quoted); err == nil {
+			node.Value = string(data)
+		} else {
+			node.Value = quoted
+		}
 	} else if isHexFloat(valueStr) {
 		node.Type = "double"
 		decimalValue := convertHexFloatToDecimal(valueStr)
 		node.Value = decimalValue
+		// protoc выводит fixed32 восемью шестнадцатеричными цифрами, fixed64 - шестнадцатью
+		node.wireType = wireTypeFixed64
+		if len(valueStr) == len("0x")+8 {
+			node.wireType = wireTypeFixed32
+		}
 	} else if isFloat(valueStr) {
 		node.Type = "double"
 		node.Value = valueStr
+		node.wireType = wireTypeFixed64
 	} else if isInteger(valueStr) {
+		node.wireType = wireTypeVarint
 		normalizedValue := parseSignedNumber(valueStr)
 		if normalizedValue == "0" || normalizedValue == "1" {
 			node.Type = "bool"
@@ -328,84 +266,315 @@ type schemaFieldInfo struct {
 	isRepeated bool
 	isRequired bool
 	isOptional bool
+	// options - опции поля в квадратных скобках ([default = 5, packed = true])
+	options map[string]string
+	// defaultValue - значение опции [default = ...] из proto2 схемы
+	defaultValue string
+	hasDefault   bool
+	// oneof - имя oneof блока, в котором объявлено поле
+	oneof string
+	// isGroup отмечает proto2 группы; fieldType в этом случае - имя вложенного сообщения группы
+	isGroup bool
 }
 
 type schemaMessageInfo struct {
 	messageName string
-	fields      []*schemaFieldInfo
-	messages    map[string]*schemaMessageInfo
+	// fullName - полное имя с пакетом и внешними сообщениями без ведущей точки: pkg.Outer.Inner
+	fullName string
+	fields   []*schemaFieldInfo
+	messages map[string]*schemaMessageInfo
+	enums    map[string]*schemaEnumInfo
+	// nestedOrder и enumOrder сохраняют порядок объявления вложенных типов
+	nestedOrder []string
+	enumOrder   []string
+	// extensionRanges - диапазоны объявлений "extensions 100 to 199;"
+	extensionRanges []string
 }
 
-// ParseSchemaFile парсит proto файл и возвращает список имен сообщений верхнего уровня
-func (p *Parser) ParseSchemaFile(schemaPath string) ([]string, error) {
-	schemaContent, err := os.ReadFile(schemaPath)
-	if err != nil {
-		return nil, fmt.Errorf("ошибка чтения схемы: %w", err)
+type schemaEnumValue struct {
+	name   string
+	number int
+}
+
+type schemaEnumInfo struct {
+	enumName string
+	// fullName - полное имя перечисления, как у schemaMessageInfo
+	fullName string
+	values   []*schemaEnumValue
+}
+
+// protoSchema - результат разбора proto файла
+type protoSchema struct {
+	// messages содержит все сообщения, включая вложенные и импортированные, по полному имени
+	messages map[string]*schemaMessageInfo
+	// topLevelMessages - короткие имена сообщений верхнего уровня в порядке объявления
+	topLevelMessages []string
+	// enums содержит все перечисления по полному имени
+	enums         map[string]*schemaEnumInfo
+	topLevelEnums []string
+	syntax        string
+	packageName   string
+	imports       []string
+	// extensions - поля из блоков extend этого файла и импортированных файлов
+	extensions []*schemaExtension
+}
+
+// declarationValue возвращает значение объявления верхнего уровня: `package a.b;` -> "a.b",
+// `syntax = "proto3";` -> "proto3"
+func declarationValue(line string) string {
+	value := strings.TrimSuffix(strings.TrimSpace(line), ";")
+	if idx := strings.Index(value, "="); idx >= 0 {
+		value = value[idx+1:]
+	} else if idx := strings.IndexAny(value, " \t"); idx >= 0 {
+		value = value[idx+1:]
 	}
+	return strings.Trim(strings.TrimSpace(value), "\"'")
+}
 
-	_, topLevelMessages, err := p.parseProtoSchema(string(schemaContent))
-	if err != nil {
-		return nil, fmt.Errorf("ошибка парсинга схемы: %w", err)
+// qualifiedName соединяет область (пакет или полное имя сообщения) и имя типа
+func qualifiedName(scope string, name string) string {
+	if scope == "" {
+		return name
 	}
+	return scope + "." + name
+}
 
-	return topLevelMessages, nil
+// typeNameCandidates возвращает полные имена, под которыми ищется тип, по правилам protobuf:
+// имя с ведущей точкой уже полное, остальные ищутся от области scope (полное имя сообщения
+// или пакет) наружу до корня
+func typeNameCandidates(typeName string, scope string) []string {
+	if strings.HasPrefix(typeName, ".") {
+		return []string{typeName[1:]}
+	}
+	candidates := make([]string, 0, strings.Count(scope, ".")+2)
+	for scope != "" {
+		candidates = append(candidates, scope+"."+typeName)
+		if idx := strings.LastIndex(scope, "."); idx >= 0 {
+			scope = scope[:idx]
+		} else {
+			scope = ""
+		}
+	}
+	return append(candidates, typeName)
 }
 
-func (p *Parser) ApplySchema(tree *TreeNode, schemaPath string) (*TreeNode, error) {
-	return p.ApplySchemaWithMessage(tree, schemaPath, "")
+// findMessage разрешает имя типа сообщения в области scope: полном имени сообщения, в котором
+// объявлено поле, или пакете для объявлений верхнего уровня
+func (s *protoSchema) findMessage(typeName string, scope string) *schemaMessageInfo {
+	for _, name := range typeNameCandidates(typeName, scope) {
+		if msg, ok := s.messages[name]; ok {
+			return msg
+		}
+	}
+	return nil
 }
 
-func (p *Parser) ApplySchemaWithMessage(tree *TreeNode, schemaPath string, messageName string) (*TreeNode, error) {
-	schemaContent, err := os.ReadFile(schemaPath)
-	if err != nil {
-		return nil, fmt.Errorf("ошибка чтения схемы: %w", err)
+// findEnum разрешает имя перечисления так же, как findMessage
+func (s *protoSchema) findEnum(typeName string, scope string) *schemaEnumInfo {
+	for _, name := range typeNameCandidates(typeName, scope) {
+		if enum, ok := s.enums[name]; ok {
+			return enum
+		}
 	}
+	return nil
+}
 
-	schema, topLevelMessages, err := p.parseProtoSchema(string(schemaContent))
-	if err != nil {
-		return nil, fmt.Errorf("ошибка парсинга схемы: %w", err)
+// lookupMessage ищет сообщение по имени, заданному пользователем: относительно пакета схемы,
+// полным именем или, если имя без точек однозначно, коротким именем вложенного сообщения
+// или сообщения из другого пакета набора дескрипторов
+func (s *protoSchema) lookupMessage(name string) *schemaMessageInfo {
+	if message := s.findMessage(name, s.packageName); message != nil {
+		return message
+	}
+	if strings.Contains(name, ".") {
+		return nil
+	}
+	var found *schemaMessageInfo
+	for _, message := range s.messages {
+		if message.messageName != name {
+			continue
+		}
+		if found != nil {
+			return nil
+		}
+		found = message
+	}
+	return found
+}
+
+// lookupEnum ищет перечисление верхнего уровня так же, как lookupMessage
+func (s *protoSchema) lookupEnum(name string) *schemaEnumInfo {
+	if enum := s.findEnum(name, s.packageName); enum != nil {
+		return enum
+	}
+	var found *schemaEnumInfo
+	for _, enum := range s.enums {
+		if enum.enumName != name {
+			continue
+		}
+		if found != nil {
+			return nil
+		}
+		found = enum
+	}
+	return found
+}
+
+// relativeName возвращает полное имя типа относительно пакета схемы: pkg.Outer.Inner -> Outer.Inner
+func (s *protoSchema) relativeName(fullName string) string {
+	if s.packageName != "" && strings.HasPrefix(fullName, s.packageName+".") {
+		return strings.TrimPrefix(fullName, s.packageName+".")
+	}
+	return fullName
+}
+
+// indexTypes присваивает сообщениям и перечислениям области prefix полные имена и добавляет их
+// вместе с вложенными типами в словари схемы
+func (s *protoSchema) indexTypes(prefix string, messages []*schemaMessageInfo, enums []*schemaEnumInfo) {
+	for _, enum := range enums {
+		enum.fullName = qualifiedName(prefix, enum.enumName)
+		s.enums[enum.fullName] = enum
+	}
+	for _, message := range messages {
+		message.fullName = qualifiedName(prefix, message.messageName)
+		s.messages[message.fullName] = message
+
+		nestedEnums := make([]*schemaEnumInfo, 0, len(message.enumOrder))
+		for _, name := range message.enumOrder {
+			nestedEnums = append(nestedEnums, message.enums[name])
+		}
+		nested := make([]*schemaMessageInfo, 0, len(message.nestedOrder))
+		for _, name := range message.nestedOrder {
+			nested = append(nested, message.messages[name])
+		}
+		s.indexTypes(message.fullName, nested, nestedEnums)
 	}
+}
 
-	if len(schema) == 0 {
+// resolveRootMessage возвращает сообщение с указанным именем или, если имя пустое,
+// выбирает корневое сообщение автоматически
+func (s *protoSchema) resolveRootMessage(messageName string) (*schemaMessageInfo, error) {
+	if len(s.messages) == 0 {
 		return nil, fmt.Errorf("схема не содержит сообщений")
 	}
 
 	var rootMessage *schemaMessageInfo
 	if messageName != "" {
 		// Используем указанное сообщение
-		rootMessage = schema[messageName]
+		rootMessage = s.lookupMessage(messageName)
 		if rootMessage == nil {
 			return nil, fmt.Errorf("сообщение '%s' не найдено в схеме", messageName)
 		}
 	} else {
 		// Автоматический выбор: если сообщение одно, используем его, иначе ищем по приоритету
-		if len(topLevelMessages) == 1 {
-			rootMessage = schema[topLevelMessages[0]]
+		if len(s.topLevelMessages) == 1 {
+			rootMessage = s.lookupMessage(s.topLevelMessages[0])
 		} else {
-			rootMessage = findRootMessage(schema, topLevelMessages)
+			rootMessage = s.findRootMessage()
 		}
 	}
 
 	if rootMessage == nil {
 		return nil, fmt.Errorf("не удалось найти корневое сообщение в схеме")
 	}
+	return rootMessage, nil
+}
+
+func (p *Parser) loadSchema(schemaPath string) (*protoSchema, error) {
+	schemaContent, err := os.ReadFile(schemaPath)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения схемы: %w", err)
+	}
+
+	if IsDescriptorSetPath(schemaPath) {
+		return parseDescriptorSet(schemaContent)
+	}
+
+	schema, err := p.parseProtoSchema(string(schemaContent))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка парсинга схемы: %w", err)
+	}
+	if err := p.loadImports(schema, schemaPath); err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
+
+// ParseSchemaFile парсит proto файл и возвращает список имен сообщений верхнего уровня
+func (p *Parser) ParseSchemaFile(schemaPath string) ([]string, error) {
+	schema, err := p.loadSchema(schemaPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return schema.topLevelMessages, nil
+}
+
+// ApplySchema применяет схему к корневому сообщению по умолчанию и прерывается,
+// если в данных нет обязательного поля
+func (p *Parser) ApplySchema(tree *TreeNode, schemaPath string) (*TreeNode, error) {
+	schema, err := p.loadSchema(schemaPath)
+	if err != nil {
+		return nil, err
+	}
+
+	rootMessage, err := schema.resolveRootMessage("")
+	if err != nil {
+		return nil, err
+	}
 
 	if err := p.validateSchema(tree, rootMessage); err != nil {
 		return nil, err
 	}
 
-	p.applySchemaToTree(tree, rootMessage, schema)
+	forgetSchema(tree)
+	p.applySchemaToTree(tree, rootMessage, schema, "", newSchemaReport())
 
 	return tree, nil
 }
 
-func findRootMessage(messages map[string]*schemaMessageInfo, topLevelMessages []string) *schemaMessageInfo {
+// ApplySchemaWithMessage применяет схему к дереву, начиная с сообщения messageName, и не прерывается
+// на отсутствующих required полях: все расхождения данных со схемой на любом уровне
+// вложенности возвращаются в отчете
+func (p *Parser) ApplySchemaWithMessage(tree *TreeNode, schemaPath string, messageName string) (*TreeNode, *SchemaReport, error) {
+	return p.ApplySchemaWithRules(tree, schemaPath, messageName, nil)
+}
+
+// ApplySchemaWithRules дополнительно проверяет бизнес-правила: buf.validate опции полей
+// схемы и правила rules (например, из файла правил), нарушения попадают в тот же отчет.
+// Ленивые узлы не раскрываются: схема и правила применяются к ним при разборе, а их
+// расхождения добавляются в отчет в этот момент
+func (p *Parser) ApplySchemaWithRules(tree *TreeNode, schemaPath string, messageName string, rules ConstraintRules) (*TreeNode, *SchemaReport, error) {
+	schema, err := p.loadSchema(schemaPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rootMessage, err := schema.resolveRootMessage(messageName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	schemaRules, err := constraintsFromSchema(schema)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	report := newSchemaReport()
+	forgetSchema(tree)
+	p.applySchemaToTree(tree, rootMessage, schema, "", report)
+	p.evaluateConstraints(tree, rootMessage, schema, schemaRules.Merge(rules), "", report)
+
+	return tree, report, nil
+}
+
+func (s *protoSchema) findRootMessage() *schemaMessageInfo {
 	// Сначала ищем сообщения с приоритетными именами среди сообщений верхнего уровня
 	priorityNames := []string{"Message", "Root", "RootMessage"}
 	for _, priorityName := range priorityNames {
-		for _, topLevelName := range topLevelMessages {
+		for _, topLevelName := range s.topLevelMessages {
 			if topLevelName == priorityName {
-				if msg, exists := messages[priorityName]; exists {
+				if msg := s.lookupMessage(priorityName); msg != nil {
 					return msg
 				}
 			}
@@ -413,65 +582,181 @@ func findRootMessage(messages map[string]*schemaMessageInfo, topLevelMessages []
 	}
 
 	// Если не найдено, возвращаем первое сообщение верхнего уровня
-	if len(topLevelMessages) > 0 {
-		return messages[topLevelMessages[0]]
+	if len(s.topLevelMessages) > 0 {
+		return s.lookupMessage(s.topLevelMessages[0])
 	}
 
 	return nil
 }
 
-// parseProtoSchema парсит proto схему и возвращает:
-// 1. Словарь всех сообщений (включая вложенные)
-// 2. Список имен сообщений верхнего уровня
-func (p *Parser) parseProtoSchema(content string) (map[string]*schemaMessageInfo, []string, error) {
-	messages := make(map[string]*schemaMessageInfo)
-	topLevelMessages := make([]string, 0)
+// parseProtoSchema парсит proto схему и возвращает словарь всех сообщений (включая вложенные),
+// список имен сообщений верхнего уровня и перечисления
+func (p *Parser) parseProtoSchema(content string) (*protoSchema, error) {
+	schema := &protoSchema{
+		messages:         make(map[string]*schemaMessageInfo),
+		topLevelMessages: make([]string, 0),
+		enums:            make(map[string]*schemaEnumInfo),
+	}
 	lines := strings.Split(content, "\n")
 
 	var currentMessage *schemaMessageInfo
+	var currentEnum *schemaEnumInfo
+	// topLevel и topEnums получают полные имена после разбора, когда известен пакет
+	var topLevel []*schemaMessageInfo
+	var topEnums []*schemaEnumInfo
+	var currentOneof string
+	// currentExtend - расширяемое сообщение открытого блока extend
+	var currentExtend string
+	extendBlocks := 0
 	var messageStack []*schemaMessageInfo
+	// blockStack хранит вид каждого открытого блока, чтобы закрывающая скобка enum или oneof
+	// не завершала текущее сообщение
+	var blockStack []string
+
+	openMessage := func(messageName string) {
+		msg := &schemaMessageInfo{
+			messageName: messageName,
+			fields:      make([]*schemaFieldInfo, 0),
+			messages:    make(map[string]*schemaMessageInfo),
+			enums:       make(map[string]*schemaEnumInfo),
+		}
+
+		if currentMessage != nil {
+			// Это вложенное сообщение
+			messageStack = append(messageStack, currentMessage)
+			currentMessage.messages[messageName] = msg
+			currentMessage.nestedOrder = append(currentMessage.nestedOrder, messageName)
+		} else {
+			// Это сообщение верхнего уровня
+			messageStack = append(messageStack, nil)
+			topLevel = append(topLevel, msg)
+			schema.topLevelMessages = append(schema.topLevelMessages, messageName)
+		}
+
+		currentMessage = msg
+		blockStack = append(blockStack, "message")
+	}
 
 	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" || strings.HasPrefix(line, "//") || strings.HasPrefix(line, "syntax") || strings.HasPrefix(line, "package") || strings.HasPrefix(line, "import") {
+		line = strings.TrimSpace(stripLineComment(line))
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "syntax") {
+			schema.syntax = declarationValue(line)
+			continue
+		}
+		if strings.HasPrefix(line, "package") {
+			schema.packageName = declarationValue(line)
+			continue
+		}
+		if strings.HasPrefix(line, "import") {
+			schema.imports = append(schema.imports, line)
 			continue
 		}
 
+		// Группа объявляет поле и одноименное вложенное сообщение, тело которого
+		// разбирается так же, как тело message
+		if currentMessage != nil && currentEnum == nil && currentExtend == "" {
+			if field := parseGroupLine(line); field != nil {
+				field.oneof = currentOneof
+				currentMessage.fields = append(currentMessage.fields, field)
+				openMessage(field.fieldType)
+				continue
+			}
+		}
+
 		if strings.HasPrefix(line, "message ") {
 			parts := strings.Fields(line)
 			if len(parts) < 2 {
 				continue
 			}
-			messageName := strings.TrimSuffix(strings.TrimSuffix(parts[1], "{"), " ")
+			openMessage(strings.TrimSuffix(strings.TrimSuffix(parts[1], "{"), " "))
+			continue
+		}
 
-			msg := &schemaMessageInfo{
-				messageName: messageName,
-				fields:      make([]*schemaFieldInfo, 0),
-				messages:    make(map[string]*schemaMessageInfo),
+		if strings.HasPrefix(line, "enum ") {
+			parts := strings.Fields(line)
+			if len(parts) < 2 {
+				continue
+			}
+			enumName := strings.TrimSuffix(parts[1], "{")
+			currentEnum = &schemaEnumInfo{
+				enumName: enumName,
+				values:   make([]*schemaEnumValue, 0),
 			}
-
 			if currentMessage != nil {
-				// Это вложенное сообщение
-				messageStack = append(messageStack, currentMessage)
-				currentMessage.messages[messageName] = msg
-				// Добавляем вложенное сообщение в общий словарь для доступа из других мест
-				messages[messageName] = msg
+				currentMessage.enums[enumName] = currentEnum
+				currentMessage.enumOrder = append(currentMessage.enumOrder, enumName)
 			} else {
-				// Это сообщение верхнего уровня
-				messages[messageName] = msg
-				topLevelMessages = append(topLevelMessages, messageName)
+				schema.topLevelEnums = append(schema.topLevelEnums, enumName)
+				topEnums = append(topEnums, currentEnum)
 			}
-
-			currentMessage = msg
+			blockStack = append(blockStack, "enum")
 			continue
 		}
 
 		if strings.HasPrefix(line, "}") {
-			if len(messageStack) > 0 {
-				currentMessage = messageStack[len(messageStack)-1]
-				messageStack = messageStack[:len(messageStack)-1]
-			} else {
-				currentMessage = nil
+			if len(blockStack) == 0 {
+				continue
+			}
+			kind := blockStack[len(blockStack)-1]
+			blockStack = blockStack[:len(blockStack)-1]
+
+			switch kind {
+			case "message":
+				if len(messageStack) > 0 {
+					currentMessage = messageStack[len(messageStack)-1]
+					messageStack = messageStack[:len(messageStack)-1]
+				} else {
+					currentMessage = nil
+				}
+			case "enum":
+				currentEnum = nil
+			case "oneof":
+				currentOneof = ""
+			case "extend":
+				currentExtend = ""
+			}
+			continue
+		}
+
+		if currentEnum != nil {
+			if value := parseEnumValueLine(line); value != nil {
+				currentEnum.values = append(currentEnum.values, value)
+			}
+			continue
+		}
+
+		if strings.HasPrefix(line, "oneof ") && strings.HasSuffix(line, "{") {
+			// Поля oneof принадлежат текущему сообщению
+			currentOneof = strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(line, "oneof "), "{"))
+			blockStack = append(blockStack, "oneof")
+			continue
+		}
+
+		if strings.HasPrefix(line, "extend ") && strings.HasSuffix(line, "{") {
+			currentExtend = strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(line, "extend "), "{"))
+			extendBlocks++
+			blockStack = append(blockStack, "extend")
+			continue
+		}
+
+		if strings.HasSuffix(line, "{") {
+			// service и другие блоки
+			blockStack = append(blockStack, "block")
+			continue
+		}
+
+		if strings.HasPrefix(line, "extensions ") && currentMessage != nil {
+			currentMessage.extensionRanges = append(currentMessage.extensionRanges,
+				strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(line, "extensions "), ";")))
+			continue
+		}
+
+		if currentExtend != "" {
+			if field := p.parseFieldLine(line); field != nil {
+				schema.extensions = append(schema.extensions, newSchemaExtension(schema.packageName, currentExtend, extendBlocks, field, currentMessage, messageStack))
 			}
 			continue
 		}
@@ -479,12 +764,79 @@ func (p *Parser) parseProtoSchema(content string) (map[string]*schemaMessageInfo
 		if currentMessage != nil {
 			field := p.parseFieldLine(line)
 			if field != nil {
+				field.oneof = currentOneof
 				currentMessage.fields = append(currentMessage.fields, field)
 			}
 		}
 	}
 
-	return messages, topLevelMessages, nil
+	schema.indexTypes(schema.packageName, topLevel, topEnums)
+	return schema, nil
+}
+
+// parseEnumValueLine разбирает строку вида "RED = 1;" внутри enum
+func parseEnumValueLine(line string) *schemaEnumValue {
+	line = strings.TrimSuffix(strings.TrimSpace(line), ";")
+	if idx := strings.Index(line, "["); idx >= 0 {
+		line = line[:idx]
+	}
+	parts := strings.SplitN(line, "=", 2)
+	if len(parts) != 2 {
+		return nil
+	}
+
+	name := strings.TrimSpace(parts[0])
+	if name == "" || strings.Contains(name, " ") {
+		return nil
+	}
+	number, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if err != nil {
+		return nil
+	}
+	return &schemaEnumValue{name: name, number: number}
+}
+
+// parseGroupLine разбирает объявление proto2 группы вида "repeated group Result = 1 {".
+// Имя поля группы - имя ее типа в нижнем регистре
+func parseGroupLine(line string) *schemaFieldInfo {
+	if !strings.HasSuffix(line, "{") {
+		return nil
+	}
+	declaration := strings.TrimSpace(strings.TrimSuffix(line, "{"))
+	var options map[string]string
+	if start := strings.Index(declaration, "["); start >= 0 {
+		if end := strings.LastIndex(declaration, "]"); end > start {
+			options = parseFieldOptions(declaration[start+1 : end])
+		}
+		declaration = strings.TrimSpace(declaration[:start])
+	}
+
+	parts := strings.Fields(strings.Replace(declaration, "=", " = ", 1))
+	field := &schemaFieldInfo{isGroup: true, options: options}
+	if len(parts) > 0 {
+		switch parts[0] {
+		case "repeated":
+			field.isRepeated = true
+			parts = parts[1:]
+		case "required":
+			field.isRequired = true
+			parts = parts[1:]
+		case "optional":
+			field.isOptional = true
+			parts = parts[1:]
+		}
+	}
+	if len(parts) != 4 || parts[0] != "group" || parts[2] != "=" {
+		return nil
+	}
+	fieldNum, err := strconv.Atoi(parts[3])
+	if err != nil {
+		return nil
+	}
+	field.fieldType = parts[1]
+	field.fieldName = strings.ToLower(parts[1])
+	field.fieldNum = fieldNum
+	return field
 }
 
 func (p *Parser) parseFieldLine(line string) *schemaFieldInfo {
@@ -493,7 +845,16 @@ func (p *Parser) parseFieldLine(line string) *schemaFieldInfo {
 		return nil
 	}
 
-	parts := strings.Fields(line)
+	var options map[string]string
+	if start := strings.Index(line, "["); start >= 0 {
+		end := strings.LastIndex(line, "]")
+		if end > start {
+			options = parseFieldOptions(line[start+1 : end])
+		}
+		line = strings.TrimSpace(line[:start]) + ";"
+	}
+
+	parts := strings.Fields(strings.Replace(line, "=", " = ", 1))
 	if len(parts) < 4 {
 		return nil
 	}
@@ -552,14 +913,102 @@ func (p *Parser) parseFieldLine(line string) *schemaFieldInfo {
 		isOptional = true
 	}
 
-	return &schemaFieldInfo{
+	field := &schemaFieldInfo{
 		fieldNum:   fieldNum,
 		fieldName:  fieldName,
 		fieldType:  fieldType,
 		isRepeated: isRepeated,
 		isRequired: isRequired,
 		isOptional: isOptional,
+		options:    options,
+	}
+	if defaultValue, ok := options["default"]; ok {
+		field.defaultValue = unquoteOptionValue(defaultValue)
+		field.hasDefault = true
+	}
+	return field
+}
+
+// parseFieldOptions разбирает содержимое квадратных скобок после номера поля:
+// "default = 5, packed = true" -> {"default": "5", "packed": "true"}
+func parseFieldOptions(optionsStr string) map[string]string {
+	options := make(map[string]string)
+	for _, option := range splitOutsideQuotes(optionsStr, ',') {
+		parts := strings.SplitN(option, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		name := strings.TrimSpace(parts[0])
+		if name == "" {
+			continue
+		}
+		options[name] = strings.TrimSpace(parts[1])
+	}
+	return options
+}
+
+// splitOutsideQuotes делит строку по разделителю, игнорируя разделители внутри кавычек
+// и фигурных скобок ((buf.validate.field).int32 = {gte: 0, lte: 10})
+func splitOutsideQuotes(s string, sep rune) []string {
+	parts := make([]string, 0)
+	var current strings.Builder
+	var quote rune
+	escaped := false
+	depth := 0
+	for _, r := range s {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\' && quote != 0:
+			escaped = true
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote == 0 && (r == '"' || r == '\''):
+			quote = r
+		case quote == 0 && r == '{':
+			depth++
+		case quote == 0 && r == '}' && depth > 0:
+			depth--
+		case quote == 0 && depth == 0 && r == sep:
+			parts = append(parts, current.String())
+			current.Reset()
+			continue
+		}
+		current.WriteRune(r)
+	}
+	if strings.TrimSpace(current.String()) != "" {
+		parts = append(parts, current.String())
+	}
+	return parts
+}
+
+func unquoteOptionValue(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// stripLineComment удаляет комментарий "//" в конце строки, если он не внутри кавычек
+func stripLineComment(line string) string {
+	var quote rune
+	escaped := false
+	for i, r := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\' && quote != 0:
+			escaped = true
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote == 0 && (r == '"' || r == '\''):
+			quote = r
+		case quote == 0 && r == '/' && strings.HasPrefix(line[i:], "//"):
+			return line[:i]
+		}
 	}
+	return line
 }
 
 func (p *Parser) validateSchema(tree *TreeNode, schema *schemaMessageInfo) error {
@@ -585,23 +1034,51 @@ func (p *Parser) collectFieldNums(node *TreeNode, fieldNums map[int]bool) {
 	}
 }
 
-func (p *Parser) applySchemaToTree(tree *TreeNode, schema *schemaMessageInfo, allMessages map[string]*schemaMessageInfo) {
+func (p *Parser) applySchemaToTree(tree *TreeNode, message *schemaMessageInfo, schema *protoSchema, path string, report *SchemaReport) {
+	// Поля ленивого узла еще не разобраны: схема применится к ним при разборе
+	if tree.IsLazy() {
+		tree.schema = &lazySchema{parser: p, message: message, schema: schema, path: path, report: report}
+		report.Deferred++
+		return
+	}
+
 	fieldMap := make(map[int]*schemaFieldInfo)
-	for _, field := range schema.fields {
+	for _, field := range message.fields {
 		fieldMap[field.fieldNum] = field
 	}
 
+	extensions := schema.extensionsFor(message)
+
+	report.checkRequired(tree, message, path)
+
+	repeatedIndexes := make(map[int]int)
 	for _, child := range tree.Children {
-		if fieldInfo, ok := fieldMap[child.FieldNum]; ok {
+		// Типы полей расширения разрешаются в области, где объявлен блок extend
+		scope := message.fullName
+		fieldInfo, ok := fieldMap[child.FieldNum]
+		if extension, found := extensions[child.FieldNum]; !ok && found {
+			fieldInfo, ok = extension.field, true
+			scope = extension.scopeName
+		}
+		if ok {
 			oldType := child.Type
 			child.Name = fieldInfo.fieldName
 
+			// Перечисления кодируются как varint, поэтому в дереве представляются как int32
+			fieldType := fieldInfo.fieldType
+			enum := schema.findEnum(fieldType, scope)
+			isEnum := enum != nil
+			if isEnum {
+				fieldType = "int32"
+			}
+			report.checkWireType(child, fieldInfo, isEnum, p.isMessageTypeName(fieldType), oldType, path)
+
 			// Если тип поля - это тип сообщения, устанавливаем тип поля сразу
-			if p.isMessageTypeName(fieldInfo.fieldType) {
-				child.Type = fieldInfo.fieldType
+			if p.isMessageTypeName(fieldType) {
+				child.Type = fieldType
 			} else {
 				// Для не-сообщений применяем обычное преобразование типа
-				newType := p.mapProtoTypeToUIType(fieldInfo.fieldType)
+				newType := p.mapProtoTypeToUIType(fieldType)
 				if p.canConvertType(oldType, newType, child.Value) {
 					child.Type = newType
 					child.Value = p.convertValue(child.Value, oldType, newType)
@@ -609,25 +1086,32 @@ func (p *Parser) applySchemaToTree(tree *TreeNode, schema *schemaMessageInfo, al
 			}
 
 			child.IsRepeated = fieldInfo.isRepeated
-
-			// Применяем схему к вложенным сообщениям
-			if p.isMessageTypeName(fieldInfo.fieldType) {
-				// Ищем схему вложенного сообщения сначала в schema.messages (локальные вложенные сообщения),
-				// затем в allMessages (все сообщения)
-				if nestedSchema, ok := schema.messages[fieldInfo.fieldType]; ok {
-					p.applySchemaToTree(child, nestedSchema, allMessages)
-				} else if nestedSchema, ok := allMessages[fieldInfo.fieldType]; ok {
-					p.applySchemaToTree(child, nestedSchema, allMessages)
+			child.IsRequired = fieldInfo.isRequired
+			report.checkValue(child, fieldInfo, enum, path)
+
+			// Применяем схему к вложенным сообщениям: имя типа разрешается от области поля наружу
+			if p.isMessageTypeName(fieldType) {
+				if nestedSchema := schema.findMessage(fieldType, scope); nestedSchema != nil {
+					childPath := joinDiffPath(path, child.Name)
+					if child.IsRepeated {
+						childPath = fmt.Sprintf("%s[%d]", childPath, repeatedIndexes[child.FieldNum])
+					}
+					p.applySchemaToTree(child, nestedSchema, schema, childPath, report)
 				}
 			}
+			repeatedIndexes[child.FieldNum]++
+		} else {
+			report.addUnknownField(child, path)
 		}
 	}
 }
 
 func (p *Parser) mapProtoTypeToUIType(protoType string) string {
 	switch protoType {
-	case "string", "bytes":
+	case "string":
 		return "string"
+	case "bytes":
+		return "bytes"
 	case "int32", "sint32", "sfixed32":
 		return "int32"
 	case "int64", "sint64", "sfixed64":
@@ -695,6 +1179,11 @@ func (p *Parser) canConvertType(oldType, newType string, value interface{}) bool
 		return true
 	}
 
+	// protoc --decode_raw показывает length-delimited значения строкой с экранированием
+	if oldType == "string" && newType == "bytes" {
+		return true
+	}
+
 	if (oldType == "int32" || oldType == "int64" || oldType == "uint32" || oldType == "uint64" || oldType == "sint32" || oldType == "sint64") &&
 		(newType == "int32" || newType == "int64" || newType == "uint32" || newType == "uint64" || newType == "sint32" || newType == "sint64") {
 		return p.isCompatibleIntegerType(oldType, newType, valueStr)
@@ -771,6 +1260,10 @@ func (p *Parser) convertValue(value interface{}, oldType, newType string) interf
 
 	valueStr := fmt.Sprintf("%v", value)
 
+	if oldType == "string" && newType == "bytes" {
+		return []byte(valueStr)
+	}
+
 	if (oldType == "int32" || oldType == "int64" || oldType == "uint32" || oldType == "uint64" || oldType == "sint32" || oldType == "sint64") &&
 		(newType == "float" || newType == "double") {
 		if val, err := strconv.ParseInt(valueStr, 10, 64); err == nil {
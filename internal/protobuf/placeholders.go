@@ -0,0 +1,107 @@
+package protobuf
+
+import (
+	"fmt"
+)
+
+// AddSchemaPlaceholders заполняет Placeholders каждого сообщения дерева полями схемы,
+// которые отсутствуют в данных. Плейсхолдеры содержат значения по умолчанию и могут быть
+// добавлены в сообщение через MaterializePlaceholder.
+func (p *Parser) AddSchemaPlaceholders(tree *TreeNode, schemaPath string, messageName string) error {
+	ExpandAll(tree)
+	schema, err := p.loadSchema(schemaPath)
+	if err != nil {
+		return err
+	}
+
+	rootMessage, err := schema.resolveRootMessage(messageName)
+	if err != nil {
+		return err
+	}
+
+	p.addPlaceholders(tree, rootMessage, schema)
+	return nil
+}
+
+func (p *Parser) addPlaceholders(node *TreeNode, message *schemaMessageInfo, schema *protoSchema) {
+	present := make(map[int]bool)
+	for _, child := range node.Children {
+		present[child.FieldNum] = true
+	}
+
+	node.Placeholders = nil
+	for _, field := range message.fields {
+		if present[field.fieldNum] {
+			continue
+		}
+		placeholder := p.newFieldNode(field, message, schema, map[*schemaMessageInfo]bool{message: true})
+		if placeholder != nil {
+			node.Placeholders = append(node.Placeholders, placeholder)
+		}
+	}
+
+	fieldMap := make(map[int]*schemaFieldInfo)
+	for _, field := range message.fields {
+		fieldMap[field.fieldNum] = field
+	}
+	for _, child := range node.Children {
+		fieldInfo, ok := fieldMap[child.FieldNum]
+		if !ok || !p.isMessageTypeName(fieldInfo.fieldType) {
+			continue
+		}
+		if nested := schema.findMessage(fieldInfo.fieldType, message.fullName); nested != nil {
+			p.addPlaceholders(child, nested, schema)
+		}
+	}
+}
+
+// ClearPlaceholders удаляет плейсхолдеры из всего дерева
+func ClearPlaceholders(tree *TreeNode) {
+	if tree == nil {
+		return
+	}
+	tree.Placeholders = nil
+	for _, child := range tree.Children {
+		ClearPlaceholders(child)
+	}
+}
+
+// HasPlaceholders сообщает, есть ли в дереве хотя бы один плейсхолдер
+func HasPlaceholders(tree *TreeNode) bool {
+	if tree == nil {
+		return false
+	}
+	if len(tree.Placeholders) > 0 {
+		return true
+	}
+	for _, child := range tree.Children {
+		if HasPlaceholders(child) {
+			return true
+		}
+	}
+	return false
+}
+
+// MaterializePlaceholder переносит плейсхолдер с указанным индексом в дочерние элементы узла,
+// сохраняя порядок номеров полей, и возвращает добавленный узел
+func (n *TreeNode) MaterializePlaceholder(index int) (*TreeNode, error) {
+	if index < 0 || index >= len(n.Placeholders) {
+		return nil, fmt.Errorf("placeholder index %d out of range", index)
+	}
+
+	placeholder := n.Placeholders[index]
+	n.Placeholders = append(n.Placeholders[:index:index], n.Placeholders[index+1:]...)
+
+	insertAt := len(n.Children)
+	for i, child := range n.Children {
+		if child.FieldNum > placeholder.FieldNum {
+			insertAt = i
+			break
+		}
+	}
+
+	n.Children = append(n.Children, nil)
+	copy(n.Children[insertAt+1:], n.Children[insertAt:])
+	n.Children[insertAt] = placeholder
+	return placeholder, nil
+}
@@ -0,0 +1,132 @@
+package protobuf
+
+import "testing"
+
+func TestAddSchemaPlaceholders_AllLevels(t *testing.T) {
+	schemaFile := writeTestSchema(t, `syntax = "proto2";
+
+message Person {
+  optional string name = 1;
+  optional int32 age = 2 [default = 30];
+  optional Address address = 3;
+  optional string email = 4;
+}
+
+message Address {
+  optional string city = 1;
+  optional string zip = 2;
+}`)
+	tree := newTestRoot(
+		&TreeNode{Name: "field_1", Type: "string", FieldNum: 1, Value: "alice"},
+		&TreeNode{
+			Name: "field_3", Type: "message_1", FieldNum: 3,
+			Children: []*TreeNode{
+				{Name: "field_1", Type: "string", FieldNum: 1, Value: "Moscow"},
+			},
+		},
+	)
+
+	parser := &Parser{}
+	if err := parser.AddSchemaPlaceholders(tree, schemaFile, "Person"); err != nil {
+		t.Fatalf("AddSchemaPlaceholders failed: %v", err)
+	}
+
+	if len(tree.Children) != 2 {
+		t.Errorf("Expected placeholders not to change children, got %d children", len(tree.Children))
+	}
+	if len(tree.Placeholders) != 2 {
+		t.Fatalf("Expected 2 placeholders at root, got %d", len(tree.Placeholders))
+	}
+	if age := tree.Placeholders[0]; age.Name != "age" || age.FieldNum != 2 || age.Value != "30" {
+		t.Errorf("Unexpected age placeholder: %+v", age)
+	}
+	if email := tree.Placeholders[1]; email.Name != "email" || email.FieldNum != 4 {
+		t.Errorf("Unexpected email placeholder: %+v", email)
+	}
+
+	address := tree.Children[1]
+	if len(address.Placeholders) != 1 || address.Placeholders[0].Name != "zip" {
+		t.Errorf("Expected zip placeholder in address, got %+v", address.Placeholders)
+	}
+
+	ClearPlaceholders(tree)
+	if HasPlaceholders(tree) {
+		t.Error("Expected no placeholders after ClearPlaceholders")
+	}
+}
+
+func TestMaterializePlaceholder_KeepsFieldOrder(t *testing.T) {
+	schemaFile := writeTestSchema(t, `syntax = "proto2";
+
+message Person {
+  optional string name = 1;
+  optional int32 age = 2 [default = 30];
+  optional Address address = 3;
+  optional string email = 4;
+}
+
+message Address {
+  optional string city = 1;
+  optional string zip = 2;
+}`)
+	tree := newTestRoot(
+		&TreeNode{Name: "field_1", Type: "string", FieldNum: 1, Value: "alice"},
+		&TreeNode{
+			Name: "field_3", Type: "message_1", FieldNum: 3,
+			Children: []*TreeNode{
+				{Name: "field_1", Type: "string", FieldNum: 1, Value: "Moscow"},
+			},
+		},
+	)
+
+	parser := &Parser{}
+	if err := parser.AddSchemaPlaceholders(tree, schemaFile, "Person"); err != nil {
+		t.Fatalf("AddSchemaPlaceholders failed: %v", err)
+	}
+
+	node, err := tree.MaterializePlaceholder(0)
+	if err != nil {
+		t.Fatalf("MaterializePlaceholder failed: %v", err)
+	}
+	if node.FieldNum != 2 {
+		t.Errorf("Expected materialized field 2, got %d", node.FieldNum)
+	}
+
+	fieldNums := make([]int, 0, len(tree.Children))
+	for _, child := range tree.Children {
+		fieldNums = append(fieldNums, child.FieldNum)
+	}
+	if len(fieldNums) != 3 || fieldNums[0] != 1 || fieldNums[1] != 2 || fieldNums[2] != 3 {
+		t.Errorf("Expected children in field order [1 2 3], got %v", fieldNums)
+	}
+	if len(tree.Placeholders) != 1 || tree.Placeholders[0].FieldNum != 4 {
+		t.Errorf("Expected only email placeholder left, got %+v", tree.Placeholders)
+	}
+
+	if _, err := tree.MaterializePlaceholder(5); err == nil {
+		t.Error("Expected error for out of range placeholder index")
+	}
+}
+
+func TestPlaceholders_NotExported(t *testing.T) {
+	schemaFile := writeTestSchema(t, `syntax = "proto2";
+
+message Person {
+  optional string name = 1;
+  optional int32 age = 2 [default = 30];
+}`)
+	tree := newTestRoot(&TreeNode{Name: "field_1", Type: "string", FieldNum: 1, Value: "alice"})
+
+	parser := &Parser{}
+	if err := parser.AddSchemaPlaceholders(tree, schemaFile, "Person"); err != nil {
+		t.Fatalf("AddSchemaPlaceholders failed: %v", err)
+	}
+
+	jsonObj, err := TreeNodeToJSON(tree)
+	if err != nil {
+		t.Fatalf("TreeNodeToJSON failed: %v", err)
+	}
+	if _, ok := jsonObj["age"]; ok {
+		t.Error("Expected placeholder 'age' not to be exported")
+	}
+}
@@ -0,0 +1,40 @@
+package protobuf
+
+// UpdateTree заменяет содержимое target содержимым source, переиспользуя узлы target, которые
+// стоят на тех же позициях и имеют те же номера полей. Интерфейс, привязанный к узлам
+// (раскрытые ветки, выделение), так переживает перечитывание файла. Ленивые узлы source
+// раскрываются там, где соответствующие узлы target уже раскрыты. Issues отчета report,
+// если он задан, перенаправляются на переиспользованные узлы
+func UpdateTree(target *TreeNode, source *TreeNode, report *SchemaReport) {
+	reused := make(map[*TreeNode]*TreeNode)
+	updateTreeNode(target, source, reused)
+
+	if report == nil {
+		return
+	}
+	for i, issue := range report.Issues {
+		if node, ok := reused[issue.Node]; ok {
+			report.Issues[i].Node = node
+		}
+	}
+}
+
+func updateTreeNode(target *TreeNode, source *TreeNode, reused map[*TreeNode]*TreeNode) {
+	reused[source] = target
+	if !target.IsLazy() {
+		source.Expand()
+	}
+
+	children := make([]*TreeNode, len(source.Children))
+	for i, child := range source.Children {
+		if i < len(target.Children) && target.Children[i].FieldNum == child.FieldNum {
+			updateTreeNode(target.Children[i], child, reused)
+			children[i] = target.Children[i]
+			continue
+		}
+		children[i] = child
+	}
+
+	*target = *source
+	target.Children = children
+}
@@ -0,0 +1,45 @@
+package protobuf
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestUpdateTree_ReusesNodesAtSamePositions(t *testing.T) {
+	// 1: 1, 2: { 1: 2 }
+	before := []byte{0x08, 0x01, 0x12, 0x02, 0x08, 0x02}
+	// 1: 5, 2: { 1: 3 }, 3: 4
+	after := []byte{0x08, 0x05, 0x12, 0x02, 0x08, 0x03, 0x18, 0x04}
+
+	target, err := DecodeLazy(context.Background(), before, nil)
+	if err != nil {
+		t.Fatalf("DecodeLazy failed: %v", err)
+	}
+	target.Children[1].Expand()
+	scalar, message, nested := target.Children[0], target.Children[1], target.Children[1].Children[0]
+
+	source, err := DecodeLazy(context.Background(), after, nil)
+	if err != nil {
+		t.Fatalf("DecodeLazy failed: %v", err)
+	}
+	report := &SchemaReport{Issues: []SchemaIssue{{Kind: SchemaIssueUnknownField, Node: source.Children[1]}}}
+
+	UpdateTree(target, source, report)
+
+	if len(target.Children) != 3 {
+		t.Fatalf("Expected 3 fields after update, got %d", len(target.Children))
+	}
+	if target.Children[0] != scalar || target.Children[1] != message || message.Children[0] != nested {
+		t.Error("Expected nodes at unchanged positions to be reused")
+	}
+	if fmt.Sprintf("%v %v %v", scalar.Value, nested.Value, target.Children[2].Value) != "5 3 4" {
+		t.Errorf("Expected values from the new data, got %v, %v, %v", scalar.Value, nested.Value, target.Children[2].Value)
+	}
+	if message.IsLazy() {
+		t.Error("Expected expanded message to stay expanded")
+	}
+	if report.Issues[0].Node != message {
+		t.Error("Expected report to point at the reused node")
+	}
+}
@@ -0,0 +1,144 @@
+package protobuf
+
+import (
+	"strconv"
+)
+
+// NewMessageFromSchema строит дерево нового сообщения по схеме: все объявленные поля
+// заполняются значениями по умолчанию, required поля помечаются IsRequired.
+// Для repeated полей создается один элемент, из каждого oneof - только первое поле.
+// Рекурсивные ссылки на сообщения не раскрываются: необязательные пропускаются, а required
+// создаются пустыми сообщениями.
+func (p *Parser) NewMessageFromSchema(schemaPath string, messageName string) (*TreeNode, error) {
+	schema, err := p.loadSchema(schemaPath)
+	if err != nil {
+		return nil, err
+	}
+
+	rootMessage, err := schema.resolveRootMessage(messageName)
+	if err != nil {
+		return nil, err
+	}
+
+	root := &TreeNode{
+		Name:     "root",
+		Type:     "message",
+		Children: make([]*TreeNode, 0),
+	}
+	p.populateMessageFields(root, rootMessage, schema, map[*schemaMessageInfo]bool{rootMessage: true})
+	return root, nil
+}
+
+func (p *Parser) populateMessageFields(node *TreeNode, message *schemaMessageInfo, schema *protoSchema, inProgress map[*schemaMessageInfo]bool) {
+	// В данных может быть только одно поле oneof, поэтому заполняется первое из них
+	populatedOneofs := make(map[string]bool)
+	for _, field := range message.fields {
+		if field.oneof != "" {
+			if populatedOneofs[field.oneof] {
+				continue
+			}
+			populatedOneofs[field.oneof] = true
+		}
+		child := p.newFieldNode(field, message, schema, inProgress)
+		if child != nil {
+			node.AddChild(child)
+		}
+	}
+}
+
+// newFieldNode создает узел для поля схемы со значением по умолчанию.
+// Возвращает nil для необязательных полей, тип которых ссылается на уже раскрываемое сообщение.
+func (p *Parser) newFieldNode(field *schemaFieldInfo, message *schemaMessageInfo, schema *protoSchema, inProgress map[*schemaMessageInfo]bool) *TreeNode {
+	node := &TreeNode{
+		Name:       field.fieldName,
+		FieldNum:   field.fieldNum,
+		IsRepeated: field.isRepeated,
+		IsRequired: field.isRequired,
+		Children:   make([]*TreeNode, 0),
+	}
+
+	if enum := schema.findEnum(field.fieldType, message.fullName); enum != nil {
+		node.Type = "int32"
+		node.Value = enumDefaultValue(enum, field)
+		return node
+	}
+
+	if !p.isMessageTypeName(field.fieldType) {
+		node.Type = p.mapProtoTypeToUIType(field.fieldType)
+		node.Value = scalarDefaultValue(node.Type, field)
+		return node
+	}
+
+	// Сообщение без полей или с неизвестным типом (например, из неразрешенного импорта) не имеет
+	// дочерних узлов, по которым его можно отличить от скаляра, поэтому получает тип "message",
+	// как пустые сообщения при декодировании, и сериализуется как пустое сообщение
+	node.Type = "message"
+	nested := schema.findMessage(field.fieldType, message.fullName)
+	if nested == nil {
+		return node
+	}
+	if inProgress[nested] {
+		// Required поле нельзя пропустить: без него сообщение не пройдет проверку при чтении.
+		// Пустое сообщение прерывает рекурсию, его поля пользователь заполнит сам
+		if field.isRequired {
+			return node
+		}
+		return nil
+	}
+
+	inProgress[nested] = true
+	p.populateMessageFields(node, nested, schema, inProgress)
+	delete(inProgress, nested)
+	if len(node.Children) > 0 {
+		node.Type = field.fieldType
+	}
+	return node
+}
+
+// scalarDefaultValue возвращает значение по умолчанию в том представлении, которое использует
+// парсер: строки для чисел и текста, bool для логических полей, []byte для bytes
+func scalarDefaultValue(uiType string, field *schemaFieldInfo) interface{} {
+	if uiType == "bool" {
+		return field.hasDefault && field.defaultValue == "true"
+	}
+	if field.hasDefault && (uiType == "string" || uiType == "bytes") {
+		// Значение по умолчанию в схеме записано строковым литералом с экранированием
+		data, err := UnescapeText(field.defaultValue)
+		if err != nil {
+			data = []byte(field.defaultValue)
+		}
+		if uiType == "bytes" {
+			return data
+		}
+		return string(data)
+	}
+	if uiType == "bytes" {
+		return []byte{}
+	}
+	if field.hasDefault {
+		return field.defaultValue
+	}
+
+	switch uiType {
+	case "string":
+		return ""
+	default:
+		return "0"
+	}
+}
+
+// enumDefaultValue возвращает номер значения по умолчанию: явно указанного в [default = ...]
+// или первого объявленного значения перечисления
+func enumDefaultValue(enum *schemaEnumInfo, field *schemaFieldInfo) interface{} {
+	if field.hasDefault {
+		for _, value := range enum.values {
+			if value.name == field.defaultValue {
+				return strconv.Itoa(value.number)
+			}
+		}
+	}
+	if len(enum.values) > 0 {
+		return strconv.Itoa(enum.values[0].number)
+	}
+	return "0"
+}
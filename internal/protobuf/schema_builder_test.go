@@ -0,0 +1,261 @@
+package protobuf
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestNewMessageFromSchema_AllFieldsWithDefaults(t *testing.T) {
+	schemaFile := writeTestSchema(t, `syntax = "proto2";
+
+message Person {
+  enum Kind {
+    UNKNOWN = 0;
+    ADMIN = 1;
+    USER = 2;
+  }
+
+  required string name = 1;
+  optional int32 age = 2 [default = 18];
+  optional bool active = 3 [default = true];
+  optional Kind kind = 4 [default = USER];
+  repeated string tags = 5;
+  optional Address address = 6;
+}
+
+message Address {
+  required string city = 1;
+  optional double lat = 2;
+}`)
+
+	parser := &Parser{}
+	tree, err := parser.NewMessageFromSchema(schemaFile, "Person")
+	if err != nil {
+		t.Fatalf("NewMessageFromSchema failed: %v", err)
+	}
+
+	if tree.Name != "root" {
+		t.Errorf("Expected root node, got '%s'", tree.Name)
+	}
+	if len(tree.Children) != 6 {
+		t.Fatalf("Expected 6 fields, got %d", len(tree.Children))
+	}
+
+	name := tree.Children[0]
+	if name.Name != "name" || name.Type != "string" || name.Value != "" || !name.IsRequired {
+		t.Errorf("Unexpected name field: %+v", name)
+	}
+
+	age := tree.Children[1]
+	if age.Type != "int32" || age.Value != "18" || age.IsRequired {
+		t.Errorf("Expected age int32 with default 18, got %+v", age)
+	}
+
+	active := tree.Children[2]
+	if active.Type != "bool" || active.Value != true {
+		t.Errorf("Expected active bool with default true, got %+v", active)
+	}
+
+	kind := tree.Children[3]
+	if kind.Type != "int32" || kind.Value != "2" {
+		t.Errorf("Expected enum default USER=2 as int32, got %+v", kind)
+	}
+
+	tags := tree.Children[4]
+	if !tags.IsRepeated {
+		t.Errorf("Expected tags to be repeated")
+	}
+
+	address := tree.Children[5]
+	if address.Type != "Address" || len(address.Children) != 2 {
+		t.Fatalf("Expected Address message with 2 fields, got %+v", address)
+	}
+	if !address.Children[0].IsRequired || address.Children[0].Name != "city" {
+		t.Errorf("Expected required city in address, got %+v", address.Children[0])
+	}
+	if address.Children[1].Value != "0" {
+		t.Errorf("Expected lat default '0', got %v", address.Children[1].Value)
+	}
+}
+
+func TestNewMessageFromSchema_RecursiveMessage(t *testing.T) {
+	schemaFile := writeTestSchema(t, `syntax = "proto3";
+
+message Node {
+  string label = 1;
+  Node next = 2;
+}`)
+
+	parser := &Parser{}
+	tree, err := parser.NewMessageFromSchema(schemaFile, "")
+	if err != nil {
+		t.Fatalf("NewMessageFromSchema failed: %v", err)
+	}
+
+	if len(tree.Children) != 1 || tree.Children[0].Name != "label" {
+		t.Errorf("Expected only 'label' field (recursive reference skipped), got %d fields", len(tree.Children))
+	}
+}
+
+func TestNewMessageFromSchema_RequiredRecursiveMessageIsEmpty(t *testing.T) {
+	schemaFile := writeTestSchema(t, `syntax = "proto2";
+
+message Tree {
+  optional string label = 1;
+  required Tree parent = 2;
+  optional Tree child = 3;
+}`)
+
+	parser := &Parser{}
+	tree, err := parser.NewMessageFromSchema(schemaFile, "")
+	if err != nil {
+		t.Fatalf("NewMessageFromSchema failed: %v", err)
+	}
+
+	if len(tree.Children) != 2 || tree.Children[1].Name != "parent" {
+		t.Fatalf("Expected 'label' and the required 'parent' field, got %d fields", len(tree.Children))
+	}
+	parent := tree.Children[1]
+	if parent.Type != "message" || len(parent.Children) != 0 || !parent.IsRequired {
+		t.Errorf("Expected the recursive required field as an empty message, got %+v", parent)
+	}
+}
+
+func TestNewMessageFromSchema_FirstOneofMemberOnly(t *testing.T) {
+	schemaFile := writeTestSchema(t, `syntax = "proto3";
+
+message Payment {
+  string id = 1;
+  oneof method {
+    string card = 2;
+    string iban = 3;
+  }
+  oneof note {
+    string text = 4;
+  }
+}`)
+
+	parser := &Parser{}
+	tree, err := parser.NewMessageFromSchema(schemaFile, "")
+	if err != nil {
+		t.Fatalf("NewMessageFromSchema failed: %v", err)
+	}
+
+	names := make([]string, 0, len(tree.Children))
+	for _, child := range tree.Children {
+		names = append(names, child.Name)
+	}
+	if strings.Join(names, ",") != "id,card,text" {
+		t.Errorf("Expected only the first member of each oneof, got %v", names)
+	}
+}
+
+func TestNewMessageFromSchema_EmptyAndUnresolvedMessagesSerialize(t *testing.T) {
+	schemaFile := writeTestSchema(t, `syntax = "proto3";
+
+import "google/protobuf/timestamp.proto";
+
+message Order {
+  message Empty {
+  }
+
+  string id = 1;
+  Empty marker = 2;
+  google.protobuf.Timestamp created = 3;
+}`)
+
+	parser, err := NewParser()
+	if err != nil {
+		t.Fatalf("Failed to create parser: %v", err)
+	}
+	tree, err := parser.NewMessageFromSchema(schemaFile, "Order")
+	if err != nil {
+		t.Fatalf("NewMessageFromSchema failed: %v", err)
+	}
+	for _, field := range tree.Children[1:] {
+		if !field.IsMessage() {
+			t.Errorf("Expected %s to stay a message without fields, got type %q", field.Name, field.Type)
+		}
+	}
+
+	serializer := NewSerializer(parser.GetProtocPath())
+	if text := serializer.TreeToTextFormat(tree); !strings.Contains(text, "2 {\n}") || !strings.Contains(text, "3 {\n}") {
+		t.Errorf("Expected empty messages in text format, got:\n%s", text)
+	}
+
+	data, err := serializer.SerializeRaw(tree)
+	if err != nil {
+		t.Fatalf("Failed to save new message: %v", err)
+	}
+	decoded, err := DecodeLazy(context.Background(), data, nil)
+	if err != nil {
+		t.Fatalf("Failed to decode saved message: %v", err)
+	}
+	if len(decoded.Children) != 3 || decoded.Children[1].FieldNum != 2 || decoded.Children[2].FieldNum != 3 {
+		t.Errorf("Expected id, marker and created to be saved, got %+v", decoded.Children)
+	}
+}
+
+func TestNewMessageFromSchema_MessageNotFound(t *testing.T) {
+	schemaFile := writeTestSchema(t, `message A { optional int32 x = 1; }
+`)
+
+	parser := &Parser{}
+	if _, err := parser.NewMessageFromSchema(schemaFile, "Missing"); err == nil {
+		t.Error("Expected error for missing message")
+	}
+}
+
+func TestParseProtoSchema_EnumAndOneofDoNotCloseMessage(t *testing.T) {
+	parser := &Parser{}
+	schema, err := parser.parseProtoSchema(`syntax = "proto3";
+
+message Event {
+  enum Level {
+    INFO = 0;
+    WARN = 1; // warning
+  }
+  oneof payload {
+    string text = 1;
+    int64 code = 2;
+  }
+  Level level = 3;
+}`)
+	if err != nil {
+		t.Fatalf("parseProtoSchema failed: %v", err)
+	}
+
+	event := schema.messages["Event"]
+	if event == nil {
+		t.Fatal("Message 'Event' not found")
+	}
+	if len(event.fields) != 3 {
+		t.Fatalf("Expected 3 fields in Event (oneof fields included), got %d", len(event.fields))
+	}
+	if event.fields[2].fieldName != "level" {
+		t.Errorf("Expected field after oneof to belong to Event, got '%s'", event.fields[2].fieldName)
+	}
+
+	level := schema.findEnum("Level", event.fullName)
+	if level == nil || len(level.values) != 2 || level.values[1].name != "WARN" || level.values[1].number != 1 {
+		t.Errorf("Unexpected enum Level: %+v", level)
+	}
+}
+
+func TestParseFieldLine_Options(t *testing.T) {
+	parser := &Parser{}
+	field := parser.parseFieldLine(`optional string url = 4 [default = "http://a,b", deprecated = true];`)
+	if field == nil {
+		t.Fatal("Expected field to be parsed")
+	}
+	if field.fieldNum != 4 || field.fieldName != "url" {
+		t.Errorf("Unexpected field: %+v", field)
+	}
+	if !field.hasDefault || field.defaultValue != "http://a,b" {
+		t.Errorf("Expected default 'http://a,b', got '%s'", field.defaultValue)
+	}
+	if field.options["deprecated"] != "true" {
+		t.Errorf("Expected deprecated option, got %v", field.options)
+	}
+}
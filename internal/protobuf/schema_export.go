@@ -0,0 +1,563 @@
+package protobuf
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SchemaModelForTree строит модель схемы для дерева. Если указана схема, модель содержит
+// все ее типы, дополненные полями дерева, которых в схеме нет; иначе все имена придумываются,
+// а корневое сообщение называется Message. Ленивые узлы разбираются только на время
+// построения модели.
+func (p *Parser) SchemaModelForTree(tree *TreeNode, schemaPath string, messageName string) (*SchemaModel, error) {
+	if schemaPath == "" {
+		b := newSchemaModelBuilder(nil)
+		root := b.newSynthesizedMessage("Message")
+		b.model.Messages = append(b.model.Messages, root)
+		b.mergeSynthesized(root, tree)
+		b.model.Root = root.Name
+		return b.model, nil
+	}
+
+	schema, err := p.loadSchema(schemaPath)
+	if err != nil {
+		return nil, err
+	}
+	rootMessage, err := schema.resolveRootMessage(messageName)
+	if err != nil {
+		return nil, err
+	}
+
+	b := newSchemaModelBuilder(schema)
+	b.mergeKnown(b.converted[rootMessage], rootMessage, tree)
+	b.model.Root = rootMessage.messageName
+	return b.model, nil
+}
+
+// ExportSchema строит .proto для дерева. Если к дереву применена схема, сохраняются ее
+// пакет, имена сообщений и перечислений, вложенность и метки полей; имена придумываются
+// только для полей и сообщений, которых в схеме нет. syntax - "proto2" или "proto3".
+func (p *Parser) ExportSchema(tree *TreeNode, schemaPath string, messageName string, syntax string) (string, error) {
+	if syntax != "proto2" && syntax != "proto3" {
+		return "", fmt.Errorf("unsupported syntax %q, expected proto2 or proto3", syntax)
+	}
+
+	model, err := p.SchemaModelForTree(tree, schemaPath, messageName)
+	if err != nil {
+		return "", err
+	}
+	if syntax == "proto3" && model.Syntax != "proto3" {
+		if err := model.convertToProto3(); err != nil {
+			return "", err
+		}
+	}
+	model.Syntax = syntax
+	return model.Render(), nil
+}
+
+// convertToProto3 приводит модель proto2 схемы к тому, что допускает proto3, не меняя
+// кодирования данных: диапазоны extensions удаляются, поля блоков extend переносятся
+// в расширяемые сообщения обычными полями, а перечисления начинаются с нулевого значения.
+// Блоки extend опций google.protobuf разрешены и в proto3 и остаются как есть
+func (m *SchemaModel) convertToProto3() error {
+	var extensions []*SchemaExtension
+	kept := m.Extensions[:0]
+	for _, extension := range m.Extensions {
+		if isOptionsExtendee(extension.Extendee) {
+			kept = append(kept, extension)
+		} else {
+			extensions = append(extensions, extension)
+		}
+	}
+	m.Extensions = kept
+	m.walkMessages(func(msg *SchemaMessage, _ string) {
+		msg.ExtensionRanges = nil
+		keptNested := msg.Extensions[:0]
+		for _, extension := range msg.Extensions {
+			if isOptionsExtendee(extension.Extendee) {
+				keptNested = append(keptNested, extension)
+			} else {
+				extensions = append(extensions, extension)
+			}
+		}
+		msg.Extensions = keptNested
+	})
+
+	for _, extension := range extensions {
+		extendee := m.findExtendee(extension.Extendee)
+		if extendee == nil {
+			return fmt.Errorf("cannot export extensions of %s to proto3: the message is not part of the exported schema", extension.Extendee)
+		}
+		for _, field := range extension.Fields {
+			for _, existing := range extendee.Fields {
+				if existing.Name == field.Name {
+					return fmt.Errorf("cannot export extension %s of %s to proto3: the message already has a field with this name", field.Name, extension.Extendee)
+				}
+			}
+			extendee.Fields = append(extendee.Fields, field)
+		}
+	}
+
+	for _, enum := range m.Enums {
+		ensureZeroFirst(enum)
+	}
+	m.walkMessages(func(msg *SchemaMessage, _ string) {
+		for _, enum := range msg.Enums {
+			ensureZeroFirst(enum)
+		}
+	})
+	return nil
+}
+
+func isOptionsExtendee(extendee string) bool {
+	name := strings.TrimPrefix(extendee, ".")
+	return strings.HasPrefix(name, "google.protobuf.") && strings.HasSuffix(name, "Options")
+}
+
+// findExtendee ищет расширяемое сообщение по имени из блока extend: полному с пакетом,
+// относительному (Outer.Inner) или уникальному короткому
+func (m *SchemaModel) findExtendee(extendee string) *SchemaMessage {
+	name := strings.TrimPrefix(extendee, ".")
+	if m.Package != "" {
+		name = strings.TrimPrefix(name, m.Package+".")
+	}
+	if msg := m.FindMessageByFullName(name); msg != nil {
+		return msg
+	}
+
+	var found *SchemaMessage
+	matches := 0
+	m.walkMessages(func(msg *SchemaMessage, _ string) {
+		if msg.Name == name {
+			found = msg
+			matches++
+		}
+	})
+	if matches != 1 {
+		return nil
+	}
+	return found
+}
+
+// ensureZeroFirst переносит нулевое значение перечисления в начало, а если его нет,
+// добавляет значение <ENUM>_UNSPECIFIED = 0
+func ensureZeroFirst(enum *SchemaEnum) {
+	for i, value := range enum.Values {
+		if value.Number == 0 {
+			values := append([]SchemaEnumValue{value}, enum.Values[:i]...)
+			enum.Values = append(values, enum.Values[i+1:]...)
+			return
+		}
+	}
+	enum.Values = append([]SchemaEnumValue{{Name: enumValuePrefix(enum.Name) + "_UNSPECIFIED", Number: 0}}, enum.Values...)
+}
+
+// enumValuePrefix переводит имя перечисления в UPPER_SNAKE_CASE: HTTPStatus -> HTTP_STATUS
+func enumValuePrefix(name string) string {
+	isUpper := func(r rune) bool { return r >= 'A' && r <= 'Z' }
+	isLower := func(r rune) bool { return (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') }
+
+	runes := []rune(name)
+	var builder strings.Builder
+	for i, r := range runes {
+		if i > 0 && isUpper(r) && (isLower(runes[i-1]) || (i+1 < len(runes) && isUpper(runes[i-1]) && isLower(runes[i+1]))) {
+			builder.WriteByte('_')
+		}
+		builder.WriteRune(r)
+	}
+	return strings.ToUpper(builder.String())
+}
+
+// schemaModelBuilder переносит разобранную схему в модель и дополняет ее полями дерева
+type schemaModelBuilder struct {
+	schema    *protoSchema
+	model     *SchemaModel
+	usedNames map[string]bool
+	converted map[*schemaMessageInfo]*SchemaMessage
+	counter   int
+}
+
+func newSchemaModelBuilder(schema *protoSchema) *schemaModelBuilder {
+	b := &schemaModelBuilder{
+		schema:    schema,
+		model:     &SchemaModel{Syntax: "proto2"},
+		usedNames: make(map[string]bool),
+		converted: make(map[*schemaMessageInfo]*SchemaMessage),
+	}
+	if schema == nil {
+		return b
+	}
+
+	if schema.syntax != "" {
+		b.model.Syntax = schema.syntax
+	}
+	b.model.Package = schema.packageName
+	b.model.Imports = append(b.model.Imports, schema.imports...)
+	for _, message := range schema.messages {
+		b.usedNames[message.messageName] = true
+	}
+	for _, enum := range schema.enums {
+		b.usedNames[enum.enumName] = true
+	}
+	for _, name := range schema.topLevelEnums {
+		if enum := schema.lookupEnum(name); enum != nil {
+			b.model.Enums = append(b.model.Enums, convertSchemaEnum(enum))
+		}
+	}
+	for _, name := range schema.topLevelMessages {
+		if message := schema.lookupMessage(name); message != nil {
+			b.model.Messages = append(b.model.Messages, b.convertMessage(message))
+		}
+	}
+	b.model.Extensions = b.convertExtensions(nil)
+	return b
+}
+
+// convertExtensions переносит блоки extend основного файла, объявленные в scope
+func (b *schemaModelBuilder) convertExtensions(scope *schemaMessageInfo) []*SchemaExtension {
+	var result []*SchemaExtension
+	lastBlock := -1
+	for _, extension := range b.schema.extensions {
+		if extension.imported || extension.scope != scope {
+			continue
+		}
+		if extension.block != lastBlock {
+			result = append(result, &SchemaExtension{Extendee: extension.extendee})
+			lastBlock = extension.block
+		}
+		current := result[len(result)-1]
+		current.Fields = append(current.Fields, convertSchemaField(extension.field, extension.name))
+	}
+	return result
+}
+
+func convertSchemaField(field *schemaFieldInfo, name string) *SchemaField {
+	label := "optional"
+	if field.isRepeated {
+		label = "repeated"
+	} else if field.isRequired {
+		label = "required"
+	}
+	return &SchemaField{
+		Label:   label,
+		Type:    field.fieldType,
+		Name:    name,
+		Number:  field.fieldNum,
+		Options: field.options,
+		Oneof:   field.oneof,
+		Group:   field.isGroup,
+	}
+}
+
+func convertSchemaEnum(enum *schemaEnumInfo) *SchemaEnum {
+	result := &SchemaEnum{Name: enum.enumName}
+	for _, value := range enum.values {
+		result.Values = append(result.Values, SchemaEnumValue{Name: value.name, Number: value.number})
+	}
+	return result
+}
+
+func (b *schemaModelBuilder) convertMessage(message *schemaMessageInfo) *SchemaMessage {
+	result := &SchemaMessage{Name: message.messageName, synthesizedChildren: make(map[int]*SchemaMessage)}
+	b.converted[message] = result
+
+	for _, field := range message.fields {
+		result.Fields = append(result.Fields, convertSchemaField(field, field.fieldName))
+	}
+	for _, name := range message.nestedOrder {
+		result.Messages = append(result.Messages, b.convertMessage(message.messages[name]))
+	}
+	for _, name := range message.enumOrder {
+		result.Enums = append(result.Enums, convertSchemaEnum(message.enums[name]))
+	}
+	result.Extensions = b.convertExtensions(message)
+	result.ExtensionRanges = append(result.ExtensionRanges, message.extensionRanges...)
+	return result
+}
+
+func (b *schemaModelBuilder) newSynthesizedMessage(name string) *SchemaMessage {
+	b.usedNames[name] = true
+	return &SchemaMessage{Name: name, synthesizedChildren: make(map[int]*SchemaMessage)}
+}
+
+func (b *schemaModelBuilder) nextMessageName() string {
+	for {
+		b.counter++
+		name := fmt.Sprintf("Message%d", b.counter)
+		if !b.usedNames[name] {
+			b.usedNames[name] = true
+			return name
+		}
+	}
+}
+
+// mergeKnown дополняет сообщение схемы полями дерева, которых в схеме нет,
+// и спускается во вложенные сообщения, описанные схемой
+func (b *schemaModelBuilder) mergeKnown(target *SchemaMessage, message *schemaMessageInfo, node *TreeNode) {
+	fieldMap := make(map[int]*schemaFieldInfo)
+	for _, field := range message.fields {
+		fieldMap[field.fieldNum] = field
+	}
+
+	extensions := b.schema.extensionsFor(message)
+
+	unknown := &TreeNode{Name: node.Name, Type: node.Type}
+	for _, child := range node.fields() {
+		scope := message.fullName
+		fieldInfo, ok := fieldMap[child.FieldNum]
+		if extension, found := extensions[child.FieldNum]; !ok && found {
+			fieldInfo, ok = extension.field, true
+			scope = extension.scopeName
+		}
+		if !ok {
+			unknown.Children = append(unknown.Children, child)
+			continue
+		}
+		nested := b.schema.findMessage(fieldInfo.fieldType, scope)
+		// Сообщения из импортированных файлов в модель не входят и не дополняются
+		if nested != nil && b.converted[nested] != nil && b.schema.findEnum(fieldInfo.fieldType, scope) == nil {
+			b.mergeKnown(b.converted[nested], nested, child)
+		}
+	}
+
+	if len(unknown.Children) > 0 {
+		b.mergeSynthesized(target, unknown)
+	}
+}
+
+// mergeSynthesized добавляет в сообщение поля узла дерева с придуманными именами.
+// Повторные вхождения поля делают его repeated, вложенные сообщения одного поля объединяются.
+func (b *schemaModelBuilder) mergeSynthesized(target *SchemaMessage, node *TreeNode) {
+	existing := make(map[int]*SchemaField)
+	for _, field := range target.Fields {
+		existing[field.Number] = field
+	}
+
+	// Поля ленивого узла разбираются один раз и только на время обхода
+	children := node.fields()
+	counts := make(map[int]int)
+	for _, child := range children {
+		counts[child.FieldNum]++
+	}
+
+	for _, child := range children {
+		field, ok := existing[child.FieldNum]
+		if !ok {
+			field = &SchemaField{
+				Label:       "optional",
+				Name:        fieldPathName(child),
+				Number:      child.FieldNum,
+				synthesized: true,
+			}
+			if child.IsMessage() {
+				nested := b.newSynthesizedMessage(b.nextMessageName())
+				target.Messages = append(target.Messages, nested)
+				target.synthesizedChildren[child.FieldNum] = nested
+				field.Type = nested.Name
+				if child.IsGroup {
+					field.Group = true
+					field.Name = strings.ToLower(nested.Name)
+				}
+			} else {
+				field.Type = NewSerializer("").MapTypeToProtoType(child.Type)
+			}
+			target.Fields = append(target.Fields, field)
+			existing[child.FieldNum] = field
+		}
+
+		if !field.synthesized {
+			continue
+		}
+		if counts[child.FieldNum] > 1 || child.IsRepeated {
+			field.Label = "repeated"
+		}
+		if nested, ok := target.synthesizedChildren[child.FieldNum]; ok && child.IsMessage() {
+			b.mergeSynthesized(nested, child)
+		}
+	}
+
+	sort.SliceStable(target.Fields, func(i, j int) bool {
+		// Поля схемы сохраняют исходный порядок, придуманные добавляются по номеру
+		if target.Fields[i].synthesized != target.Fields[j].synthesized {
+			return !target.Fields[i].synthesized
+		}
+		if target.Fields[i].synthesized {
+			return target.Fields[i].Number < target.Fields[j].Number
+		}
+		return false
+	})
+}
+
+// Render возвращает текст .proto файла в синтаксисе модели
+func (m *SchemaModel) Render() string {
+	syntax := m.Syntax
+	if syntax == "" {
+		syntax = "proto2"
+	}
+
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("syntax = \"%s\";\n", syntax))
+
+	if m.Package != "" {
+		builder.WriteString(fmt.Sprintf("\npackage %s;\n", m.Package))
+	}
+	if len(m.Imports) > 0 {
+		builder.WriteString("\n")
+		for _, imp := range m.Imports {
+			builder.WriteString(imp + "\n")
+		}
+	}
+
+	for _, enum := range m.Enums {
+		builder.WriteString("\n")
+		writeSchemaEnum(&builder, enum, "")
+	}
+	for _, message := range m.Messages {
+		builder.WriteString("\n")
+		writeSchemaMessage(&builder, message, "", syntax)
+	}
+	for _, extension := range m.Extensions {
+		builder.WriteString("\n")
+		writeSchemaExtension(&builder, extension, "", syntax)
+	}
+	return builder.String()
+}
+
+func writeSchemaExtension(builder *strings.Builder, extension *SchemaExtension, indent string, syntax string) {
+	builder.WriteString(fmt.Sprintf("%sextend %s {\n", indent, extension.Extendee))
+	for _, field := range extension.Fields {
+		builder.WriteString(fmt.Sprintf("%s  %s\n", indent, field.declaration(syntax)))
+	}
+	builder.WriteString(fmt.Sprintf("%s}\n", indent))
+}
+
+func writeSchemaEnum(builder *strings.Builder, enum *SchemaEnum, indent string) {
+	builder.WriteString(fmt.Sprintf("%senum %s {\n", indent, enum.Name))
+	for _, value := range enum.Values {
+		builder.WriteString(fmt.Sprintf("%s  %s = %d;\n", indent, value.Name, value.Number))
+	}
+	builder.WriteString(fmt.Sprintf("%s}\n", indent))
+}
+
+func writeSchemaMessage(builder *strings.Builder, message *SchemaMessage, indent string, syntax string) {
+	builder.WriteString(fmt.Sprintf("%smessage %s {\n", indent, message.Name))
+	writeSchemaMessageBody(builder, message, indent+"  ", syntax)
+	builder.WriteString(fmt.Sprintf("%s}\n", indent))
+}
+
+// writeSchemaMessageBody выводит вложенные типы и поля сообщения. В proto2 сообщения групп
+// выводятся не среди вложенных типов, а телом объявления группы
+func writeSchemaMessageBody(builder *strings.Builder, message *SchemaMessage, inner string, syntax string) {
+	groups := make(map[string]*SchemaMessage)
+	if syntax != "proto3" {
+		for _, field := range message.Fields {
+			if !field.Group {
+				continue
+			}
+			for _, nested := range message.Messages {
+				if nested.Name == field.Type {
+					groups[field.Type] = nested
+				}
+			}
+		}
+	}
+
+	for _, enum := range message.Enums {
+		writeSchemaEnum(builder, enum, inner)
+	}
+	for _, nested := range message.Messages {
+		if groups[nested.Name] == nested {
+			continue
+		}
+		writeSchemaMessage(builder, nested, inner, syntax)
+	}
+	for _, extension := range message.Extensions {
+		writeSchemaExtension(builder, extension, inner, syntax)
+	}
+
+	currentOneof := ""
+	for _, field := range message.Fields {
+		if field.Oneof != currentOneof {
+			if currentOneof != "" {
+				builder.WriteString(fmt.Sprintf("%s}\n", inner))
+			}
+			if field.Oneof != "" {
+				builder.WriteString(fmt.Sprintf("%soneof %s {\n", inner, field.Oneof))
+			}
+			currentOneof = field.Oneof
+		}
+
+		fieldIndent := inner
+		if field.Oneof != "" {
+			fieldIndent += "  "
+		}
+		if group, ok := groups[field.Type]; ok && field.Group {
+			builder.WriteString(fieldIndent + field.groupDeclaration() + " {\n")
+			writeSchemaMessageBody(builder, group, fieldIndent+"  ", syntax)
+			builder.WriteString(fieldIndent + "}\n")
+			continue
+		}
+		builder.WriteString(fieldIndent + field.declaration(syntax) + "\n")
+	}
+	if currentOneof != "" {
+		builder.WriteString(fmt.Sprintf("%s}\n", inner))
+	}
+	for _, extensionRange := range message.ExtensionRanges {
+		builder.WriteString(fmt.Sprintf("%sextensions %s;\n", inner, extensionRange))
+	}
+}
+
+// groupDeclaration формирует заголовок proto2 группы без открывающей скобки
+func (f *SchemaField) groupDeclaration() string {
+	declaration := fmt.Sprintf("group %s = %d", f.Type, f.Number)
+	if f.Oneof == "" {
+		declaration = f.Label + " " + declaration
+	}
+	return declaration
+}
+
+// declaration формирует объявление поля с учетом синтаксиса: в proto3 нет required
+// и [default = ...], а поля oneof объявляются без метки
+func (f *SchemaField) declaration(syntax string) string {
+	label := f.Label
+	comment := ""
+	if f.Oneof != "" {
+		label = ""
+	} else if syntax == "proto3" {
+		switch label {
+		case "optional":
+			label = ""
+		case "required":
+			label = ""
+			comment = " // required in the original schema"
+		}
+	}
+
+	keys := make([]string, 0, len(f.Options))
+	for key := range f.Options {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	options := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if key == "default" && syntax == "proto3" {
+			continue
+		}
+		options = append(options, fmt.Sprintf("%s = %s", key, f.Options[key]))
+	}
+
+	if f.Group && syntax == "proto3" {
+		// В proto3 нет групп: поле объявляется обычным сообщением, что меняет wire type
+		comment = " // group in the original schema"
+	}
+
+	declaration := fmt.Sprintf("%s %s = %d", f.Type, f.Name, f.Number)
+	if label != "" {
+		declaration = label + " " + declaration
+	}
+	if len(options) > 0 {
+		declaration += " [" + strings.Join(options, ", ") + "]"
+	}
+	return declaration + ";" + comment
+}
@@ -0,0 +1,185 @@
+package protobuf
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExportSchema_PreservesAppliedSchema(t *testing.T) {
+	schemaFile := writeTestSchema(t, `syntax = "proto2";
+
+package shop.v1;
+
+enum Status {
+  UNKNOWN = 0;
+  PAID = 1;
+}
+
+message Order {
+  message Line {
+    required string sku = 1;
+    optional int32 qty = 2 [default = 1];
+  }
+
+  required string id = 1;
+  repeated Line lines = 2;
+  optional Status status = 3;
+  oneof payment {
+    string card = 4;
+    string cash = 5;
+  }
+}`)
+	tree := newTestRoot(
+		&TreeNode{Name: "field_1", Type: "string", FieldNum: 1, Value: "A-1"},
+		&TreeNode{
+			Name: "field_2", Type: "message_1", FieldNum: 2,
+			Children: []*TreeNode{
+				{Name: "field_1", Type: "string", FieldNum: 1, Value: "X"},
+				{Name: "field_7", Type: "int64", FieldNum: 7, Value: "5"},
+			},
+		},
+		&TreeNode{Name: "field_3", Type: "bool", FieldNum: 3, Value: true},
+		&TreeNode{
+			Name: "field_9", Type: "message_2", FieldNum: 9,
+			Children: []*TreeNode{
+				{Name: "field_1", Type: "string", FieldNum: 1, Value: "note"},
+			},
+		},
+		&TreeNode{Name: "field_10", Type: "int64", FieldNum: 10, Value: "1"},
+		&TreeNode{Name: "field_10", Type: "int64", FieldNum: 10, Value: "2"},
+	)
+
+	parser := &Parser{}
+	tree, _, err := parser.ApplySchemaWithMessage(tree, schemaFile, "Order")
+	if err != nil {
+		t.Fatalf("ApplySchemaWithMessage failed: %v", err)
+	}
+
+	proto, err := parser.ExportSchema(tree, schemaFile, "Order", "proto2")
+	if err != nil {
+		t.Fatalf("ExportSchema failed: %v", err)
+	}
+
+	expected := []string{
+		`syntax = "proto2";`,
+		"package shop.v1;",
+		"enum Status {\n  UNKNOWN = 0;\n  PAID = 1;\n}",
+		"message Order {",
+		"  message Line {",
+		"    required string sku = 1;",
+		"    optional int32 qty = 2 [default = 1];",
+		"    optional int64 field_7 = 7;",
+		"  required string id = 1;",
+		"  repeated Line lines = 2;",
+		"  optional Status status = 3;",
+		"  oneof payment {\n    string card = 4;\n    string cash = 5;\n  }",
+		"  message Message1 {\n    optional string field_1 = 1;\n  }",
+		"  optional Message1 field_9 = 9;",
+		"  repeated int64 field_10 = 10;",
+	}
+	for _, fragment := range expected {
+		if !strings.Contains(proto, fragment) {
+			t.Errorf("Expected exported schema to contain %q, got:\n%s", fragment, proto)
+		}
+	}
+	if strings.Contains(proto, "message Message {") {
+		t.Errorf("Expected schema message names to be reused, got:\n%s", proto)
+	}
+}
+
+func TestExportSchema_Proto3(t *testing.T) {
+	schemaFile := writeTestSchema(t, `syntax = "proto2";
+
+message Order {
+  required string id = 1;
+  optional int32 qty = 2 [default = 1];
+}`)
+	tree := newTestRoot(&TreeNode{Name: "field_1", Type: "string", FieldNum: 1, Value: "A-1"})
+	parser := &Parser{}
+
+	proto, err := parser.ExportSchema(tree, schemaFile, "Order", "proto3")
+	if err != nil {
+		t.Fatalf("ExportSchema failed: %v", err)
+	}
+
+	if !strings.Contains(proto, `syntax = "proto3";`) {
+		t.Errorf("Expected proto3 syntax, got:\n%s", proto)
+	}
+	if strings.Contains(proto, "required string") || strings.Contains(proto, "optional ") || strings.Contains(proto, "default") {
+		t.Errorf("Expected proto3 output without required/optional labels and defaults, got:\n%s", proto)
+	}
+	if !strings.Contains(proto, "string id = 1; // required in the original schema") {
+		t.Errorf("Expected note about dropped required label, got:\n%s", proto)
+	}
+}
+
+func TestExportSchema_WithoutSchema(t *testing.T) {
+	tree := newTestRoot(
+		&TreeNode{Name: "field_1", Type: "string", FieldNum: 1, Value: "A-1"},
+		&TreeNode{
+			Name: "field_2", Type: "message_1", FieldNum: 2,
+			Children: []*TreeNode{
+				{Name: "field_1", Type: "string", FieldNum: 1, Value: "X"},
+			},
+		},
+	)
+
+	parser := &Parser{}
+	proto, err := parser.ExportSchema(tree, "", "", "proto2")
+	if err != nil {
+		t.Fatalf("ExportSchema failed: %v", err)
+	}
+	if !strings.Contains(proto, "message Message {") || !strings.Contains(proto, "optional Message1 field_2 = 2;") {
+		t.Errorf("Expected synthesized names without schema, got:\n%s", proto)
+	}
+
+	if _, err := parser.ExportSchema(tree, "", "", "proto4"); err == nil {
+		t.Error("Expected error for unsupported syntax")
+	}
+}
+
+func TestExportSchema_Proto3ConvertsExtensionsAndEnums(t *testing.T) {
+	schemaFile := writeTestSchema(t, `syntax = "proto2";
+
+package shop;
+
+enum Level {
+  HIGH = 1;
+  LOW = 0;
+}
+
+enum HTTPKind {
+  RETAIL = 1;
+}
+
+message Order {
+  optional string id = 1;
+  optional Level level = 2;
+  optional HTTPKind kind = 3;
+  extensions 100 to 199;
+}
+
+extend Order {
+  optional int32 priority = 100;
+}`)
+	parser := &Parser{}
+
+	proto, err := parser.ExportSchema(newTestRoot(), schemaFile, "Order", "proto3")
+	if err != nil {
+		t.Fatalf("ExportSchema failed: %v", err)
+	}
+
+	if strings.Contains(proto, "extensions ") || strings.Contains(proto, "extend ") {
+		t.Errorf("Expected proto3 output without extension ranges and extend blocks, got:\n%s", proto)
+	}
+	expected := []string{
+		"  int32 priority = 100;\n}",
+		"enum Level {\n  LOW = 0;\n  HIGH = 1;\n}",
+		"enum HTTPKind {\n  HTTP_KIND_UNSPECIFIED = 0;\n  RETAIL = 1;\n}",
+	}
+	for _, fragment := range expected {
+		if !strings.Contains(proto, fragment) {
+			t.Errorf("Expected exported schema to contain %q, got:\n%s", fragment, proto)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package protobuf
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// detectMaxDepth ограничивает глубину, на которую Detect сравнивает вложенные сообщения
+	detectMaxDepth = 4
+	// detectMaxSamples - сколько повторений одного поля сравнивается со схемой вложенного сообщения
+	detectMaxSamples = 3
+	// detectRequiredPenalty - штраф за каждое отсутствующее required поле
+	detectRequiredPenalty = 0.1
+)
+
+// SchemaLibrary - проиндексированные схемы из каталогов и файлов библиотеки. Каждое сообщение
+// из каждой схемы становится кандидатом при определении типа данных
+type SchemaLibrary struct {
+	entries []*libraryEntry
+	// Skipped содержит схемы, которые не удалось загрузить, с причиной
+	Skipped []string
+}
+
+type libraryEntry struct {
+	schemaPath string
+	schema     *protoSchema
+	// declared - сообщения, объявленные в самом файле, без пришедших из импортов
+	declared []string
+}
+
+// TypeCandidate - сообщение библиотеки, которое может описывать данные
+type TypeCandidate struct {
+	SchemaPath  string
+	Package     string
+	MessageName string
+	// Score - степень совпадения от 0 до 1
+	Score float64
+	// Matched - сколько различных номеров полей верхнего уровня совпало со схемой по номеру
+	// и wire type; Total - сколько их всего в данных
+	Matched int
+	Total   int
+}
+
+// LoadSchemaLibrary загружает схемы по путям библиотеки. Каталоги обходятся рекурсивно, из них
+// берутся .proto файлы и наборы дескрипторов; скрытые каталоги пропускаются. Схемы с ошибками
+// не прерывают загрузку и перечисляются в Skipped
+func (p *Parser) LoadSchemaLibrary(ctx context.Context, paths []string) (*SchemaLibrary, error) {
+	library := &SchemaLibrary{}
+	for _, root := range paths {
+		err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				library.Skipped = append(library.Skipped, fmt.Sprintf("%s: %v", path, err))
+				return nil
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if entry.IsDir() {
+				if path != root && strings.HasPrefix(entry.Name(), ".") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !isSchemaFile(path) {
+				return nil
+			}
+			if err := library.add(p, path); err != nil {
+				library.Skipped = append(library.Skipped, fmt.Sprintf("%s: %v", path, err))
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return library, nil
+}
+
+func isSchemaFile(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".proto") || IsDescriptorSetPath(path)
+}
+
+func (l *SchemaLibrary) add(p *Parser, schemaPath string) error {
+	schema, err := p.loadSchema(schemaPath)
+	if err != nil {
+		return err
+	}
+
+	entry := &libraryEntry{schemaPath: schemaPath, schema: schema}
+	if IsDescriptorSetPath(schemaPath) {
+		for name := range schema.messages {
+			entry.declared = append(entry.declared, name)
+		}
+	} else {
+		// Импортированные сообщения индексируются в своих файлах, поэтому здесь учитываются
+		// только объявленные в самом файле
+		content, err := os.ReadFile(schemaPath)
+		if err != nil {
+			return err
+		}
+		own, err := p.parseProtoSchema(string(content))
+		if err != nil {
+			return err
+		}
+		for name := range own.messages {
+			entry.declared = append(entry.declared, name)
+		}
+	}
+	sort.Strings(entry.declared)
+	l.entries = append(l.entries, entry)
+	return nil
+}
+
+// Messages возвращает число сообщений в библиотеке
+func (l *SchemaLibrary) Messages() int {
+	count := 0
+	for _, entry := range l.entries {
+		count += len(entry.declared)
+	}
+	return count
+}
+
+// Detect сравнивает дерево со всеми сообщениями библиотеки и возвращает не более limit лучших
+// кандидатов по убыванию Score. Поле данных совпадает, если в сообщении есть поле с тем же
+// номером и совместимым wire type; для вложенных сообщений совпадение уточняется рекурсивно.
+// Кандидаты без единого совпавшего поля не возвращаются
+func (l *SchemaLibrary) Detect(tree *TreeNode, limit int) []TypeCandidate {
+	candidates := make([]TypeCandidate, 0)
+	for _, entry := range l.entries {
+		for _, name := range entry.declared {
+			message := entry.schema.messages[name]
+			score, matched, total := entry.schema.scoreMessage(tree, message, 0)
+			if matched == 0 {
+				continue
+			}
+			candidates = append(candidates, TypeCandidate{
+				SchemaPath:  entry.schemaPath,
+				Package:     entry.schema.packageName,
+				MessageName: entry.schema.relativeName(name),
+				Score:       score,
+				Matched:     matched,
+				Total:       total,
+			})
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].Score != candidates[j].Score {
+			return candidates[i].Score > candidates[j].Score
+		}
+		if candidates[i].MessageName != candidates[j].MessageName {
+			return candidates[i].MessageName < candidates[j].MessageName
+		}
+		return candidates[i].SchemaPath < candidates[j].SchemaPath
+	})
+	if limit > 0 && len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+	return candidates
+}
+
+// scoreMessage оценивает, насколько узел соответствует сообщению. Оценка складывается из доли
+// совпавших полей данных (основной вес) и доли использованных полей схемы, за отсутствующие
+// required поля вычитается штраф
+func (s *protoSchema) scoreMessage(node *TreeNode, message *schemaMessageInfo, depth int) (float64, int, int) {
+	node.Expand()
+
+	occurrences := make(map[int][]*TreeNode)
+	order := make([]int, 0)
+	for _, child := range node.Children {
+		if _, seen := occurrences[child.FieldNum]; !seen {
+			order = append(order, child.FieldNum)
+		}
+		occurrences[child.FieldNum] = append(occurrences[child.FieldNum], child)
+	}
+
+	fields := make(map[int]*schemaFieldInfo)
+	for _, field := range message.fields {
+		fields[field.fieldNum] = field
+	}
+	extensions := s.extensionsFor(message)
+
+	fit, matched := 0.0, 0
+	for _, fieldNum := range order {
+		field, scope := fields[fieldNum], message.fullName
+		if field == nil {
+			if extension, ok := extensions[fieldNum]; ok {
+				field, scope = extension.field, extension.scopeName
+			}
+		}
+		if field == nil {
+			continue
+		}
+		fieldScore := s.scoreField(occurrences[fieldNum], field, scope, depth)
+		if fieldScore > 0 {
+			matched++
+		}
+		fit += fieldScore
+	}
+
+	if len(order) == 0 {
+		// Пустое сообщение подходит под любую схему, но ничего о ней не говорит
+		return 0.5, 0, 0
+	}
+
+	coverage := 0.0
+	if len(message.fields) > 0 {
+		coverage = float64(matched) / float64(len(message.fields))
+	}
+	score := 0.8*fit/float64(len(order)) + 0.2*coverage
+	for _, field := range message.fields {
+		if field.isRequired && len(occurrences[field.fieldNum]) == 0 {
+			score -= detectRequiredPenalty
+		}
+	}
+	if score < 0 {
+		score = 0
+	}
+	return score, matched, len(order)
+}
+
+// scoreField оценивает повторения одного поля данных: 0 при несовместимом wire type (в том числе
+// fixed32 вместо fixed64 и наоборот), 1 для совпавших скаляров, для вложенных сообщений - в зависимости от совпадения их содержимого
+func (s *protoSchema) scoreField(nodes []*TreeNode, field *schemaFieldInfo, scope string, depth int) float64 {
+	expected, nested := s.expectedWireType(field, scope)
+	for _, node := range nodes {
+		actual := wireTypeOfNode(node, node.Type)
+		packed := field.isRepeated && nested == nil && actual == wireTypeLen && expected != wireTypeGroup
+		if actual != "" && expected != "" && actual != expected && !packed {
+			return 0
+		}
+	}
+
+	if nested == nil || depth >= detectMaxDepth {
+		return 1
+	}
+	total, samples := 0.0, 0
+	for _, node := range nodes {
+		if samples == detectMaxSamples {
+			break
+		}
+		if !node.IsMessage() {
+			// Сообщение, которое protoc не разобрал как вложенное (например, пустое),
+			// не подтверждает и не опровергает схему
+			continue
+		}
+		score, _, _ := s.scoreMessage(node, nested, depth+1)
+		total += score
+		samples++
+	}
+	if samples == 0 {
+		return 1
+	}
+	return 0.5 + 0.5*total/float64(samples)
+}
+
+// expectedWireType возвращает wire type поля по схеме и схему вложенного сообщения, если поле -
+// сообщение или группа. Для типов, которых нет в схеме (например, из неразрешенных импортов),
+// wire type неизвестен и возвращается пустая строка
+func (s *protoSchema) expectedWireType(field *schemaFieldInfo, scope string) (string, *schemaMessageInfo) {
+	if nested := s.findMessage(field.fieldType, scope); nested != nil {
+		if field.isGroup {
+			return wireTypeGroup, nested
+		}
+		return wireTypeLen, nested
+	}
+	if s.findEnum(field.fieldType, scope) != nil {
+		return wireTypeVarint, nil
+	}
+	if isScalarProtoType(field.fieldType) {
+		return wireTypeOfProtoType(field.fieldType), nil
+	}
+	return "", nil
+}
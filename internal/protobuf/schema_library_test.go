@@ -0,0 +1,165 @@
+package protobuf
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSchemaLibrary_DetectRanksCandidates(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"user.proto": `syntax = "proto3";
+package people;
+message User {
+  string name = 1;
+  int64 id = 2;
+  Address address = 3;
+}
+message Address {
+  string city = 1;
+  string street = 2;
+}`,
+		// Совпадает с User по номерам полей, но не по wire type поля 2 и содержимому поля 3
+		"log.proto": `syntax = "proto3";
+message LogEntry {
+  string message = 1;
+  double timestamp = 2;
+  bytes payload = 3;
+  int32 level = 4;
+}`,
+		"nested/point.proto": `syntax = "proto3";
+message Point {
+  fixed32 x = 1;
+  fixed32 y = 2;
+}`,
+		".hidden/skip.proto": `message Hidden { string name = 1; }`,
+		"shop.desc":          string(testDescriptorSet()),
+		"broken.pb":          "\x0a\x05\x01",
+		"README.md":          "not a schema",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	library, err := (&Parser{}).LoadSchemaLibrary(context.Background(), []string{dir})
+	if err != nil {
+		t.Fatalf("LoadSchemaLibrary failed: %v", err)
+	}
+	if library.Messages() != 6 {
+		t.Errorf("Expected 6 indexed messages, got %d", library.Messages())
+	}
+	if len(library.Skipped) != 1 {
+		t.Errorf("Expected the broken schema to be skipped, got %v", library.Skipped)
+	}
+
+	// 1: "Ann", 2: 7, 3: { 1: "Kazan", 2: "Lenina" }
+	data := []byte{
+		0x0a, 0x03, 'A', 'n', 'n',
+		0x10, 0x07,
+		0x1a, 0x0f, 0x0a, 0x05, 'K', 'a', 'z', 'a', 'n', 0x12, 0x06, 'L', 'e', 'n', 'i', 'n', 'a',
+	}
+	tree, err := DecodeLazy(context.Background(), data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	candidates := library.Detect(tree, 3)
+	if len(candidates) != 3 {
+		t.Fatalf("Expected 3 candidates, got %+v", candidates)
+	}
+	best := candidates[0]
+	if best.MessageName != "User" || best.Package != "people" || filepath.Base(best.SchemaPath) != "user.proto" {
+		t.Fatalf("Expected people.User to rank first, got %+v", candidates)
+	}
+	if best.Matched != 3 || best.Total != 3 || best.Score <= candidates[1].Score {
+		t.Errorf("Expected User to match all fields with the best score, got %+v", candidates)
+	}
+	for _, candidate := range candidates {
+		if candidate.MessageName == "Point" {
+			t.Errorf("Expected Point to be ruled out by wire types, got %+v", candidates)
+		}
+	}
+
+	all := library.Detect(tree, 0)
+	for i := 1; i < len(all); i++ {
+		if all[i-1].Score < all[i].Score {
+			t.Fatalf("Expected candidates sorted by score, got %+v", all)
+		}
+	}
+}
+
+func TestSchemaLibrary_RequiredFieldsPenalty(t *testing.T) {
+	schema, err := parseDescriptorSet(testDescriptorSet())
+	if err != nil {
+		t.Fatal(err)
+	}
+	order := schema.messages["shop.Order"]
+
+	withID := newTestRoot(
+		&TreeNode{Name: "field_1", Type: "string", FieldNum: 1, Value: "A-1"},
+		&TreeNode{Name: "field_3", Type: "int64", FieldNum: 3, Value: "1"},
+	)
+	withoutID := newTestRoot(
+		&TreeNode{Name: "field_3", Type: "int64", FieldNum: 3, Value: "1"},
+	)
+
+	complete, _, _ := schema.scoreMessage(withID, order, 0)
+	missing, _, _ := schema.scoreMessage(withoutID, order, 0)
+	if missing >= complete {
+		t.Errorf("Expected a missing required field to lower the score, got %v >= %v", missing, complete)
+	}
+}
+
+func TestSchemaLibrary_DetectTellsFixed32FromFixed64(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"reading.proto": `syntax = "proto3";
+message Reading {
+  float value = 1;
+  string unit = 2;
+}`,
+		"sample.proto": `syntax = "proto3";
+message Sample {
+  double value = 1;
+  string unit = 2;
+}`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	library, err := (&Parser{}).LoadSchemaLibrary(context.Background(), []string{dir})
+	if err != nil {
+		t.Fatalf("LoadSchemaLibrary failed: %v", err)
+	}
+
+	// 1: 1.5f (fixed32), 2: "C"
+	data := []byte{0x0d, 0x00, 0x00, 0xc0, 0x3f, 0x12, 0x01, 'C'}
+	tree, err := DecodeLazy(context.Background(), data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	candidates := library.Detect(tree, 0)
+	if len(candidates) != 2 || candidates[0].MessageName != "Reading" {
+		t.Fatalf("Expected Reading to rank first, got %+v", candidates)
+	}
+	if candidates[0].Matched != 2 || candidates[1].Matched != 1 {
+		t.Errorf("Expected the fixed32 value not to match the double field, got %+v", candidates)
+	}
+
+	// Узел, созданный вручную, без прочитанного wire type оценивается по своему типу
+	edited := newTestRoot(&TreeNode{Name: "value", Type: "fixed64", FieldNum: 1, Value: "1"})
+	candidates = library.Detect(edited, 0)
+	if len(candidates) != 1 || candidates[0].MessageName != "Sample" {
+		t.Errorf("Expected only Sample to match a fixed64 value, got %+v", candidates)
+	}
+}
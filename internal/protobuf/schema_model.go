@@ -0,0 +1,366 @@
+package protobuf
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// SchemaModel - редактируемое представление .proto файла: его можно изменять в UI,
+// применять к дереву и сохранять обратно в текст
+type SchemaModel struct {
+	Syntax   string
+	Package  string
+	Imports  []string
+	Enums    []*SchemaEnum
+	Messages []*SchemaMessage
+	// Extensions - блоки extend верхнего уровня
+	Extensions []*SchemaExtension
+	// Root - имя сообщения, соответствующего корню дерева (заполняется SchemaModelForTree)
+	Root string
+}
+
+// SchemaMessage - сообщение схемы с вложенными типами
+type SchemaMessage struct {
+	Name     string
+	Fields   []*SchemaField
+	Messages []*SchemaMessage
+	Enums    []*SchemaEnum
+	// Extensions - блоки extend, объявленные внутри сообщения
+	Extensions []*SchemaExtension
+	// ExtensionRanges - номера, зарезервированные для расширений: "100 to 199"
+	ExtensionRanges []string
+
+	// synthesizedChildren сопоставляет номер придуманного поля-сообщения с его типом
+	synthesizedChildren map[int]*SchemaMessage
+}
+
+// SchemaField - поле сообщения. Label: "optional", "required" или "repeated".
+type SchemaField struct {
+	Name    string
+	Type    string
+	Label   string
+	Number  int
+	Options map[string]string
+	Oneof   string
+	// Group отмечает proto2 группу: Type - имя вложенного сообщения группы,
+	// которое выводится прямо в объявлении поля
+	Group bool
+
+	// synthesized отмечает поля, которых нет в исходной схеме
+	synthesized bool
+}
+
+// SchemaExtension - блок extend: поля, добавляемые в сообщение Extendee
+type SchemaExtension struct {
+	Extendee string
+	Fields   []*SchemaField
+}
+
+// SchemaEnum - перечисление схемы
+type SchemaEnum struct {
+	Name   string
+	Values []SchemaEnumValue
+}
+
+type SchemaEnumValue struct {
+	Name   string
+	Number int
+}
+
+// LoadSchemaModel читает .proto файл в редактируемую модель
+func (p *Parser) LoadSchemaModel(schemaPath string) (*SchemaModel, error) {
+	schema, err := p.loadSchema(schemaPath)
+	if err != nil {
+		return nil, err
+	}
+	b := newSchemaModelBuilder(schema)
+	return b.model, nil
+}
+
+// ApplySchemaModel применяет модель к дереву так же, как ApplySchemaWithMessage применяет файл
+func (p *Parser) ApplySchemaModel(tree *TreeNode, model *SchemaModel, messageName string) (*TreeNode, *SchemaReport, error) {
+	if err := model.Validate(); err != nil {
+		return nil, nil, err
+	}
+	return p.ApplySchemaSource(tree, model.Render(), messageName)
+}
+
+// ApplySchemaSource применяет к дереву схему из текста source, например отрисованную
+// моделью. Интерфейс отрисовывает модель до того, как передать применение в фон
+func (p *Parser) ApplySchemaSource(tree *TreeNode, source string, messageName string) (*TreeNode, *SchemaReport, error) {
+	schema, err := p.parseProtoSchema(source)
+	if err != nil {
+		return nil, nil, err
+	}
+	rootMessage, err := schema.resolveRootMessage(messageName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	report := newSchemaReport()
+	forgetSchema(tree)
+	p.applySchemaToTree(tree, rootMessage, schema, "", report)
+	return tree, report, nil
+}
+
+// Save записывает модель в .proto файл
+func (m *SchemaModel) Save(path string) error {
+	if err := m.Validate(); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(m.Render()), 0644); err != nil {
+		return fmt.Errorf("failed to write schema: %w", err)
+	}
+	return nil
+}
+
+// FindMessage ищет сообщение по короткому имени на любом уровне вложенности
+func (m *SchemaModel) FindMessage(name string) *SchemaMessage {
+	var found *SchemaMessage
+	m.walkMessages(func(msg *SchemaMessage, _ string) {
+		if found == nil && msg.Name == name {
+			found = msg
+		}
+	})
+	return found
+}
+
+// MessageNames возвращает полные имена всех сообщений (Outer.Inner) в порядке объявления
+func (m *SchemaModel) MessageNames() []string {
+	names := make([]string, 0)
+	m.walkMessages(func(_ *SchemaMessage, fullName string) {
+		names = append(names, fullName)
+	})
+	return names
+}
+
+// FindMessageByFullName ищет сообщение по имени вида Outer.Inner
+func (m *SchemaModel) FindMessageByFullName(fullName string) *SchemaMessage {
+	var found *SchemaMessage
+	m.walkMessages(func(msg *SchemaMessage, name string) {
+		if name == fullName {
+			found = msg
+		}
+	})
+	return found
+}
+
+// TypeNames возвращает имена всех сообщений и перечислений модели, пригодные для типа поля
+func (m *SchemaModel) TypeNames() []string {
+	names := make([]string, 0)
+	for _, enum := range m.Enums {
+		names = append(names, enum.Name)
+	}
+	m.walkMessages(func(msg *SchemaMessage, _ string) {
+		names = append(names, msg.Name)
+		for _, enum := range msg.Enums {
+			names = append(names, enum.Name)
+		}
+	})
+	return names
+}
+
+func (m *SchemaModel) walkMessages(visit func(msg *SchemaMessage, fullName string)) {
+	var walk func(messages []*SchemaMessage, prefix string)
+	walk = func(messages []*SchemaMessage, prefix string) {
+		for _, msg := range messages {
+			fullName := msg.Name
+			if prefix != "" {
+				fullName = prefix + "." + msg.Name
+			}
+			visit(msg, fullName)
+			walk(msg.Messages, fullName)
+		}
+	}
+	walk(m.Messages, "")
+}
+
+// AddMessage добавляет пустое сообщение в parent или, если parent равен nil, на верхний уровень
+func (m *SchemaModel) AddMessage(parent *SchemaMessage, name string) (*SchemaMessage, error) {
+	if err := m.checkNewTypeName(name); err != nil {
+		return nil, err
+	}
+
+	msg := &SchemaMessage{Name: name, synthesizedChildren: make(map[int]*SchemaMessage)}
+	if parent == nil {
+		m.Messages = append(m.Messages, msg)
+	} else {
+		parent.Messages = append(parent.Messages, msg)
+	}
+	return msg, nil
+}
+
+// AddEnum добавляет перечисление в parent или, если parent равен nil, на верхний уровень
+func (m *SchemaModel) AddEnum(parent *SchemaMessage, name string, values []SchemaEnumValue) (*SchemaEnum, error) {
+	if err := m.checkNewTypeName(name); err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("enum %s must have at least one value", name)
+	}
+
+	enum := &SchemaEnum{Name: name, Values: values}
+	if parent == nil {
+		m.Enums = append(m.Enums, enum)
+	} else {
+		parent.Enums = append(parent.Enums, enum)
+	}
+	return enum, nil
+}
+
+// RenameMessage переименовывает сообщение и обновляет ссылки на него в типах полей. Ссылки
+// разрешаются с учетом области видимости поля, поэтому одноименное сообщение в другой области
+// (Order.Line и Invoice.Line) и ссылки на него не меняются
+func (m *SchemaModel) RenameMessage(msg *SchemaMessage, newName string) error {
+	if msg.Name == newName {
+		return nil
+	}
+	if err := m.checkNewTypeName(newName); err != nil {
+		return err
+	}
+
+	// Ссылки собираются до переименования, пока их можно разрешить по прежнему имени
+	var fields []*SchemaField
+	var extensions []*SchemaExtension
+	collectFields := func(scope *SchemaMessage, candidates []*SchemaField) {
+		for _, field := range candidates {
+			if m.resolveMessage(field.Type, scope) == msg {
+				fields = append(fields, field)
+			}
+		}
+	}
+	collectExtensions := func(scope *SchemaMessage, candidates []*SchemaExtension) {
+		for _, extension := range candidates {
+			if m.resolveMessage(extension.Extendee, scope) == msg {
+				extensions = append(extensions, extension)
+			}
+			collectFields(scope, extension.Fields)
+		}
+	}
+	collectExtensions(nil, m.Extensions)
+	m.walkMessages(func(other *SchemaMessage, _ string) {
+		collectFields(other, other.Fields)
+		collectExtensions(other, other.Extensions)
+	})
+
+	oldName := msg.Name
+	msg.Name = newName
+	for _, field := range fields {
+		field.Type = strings.TrimSuffix(field.Type, oldName) + newName
+		// Имя поля группы всегда совпадает с именем ее типа в нижнем регистре
+		if field.Group {
+			field.Name = strings.ToLower(newName)
+		}
+	}
+	for _, extension := range extensions {
+		extension.Extendee = strings.TrimSuffix(extension.Extendee, oldName) + newName
+	}
+	return nil
+}
+
+// resolveMessage возвращает сообщение, на которое ссылается тип typeName поля сообщения scope
+// (nil - верхний уровень), или nil. Как в protoc, имя ищется от scope к внешним сообщениям,
+// затем от верхнего уровня. Неполное имя, не найденное так, ищется по короткому имени на любом
+// уровне, как это делает findMessage при применении схемы
+func (m *SchemaModel) resolveMessage(typeName string, scope *SchemaMessage) *SchemaMessage {
+	name := strings.TrimPrefix(typeName, ".")
+	absolute := name != typeName
+	if m.Package != "" {
+		name = strings.TrimPrefix(name, m.Package+".")
+	}
+
+	if !absolute && scope != nil {
+		scopeName := ""
+		m.walkMessages(func(msg *SchemaMessage, fullName string) {
+			if msg == scope {
+				scopeName = fullName
+			}
+		})
+		for scopeName != "" {
+			if found := m.FindMessageByFullName(scopeName + "." + name); found != nil {
+				return found
+			}
+			if idx := strings.LastIndex(scopeName, "."); idx >= 0 {
+				scopeName = scopeName[:idx]
+			} else {
+				scopeName = ""
+			}
+		}
+	}
+	if found := m.FindMessageByFullName(name); found != nil {
+		return found
+	}
+	if !strings.Contains(name, ".") {
+		return m.FindMessage(name)
+	}
+	return nil
+}
+
+// AddField добавляет поле в сообщение
+func (msg *SchemaMessage) AddField(field *SchemaField) error {
+	for _, existing := range msg.Fields {
+		if existing.Number == field.Number {
+			return fmt.Errorf("field number %d is already used by '%s'", field.Number, existing.Name)
+		}
+	}
+	msg.Fields = append(msg.Fields, field)
+	return nil
+}
+
+func (m *SchemaModel) checkNewTypeName(name string) error {
+	if !isValidIdentifier(name) {
+		return fmt.Errorf("invalid type name %q", name)
+	}
+	for _, existing := range m.TypeNames() {
+		if existing == name {
+			return fmt.Errorf("type %s already exists", name)
+		}
+	}
+	return nil
+}
+
+// Validate проверяет имена и номера полей каждого сообщения
+func (m *SchemaModel) Validate() error {
+	var err error
+	m.walkMessages(func(msg *SchemaMessage, fullName string) {
+		if err != nil {
+			return
+		}
+		names := make(map[string]bool)
+		numbers := make(map[int]bool)
+		for _, field := range msg.Fields {
+			switch {
+			case !isValidIdentifier(field.Name):
+				err = fmt.Errorf("%s: invalid field name %q", fullName, field.Name)
+			case field.Type == "":
+				err = fmt.Errorf("%s.%s: field type is empty", fullName, field.Name)
+			case field.Number <= 0:
+				err = fmt.Errorf("%s.%s: invalid field number %d", fullName, field.Name, field.Number)
+			case names[field.Name]:
+				err = fmt.Errorf("%s: duplicate field name %q", fullName, field.Name)
+			case numbers[field.Number]:
+				err = fmt.Errorf("%s: duplicate field number %d", fullName, field.Number)
+			}
+			if err != nil {
+				return
+			}
+			names[field.Name] = true
+			numbers[field.Number] = true
+		}
+	})
+	return err
+}
+
+func isValidIdentifier(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		isLetter := (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || r == '_'
+		if !isLetter && (i == 0 || r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
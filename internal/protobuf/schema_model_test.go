@@ -0,0 +1,219 @@
+package protobuf
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSchemaModel_EditAndApply(t *testing.T) {
+	parser := &Parser{}
+	tree := newTestRoot(
+		&TreeNode{Name: "field_1", Type: "string", FieldNum: 1, Value: "A-1"},
+		&TreeNode{
+			Name: "field_2", Type: "message_1", FieldNum: 2,
+			Children: []*TreeNode{
+				{Name: "field_1", Type: "string", FieldNum: 1, Value: "X"},
+			},
+		},
+		&TreeNode{Name: "field_3", Type: "bool", FieldNum: 3, Value: true},
+	)
+
+	model, err := parser.SchemaModelForTree(tree, "", "")
+	if err != nil {
+		t.Fatalf("SchemaModelForTree failed: %v", err)
+	}
+
+	root := model.FindMessage(model.Root)
+	if model.Root != "Message" || root == nil {
+		t.Fatalf("Expected synthesized root message 'Message', got '%s'", model.Root)
+	}
+
+	root.Fields[0].Name = "id"
+	root.Fields[0].Label = "required"
+
+	line := model.FindMessage("Message1")
+	if err := model.RenameMessage(line, "Line"); err != nil {
+		t.Fatalf("RenameMessage failed: %v", err)
+	}
+	if root.Fields[1].Type != "Line" {
+		t.Errorf("Expected field type to follow message rename, got '%s'", root.Fields[1].Type)
+	}
+	line.Fields[0].Name = "sku"
+
+	if _, err := model.AddEnum(nil, "Status", []SchemaEnumValue{{Name: "UNKNOWN", Number: 0}, {Name: "OK", Number: 1}}); err != nil {
+		t.Fatalf("AddEnum failed: %v", err)
+	}
+	root.Fields[2].Type = "Status"
+	root.Fields[2].Name = "status"
+
+	if _, err := model.AddMessage(nil, "Line"); err == nil {
+		t.Error("Expected error when adding a message with an existing name")
+	}
+	if err := root.AddField(&SchemaField{Name: "dup", Type: "string", Label: "optional", Number: 1}); err == nil {
+		t.Error("Expected error when adding a field with a used number")
+	}
+
+	_, report, err := parser.ApplySchemaModel(tree, model, "Message")
+	if err != nil {
+		t.Fatalf("ApplySchemaModel failed: %v", err)
+	}
+	if report.HasIssues() {
+		t.Errorf("Expected no issues, got %+v", report.Issues)
+	}
+
+	if tree.Children[0].Name != "id" || !tree.Children[0].IsRequired {
+		t.Errorf("Expected renamed required 'id' field, got %+v", tree.Children[0])
+	}
+	if tree.Children[1].Type != "Line" || tree.Children[1].Children[0].Name != "sku" {
+		t.Errorf("Expected nested Line.sku, got %+v", tree.Children[1])
+	}
+	if tree.Children[2].Name != "status" || tree.Children[2].Type != "int32" {
+		t.Errorf("Expected enum field 'status' as int32, got %+v", tree.Children[2])
+	}
+
+	schemaFile := filepath.Join(t.TempDir(), "edited.proto")
+	if err := model.Save(schemaFile); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	saved, err := parser.LoadSchemaModel(schemaFile)
+	if err != nil {
+		t.Fatalf("LoadSchemaModel failed: %v", err)
+	}
+	if saved.Render() != model.Render() {
+		t.Errorf("Expected saved schema to round-trip, got:\n%s\nwant:\n%s", saved.Render(), model.Render())
+	}
+}
+
+func TestSchemaModel_Validate(t *testing.T) {
+	model := &SchemaModel{Syntax: "proto2"}
+	msg, err := model.AddMessage(nil, "Msg")
+	if err != nil {
+		t.Fatalf("AddMessage failed: %v", err)
+	}
+	msg.Fields = append(msg.Fields,
+		&SchemaField{Name: "a", Type: "string", Label: "optional", Number: 1},
+		&SchemaField{Name: "a", Type: "int32", Label: "optional", Number: 2},
+	)
+
+	if err := model.Validate(); err == nil || !strings.Contains(err.Error(), "duplicate field name") {
+		t.Errorf("Expected duplicate field name error, got %v", err)
+	}
+
+	msg.Fields[1].Name = "2b"
+	if err := model.Validate(); err == nil || !strings.Contains(err.Error(), "invalid field name") {
+		t.Errorf("Expected invalid field name error, got %v", err)
+	}
+
+	if _, err := model.AddMessage(nil, "bad name"); err == nil {
+		t.Error("Expected error for invalid message name")
+	}
+}
+
+func TestSchemaModel_Groups(t *testing.T) {
+	parser := &Parser{}
+	schemaFile := writeTestSchema(t, `syntax = "proto2";
+
+message SearchResponse {
+  repeated group Result = 1 {
+    required string url = 2;
+  }
+}`)
+
+	model, err := parser.LoadSchemaModel(schemaFile)
+	if err != nil {
+		t.Fatalf("LoadSchemaModel failed: %v", err)
+	}
+	field := model.FindMessage("SearchResponse").Fields[0]
+	if !field.Group || field.Name != "result" || field.Type != "Result" {
+		t.Fatalf("Expected group field 'result', got %+v", field)
+	}
+
+	if err := model.RenameMessage(model.FindMessage("Result"), "Hit"); err != nil {
+		t.Fatalf("RenameMessage failed: %v", err)
+	}
+	rendered := model.Render()
+	expected := "  repeated group Hit = 1 {\n    required string url = 2;\n  }\n"
+	if !strings.Contains(rendered, expected) {
+		t.Errorf("Expected inline group declaration, got:\n%s", rendered)
+	}
+	if strings.Contains(rendered, "message Hit") {
+		t.Errorf("Expected group type not to be declared as a nested message, got:\n%s", rendered)
+	}
+
+	model.Syntax = "proto3"
+	rendered = model.Render()
+	if !strings.Contains(rendered, "message Hit {") || !strings.Contains(rendered, "repeated Hit hit = 1; // group in the original schema") {
+		t.Errorf("Expected proto3 rendering to declare the group as a message, got:\n%s", rendered)
+	}
+}
+
+func TestSchemaModelForTree_SynthesizedGroup(t *testing.T) {
+	parser := &Parser{}
+	tree := &TreeNode{Name: "root", Type: "message"}
+	group := &TreeNode{Name: "field_1", Type: "message_1", FieldNum: 1, IsGroup: true}
+	group.AddChild(&TreeNode{Name: "field_1", Type: "int64", FieldNum: 1, Value: "7"})
+	tree.AddChild(group)
+
+	model, err := parser.SchemaModelForTree(tree, "", "")
+	if err != nil {
+		t.Fatalf("SchemaModelForTree failed: %v", err)
+	}
+	rendered := model.Render()
+	if !strings.Contains(rendered, "optional group Message1 = 1 {") {
+		t.Errorf("Expected synthesized group declaration, got:\n%s", rendered)
+	}
+	if err := model.Validate(); err != nil {
+		t.Errorf("Expected valid model, got %v", err)
+	}
+}
+
+func TestSchemaModel_RenameMessageResolvesScope(t *testing.T) {
+	schema := `syntax = "proto3";
+package shop;
+
+message Order {
+  message Line {
+    int64 sku = 1;
+  }
+  repeated Line lines = 1;
+}
+
+message Invoice {
+  message Line {
+    string text = 1;
+  }
+  repeated Line lines = 1;
+  Order.Line order_line = 2;
+  .shop.Order.Line absolute_line = 3;
+}
+`
+	schemaFile := filepath.Join(t.TempDir(), "shop.proto")
+	if err := os.WriteFile(schemaFile, []byte(schema), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	parser := &Parser{}
+	model, err := parser.LoadSchemaModel(schemaFile)
+	if err != nil {
+		t.Fatalf("LoadSchemaModel failed: %v", err)
+	}
+
+	if err := model.RenameMessage(model.FindMessageByFullName("Order.Line"), "OrderLine"); err != nil {
+		t.Fatalf("RenameMessage failed: %v", err)
+	}
+	order := model.FindMessageByFullName("Order")
+	invoice := model.FindMessageByFullName("Invoice")
+	if order.Fields[0].Type != "OrderLine" {
+		t.Errorf("Expected Order.lines to follow the rename, got %s", order.Fields[0].Type)
+	}
+	if invoice.Fields[0].Type != "Line" {
+		t.Errorf("Expected Invoice.lines to keep referring to Invoice.Line, got %s", invoice.Fields[0].Type)
+	}
+	if invoice.Fields[1].Type != "Order.OrderLine" || invoice.Fields[2].Type != ".shop.Order.OrderLine" {
+		t.Errorf("Expected qualified references to follow the rename, got %s and %s", invoice.Fields[1].Type, invoice.Fields[2].Type)
+	}
+	if model.FindMessageByFullName("Invoice.Line") == nil {
+		t.Error("Expected Invoice.Line to keep its name")
+	}
+}
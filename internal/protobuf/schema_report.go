@@ -0,0 +1,332 @@
+package protobuf
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+)
+
+// SchemaIssueKind описывает вид расхождения данных со схемой
+type SchemaIssueKind string
+
+const (
+	SchemaIssueUnknownField     SchemaIssueKind = "unknown_field"
+	SchemaIssueWireTypeMismatch SchemaIssueKind = "wire_type_mismatch"
+	SchemaIssueMissingRequired  SchemaIssueKind = "missing_required"
+	SchemaIssueEnumOutOfRange   SchemaIssueKind = "enum_out_of_range"
+	SchemaIssueInvalidUTF8      SchemaIssueKind = "invalid_utf8"
+	// SchemaIssueConstraintViolation - нарушение бизнес-правила из buf.validate опций или файла правил
+	SchemaIssueConstraintViolation SchemaIssueKind = "constraint_violation"
+)
+
+// SchemaIssue - одно расхождение данных со схемой. Path указывает на поле,
+// MessagePath - на сообщение, в котором оно найдено ("" для корня).
+type SchemaIssue struct {
+	Kind        SchemaIssueKind `json:"kind"`
+	Path        string          `json:"path"`
+	MessagePath string          `json:"message_path"`
+	FieldNum    int             `json:"field_num"`
+	FieldName   string          `json:"field_name,omitempty"`
+	Expected    string          `json:"expected,omitempty"`
+	Actual      string          `json:"actual,omitempty"`
+	// Node - узел дерева с расхождением; для отсутствующих полей это сообщение-родитель
+	Node *TreeNode `json:"-"`
+}
+
+// Message возвращает описание расхождения для пользователя
+func (i SchemaIssue) Message() string {
+	switch i.Kind {
+	case SchemaIssueUnknownField:
+		return fmt.Sprintf("field %d is not described by the schema", i.FieldNum)
+	case SchemaIssueWireTypeMismatch:
+		return fmt.Sprintf("field '%s' (%d) is declared as %s but encoded as %s", i.FieldName, i.FieldNum, i.Expected, i.Actual)
+	case SchemaIssueMissingRequired:
+		return fmt.Sprintf("required field '%s' (%d) is missing", i.FieldName, i.FieldNum)
+	case SchemaIssueEnumOutOfRange:
+		return fmt.Sprintf("value %s of field '%s' (%d) is not defined in enum %s", i.Actual, i.FieldName, i.FieldNum, i.Expected)
+	case SchemaIssueInvalidUTF8:
+		return fmt.Sprintf("string field '%s' (%d) contains invalid UTF-8", i.FieldName, i.FieldNum)
+	case SchemaIssueConstraintViolation:
+		return fmt.Sprintf("field '%s' (%d) violates %s: got %s", i.FieldName, i.FieldNum, i.Expected, i.Actual)
+	default:
+		return string(i.Kind)
+	}
+}
+
+// SchemaReport собирает расхождения, найденные при применении схемы
+type SchemaReport struct {
+	Issues []SchemaIssue `json:"issues"`
+	// Deferred - число ленивых сообщений, которые еще не разобраны: схема применяется к ним
+	// и их расхождения попадают в отчет при раскрытии
+	Deferred int `json:"deferred,omitempty"`
+}
+
+func newSchemaReport() *SchemaReport {
+	return &SchemaReport{Issues: make([]SchemaIssue, 0)}
+}
+
+// HasIssues сообщает, найдены ли расхождения
+func (r *SchemaReport) HasIssues() bool {
+	return r != nil && len(r.Issues) > 0
+}
+
+// IssuesFor возвращает расхождения, относящиеся к узлу дерева
+func (r *SchemaReport) IssuesFor(node *TreeNode) []SchemaIssue {
+	if r == nil || node == nil {
+		return nil
+	}
+
+	var issues []SchemaIssue
+	for _, issue := range r.Issues {
+		if issue.Node == node {
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
+
+// UnknownFieldNums возвращает номера неизвестных полей, сгруппированные по пути сообщения
+func (r *SchemaReport) UnknownFieldNums() map[string][]int {
+	result := make(map[string][]int)
+	if r == nil {
+		return result
+	}
+
+	seen := make(map[string]bool)
+	for _, issue := range r.Issues {
+		if issue.Kind != SchemaIssueUnknownField {
+			continue
+		}
+		key := fmt.Sprintf("%s#%d", issue.MessagePath, issue.FieldNum)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		result[issue.MessagePath] = append(result[issue.MessagePath], issue.FieldNum)
+	}
+	for path := range result {
+		sort.Ints(result[path])
+	}
+	return result
+}
+
+// Count возвращает количество расхождений указанного вида
+func (r *SchemaReport) Count(kind SchemaIssueKind) int {
+	if r == nil {
+		return 0
+	}
+
+	count := 0
+	for _, issue := range r.Issues {
+		if issue.Kind == kind {
+			count++
+		}
+	}
+	return count
+}
+
+// Summary возвращает краткую сводку отчета
+func (r *SchemaReport) Summary() string {
+	if !r.HasIssues() {
+		if r == nil {
+			return "Data matches the schema"
+		}
+		return "Data matches the schema" + r.deferredSummary()
+	}
+	summary := fmt.Sprintf("%d unknown field(s), %d wire type mismatch(es), %d missing required field(s)",
+		r.Count(SchemaIssueUnknownField), r.Count(SchemaIssueWireTypeMismatch), r.Count(SchemaIssueMissingRequired))
+	if count := r.Count(SchemaIssueEnumOutOfRange); count > 0 {
+		summary += fmt.Sprintf(", %d enum value(s) out of range", count)
+	}
+	if count := r.Count(SchemaIssueInvalidUTF8); count > 0 {
+		summary += fmt.Sprintf(", %d invalid UTF-8 string(s)", count)
+	}
+	if count := r.Count(SchemaIssueConstraintViolation); count > 0 {
+		summary += fmt.Sprintf(", %d constraint violation(s)", count)
+	}
+	return summary + r.deferredSummary()
+}
+
+// deferredSummary дополняет сводку числом сообщений, которые будут проверены при раскрытии
+func (r *SchemaReport) deferredSummary() string {
+	if r.Deferred == 0 {
+		return ""
+	}
+	return fmt.Sprintf(", %d nested message(s) are checked when expanded", r.Deferred)
+}
+
+// ToJSONString сериализует отчет в JSON
+func (r *SchemaReport) ToJSONString() (string, error) {
+	jsonBytes, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("error marshaling report to JSON: %w", err)
+	}
+	return string(jsonBytes), nil
+}
+
+func (r *SchemaReport) addUnknownField(node *TreeNode, messagePath string) {
+	r.Issues = append(r.Issues, SchemaIssue{
+		Kind:        SchemaIssueUnknownField,
+		Path:        joinDiffPath(messagePath, fieldPathName(node)),
+		MessagePath: messagePath,
+		FieldNum:    node.FieldNum,
+		Actual:      wireTypeOfNode(node, node.Type),
+		Node:        node,
+	})
+}
+
+func (r *SchemaReport) checkRequired(node *TreeNode, message *schemaMessageInfo, messagePath string) {
+	present := make(map[int]bool)
+	for _, child := range node.Children {
+		present[child.FieldNum] = true
+	}
+
+	for _, field := range message.fields {
+		if !field.isRequired || present[field.fieldNum] {
+			continue
+		}
+		r.Issues = append(r.Issues, SchemaIssue{
+			Kind:        SchemaIssueMissingRequired,
+			Path:        joinDiffPath(messagePath, field.fieldName),
+			MessagePath: messagePath,
+			FieldNum:    field.fieldNum,
+			FieldName:   field.fieldName,
+			Node:        node,
+		})
+	}
+}
+
+// checkWireType сравнивает wire type, с которым поле закодировано в данных, с ожидаемым по схеме.
+// Для repeated скалярных полей допускается упакованная (length-delimited) форма.
+func (r *SchemaReport) checkWireType(node *TreeNode, field *schemaFieldInfo, isEnum bool, isMessage bool, decodedType string, messagePath string) {
+	actual := wireTypeOfNode(node, decodedType)
+	if actual == "" {
+		return
+	}
+
+	expected := wireTypeLen
+	if field.isGroup {
+		expected = wireTypeGroup
+	} else if isEnum {
+		expected = wireTypeVarint
+	} else if !isMessage {
+		expected = wireTypeOfProtoType(field.fieldType)
+	}
+
+	if actual == expected || (field.isRepeated && actual == wireTypeLen && expected != wireTypeGroup) {
+		return
+	}
+
+	r.Issues = append(r.Issues, SchemaIssue{
+		Kind:        SchemaIssueWireTypeMismatch,
+		Path:        joinDiffPath(messagePath, field.fieldName),
+		MessagePath: messagePath,
+		FieldNum:    field.fieldNum,
+		FieldName:   field.fieldName,
+		Expected:    fmt.Sprintf("%s (%s)", field.fieldType, expected),
+		Actual:      actual,
+		Node:        node,
+	})
+}
+
+// checkValue проверяет значение поля после применения схемы: номер значения перечисления
+// должен быть объявлен, строка - быть корректной UTF-8
+func (r *SchemaReport) checkValue(node *TreeNode, field *schemaFieldInfo, enum *schemaEnumInfo, messagePath string) {
+	if enum != nil {
+		// Значение, не ставшее int32, уже отмечено как несовпадение wire type
+		if node.Type != "int32" && node.Type != "bool" {
+			return
+		}
+		value := fmt.Sprintf("%v", node.Value)
+		switch node.Value {
+		case true:
+			value = "1"
+		case false:
+			value = "0"
+		}
+		for _, enumValue := range enum.values {
+			if strconv.Itoa(enumValue.number) == value {
+				return
+			}
+		}
+		r.Issues = append(r.Issues, SchemaIssue{
+			Kind:        SchemaIssueEnumOutOfRange,
+			Path:        joinDiffPath(messagePath, field.fieldName),
+			MessagePath: messagePath,
+			FieldNum:    field.fieldNum,
+			FieldName:   field.fieldName,
+			Expected:    enum.enumName,
+			Actual:      value,
+			Node:        node,
+		})
+		return
+	}
+
+	if field.fieldType != "string" || node.Type != "string" {
+		return
+	}
+	if utf8.ValidString(fmt.Sprintf("%v", node.Value)) {
+		return
+	}
+	r.Issues = append(r.Issues, SchemaIssue{
+		Kind:        SchemaIssueInvalidUTF8,
+		Path:        joinDiffPath(messagePath, field.fieldName),
+		MessagePath: messagePath,
+		FieldNum:    field.fieldNum,
+		FieldName:   field.fieldName,
+		Node:        node,
+	})
+}
+
+const (
+	wireTypeVarint  = "varint"
+	wireTypeFixed32 = "fixed32"
+	wireTypeFixed64 = "fixed64"
+	wireTypeLen     = "length-delimited"
+	wireTypeGroup   = "group"
+)
+
+// wireTypeOfNode возвращает wire type, с которым узел прочитан декодером. Для узлов, созданных
+// вручную, он определяется по типу decodedType: скалярный тип protobuf задает wire type точно,
+// для перечислений и неизвестных типов его не определить
+func wireTypeOfNode(node *TreeNode, decodedType string) string {
+	if node.wireType != "" {
+		return node.wireType
+	}
+	switch {
+	case node.IsGroup:
+		return wireTypeGroup
+	case isMessageType(decodedType) || len(node.Children) > 0:
+		return wireTypeLen
+	case isScalarProtoType(decodedType):
+		return wireTypeOfProtoType(decodedType)
+	default:
+		return ""
+	}
+}
+
+// isScalarProtoType сообщает, является ли тип скалярным типом protobuf
+func isScalarProtoType(protoType string) bool {
+	switch protoType {
+	case "int32", "int64", "uint32", "uint64", "sint32", "sint64", "bool",
+		"fixed32", "fixed64", "sfixed32", "sfixed64", "float", "double", "string", "bytes":
+		return true
+	default:
+		return false
+	}
+}
+
+func wireTypeOfProtoType(protoType string) string {
+	switch protoType {
+	case "string", "bytes":
+		return wireTypeLen
+	case "fixed32", "sfixed32", "float":
+		return wireTypeFixed32
+	case "fixed64", "sfixed64", "double":
+		return wireTypeFixed64
+	default:
+		return wireTypeVarint
+	}
+}
@@ -0,0 +1,183 @@
+package protobuf
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestApplySchemaWithMessage_CollectsIssuesAtAllLevels(t *testing.T) {
+	schemaFile := writeTestSchema(t, `syntax = "proto2";
+
+message Order {
+  required string id = 1;
+  optional int32 count = 2;
+  repeated Item items = 3;
+  repeated int32 codes = 4;
+}
+
+message Item {
+  required string sku = 1;
+  optional double price = 2;
+}`)
+	tree := newTestRoot(
+		&TreeNode{Name: "field_2", Type: "string", FieldNum: 2, Value: "oops"},
+		&TreeNode{
+			Name: "field_3", Type: "message_1", FieldNum: 3,
+			Children: []*TreeNode{
+				{Name: "field_1", Type: "string", FieldNum: 1, Value: "A-1"},
+				{Name: "field_9", Type: "int64", FieldNum: 9, Value: "5"},
+			},
+		},
+		&TreeNode{
+			Name: "field_3", Type: "message_2", FieldNum: 3,
+			Children: []*TreeNode{
+				{Name: "field_2", Type: "double", FieldNum: 2, Value: "1.5"},
+			},
+		},
+		&TreeNode{Name: "field_4", Type: "string", FieldNum: 4, Value: "\x01\x02"},
+		&TreeNode{Name: "field_7", Type: "int64", FieldNum: 7, Value: "42"},
+	)
+
+	parser := &Parser{}
+	_, report, err := parser.ApplySchemaWithMessage(tree, schemaFile, "Order")
+	if err != nil {
+		t.Fatalf("ApplySchemaWithMessage failed: %v", err)
+	}
+
+	unknown := report.UnknownFieldNums()
+	if len(unknown[""]) != 1 || unknown[""][0] != 7 {
+		t.Errorf("Expected unknown field 7 at root, got %v", unknown)
+	}
+	if len(unknown["items[0]"]) != 1 || unknown["items[0]"][0] != 9 {
+		t.Errorf("Expected unknown field 9 in items[0], got %v", unknown)
+	}
+
+	var missing []string
+	var mismatched []string
+	for _, issue := range report.Issues {
+		switch issue.Kind {
+		case SchemaIssueMissingRequired:
+			missing = append(missing, issue.Path)
+		case SchemaIssueWireTypeMismatch:
+			mismatched = append(mismatched, issue.Path)
+		}
+	}
+	if strings.Join(missing, ",") != "id,items[1].sku" {
+		t.Errorf("Expected missing required [id items[1].sku], got %v", missing)
+	}
+	// Упакованное repeated поле codes закодировано как length-delimited и не считается ошибкой
+	if strings.Join(mismatched, ",") != "count" {
+		t.Errorf("Expected wire type mismatch only for 'count', got %v", mismatched)
+	}
+
+	countNode := tree.Children[0]
+	if issues := report.IssuesFor(countNode); len(issues) != 1 || issues[0].Actual != wireTypeLen {
+		t.Errorf("Expected issue attached to count node, got %+v", issues)
+	}
+}
+
+func TestApplySchemaWithMessage_CleanData(t *testing.T) {
+	schemaFile := writeTestSchema(t, `syntax = "proto2";
+
+message Order {
+  required string id = 1;
+  optional int32 count = 2;
+}`)
+	tree := newTestRoot(
+		&TreeNode{Name: "field_1", Type: "string", FieldNum: 1, Value: "X"},
+		&TreeNode{Name: "field_2", Type: "int64", FieldNum: 2, Value: "3"},
+	)
+
+	parser := &Parser{}
+	_, report, err := parser.ApplySchemaWithMessage(tree, schemaFile, "Order")
+	if err != nil {
+		t.Fatalf("ApplySchemaWithMessage failed: %v", err)
+	}
+	if report.HasIssues() {
+		t.Errorf("Expected no issues, got %+v", report.Issues)
+	}
+	if report.Summary() != "Data matches the schema" {
+		t.Errorf("Unexpected summary: %s", report.Summary())
+	}
+}
+
+func TestApplySchemaWithMessage_EnumRangeAndUTF8(t *testing.T) {
+	schemaFile := writeTestSchema(t, `syntax = "proto2";
+
+enum Status {
+  UNKNOWN = 0;
+  ACTIVE = 1;
+}
+
+message Account {
+  optional string name = 1;
+  optional Status status = 2;
+  optional bytes blob = 3;
+}`)
+	tree := newTestRoot(
+		&TreeNode{Name: "field_1", Type: "string", FieldNum: 1, Value: "caf\xc3"},
+		&TreeNode{Name: "field_2", Type: "int64", FieldNum: 2, Value: "7"},
+		&TreeNode{Name: "field_3", Type: "string", FieldNum: 3, Value: "\xff"},
+	)
+
+	parser := &Parser{}
+	_, report, err := parser.ApplySchemaWithMessage(tree, schemaFile, "Account")
+	if err != nil {
+		t.Fatalf("ApplySchemaWithMessage failed: %v", err)
+	}
+
+	if report.Count(SchemaIssueEnumOutOfRange) != 1 || report.Count(SchemaIssueInvalidUTF8) != 1 {
+		t.Fatalf("Expected one enum and one UTF-8 issue, got %+v", report.Issues)
+	}
+	for _, issue := range report.Issues {
+		switch issue.Kind {
+		case SchemaIssueEnumOutOfRange:
+			if issue.Path != "status" || issue.Actual != "7" || issue.Expected != "Status" {
+				t.Errorf("Unexpected enum issue: %+v", issue)
+			}
+		case SchemaIssueInvalidUTF8:
+			// bytes поле не проверяется на UTF-8
+			if issue.Path != "name" {
+				t.Errorf("Unexpected UTF-8 issue: %+v", issue)
+			}
+		}
+	}
+	if !strings.Contains(report.Summary(), "1 enum value(s) out of range") {
+		t.Errorf("Unexpected summary: %s", report.Summary())
+	}
+}
+
+func TestApplySchemaWithMessage_DistinguishesFixedWidths(t *testing.T) {
+	schemaFile := writeTestSchema(t, `syntax = "proto2";
+
+message Sample {
+  optional double ratio = 1;
+  optional double total = 2;
+}`)
+	// 1: 1.5 как float (fixed32), 2: 1.5 как double (fixed64)
+	data := []byte{
+		0x0d, 0x00, 0x00, 0xc0, 0x3f,
+		0x11, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xf8, 0x3f,
+	}
+	tree, err := DecodeLazy(context.Background(), data, nil)
+	if err != nil {
+		t.Fatalf("DecodeLazy failed: %v", err)
+	}
+
+	parser := &Parser{}
+	// Повторное применение проверяет wire type, прочитанный декодером, а не тип после первой схемы
+	for i := 0; i < 2; i++ {
+		_, report, err := parser.ApplySchemaWithMessage(tree, schemaFile, "Sample")
+		if err != nil {
+			t.Fatalf("ApplySchemaWithMessage failed: %v", err)
+		}
+		if len(report.Issues) != 1 {
+			t.Fatalf("Expected one wire type mismatch, got %+v", report.Issues)
+		}
+		issue := report.Issues[0]
+		if issue.Path != "ratio" || issue.Actual != wireTypeFixed32 || issue.Expected != "double (fixed64)" {
+			t.Errorf("Unexpected issue: %+v", issue)
+		}
+	}
+}
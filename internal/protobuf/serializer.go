@@ -1,6 +1,7 @@
 package protobuf
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
@@ -19,6 +20,14 @@ func NewSerializer(protocPath string) *Serializer {
 }
 
 func (s *Serializer) SerializeRaw(tree *TreeNode) ([]byte, error) {
+	return s.SerializeRawContext(context.Background(), tree)
+}
+
+// SerializeRawContext кодирует дерево как SerializeRaw; при отмене ctx protoc завершается
+// и возвращается ctx.Err(). Неразобранные сообщения ленивого дерева кодируются исходными
+// байтами и не раскрываются
+func (s *Serializer) SerializeRawContext(ctx context.Context, tree *TreeNode) ([]byte, error) {
+	tree = lazyAsBytes(tree)
 	tempDir, err := os.MkdirTemp("", "prospect_proto_*")
 	if err != nil {
 		return nil, fmt.Errorf("error creating temp directory: %w", err)
@@ -28,19 +37,24 @@ func (s *Serializer) SerializeRaw(tree *TreeNode) ([]byte, error) {
 	fieldNameMap := make(map[int]string)
 	messageCounter := 1
 	usedMessageNames := make(map[string]string)
-	protoContent := s.GenerateProtoSchemaWithFieldNames(tree, fieldNameMap, &messageCounter, usedMessageNames)
+	protoContent := s.writeProtoSchema(tree, fieldNameMap, &messageCounter, usedMessageNames)
 	protoFile := filepath.Join(tempDir, "message.proto")
 	if err := os.WriteFile(protoFile, []byte(protoContent), 0644); err != nil {
 		return nil, fmt.Errorf("error writing proto file: %w", err)
 	}
 
-	textFormat := s.TreeToTextFormatWithFieldNames(tree, fieldNameMap)
+	var textBuilder strings.Builder
+	s.WriteNodeToTextFormatWithFieldNames(&textBuilder, tree, 0, fieldNameMap)
+	textFormat := textBuilder.String()
 
 	messageName := "Message"
-	cmd := exec.Command(s.protocPath, "--encode", messageName, "--proto_path", tempDir, protoFile)
+	cmd := exec.CommandContext(ctx, s.protocPath, "--encode", messageName, "--proto_path", tempDir, protoFile)
 	cmd.Stdin = strings.NewReader(textFormat)
 
 	output, err := cmd.Output()
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
 	if err != nil {
 		if exitError, ok := err.(*exec.ExitError); ok {
 			stderr := string(exitError.Stderr)
@@ -49,13 +63,19 @@ func (s *Serializer) SerializeRaw(tree *TreeNode) ([]byte, error) {
 		return nil, fmt.Errorf("error encoding protobuf: %w", err)
 	}
 
+	if tree.Framing != nil {
+		output = append(tree.Framing.Bytes(), output...)
+	}
 	return output, nil
 }
 
+// TreeToTextFormat выводит дерево в text format по номерам полей. Ленивые узлы разбираются
+// в копии дерева, само дерево не раскрывается
 func (s *Serializer) TreeToTextFormat(node *TreeNode) string {
 	if node == nil {
 		return ""
 	}
+	node = expandedCopy(node)
 
 	var result strings.Builder
 	s.WriteNodeToTextFormat(&result, node, 0)
@@ -87,7 +107,9 @@ func (s *Serializer) WriteNodeToTextFormat(builder *strings.Builder, node *TreeN
 		builder.WriteString(fmt.Sprintf("%d: ", node.FieldNum))
 		if node.Value != nil {
 			if node.Type == "string" {
-				builder.WriteString(fmt.Sprintf("\"%s\"", fmt.Sprintf("%v", node.Value)))
+				builder.WriteString(fmt.Sprintf("\"%s\"", EscapeText(fmt.Sprintf("%v", node.Value))))
+			} else if node.Type == "bytes" {
+				builder.WriteString(fmt.Sprintf("\"%s\"", EscapeTextBytes(BytesValue(node.Value))))
 			} else if node.Type == "bool" {
 				if v, ok := node.Value.(bool); ok {
 					if v {
@@ -111,7 +133,7 @@ func (s *Serializer) WriteNodeToTextFormat(builder *strings.Builder, node *TreeN
 					if isNumeric(v) {
 						builder.WriteString(v)
 					} else {
-						builder.WriteString(fmt.Sprintf("\"%s\"", v))
+						builder.WriteString(fmt.Sprintf("\"%s\"", EscapeText(v)))
 					}
 				case bool:
 					if v {
@@ -135,7 +157,13 @@ func (s *Serializer) GenerateProtoSchema(tree *TreeNode) string {
 	return s.GenerateProtoSchemaWithFieldNames(tree, fieldNameMap, &messageCounter, usedMessageNames)
 }
 
+// GenerateProtoSchemaWithFieldNames строит схему для кодирования дерева через protoc.
+// Ленивые узлы разбираются в копии дерева, само дерево не раскрывается
 func (s *Serializer) GenerateProtoSchemaWithFieldNames(tree *TreeNode, fieldNameMap map[int]string, messageCounter *int, usedMessageNames map[string]string) string {
+	return s.writeProtoSchema(expandedCopy(tree), fieldNameMap, messageCounter, usedMessageNames)
+}
+
+func (s *Serializer) writeProtoSchema(tree *TreeNode, fieldNameMap map[int]string, messageCounter *int, usedMessageNames map[string]string) string {
 	var builder strings.Builder
 	builder.WriteString("syntax = \"proto2\";\n\n")
 	builder.WriteString("message Message {\n")
@@ -178,7 +206,10 @@ func (s *Serializer) WriteProtoFields(builder *strings.Builder, node *TreeNode,
 func (s *Serializer) WriteProtoField(builder *strings.Builder, node *TreeNode, fieldNum *int, messageCounter *int, usedMessageNames map[string]string, fieldNameCounter map[string]int, fieldNameMap map[int]string) {
 	indent := "  "
 
-	if isMessageType(node.Type) || len(node.Children) > 0 {
+	if node.IsGroup {
+		s.writeGroupField(builder, node, indent, messageCounter, usedMessageNames)
+		*fieldNum++
+	} else if isMessageType(node.Type) || len(node.Children) > 0 {
 		var messageName string
 		alreadyDefined := false
 		if existingName, exists := usedMessageNames[node.Name]; exists {
@@ -190,7 +221,7 @@ func (s *Serializer) WriteProtoField(builder *strings.Builder, node *TreeNode, f
 			*messageCounter++
 		}
 
-		fieldName := node.Name
+		fieldName := protoFieldName(node)
 		if isMessageType(node.Type) {
 			fieldNameCounter[fieldName]++
 			if fieldNameCounter[fieldName] > 1 {
@@ -213,16 +244,19 @@ func (s *Serializer) WriteProtoField(builder *strings.Builder, node *TreeNode, f
 	} else {
 		protoType := s.MapTypeToProtoType(node.Type)
 		if node.IsRepeated {
-			builder.WriteString(fmt.Sprintf("%srepeated %s %s = %d;\n", indent, protoType, node.Name, node.FieldNum))
+			builder.WriteString(fmt.Sprintf("%srepeated %s %s = %d;\n", indent, protoType, protoFieldName(node), node.FieldNum))
 		} else {
-			builder.WriteString(fmt.Sprintf("%soptional %s %s = %d;\n", indent, protoType, node.Name, node.FieldNum))
+			builder.WriteString(fmt.Sprintf("%soptional %s %s = %d;\n", indent, protoType, protoFieldName(node), node.FieldNum))
 		}
 		*fieldNum++
 	}
 }
 
 func (s *Serializer) WriteProtoFieldRecursive(builder *strings.Builder, node *TreeNode, fieldNum *int, indent string, messageCounter *int, usedMessageNames map[string]string) {
-	if isMessageType(node.Type) || len(node.Children) > 0 {
+	if node.IsGroup {
+		s.writeGroupField(builder, node, indent, messageCounter, usedMessageNames)
+		*fieldNum++
+	} else if isMessageType(node.Type) || len(node.Children) > 0 {
 		var messageName string
 		alreadyDefined := false
 		if existingName, exists := usedMessageNames[node.Name]; exists {
@@ -233,7 +267,7 @@ func (s *Serializer) WriteProtoFieldRecursive(builder *strings.Builder, node *Tr
 			usedMessageNames[node.Name] = messageName
 			*messageCounter++
 		}
-		builder.WriteString(fmt.Sprintf("%soptional %s %s = %d;\n", indent, messageName, node.Name, node.FieldNum))
+		builder.WriteString(fmt.Sprintf("%soptional %s %s = %d;\n", indent, messageName, protoFieldName(node), node.FieldNum))
 
 		if !alreadyDefined {
 			childFieldNum := 1
@@ -247,18 +281,72 @@ func (s *Serializer) WriteProtoFieldRecursive(builder *strings.Builder, node *Tr
 	} else {
 		protoType := s.MapTypeToProtoType(node.Type)
 		if node.IsRepeated {
-			builder.WriteString(fmt.Sprintf("%srepeated %s %s = %d;\n", indent, protoType, node.Name, node.FieldNum))
+			builder.WriteString(fmt.Sprintf("%srepeated %s %s = %d;\n", indent, protoType, protoFieldName(node), node.FieldNum))
 		} else {
-			builder.WriteString(fmt.Sprintf("%soptional %s %s = %d;\n", indent, protoType, node.Name, node.FieldNum))
+			builder.WriteString(fmt.Sprintf("%soptional %s %s = %d;\n", indent, protoType, protoFieldName(node), node.FieldNum))
 		}
 		*fieldNum++
 	}
 }
 
+// protoFieldName возвращает имя поля для генерируемой схемы. Имена расширений вида
+// [pkg.name] не являются идентификаторами и заменяются на ext_pkg_name
+func protoFieldName(node *TreeNode) string {
+	if !strings.HasPrefix(node.Name, "[") {
+		return node.Name
+	}
+	var builder strings.Builder
+	builder.WriteString("ext_")
+	for _, r := range strings.Trim(node.Name, "[]") {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' {
+			builder.WriteRune(r)
+		} else {
+			builder.WriteRune('_')
+		}
+	}
+	return builder.String()
+}
+
+// groupTypeName возвращает имя типа группы в генерируемой схеме. В text format
+// группа записывается именем типа, а не именем поля
+func groupTypeName(node *TreeNode) string {
+	return fmt.Sprintf("Group%d", node.FieldNum)
+}
+
+// writeGroupField объявляет proto2 группу: protoc кодирует ее тегами начала и конца
+// группы. Поля тела берутся из узла, повторяющиеся номера объявляются один раз как repeated
+func (s *Serializer) writeGroupField(builder *strings.Builder, node *TreeNode, indent string, messageCounter *int, usedMessageNames map[string]string) {
+	label := "optional"
+	if node.IsRepeated {
+		label = "repeated"
+	}
+	builder.WriteString(fmt.Sprintf("%s%s group %s = %d {\n", indent, label, groupTypeName(node), node.FieldNum))
+
+	counts := make(map[int]int)
+	for _, child := range node.Children {
+		counts[child.FieldNum]++
+	}
+	written := make(map[int]bool)
+	childFieldNum := 1
+	for _, child := range node.Children {
+		if written[child.FieldNum] {
+			continue
+		}
+		written[child.FieldNum] = true
+		if counts[child.FieldNum] > 1 {
+			child.IsRepeated = true
+		}
+		s.WriteProtoFieldRecursive(builder, child, &childFieldNum, indent+"  ", messageCounter, usedMessageNames)
+	}
+	builder.WriteString(fmt.Sprintf("%s}\n", indent))
+}
+
 func (s *Serializer) MapTypeToProtoType(ourType string) string {
 	switch ourType {
 	case "string":
 		return "string"
+	case "bytes":
+		return "bytes"
 	case "int32":
 		return "int32"
 	case "int64":
@@ -286,6 +374,7 @@ func (s *Serializer) TreeToTextFormatWithNames(node *TreeNode) string {
 	if node == nil {
 		return ""
 	}
+	node = expandedCopy(node)
 
 	var result strings.Builder
 	s.WriteNodeToTextFormatWithNames(&result, node, 0)
@@ -296,6 +385,7 @@ func (s *Serializer) TreeToTextFormatWithFieldNames(node *TreeNode, fieldNameMap
 	if node == nil {
 		return ""
 	}
+	node = expandedCopy(node)
 
 	var result strings.Builder
 	s.WriteNodeToTextFormatWithFieldNames(&result, node, 0, fieldNameMap)
@@ -327,7 +417,9 @@ func (s *Serializer) WriteNodeToTextFormatWithNames(builder *strings.Builder, no
 		builder.WriteString(fmt.Sprintf("%d: ", node.FieldNum))
 		if node.Value != nil {
 			if node.Type == "string" {
-				builder.WriteString(fmt.Sprintf("\"%s\"", fmt.Sprintf("%v", node.Value)))
+				builder.WriteString(fmt.Sprintf("\"%s\"", EscapeText(fmt.Sprintf("%v", node.Value))))
+			} else if node.Type == "bytes" {
+				builder.WriteString(fmt.Sprintf("\"%s\"", EscapeTextBytes(BytesValue(node.Value))))
 			} else if node.Type == "bool" {
 				if v, ok := node.Value.(bool); ok {
 					if v {
@@ -351,7 +443,7 @@ func (s *Serializer) WriteNodeToTextFormatWithNames(builder *strings.Builder, no
 					if isNumeric(v) {
 						builder.WriteString(v)
 					} else {
-						builder.WriteString(fmt.Sprintf("\"%s\"", v))
+						builder.WriteString(fmt.Sprintf("\"%s\"", EscapeText(v)))
 					}
 				case bool:
 					if v {
@@ -383,7 +475,10 @@ func (s *Serializer) WriteNodeToTextFormatWithFieldNames(builder *strings.Builde
 	if isMessageType(node.Type) || len(node.Children) > 0 {
 		fieldName, exists := fieldNameMap[node.FieldNum]
 		if !exists {
-			fieldName = node.Name
+			fieldName = protoFieldName(node)
+		}
+		if node.IsGroup {
+			fieldName = groupTypeName(node)
 		}
 		builder.WriteString(fmt.Sprintf("%s {\n", fieldName))
 		for _, child := range node.Children {
@@ -394,10 +489,12 @@ func (s *Serializer) WriteNodeToTextFormatWithFieldNames(builder *strings.Builde
 		}
 		builder.WriteString("}\n")
 	} else {
-		builder.WriteString(fmt.Sprintf("%s: ", node.Name))
+		builder.WriteString(fmt.Sprintf("%s: ", protoFieldName(node)))
 		if node.Value != nil {
 			if node.Type == "string" {
-				builder.WriteString(fmt.Sprintf("\"%s\"", fmt.Sprintf("%v", node.Value)))
+				builder.WriteString(fmt.Sprintf("\"%s\"", EscapeText(fmt.Sprintf("%v", node.Value))))
+			} else if node.Type == "bytes" {
+				builder.WriteString(fmt.Sprintf("\"%s\"", EscapeTextBytes(BytesValue(node.Value))))
 			} else if node.Type == "bool" {
 				if v, ok := node.Value.(bool); ok {
 					if v {
@@ -421,7 +518,7 @@ func (s *Serializer) WriteNodeToTextFormatWithFieldNames(builder *strings.Builde
 					if isNumeric(v) {
 						builder.WriteString(v)
 					} else {
-						builder.WriteString(fmt.Sprintf("\"%s\"", v))
+						builder.WriteString(fmt.Sprintf("\"%s\"", EscapeText(v)))
 					}
 				case bool:
 					if v {
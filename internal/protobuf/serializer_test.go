@@ -1189,4 +1189,54 @@ func TestSerializeRaw_BoolFalseValuePreserved(t *testing.T) {
 			t.Errorf("Expected field_2 value to be true, got %v (type: %T)", field2Value, field2Value)
 		}
 	}
-}
\ No newline at end of file
+}
+
+func TestGenerateProtoSchema_Group(t *testing.T) {
+	serializer := NewSerializer("")
+	root := &TreeNode{Name: "root", Type: "message"}
+	group := &TreeNode{Name: "field_1", Type: "message_1", FieldNum: 1, IsGroup: true}
+	group.AddChild(&TreeNode{Name: "field_2", Type: "int32", FieldNum: 2, Value: "1"})
+	group.AddChild(&TreeNode{Name: "field_2", Type: "int32", FieldNum: 2, Value: "2"})
+	root.AddChild(group)
+
+	fieldNameMap := make(map[int]string)
+	messageCounter := 1
+	schema := serializer.GenerateProtoSchemaWithFieldNames(root, fieldNameMap, &messageCounter, make(map[string]string))
+	expected := "  optional group Group1 = 1 {\n    repeated int32 field_2 = 2;\n  }\n"
+	if !strings.Contains(schema, expected) {
+		t.Errorf("Expected group declaration %q, got:\n%s", expected, schema)
+	}
+
+	text := serializer.TreeToTextFormatWithFieldNames(root, fieldNameMap)
+	if !strings.HasPrefix(text, "Group1 {\n") {
+		t.Errorf("Expected group to be written by its type name, got:\n%s", text)
+	}
+}
+
+func TestTreeToTextFormat_GroupDoesNotRenameNestedFields(t *testing.T) {
+	serializer := NewSerializer("")
+	root := &TreeNode{Name: "root", Type: "message"}
+	group := &TreeNode{Name: "field_1", Type: "message_1", FieldNum: 1, IsGroup: true}
+	group.AddChild(&TreeNode{Name: "field_2", Type: "int32", FieldNum: 2, Value: "1"})
+	root.AddChild(group)
+	inner := &TreeNode{Name: "field_1", Type: "message_3", FieldNum: 1}
+	inner.AddChild(&TreeNode{Name: "field_5", Type: "int64", FieldNum: 5, Value: "3"})
+	outer := &TreeNode{Name: "field_2", Type: "message_2", FieldNum: 2}
+	outer.AddChild(inner)
+	root.AddChild(outer)
+
+	fieldNameMap := make(map[int]string)
+	messageCounter := 1
+	schema := serializer.GenerateProtoSchemaWithFieldNames(root, fieldNameMap, &messageCounter, make(map[string]string))
+	text := serializer.TreeToTextFormatWithFieldNames(root, fieldNameMap)
+
+	if !strings.Contains(schema, "optional Message2 field_1 = 1;") {
+		t.Fatalf("Expected nested message field field_1, got:\n%s", schema)
+	}
+	if !strings.Contains(text, "field_2 {\n  field_1 {\n    field_5: 3\n") {
+		t.Errorf("Expected nested field to keep its schema name, got:\n%s", text)
+	}
+	if !strings.HasPrefix(text, "Group1 {\n") {
+		t.Errorf("Expected group to be written by its type name, got:\n%s", text)
+	}
+}
@@ -0,0 +1,274 @@
+package protobuf
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxFieldNumber - наибольший допустимый номер поля protobuf
+const maxFieldNumber = 1<<29 - 1
+
+// TextSyntaxError - ошибка разбора текстового представления с номером строки (начиная с 1)
+type TextSyntaxError struct {
+	Line    int
+	Message string
+}
+
+func (e *TextSyntaxError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+}
+
+// textValue - значение поля из строки текста: содержимое строки в кавычках уже раскрыто
+type textValue struct {
+	text   string
+	quoted bool
+	line   int
+}
+
+// ParseTextTree разбирает текст в формате TreeToTextFormatWithNames ("N: value" и "N { ... }")
+// в новое дерево. Имена, типы и признаки полей переносятся из reference: k-е вхождение поля N
+// сопоставляется k-му вхождению того же поля в соответствующем сообщении reference. Для новых
+// полей тип определяется по значению, как при декодировании
+func ParseTextTree(text string, reference *TreeNode) (*TreeNode, error) {
+	root := &TreeNode{Name: "root", Type: "message", Children: make([]*TreeNode, 0)}
+	values := make(map[*TreeNode]textValue)
+
+	stack := []*TreeNode{root}
+	openLines := []int{0}
+
+	for i, rawLine := range strings.Split(text, "\n") {
+		lineNum := i + 1
+		line := strings.TrimSpace(stripTextComment(rawLine))
+		if line == "" {
+			continue
+		}
+
+		if line == "}" {
+			if len(stack) == 1 {
+				return nil, &TextSyntaxError{Line: lineNum, Message: "unexpected '}'"}
+			}
+			stack = stack[:len(stack)-1]
+			openLines = openLines[:len(openLines)-1]
+			continue
+		}
+
+		digits := 0
+		for digits < len(line) && line[digits] >= '0' && line[digits] <= '9' {
+			digits++
+		}
+		if digits == 0 {
+			return nil, &TextSyntaxError{Line: lineNum, Message: fmt.Sprintf("expected field number, got %q", line)}
+		}
+		fieldNum := parseInt(line[:digits])
+		if fieldNum < 1 || fieldNum > maxFieldNumber || digits > 9 {
+			return nil, &TextSyntaxError{Line: lineNum, Message: fmt.Sprintf("invalid field number %s", line[:digits])}
+		}
+
+		rest := strings.TrimSpace(line[digits:])
+		isBlock := rest == "{"
+		if !isBlock {
+			if !strings.HasPrefix(rest, ":") {
+				return nil, &TextSyntaxError{Line: lineNum, Message: "expected ':' or '{' after field number"}
+			}
+			rest = strings.TrimSpace(rest[1:])
+			isBlock = rest == "{"
+		}
+
+		parent := stack[len(stack)-1]
+		node := &TreeNode{FieldNum: fieldNum, Name: fmt.Sprintf("field_%d", fieldNum)}
+		parent.AddChild(node)
+
+		if isBlock {
+			node.Type = "message"
+			node.Children = make([]*TreeNode, 0)
+			stack = append(stack, node)
+			openLines = append(openLines, lineNum)
+			continue
+		}
+
+		value, err := parseTextValue(rest)
+		if err != nil {
+			return nil, &TextSyntaxError{Line: lineNum, Message: err.Error()}
+		}
+		value.line = lineNum
+		values[node] = value
+	}
+
+	if len(stack) > 1 {
+		return nil, &TextSyntaxError{Line: openLines[len(openLines)-1], Message: "unclosed '{'"}
+	}
+
+	if err := resolveTextNode(root, reference, values); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+// stripTextComment убирает комментарий "# ..." вне строк в кавычках
+func stripTextComment(line string) string {
+	inString := false
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			if inString {
+				i++
+			}
+		case '"':
+			inString = !inString
+		case '#':
+			if !inString {
+				return line[:i]
+			}
+		}
+	}
+	return line
+}
+
+func parseTextValue(valueStr string) (textValue, error) {
+	if valueStr == "" {
+		return textValue{}, nil
+	}
+	if strings.HasPrefix(valueStr, "\"") {
+		if len(valueStr) < 2 || !strings.HasSuffix(valueStr, "\"") {
+			return textValue{}, fmt.Errorf("unterminated string")
+		}
+		data, err := UnescapeText(valueStr[1 : len(valueStr)-1])
+		if err != nil {
+			return textValue{}, err
+		}
+		return textValue{text: string(data), quoted: true}, nil
+	}
+	if valueStr == "true" || valueStr == "false" || isTextNumber(valueStr) {
+		return textValue{text: valueStr}, nil
+	}
+	return textValue{}, fmt.Errorf("invalid value %q", valueStr)
+}
+
+func isTextNumber(s string) bool {
+	return isInteger(s) || isFloat(s) || isHexFloat(s)
+}
+
+// resolveTextNode назначает узлам разобранного сообщения имена и типы из reference и
+// преобразует значения к типам полей
+func resolveTextNode(node *TreeNode, reference *TreeNode, values map[*TreeNode]textValue) error {
+	var referenceChildren map[int][]*TreeNode
+	if reference != nil {
+		node.Placeholders = nil
+		for _, placeholder := range reference.Placeholders {
+			node.Placeholders = append(node.Placeholders, placeholder.Clone())
+		}
+		referenceChildren = make(map[int][]*TreeNode)
+		for _, child := range reference.fields() {
+			referenceChildren[child.FieldNum] = append(referenceChildren[child.FieldNum], child)
+		}
+	}
+
+	counts := make(map[int]int)
+	for _, child := range node.Children {
+		counts[child.FieldNum]++
+	}
+
+	seen := make(map[int]int)
+	for _, child := range node.Children {
+		var match *TreeNode
+		if candidates := referenceChildren[child.FieldNum]; seen[child.FieldNum] < len(candidates) {
+			match = candidates[seen[child.FieldNum]]
+		}
+		seen[child.FieldNum]++
+
+		_, isScalar := values[child]
+		if match != nil && match.IsMessage() == isScalar {
+			// Сообщение заменено скаляром или наоборот: тип определяется заново
+			match = nil
+		}
+		if match != nil {
+			child.Name = match.Name
+			child.Type = match.Type
+			child.IsRepeated = match.IsRepeated
+			child.IsRequired = match.IsRequired
+			child.IsGroup = match.IsGroup
+			child.wireType = match.wireType
+		}
+		if counts[child.FieldNum] > 1 {
+			child.IsRepeated = true
+		}
+
+		if !isScalar {
+			if err := resolveTextNode(child, match, values); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := resolveTextValue(child, match != nil, values[child]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func resolveTextValue(node *TreeNode, typed bool, value textValue) error {
+	if value.text == "" && !value.quoted {
+		if !typed {
+			return &TextSyntaxError{Line: value.line, Message: fmt.Sprintf("missing value for field %d", node.FieldNum)}
+		}
+		node.Value = nil
+		return nil
+	}
+
+	if !typed {
+		switch {
+		case value.quoted:
+			node.Type = "string"
+			node.Value = value.text
+		case value.text == "true" || value.text == "false":
+			node.Type = "bool"
+			node.Value = value.text == "true"
+		case isInteger(value.text):
+			node.Type = "int64"
+			node.Value = parseSignedNumber(value.text)
+		case isHexFloat(value.text):
+			node.Type = "double"
+			node.Value = convertHexFloatToDecimal(value.text)
+		default:
+			node.Type = "double"
+			node.Value = value.text
+		}
+		return nil
+	}
+
+	switch node.Type {
+	case "string", "bytes":
+		if !value.quoted {
+			return &TextSyntaxError{Line: value.line, Message: fmt.Sprintf("field %d expects a quoted %s value", node.FieldNum, node.Type)}
+		}
+		if node.Type == "bytes" {
+			node.Value = []byte(value.text)
+		} else {
+			node.Value = value.text
+		}
+	case "bool":
+		switch {
+		case !value.quoted && (value.text == "true" || value.text == "1"):
+			node.Value = true
+		case !value.quoted && (value.text == "false" || value.text == "0"):
+			node.Value = false
+		default:
+			return &TextSyntaxError{Line: value.line, Message: fmt.Sprintf("field %d expects true or false", node.FieldNum)}
+		}
+	case "int32", "int64", "uint32", "uint64", "sint32", "sint64", "fixed32", "fixed64", "sfixed32", "sfixed64", "float", "double":
+		if value.quoted || !isTextNumber(value.text) {
+			return &TextSyntaxError{Line: value.line, Message: fmt.Sprintf("field %d expects a number", node.FieldNum)}
+		}
+		node.Value = value.text
+	default:
+		node.Value = value.text
+	}
+	return nil
+}
+
+// ReplaceTreeContent переносит содержимое разобранного дерева в target, сохраняя сам узел:
+// на корень ссылаются адаптеры дерева и таблицы вкладки
+func ReplaceTreeContent(target *TreeNode, source *TreeNode) {
+	target.Children = source.Children
+	target.Placeholders = source.Placeholders
+}
@@ -0,0 +1,114 @@
+package protobuf
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseTextTree_RoundTripKeepsSchemaInfo(t *testing.T) {
+	reference := newTestRoot(
+		&TreeNode{Name: "id", Type: "string", FieldNum: 1, Value: "A-1"},
+		&TreeNode{Name: "payload", Type: "bytes", FieldNum: 2, Value: []byte{0xff, 0x00}},
+		&TreeNode{Name: "items", Type: "Item", FieldNum: 3, IsRepeated: true, Children: []*TreeNode{
+			{Name: "qty", Type: "int32", FieldNum: 1, Value: "2"},
+		}},
+		&TreeNode{Name: "items", Type: "Item", FieldNum: 3, IsRepeated: true, Children: []*TreeNode{
+			{Name: "qty", Type: "int32", FieldNum: 1, Value: "5"},
+		}},
+		&TreeNode{Name: "active", Type: "bool", FieldNum: 4, Value: true},
+	)
+	serializer := &Serializer{}
+	text := serializer.TreeToTextFormatWithNames(reference)
+
+	tree, err := ParseTextTree(text, reference)
+	if err != nil {
+		t.Fatalf("ParseTextTree failed: %v\n%s", err, text)
+	}
+	if got := serializer.TreeToTextFormatWithNames(tree); got != text {
+		t.Errorf("Expected round trip to keep the text, got:\n%s\nwant:\n%s", got, text)
+	}
+	if tree.Children[0].Name != "id" || tree.Children[3].Type != "Item" || tree.Children[3].Children[0].Name != "qty" {
+		t.Errorf("Expected names and types from the reference tree, got %+v", tree.Children)
+	}
+	if data, ok := tree.Children[1].Value.([]byte); !ok || string(data) != "\xff\x00" {
+		t.Errorf("Expected bytes value, got %#v", tree.Children[1].Value)
+	}
+	if tree.Children[4].Value != true {
+		t.Errorf("Expected bool value, got %#v", tree.Children[4].Value)
+	}
+}
+
+func TestParseTextTree_EditsAndNewFields(t *testing.T) {
+	reference := newTestRoot(
+		&TreeNode{Name: "id", Type: "string", FieldNum: 1, Value: "A-1"},
+		&TreeNode{Name: "items", Type: "Item", FieldNum: 3, IsRepeated: true, Children: []*TreeNode{
+			{Name: "qty", Type: "int32", FieldNum: 1, Value: "2"},
+		}},
+	)
+	text := `1: "B-2" # renamed
+3 {
+  1: 7
+}
+9: -12
+10 {
+  1: "x"
+}
+`
+	tree, err := ParseTextTree(text, reference)
+	if err != nil {
+		t.Fatalf("ParseTextTree failed: %v", err)
+	}
+	if len(tree.Children) != 4 {
+		t.Fatalf("Expected 4 fields, got %d", len(tree.Children))
+	}
+	if tree.Children[0].Value != "B-2" {
+		t.Errorf("Expected edited string, got %v", tree.Children[0].Value)
+	}
+	if tree.Children[1].Children[0].Value != "7" || tree.Children[1].Name != "items" {
+		t.Errorf("Expected edited repeated message, got %+v", tree.Children[1])
+	}
+	if tree.Children[1].IsRepeated != true {
+		t.Error("Expected repeated flag to be kept from the reference")
+	}
+	if tree.Children[2].Name != "field_9" || tree.Children[2].Type != "int64" || tree.Children[2].Value != "-12" {
+		t.Errorf("Expected new integer field, got %+v", tree.Children[2])
+	}
+	if tree.Children[3].Type != "message" || tree.Children[3].Children[0].Type != "string" {
+		t.Errorf("Expected new message field, got %+v", tree.Children[3])
+	}
+}
+
+func TestParseTextTree_Errors(t *testing.T) {
+	reference := newTestRoot(
+		&TreeNode{Name: "id", Type: "string", FieldNum: 1, Value: "A-1"},
+		&TreeNode{Name: "items", Type: "Item", FieldNum: 3, Children: []*TreeNode{
+			{Name: "qty", Type: "int32", FieldNum: 1, Value: "2"},
+		}},
+		&TreeNode{Name: "active", Type: "bool", FieldNum: 4, Value: true},
+	)
+	tests := []struct {
+		text string
+		line int
+	}{
+		{"1: \"a\"\n}\n", 2},
+		{"1: \"a\"\n3 {\n  1: 2\n", 2},
+		{"\n1: \"unterminated\n", 2},
+		{"1: 5\n", 1},
+		{"4: \"yes\"\n", 1},
+		{"3 {\n  1: abc\n}\n", 2},
+		{"name: 1\n", 1},
+		{"0: 1\n", 1},
+		{"7:\n", 1},
+	}
+	for _, tt := range tests {
+		_, err := ParseTextTree(tt.text, reference)
+		var syntaxErr *TextSyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("%q: expected TextSyntaxError, got %v", tt.text, err)
+			continue
+		}
+		if syntaxErr.Line != tt.line {
+			t.Errorf("%q: expected error on line %d, got %v", tt.text, tt.line, err)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package protobuf
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// EscapeText экранирует строку для строкового литерала text format. Печатаемые символы
+// корректной UTF-8 сохраняются как есть, управляющие символы, кавычки и обратный слеш
+// заменяются C-escape, байты некорректной UTF-8 - восьмеричными \NNN
+func EscapeText(s string) string {
+	var builder strings.Builder
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == utf8.RuneError && size <= 1:
+			writeOctalEscape(&builder, s[i])
+		case r < utf8.RuneSelf:
+			writeEscapedASCII(&builder, byte(r))
+		case unicode.IsPrint(r):
+			builder.WriteString(s[i : i+size])
+		default:
+			for j := i; j < i+size; j++ {
+				writeOctalEscape(&builder, s[j])
+			}
+		}
+		i += size
+	}
+	return builder.String()
+}
+
+// EscapeTextBytes экранирует байты так же, как protoc для bytes полей: все байты вне
+// печатаемого ASCII записываются восьмеричными \NNN
+func EscapeTextBytes(data []byte) string {
+	var builder strings.Builder
+	for _, b := range data {
+		if b >= utf8.RuneSelf {
+			writeOctalEscape(&builder, b)
+			continue
+		}
+		writeEscapedASCII(&builder, b)
+	}
+	return builder.String()
+}
+
+func writeEscapedASCII(builder *strings.Builder, b byte) {
+	switch b {
+	case '\n':
+		builder.WriteString(`\n`)
+	case '\r':
+		builder.WriteString(`\r`)
+	case '\t':
+		builder.WriteString(`\t`)
+	case '"':
+		builder.WriteString(`\"`)
+	case '\'':
+		builder.WriteString(`\'`)
+	case '\\':
+		builder.WriteString(`\\`)
+	default:
+		if b < 0x20 || b == 0x7f {
+			writeOctalEscape(builder, b)
+		} else {
+			builder.WriteByte(b)
+		}
+	}
+}
+
+func writeOctalEscape(builder *strings.Builder, b byte) {
+	builder.WriteString(fmt.Sprintf("\\%03o", b))
+}
+
+// UnescapeText восстанавливает байты строкового литерала text format (без кавычек).
+// Поддерживаются все escape-последовательности, которые понимает protoc: \a \b \f \n
+// \r \t \v \\ \' \" \?, восьмеричные \NNN, шестнадцатеричные \xHH и \uXXXX, \UXXXXXXXX
+func UnescapeText(s string) ([]byte, error) {
+	result := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			result = append(result, s[i])
+			continue
+		}
+
+		i++
+		if i >= len(s) {
+			return nil, fmt.Errorf("unterminated escape sequence at end of string")
+		}
+
+		switch c := s[i]; c {
+		case 'a':
+			result = append(result, '\a')
+		case 'b':
+			result = append(result, '\b')
+		case 'f':
+			result = append(result, '\f')
+		case 'n':
+			result = append(result, '\n')
+		case 'r':
+			result = append(result, '\r')
+		case 't':
+			result = append(result, '\t')
+		case 'v':
+			result = append(result, '\v')
+		case '\\', '\'', '"', '?':
+			result = append(result, c)
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			value := 0
+			j := i
+			for ; j < len(s) && j < i+3 && isOctalDigit(s[j]); j++ {
+				value = value*8 + int(s[j]-'0')
+			}
+			if value > 0xff {
+				return nil, fmt.Errorf("octal escape \\%s is out of range", s[i:j])
+			}
+			result = append(result, byte(value))
+			i = j - 1
+		case 'x', 'X':
+			value, digits := parseHexDigits(s[i+1:], 2)
+			if digits == 0 {
+				return nil, fmt.Errorf("invalid hex escape at offset %d", i-1)
+			}
+			result = append(result, byte(value))
+			i += digits
+		case 'u', 'U':
+			length := 4
+			if c == 'U' {
+				length = 8
+			}
+			value, digits := parseHexDigits(s[i+1:], length)
+			if digits != length || !utf8.ValidRune(rune(value)) {
+				return nil, fmt.Errorf("invalid unicode escape at offset %d", i-1)
+			}
+			result = utf8.AppendRune(result, rune(value))
+			i += digits
+		default:
+			return nil, fmt.Errorf("invalid escape sequence \\%c", c)
+		}
+	}
+	return result, nil
+}
+
+func isOctalDigit(c byte) bool {
+	return c >= '0' && c <= '7'
+}
+
+// parseHexDigits читает не более limit шестнадцатеричных цифр и возвращает значение и их количество
+func parseHexDigits(s string, limit int) (int, int) {
+	value := 0
+	digits := 0
+	for ; digits < len(s) && digits < limit; digits++ {
+		c := s[digits]
+		switch {
+		case c >= '0' && c <= '9':
+			value = value*16 + int(c-'0')
+		case c >= 'a' && c <= 'f':
+			value = value*16 + int(c-'a'+10)
+		case c >= 'A' && c <= 'F':
+			value = value*16 + int(c-'A'+10)
+		default:
+			return value, digits
+		}
+	}
+	return value, digits
+}
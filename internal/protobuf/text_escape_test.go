@@ -0,0 +1,112 @@
+package protobuf
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestUnescapeText_ProtocEscapes(t *testing.T) {
+	tests := []struct {
+		escaped string
+		want    string
+	}{
+		{`\n`, "\n"},
+		{`\r`, "\r"},
+		{`\t`, "\t"},
+		{`\"`, `"`},
+		{`\'`, `'`},
+		{`\\`, `\`},
+		{`\000`, "\x00"},
+		{`\177`, "\x7f"},
+		{`\377`, "\xff"},
+		{`\0017`, "\x017"},
+		{`\7`, "\x07"},
+		{`\a\b\f\v\?`, "\a\b\f\v?"},
+		{`\x41\xff\xA`, "A\xff\n"},
+		{`\u00e9\U0001F600`, "é😀"},
+		{`caf\303\251`, "café"},
+		{`plain text`, "plain text"},
+	}
+
+	for _, tt := range tests {
+		got, err := UnescapeText(tt.escaped)
+		if err != nil {
+			t.Errorf("UnescapeText(%q) failed: %v", tt.escaped, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("UnescapeText(%q) = %q, want %q", tt.escaped, got, tt.want)
+		}
+	}
+}
+
+func TestUnescapeText_Errors(t *testing.T) {
+	for _, escaped := range []string{`trailing\`, `\q`, `\x`, `\u12`, `\400`, `\UFFFFFFFF`} {
+		if _, err := UnescapeText(escaped); err == nil {
+			t.Errorf("Expected error for %q", escaped)
+		}
+	}
+}
+
+func TestEscapeText(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"say \"hi\"\n", `say \"hi\"\n`},
+		{`C:\dir`, `C:\\dir`},
+		{"it's\t", `it\'s\t`},
+		{"\x00\x1f\x7f", `\000\037\177`},
+		{"привет", "привет"},
+		{"caf\xc3", `caf\303`},
+		{"\u200b", `\342\200\213`},
+	}
+
+	for _, tt := range tests {
+		if got := EscapeText(tt.value); got != tt.want {
+			t.Errorf("EscapeText(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestEscapeText_RoundTripAllBytes(t *testing.T) {
+	data := make([]byte, 256)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	for _, escaped := range []string{EscapeText(string(data)), EscapeTextBytes(data)} {
+		got, err := UnescapeText(escaped)
+		if err != nil {
+			t.Fatalf("UnescapeText failed: %v", err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("Round trip mismatch for %q", escaped)
+		}
+	}
+}
+
+func TestParseLine_UnescapesStrings(t *testing.T) {
+	parser := &Parser{}
+
+	node := parser.parseLine(`1: "say \"hi\"\\\303"`)
+	if node == nil || node.Type != "string" || node.Value != "say \"hi\"\\\xc3" {
+		t.Fatalf("Expected unescaped string, got %#v", node)
+	}
+
+	node = parser.parseLine(`2: "\""`)
+	if node == nil || node.Value != `"` {
+		t.Errorf("Expected a single quote character, got %#v", node)
+	}
+}
+
+func TestTreeToTextFormat_EscapesStrings(t *testing.T) {
+	tree := newTestRoot(&TreeNode{Name: "text", Type: "string", FieldNum: 1, Value: "a\"b\\c\n\xff"})
+
+	serializer := &Serializer{}
+	want := `1: "a\"b\\c\n\377"`
+	if text := serializer.TreeToTextFormat(tree); !strings.Contains(text, want) {
+		t.Errorf("Expected %s in text format, got %q", want, text)
+	}
+}
@@ -9,6 +9,24 @@ type TreeNode struct {
 	Children   []*TreeNode
 	FieldNum   int
 	IsRepeated bool
+	// IsRequired отмечает поля, объявленные в схеме как required
+	IsRequired bool
+	// IsGroup отмечает proto2 группы: сообщение закодировано тегами начала и конца
+	// группы (wire type 3/4), а не как length-delimited значение
+	IsGroup bool
+	// Placeholders содержит объявленные в схеме, но отсутствующие в данных поля.
+	// Они только отображаются и не участвуют в сериализации и экспорте.
+	Placeholders []*TreeNode
+	// Framing - заголовок Confluent wire format, с которым было записано корневое сообщение.
+	// Сериализация записывает его перед телом сообщения
+	Framing *ConfluentHeader
+	// lazy - неразобранное тело сообщения при ленивом декодировании (см. DecodeLazy)
+	lazy *lazyPayload
+	// schema - схема, применяемая к полям ленивого узла при разборе (см. lazySchema)
+	schema *lazySchema
+	// wireType - wire type, с которым поле прочитано из бинарных данных (wireTypeVarint и т.д.).
+	// Не меняется при применении схемы; пустой у узлов, созданных не декодером
+	wireType string
 }
 
 func NewTreeNode(name, fieldType string, fieldNum int) *TreeNode {
@@ -24,8 +42,41 @@ func (n *TreeNode) AddChild(child *TreeNode) {
 	n.Children = append(n.Children, child)
 }
 
+// Clone возвращает глубокую копию узла со всеми дочерними элементами
+func (n *TreeNode) Clone() *TreeNode {
+	if n == nil {
+		return nil
+	}
+
+	clone := &TreeNode{
+		Name:       n.Name,
+		Type:       n.Type,
+		Value:      n.Value,
+		FieldNum:   n.FieldNum,
+		IsRepeated: n.IsRepeated,
+		IsRequired: n.IsRequired,
+		IsGroup:    n.IsGroup,
+		Framing:    n.Framing,
+		Children:   make([]*TreeNode, 0, len(n.Children)),
+		lazy:       n.lazy,
+		schema:     n.schema.detached(),
+		wireType:   n.wireType,
+	}
+	// Срез байтов bytes поля копируется, чтобы правки клона не затрагивали оригинал
+	if data, ok := n.Value.([]byte); ok {
+		clone.Value = append([]byte(nil), data...)
+	}
+	for _, child := range n.Children {
+		clone.Children = append(clone.Children, child.Clone())
+	}
+	for _, placeholder := range n.Placeholders {
+		clone.Placeholders = append(clone.Placeholders, placeholder.Clone())
+	}
+	return clone
+}
+
 func (n *TreeNode) IsMessage() bool {
-	return len(n.Children) > 0 || isMessageType(n.Type)
+	return len(n.Children) > 0 || n.IsLazy() || isMessageType(n.Type)
 }
 
 func isMessageType(t string) bool {
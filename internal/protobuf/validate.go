@@ -0,0 +1,42 @@
+package protobuf
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// ValidateData декодирует одно бинарное сообщение и проверяет его на соответствие схеме.
+// Отчет содержит неизвестные поля, несовпадения wire type, отсутствующие required поля,
+// значения вне перечислений, строки с некорректной UTF-8 и нарушения правил rules
+func (p *Parser) ValidateData(data []byte, schemaPath string, messageName string, rules ConstraintRules) (*SchemaReport, error) {
+	tree, err := p.ParseRaw(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode: %w", err)
+	}
+
+	_, report, err := p.ApplySchemaWithRules(tree, schemaPath, messageName, rules)
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// SplitDelimited разбивает поток сообщений, каждое из которых предварено длиной в формате
+// varint (writeDelimitedTo в Java, DelimitedMessages в Go)
+func SplitDelimited(data []byte) ([][]byte, error) {
+	messages := make([][]byte, 0)
+	offset := 0
+	for offset < len(data) {
+		length, n := binary.Uvarint(data[offset:])
+		if n <= 0 {
+			return nil, fmt.Errorf("invalid length prefix at offset %d", offset)
+		}
+		offset += n
+		if length > uint64(len(data)-offset) {
+			return nil, fmt.Errorf("message at offset %d is truncated: need %d bytes, have %d", offset-n, length, len(data)-offset)
+		}
+		messages = append(messages, data[offset:offset+int(length)])
+		offset += int(length)
+	}
+	return messages, nil
+}
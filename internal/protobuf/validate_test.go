@@ -0,0 +1,31 @@
+package protobuf
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSplitDelimited(t *testing.T) {
+	long := bytes.Repeat([]byte{0x08}, 200)
+	data := append([]byte{0x02, 0x08, 0x01, 0x00, 0xc8, 0x01}, long...)
+
+	messages, err := SplitDelimited(data)
+	if err != nil {
+		t.Fatalf("SplitDelimited failed: %v", err)
+	}
+	if len(messages) != 3 {
+		t.Fatalf("Expected 3 messages, got %d", len(messages))
+	}
+	if !bytes.Equal(messages[0], []byte{0x08, 0x01}) || len(messages[1]) != 0 || len(messages[2]) != 200 {
+		t.Errorf("Unexpected messages: %v", messages)
+	}
+}
+
+func TestSplitDelimited_Truncated(t *testing.T) {
+	if _, err := SplitDelimited([]byte{0x05, 0x08, 0x01}); err == nil {
+		t.Error("Expected error for truncated message")
+	}
+	if _, err := SplitDelimited([]byte{0x80}); err == nil {
+		t.Error("Expected error for invalid length prefix")
+	}
+}
@@ -0,0 +1,145 @@
+package protobuf
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Wire types кодирования protobuf
+const (
+	wireVarint     = 0
+	wireFixed64    = 1
+	wireBytes      = 2
+	wireStartGroup = 3
+	wireEndGroup   = 4
+	wireFixed32    = 5
+)
+
+// wireField - одно поле сообщения, прочитанное напрямую из бинарных данных.
+// Для length-delimited полей и групп payload содержит тело без тегов и длины
+type wireField struct {
+	number   int
+	wireType int
+	payload  []byte
+	// end - смещение конца поля в разобранных данных
+	end int
+}
+
+// scanWireFields разбирает верхний уровень сообщения. Группы читаются целиком
+// до парного тега конца группы, их тело возвращается в payload
+func scanWireFields(data []byte) ([]wireField, error) {
+	fields, _, err := scanWireFieldsUntil(data, 0)
+	return fields, err
+}
+
+// scanWireFieldsUntil читает поля до конца данных или, если endNumber не 0, до тега
+// конца группы endNumber. Возвращает количество прочитанных байт вместе с этим тегом
+func scanWireFieldsUntil(data []byte, endNumber int) ([]wireField, int, error) {
+	fields := make([]wireField, 0)
+	offset := 0
+	for offset < len(data) {
+		tagOffset := offset
+		tag, n := binary.Uvarint(data[offset:])
+		if n <= 0 {
+			return nil, 0, fmt.Errorf("invalid tag at offset %d", tagOffset)
+		}
+		offset += n
+		number := int(tag >> 3)
+		wireType := int(tag & 7)
+		if number == 0 {
+			return nil, 0, fmt.Errorf("invalid field number 0 at offset %d", tagOffset)
+		}
+
+		field := wireField{number: number, wireType: wireType}
+		switch wireType {
+		case wireVarint:
+			_, n := binary.Uvarint(data[offset:])
+			if n <= 0 {
+				return nil, 0, fmt.Errorf("invalid varint at offset %d", offset)
+			}
+			field.payload = data[offset : offset+n]
+			offset += n
+		case wireFixed64, wireFixed32:
+			size := 8
+			if wireType == wireFixed32 {
+				size = 4
+			}
+			if len(data)-offset < size {
+				return nil, 0, fmt.Errorf("truncated fixed-width value at offset %d", offset)
+			}
+			field.payload = data[offset : offset+size]
+			offset += size
+		case wireBytes:
+			length, n := binary.Uvarint(data[offset:])
+			if n <= 0 {
+				return nil, 0, fmt.Errorf("invalid length at offset %d", offset)
+			}
+			offset += n
+			if length > uint64(len(data)-offset) {
+				return nil, 0, fmt.Errorf("truncated length-delimited value at offset %d", offset)
+			}
+			field.payload = data[offset : offset+int(length)]
+			offset += int(length)
+		case wireStartGroup:
+			_, consumed, err := scanWireFieldsUntil(data[offset:], number)
+			if err != nil {
+				return nil, 0, err
+			}
+			endTagSize := len(binary.AppendUvarint(nil, uint64(number)<<3|wireEndGroup))
+			field.payload = data[offset : offset+consumed-endTagSize]
+			offset += consumed
+		case wireEndGroup:
+			if number != endNumber {
+				return nil, 0, fmt.Errorf("unexpected end group tag for field %d at offset %d", number, tagOffset)
+			}
+			return fields, offset, nil
+		default:
+			return nil, 0, fmt.Errorf("invalid wire type %d at offset %d", wireType, tagOffset)
+		}
+		field.end = offset
+		fields = append(fields, field)
+	}
+	if endNumber != 0 {
+		return nil, 0, fmt.Errorf("group %d is not terminated", endNumber)
+	}
+	return fields, offset, nil
+}
+
+// markGroups отмечает узлы дерева, закодированные как группы. Вывод protoc --decode_raw
+// не отличает группы от вложенных сообщений, поэтому дерево сопоставляется с полями
+// бинарных данных по порядку. При любом расхождении разметка уровня пропускается
+func markGroups(node *TreeNode, data []byte) {
+	fields, err := scanWireFields(data)
+	if err != nil {
+		return
+	}
+	markGroupFields(node, fields)
+}
+
+func markGroupFields(node *TreeNode, fields []wireField) {
+	if len(fields) != len(node.Children) {
+		return
+	}
+	for i, field := range fields {
+		if node.Children[i].FieldNum != field.number {
+			return
+		}
+	}
+
+	for i, field := range fields {
+		child := node.Children[i]
+		switch field.wireType {
+		case wireStartGroup:
+			child.IsGroup = true
+			child.wireType = wireTypeGroup
+			groupFields, err := scanWireFields(field.payload)
+			if err == nil {
+				markGroupFields(child, groupFields)
+			}
+		case wireBytes:
+			if len(child.Children) > 0 {
+				markGroups(child, field.payload)
+			}
+		}
+	}
+}
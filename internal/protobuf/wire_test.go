@@ -0,0 +1,87 @@
+package protobuf
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestScanWireFields_Group(t *testing.T) {
+	// 1: group { 1: 1, 2: "a" }, 2: 5
+	data := []byte{0x0b, 0x08, 0x01, 0x12, 0x01, 'a', 0x0c, 0x10, 0x05}
+
+	fields, err := scanWireFields(data)
+	if err != nil {
+		t.Fatalf("scanWireFields failed: %v", err)
+	}
+	if len(fields) != 2 {
+		t.Fatalf("Expected 2 fields, got %d", len(fields))
+	}
+	if fields[0].number != 1 || fields[0].wireType != wireStartGroup {
+		t.Errorf("Expected group field 1, got %+v", fields[0])
+	}
+	if !bytes.Equal(fields[0].payload, []byte{0x08, 0x01, 0x12, 0x01, 'a'}) {
+		t.Errorf("Expected group body without tags, got %x", fields[0].payload)
+	}
+	if fields[1].number != 2 || fields[1].wireType != wireVarint {
+		t.Errorf("Expected varint field 2, got %+v", fields[1])
+	}
+}
+
+func TestScanWireFields_InvalidGroups(t *testing.T) {
+	tests := map[string][]byte{
+		"unterminated": {0x0b, 0x08, 0x01},
+		"mismatched":   {0x0b, 0x08, 0x01, 0x14},
+		"stray end":    {0x08, 0x01, 0x0c},
+	}
+	for name, data := range tests {
+		if _, err := scanWireFields(data); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestMarkGroups(t *testing.T) {
+	// 1: group { 3: group { 1: 7 } }, 2: { 4: group { } }
+	data := []byte{0x0b, 0x1b, 0x08, 0x07, 0x1c, 0x0c, 0x12, 0x02, 0x23, 0x24}
+
+	root := &TreeNode{Name: "root", Type: "message"}
+	group := &TreeNode{Name: "field_1", Type: "message_1", FieldNum: 1}
+	inner := &TreeNode{Name: "field_3", Type: "message_2", FieldNum: 3}
+	inner.AddChild(&TreeNode{Name: "field_1", Type: "int64", FieldNum: 1, Value: "7"})
+	group.AddChild(inner)
+	message := &TreeNode{Name: "field_2", Type: "message_3", FieldNum: 2}
+	message.AddChild(&TreeNode{Name: "field_4", Type: "message_4", FieldNum: 4})
+	root.AddChild(group)
+	root.AddChild(message)
+
+	markGroups(root, data)
+
+	if !group.IsGroup || !inner.IsGroup {
+		t.Errorf("Expected nested groups to be marked, got %v and %v", group.IsGroup, inner.IsGroup)
+	}
+	if message.IsGroup {
+		t.Error("Expected length-delimited message not to be marked as group")
+	}
+	if !message.Children[0].IsGroup {
+		t.Error("Expected group inside length-delimited message to be marked")
+	}
+
+	clone := root.Clone()
+	if !clone.Children[0].IsGroup {
+		t.Error("Expected Clone to preserve IsGroup")
+	}
+}
+
+func TestMarkGroups_SkipsMismatchedTree(t *testing.T) {
+	data := []byte{0x0b, 0x08, 0x01, 0x0c}
+
+	root := &TreeNode{Name: "root", Type: "message"}
+	node := &TreeNode{Name: "field_2", Type: "message_1", FieldNum: 2}
+	root.AddChild(node)
+
+	markGroups(root, data)
+
+	if node.IsGroup {
+		t.Error("Expected no marks when tree does not match the data")
+	}
+}
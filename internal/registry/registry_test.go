@@ -0,0 +1,127 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// newStubRegistry отвечает как Confluent Schema Registry: схема 1 импортирует common/money.proto,
+// схема 2 - Avro, остальных схем нет
+func newStubRegistry(t *testing.T) (*httptest.Server, map[string]int) {
+	var mu sync.Mutex
+	requests := make(map[string]int)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.URL.Path]++
+		mu.Unlock()
+
+		if user, password, ok := r.BasicAuth(); !ok || user != "reader" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		switch r.URL.Path {
+		case "/schemas/ids/1":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"schemaType": "PROTOBUF",
+				"schema":     "syntax = \"proto3\";\nimport \"common/money.proto\";\nmessage Order { string id = 1; Money total = 2; }\n",
+				"references": []map[string]interface{}{{"name": "common/money.proto", "subject": "money value", "version": 3}},
+			})
+		case "/subjects/money value/versions/3":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"schemaType": "PROTOBUF",
+				"schema":     "syntax = \"proto3\";\nmessage Money { int64 units = 1; }\n",
+			})
+		case "/schemas/ids/2":
+			json.NewEncoder(w).Encode(map[string]interface{}{"schema": "{\"type\": \"string\"}"})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]interface{}{"error_code": 40403, "message": "Schema not found"})
+		}
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	t.Cleanup(server.Close)
+	return server, requests
+}
+
+func TestClient_FetchesAndCachesSchemas(t *testing.T) {
+	server, requests := newStubRegistry(t)
+	cacheDir := t.TempDir()
+	registryURL := strings.Replace(server.URL, "http://", "http://reader:secret@", 1)
+
+	client, err := NewClient(registryURL+"/", cacheDir)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	schemaPath, err := client.SchemaPath(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("SchemaPath failed: %v", err)
+	}
+	if !strings.HasPrefix(schemaPath, cacheDir) || strings.Contains(schemaPath, "secret") {
+		t.Errorf("Expected schema in the cache without credentials, got %s", schemaPath)
+	}
+	content, err := os.ReadFile(schemaPath)
+	if err != nil || !strings.Contains(string(content), "message Order") {
+		t.Fatalf("Expected cached Order schema, got %q, %v", content, err)
+	}
+	imported, err := os.ReadFile(filepath.Join(filepath.Dir(schemaPath), "common", "money.proto"))
+	if err != nil || !strings.Contains(string(imported), "message Money") {
+		t.Fatalf("Expected referenced schema next to the main one, got %q, %v", imported, err)
+	}
+
+	// Повторный запрос, в том числе новым клиентом, берет схему из кэша
+	again, err := NewClient(registryURL, cacheDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cached, err := again.SchemaPath(context.Background(), 1); err != nil || cached != schemaPath {
+		t.Errorf("Expected cached path %s, got %s, %v", schemaPath, cached, err)
+	}
+	if requests["/schemas/ids/1"] != 1 {
+		t.Errorf("Expected a single registry request, got %d", requests["/schemas/ids/1"])
+	}
+
+	if _, err := client.SchemaPath(context.Background(), 2); err == nil || !strings.Contains(err.Error(), "AVRO") {
+		t.Errorf("Expected an error for an Avro schema, got %v", err)
+	}
+	if _, err := client.SchemaPath(context.Background(), 7); err == nil || !strings.Contains(err.Error(), "Schema not found") {
+		t.Errorf("Expected the registry error message, got %v", err)
+	}
+
+	anonymous, _ := NewClient(server.URL, t.TempDir())
+	if _, err := anonymous.SchemaPath(context.Background(), 1); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("Expected an authorization error, got %v", err)
+	}
+}
+
+func TestNewClient_RejectsInvalidURLs(t *testing.T) {
+	for _, registryURL := range []string{"", "registry:8081", "ftp://registry", "http://"} {
+		if _, err := NewClient(registryURL, t.TempDir()); err == nil {
+			t.Errorf("Expected an error for %q", registryURL)
+		}
+	}
+}
+
+func TestValidReferenceName(t *testing.T) {
+	cases := map[string]bool{
+		"money.proto":        true,
+		"common/money.proto": true,
+		"../money.proto":     false,
+		"/etc/money.proto":   false,
+		"a/../../b.proto":    false,
+		"schema.proto":       false,
+		"":                   false,
+	}
+	for name, want := range cases {
+		if got := validReferenceName(name); got != want {
+			t.Errorf("validReferenceName(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
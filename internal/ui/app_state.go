@@ -2,8 +2,10 @@ package ui
 
 import (
 	"encoding/json"
+	"log"
 	"os"
 	"path/filepath"
+	"sync"
 
 	"fyne.io/fyne/v2"
 )
@@ -16,13 +18,34 @@ type appState struct {
 		DialogWidth  float32 `json:"dialogWidth"`
 		DialogHeight float32 `json:"dialogHeight"`
 	} `json:"fileDialogState"`
+	// RecentFiles и RecentSchemas - недавно открытые файлы и схемы, последние первыми
+	RecentFiles   []string `json:"recentFiles,omitempty"`
+	RecentSchemas []string `json:"recentSchemas,omitempty"`
+	// SchemaAssociations - схемы, применявшиеся к файлам, последние первыми (см. schemaFor)
+	SchemaAssociations []schemaAssociation `json:"schemaAssociations,omitempty"`
+	// SchemaLibrary - каталоги и наборы дескрипторов, среди которых ищется тип данных
+	SchemaLibrary []string `json:"schemaLibrary,omitempty"`
+	// RegistryURL - адрес реестра схем для данных с заголовком Confluent wire format
+	RegistryURL string `json:"registryUrl,omitempty"`
+	// WorkspacePath - рабочее пространство, открытое при выходе. Его вкладки хранятся в его
+	// файле, а Tabs остаются вкладками вне рабочего пространства
+	WorkspacePath string `json:"workspacePath,omitempty"`
 }
 
+// appStateMu защищает чтение-изменение-запись state.json: состояние вкладок сохраняется из
+// горутин, а история файлов - из интерфейса
+var appStateMu sync.Mutex
+
 type tabState struct {
 	Title             string `json:"title"`
 	FilePath          string `json:"filePath,omitempty"`
 	SchemaPath        string `json:"schemaPath,omitempty"`
 	SchemaMessageName string `json:"schemaMessageName,omitempty"`
+	RulesPath         string `json:"rulesPath,omitempty"`
+	ViewMode          string `json:"viewMode,omitempty"`
+	// ExpandedNodes - раскрытые ветки дерева как пути индексов от корня ("0/2").
+	// Сохраняются только в рабочих пространствах
+	ExpandedNodes []string `json:"expandedNodes,omitempty"`
 }
 
 func getAppStatePath() (string, error) {
@@ -73,30 +96,105 @@ func saveAppState(state *appState) error {
 		return err
 	}
 
-	return os.WriteFile(statePath, data, 0644)
+	// Адрес реестра схем может содержать учетные данные, поэтому состояние доступно только
+	// владельцу. Chmod закрывает и файлы, созданные прежними версиями с правами 0644
+	if err := os.WriteFile(statePath, data, 0600); err != nil {
+		return err
+	}
+	return os.Chmod(statePath, 0600)
+}
+
+// readAppState загружает сохраненное состояние, не пересекаясь с его записью
+func readAppState() (*appState, error) {
+	appStateMu.Lock()
+	defer appStateMu.Unlock()
+	return loadAppState()
 }
 
-func saveTabState(tm *tabManager) error {
-	state := &appState{
-		Tabs:        make([]tabState, 0, len(tm.tabs)),
-		SelectedTab: tm.selectedTab,
+// updateAppState загружает сохраненное состояние, изменяет его через update и сохраняет.
+// Поврежденное или отсутствующее состояние заменяется пустым
+func updateAppState(update func(state *appState)) error {
+	appStateMu.Lock()
+	defer appStateMu.Unlock()
+
+	state, err := loadAppState()
+	if err != nil || state == nil {
+		state = &appState{
+			Tabs:        make([]tabState, 0),
+			SelectedTab: -1,
+		}
 	}
+	update(state)
+	return saveAppState(state)
+}
 
-	for _, tab := range tm.tabs {
-		state.Tabs = append(state.Tabs, tabState{
+// collectTabStates возвращает состояние вкладок, кроме transient, и индекс выбранной среди них.
+// Раскрытые ветки обходят виджеты деревьев, поэтому собираются только по запросу из интерфейса
+func collectTabStates(tm *tabManager, withExpandedNodes bool) ([]tabState, int) {
+	tm.mu.Lock()
+	tabs := make([]tabState, 0, len(tm.tabs))
+	expandedNodes := make([]func() []string, 0, len(tm.tabs))
+	selectedTab := -1
+	for i, tab := range tm.tabs {
+		if tab.transient {
+			continue
+		}
+		if i == tm.selectedTab {
+			selectedTab = len(tabs)
+		}
+		tabs = append(tabs, tabState{
 			Title:             tab.title,
 			FilePath:          tab.filePath,
 			SchemaPath:        tab.schemaPath,
 			SchemaMessageName: tab.schemaMessageName,
+			RulesPath:         tab.rulesPath,
+			ViewMode:          tab.viewMode,
 		})
+		expandedNodes = append(expandedNodes, tab.expandedNodes)
 	}
+	tm.mu.Unlock()
 
-	fileDialogState := getFileDialogState()
-	state.FileDialogState.LastDirPath = fileDialogState.lastDirPath
-	state.FileDialogState.DialogWidth = fileDialogState.dialogSize.Width
-	state.FileDialogState.DialogHeight = fileDialogState.dialogSize.Height
+	// Деревья обходятся без mu: виджеты могут обращаться к вкладкам
+	if withExpandedNodes {
+		for i, expanded := range expandedNodes {
+			if expanded != nil {
+				tabs[i].ExpandedNodes = expanded()
+			}
+		}
+	}
+	return tabs, selectedTab
+}
 
-	return saveAppState(state)
+// pendingStateSaves учитывает сохранения состояния, запущенные saveTabStateInBackground
+var pendingStateSaves sync.WaitGroup
+
+// saveTabStateInBackground сохраняет состояние вкладок в горутине, чтобы запись файла не
+// задерживала интерфейс. Завершения сохранений можно дождаться через pendingStateSaves
+func saveTabStateInBackground(tm *tabManager) {
+	pendingStateSaves.Add(1)
+	go func() {
+		defer pendingStateSaves.Done()
+		saveTabState(tm)
+	}()
+}
+
+// saveTabState сохраняет вкладки в state.json. Если открыто рабочее пространство, сохраняется
+// только путь к нему, а вкладки вне рабочего пространства остаются прежними
+func saveTabState(tm *tabManager) error {
+	workspacePath := tm.getWorkspacePath()
+	tabs, selectedTab := collectTabStates(tm, false)
+
+	fileDialogState := getFileDialogState()
+	return updateAppState(func(state *appState) {
+		state.WorkspacePath = workspacePath
+		if workspacePath == "" {
+			state.Tabs = tabs
+			state.SelectedTab = selectedTab
+		}
+		state.FileDialogState.LastDirPath = fileDialogState.lastDirPath
+		state.FileDialogState.DialogWidth = fileDialogState.dialogSize.Width
+		state.FileDialogState.DialogHeight = fileDialogState.dialogSize.Height
+	})
 }
 
 func loadTabState(tm *tabManager, fyneApp fyne.App, window fyne.Window) error {
@@ -105,10 +203,6 @@ func loadTabState(tm *tabManager, fyneApp fyne.App, window fyne.Window) error {
 		return err
 	}
 
-	if len(state.Tabs) == 0 {
-		return nil
-	}
-
 	fileDialogState := getFileDialogState()
 	if state.FileDialogState.LastDirPath != "" {
 		fileDialogState.lastDirPath = state.FileDialogState.LastDirPath
@@ -117,37 +211,54 @@ func loadTabState(tm *tabManager, fyneApp fyne.App, window fyne.Window) error {
 		fileDialogState.dialogSize = fyne.NewSize(state.FileDialogState.DialogWidth, state.FileDialogState.DialogHeight)
 	}
 
-	for i, tabState := range state.Tabs {
-		content := protoViewWithFile(fyneApp, window, tm, tabState.FilePath, tabState.SchemaPath, tabState.SchemaMessageName)
-		tabIndex := len(tm.tabs)
-		tm.addTabWithPathWithoutSave(tabState.Title, content, tabState.FilePath)
+	if state.WorkspacePath != "" {
+		err := openWorkspace(tm, fyneApp, window, state.WorkspacePath)
+		if err == nil {
+			return nil
+		}
+		// Рабочее пространство удалено или повреждено: открываются вкладки вне него
+		log.Printf("Failed to reopen workspace %s: %v", state.WorkspacePath, err)
+	}
+	restoreTabs(tm, fyneApp, window, state.Tabs, state.SelectedTab)
+	return nil
+}
+
+// restoreTabs открывает вкладки по сохраненному состоянию и выбирает вкладку selectedTab
+func restoreTabs(tm *tabManager, fyneApp fyne.App, window fyne.Window, tabs []tabState, selectedTab int) {
+	for i, tabState := range tabs {
+		tab := addViewTab(tm, tabState.Title, tabState.FilePath, func() fyne.CanvasObject {
+			// Правила и режим отображения нужны уже при построении содержимого вкладки
+			tm.SetTabRules(tm.CurrentTab(), tabState.RulesPath)
+			tm.SetTabViewMode(tm.CurrentTab(), tabState.ViewMode)
+			return protoViewWithFile(fyneApp, window, tm, tabState.FilePath, tabState.SchemaPath, tabState.SchemaMessageName)
+		})
+		tabIndex := tm.tabCount() - 1
 		if tabState.SchemaPath != "" {
-			tm.tabs[tabIndex].schemaPath = tabState.SchemaPath
-			tm.tabs[tabIndex].schemaMessageName = tabState.SchemaMessageName
+			tm.updateTab(tab, func() {
+				tab.schemaPath = tabState.SchemaPath
+				tab.schemaMessageName = tabState.SchemaMessageName
+			})
 		}
-		if i == state.SelectedTab && state.SelectedTab >= 0 && state.SelectedTab < len(state.Tabs) {
+		var expandNodes func(paths []string)
+		tm.readTab(tab, func() { expandNodes = tab.expandNodes })
+		if len(tabState.ExpandedNodes) > 0 && expandNodes != nil {
+			expandNodes(tabState.ExpandedNodes)
+		}
+		if i == selectedTab && selectedTab >= 0 && selectedTab < len(tabs) {
 			tm.selectTabWithoutSave(tabIndex)
 		}
 	}
-
-	return nil
 }
 
 func saveFileDialogState() {
 	if globalDialogState == nil {
 		return
 	}
-	state, _ := loadAppState()
-	if state == nil {
-		state = &appState{
-			Tabs:        make([]tabState, 0),
-			SelectedTab: -1,
-		}
-	}
-	state.FileDialogState.LastDirPath = globalDialogState.lastDirPath
-	state.FileDialogState.DialogWidth = globalDialogState.dialogSize.Width
-	state.FileDialogState.DialogHeight = globalDialogState.dialogSize.Height
-	saveAppState(state)
+	updateAppState(func(state *appState) {
+		state.FileDialogState.LastDirPath = globalDialogState.lastDirPath
+		state.FileDialogState.DialogWidth = globalDialogState.dialogSize.Width
+		state.FileDialogState.DialogHeight = globalDialogState.dialogSize.Height
+	})
 }
 
 func loadFileDialogState() {
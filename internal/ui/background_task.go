@@ -0,0 +1,91 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"prospect/internal/protobuf"
+
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// backgroundWork выполняется в отдельной горутине. Она не трогает виджеты, а возвращает
+// функцию, которая применит результат к интерфейсу вкладки
+type backgroundWork func(ctx context.Context, progress protobuf.ProgressFunc) (apply func(), err error)
+
+// runInBackground выполняет work в горутине и показывает над содержимым вкладки заголовок,
+// прогресс и кнопку Cancel. Пока work не сообщила долю выполненного, прогресс бесконечный.
+// Результат применяется к вкладке tab, даже если пользователь переключился на другую; для
+// закрытой вкладки результат отбрасывается. Отмена и ошибка
+// возвращают прежнее содержимое, ошибка передается в onError.
+//
+// В Fyne 2.4 нет отдельной передачи вызовов в главный поток: виджеты можно обновлять из
+// горутин. Поэтому вся работа с интерфейсом собрана в apply и выполняется одним шагом
+// после завершения work
+func runInBackground(browserTabs *tabManager, tab *tabData, title string, work backgroundWork, onError func(error)) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	if browserTabs == nil || tab == nil {
+		go func() {
+			defer cancel()
+			finishBackgroundWork(ctx, title, work, nil, onError, func(fn func()) { fn() }, nil)
+		}()
+		return
+	}
+
+	previous := browserTabs.GetTabContent(tab)
+	infinite := widget.NewProgressBarInfinite()
+	bar := widget.NewProgressBar()
+	bar.Hide()
+	cancelButton := widget.NewButtonWithIcon("Cancel", theme.CancelIcon(), cancel)
+	header := container.NewVBox(
+		container.NewBorder(nil, nil, nil, cancelButton, widget.NewLabel(title)),
+		container.NewStack(infinite, bar),
+	)
+	browserTabs.UpdateTabContent(tab, container.NewPadded(container.NewBorder(header, nil, nil, nil, previous)))
+
+	progress := func(fraction float64) {
+		if infinite.Visible() {
+			infinite.Stop()
+			infinite.Hide()
+			bar.Show()
+		}
+		bar.SetValue(fraction)
+	}
+
+	go func() {
+		defer cancel()
+		inTab := func(fn func()) {
+			if !browserTabs.RunForTab(tab, fn) {
+				log.Printf("%s: tab was closed, result discarded", title)
+			}
+		}
+		restore := func() {
+			browserTabs.UpdateTabContent(tab, previous)
+		}
+		finishBackgroundWork(ctx, title, work, progress, onError, inTab, restore)
+	}()
+}
+
+// finishBackgroundWork выполняет work и применяет ее результат через inTab. restore
+// (если задан) возвращает содержимое вкладки при отмене или ошибке
+func finishBackgroundWork(ctx context.Context, title string, work backgroundWork, progress protobuf.ProgressFunc, onError func(error), inTab func(func()), restore func()) {
+	apply, err := work(ctx, progress)
+	inTab(func() {
+		if err != nil || ctx.Err() != nil {
+			if restore != nil {
+				restore()
+			}
+			if err != nil && !errors.Is(err, context.Canceled) {
+				onError(err)
+			} else {
+				log.Printf("%s: cancelled", title)
+			}
+			return
+		}
+		apply()
+	})
+}
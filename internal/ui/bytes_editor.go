@@ -0,0 +1,78 @@
+package ui
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"prospect/internal/protobuf"
+
+	"fyne.io/fyne/v2/widget"
+)
+
+// bytesFormatFor возвращает представление bytes поля; по умолчанию hex
+func (a *protoTreeAdapter) bytesFormatFor(node *protobuf.TreeNode) protobuf.BytesFormat {
+	if format, ok := a.bytesFormats[node]; ok {
+		return format
+	}
+	return protobuf.BytesHex
+}
+
+// nextBytesFormat возвращает следующее представление в порядке hex -> base64 -> escaped
+func nextBytesFormat(format protobuf.BytesFormat) protobuf.BytesFormat {
+	for i, f := range protobuf.BytesFormats {
+		if f == format {
+			return protobuf.BytesFormats[(i+1)%len(protobuf.BytesFormats)]
+		}
+	}
+	return protobuf.BytesFormats[0]
+}
+
+// setupBytesFormat показывает переключатель представления bytes значения и проверяет
+// ввод в выбранном представлении
+func (a *protoTreeAdapter) setupBytesFormat(uid widget.TreeNodeID, node *protobuf.TreeNode, editWidget *protoFieldEditor) {
+	format := a.bytesFormatFor(node)
+	editWidget.formatBtn.SetText(string(format))
+	editWidget.formatBtn.OnTapped = func() {
+		a.bytesFormats[node] = nextBytesFormat(format)
+		if a.treeWidget != nil {
+			a.treeWidget.RefreshItem(uid)
+		}
+	}
+	editWidget.entry.Validator = func(text string) error {
+		_, err := protobuf.ParseBytes(text, format)
+		return err
+	}
+	editWidget.SetFormatVisible(true)
+}
+
+// convertStringBytes переводит значение между string и bytes без потерь: оба типа
+// кодируются как length-delimited, меняется только представление значения
+func (a *protoTreeAdapter) convertStringBytes(uid widget.TreeNodeID, node *protobuf.TreeNode, newType string) {
+	if newType == "bytes" {
+		node.Value = []byte(fmt.Sprintf("%v", node.Value))
+	} else {
+		node.Value = string(protobuf.BytesValue(node.Value))
+	}
+	delete(a.escapedStrings, node)
+	node.Type = newType
+
+	if a.treeWidget != nil {
+		a.treeWidget.RefreshItem(uid)
+	}
+}
+
+// hasInvalidUTF8 сообщает, что строковое поле содержит байты, не являющиеся корректной UTF-8
+func hasInvalidUTF8(node *protobuf.TreeNode) bool {
+	value, ok := node.Value.(string)
+	return ok && node.Type == "string" && !utf8.ValidString(value)
+}
+
+// isEscapedString сообщает, что строка редактируется в экранированном виде. Режим включается
+// для строк с некорректной UTF-8 и сохраняется, пока дерево не перестроено, чтобы правка
+// одного байта не переключала поле ввода между текстом и escape-последовательностями
+func (a *protoTreeAdapter) isEscapedString(node *protobuf.TreeNode) bool {
+	if hasInvalidUTF8(node) {
+		a.escapedStrings[node] = true
+	}
+	return a.escapedStrings[node]
+}
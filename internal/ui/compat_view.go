@@ -0,0 +1,154 @@
+package ui
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"prospect/internal/protobuf"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// showCompatDialog запрашивает старую и новую версии схемы и открывает отчет о совместимости
+// в новой вкладке. Старой версией по умолчанию считается схема текущей вкладки. Если указан
+// каталог корпуса, каждый файл из него дополнительно проверяется новой версией схемы.
+func showCompatDialog(parentWindow fyne.Window, browserTabs *tabManager, parser *protobuf.Parser, oldSchemaPath string, schemaMessageName string) {
+	oldEntry := widget.NewEntry()
+	oldEntry.SetText(oldSchemaPath)
+	newEntry := widget.NewEntry()
+	corpusEntry := widget.NewEntry()
+	corpusEntry.SetPlaceHolder("optional")
+	messageEntry := widget.NewEntry()
+	messageEntry.SetText(schemaMessageName)
+	messageEntry.SetPlaceHolder("top-level message for the corpus")
+
+	form := widget.NewForm(
+		widget.NewFormItem("Old schema", newBrowseRow(parentWindow, oldEntry, true)),
+		widget.NewFormItem("New schema", newBrowseRow(parentWindow, newEntry, true)),
+		widget.NewFormItem("Corpus directory", newFolderBrowseRow(parentWindow, corpusEntry)),
+		widget.NewFormItem("Message", messageEntry),
+	)
+
+	confirmDialog := dialog.NewCustomConfirm("Schema compatibility", "Check", "Cancel", form, func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+		if oldEntry.Text == "" || newEntry.Text == "" {
+			dialog.ShowError(fmt.Errorf("both schema files are required"), parentWindow)
+			return
+		}
+
+		issues, err := parser.CheckSchemaCompatibility(oldEntry.Text, newEntry.Text)
+		if err != nil {
+			dialog.ShowError(err, parentWindow)
+			return
+		}
+
+		var corpus []protobuf.CorpusResult
+		if corpusEntry.Text != "" {
+			corpus, err = parser.ValidateCorpus(corpusEntry.Text, newEntry.Text, messageEntry.Text)
+			if err != nil {
+				dialog.ShowError(err, parentWindow)
+				return
+			}
+		}
+
+		if browserTabs != nil {
+			title := fmt.Sprintf("compat: %s → %s", filepath.Base(oldEntry.Text), filepath.Base(newEntry.Text))
+			browserTabs.AddTransientTab(title, container.NewPadded(newCompatView(issues, corpus)))
+		}
+	}, parentWindow)
+
+	confirmDialog.Resize(fyne.NewSize(600, 300))
+	confirmDialog.Show()
+}
+
+// newFolderBrowseRow возвращает поле ввода каталога с кнопкой выбора
+func newFolderBrowseRow(parentWindow fyne.Window, entry *widget.Entry) fyne.CanvasObject {
+	browseBtn := widget.NewButton("Browse...", func() {
+		dialog.ShowFolderOpen(func(dir fyne.ListableURI, err error) {
+			if err != nil {
+				dialog.ShowError(err, parentWindow)
+				return
+			}
+			if dir == nil {
+				return
+			}
+			entry.SetText(dir.Path())
+		}, parentWindow)
+	})
+	return container.NewBorder(nil, nil, nil, browseBtn, entry)
+}
+
+// compatCorpusIssues превращает файлы корпуса, не прошедшие проверку новой схемой, в строки
+// отчета: путь к файлу и ошибка чтения или сводка расхождений
+func compatCorpusIssues(corpus []protobuf.CorpusResult) []protobuf.CompatIssue {
+	var issues []protobuf.CompatIssue
+	for _, result := range corpus {
+		switch {
+		case result.Err != nil:
+			issues = append(issues, protobuf.CompatIssue{Severity: protobuf.CompatError, Path: result.File, Message: result.Err.Error()})
+		case result.Report.HasIssues():
+			issues = append(issues, protobuf.CompatIssue{Severity: protobuf.CompatError, Path: result.File, Message: result.Report.Summary()})
+		}
+	}
+	return issues
+}
+
+// compatSummary возвращает строку итога для отчета о совместимости
+func compatSummary(issues []protobuf.CompatIssue, corpus []protobuf.CorpusResult, failedFiles int) string {
+	errors := 0
+	for _, issue := range issues {
+		if issue.Severity == protobuf.CompatError {
+			errors++
+		}
+	}
+
+	summary := fmt.Sprintf("%d breaking change(s), %d warning(s)", errors, len(issues)-errors)
+	if len(issues) == 0 {
+		summary = "Schemas are wire compatible"
+	}
+	if len(corpus) > 0 {
+		summary += fmt.Sprintf("; %d of %d corpus file(s) failed", failedFiles, len(corpus))
+	}
+	return summary
+}
+
+// newCompatView показывает список изменений схемы и файлы корпуса, не прошедшие проверку;
+// ошибки совместимости выделены цветом
+func newCompatView(issues []protobuf.CompatIssue, corpus []protobuf.CorpusResult) fyne.CanvasObject {
+	corpusIssues := compatCorpusIssues(corpus)
+	summaryLabel := widget.NewLabel(compatSummary(issues, corpus, len(corpusIssues)))
+	issues = append(append([]protobuf.CompatIssue(nil), issues...), corpusIssues...)
+
+	list := widget.NewList(
+		func() int {
+			return len(issues)
+		},
+		func() fyne.CanvasObject {
+			background := canvas.NewRectangle(diffChangedColor)
+			return container.NewStack(background, container.NewGridWithColumns(2, widget.NewLabel(""), widget.NewLabel("")))
+		},
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			issue := issues[id]
+			stack := obj.(*fyne.Container)
+			background := stack.Objects[0].(*canvas.Rectangle)
+			if issue.Severity == protobuf.CompatError {
+				background.FillColor = diffRemovedColor
+			} else {
+				background.FillColor = diffChangedColor
+			}
+			background.Refresh()
+
+			row := stack.Objects[1].(*fyne.Container)
+			row.Objects[0].(*widget.Label).SetText(issue.Path)
+			row.Objects[1].(*widget.Label).SetText(issue.Message)
+		},
+	)
+
+	return container.NewBorder(summaryLabel, nil, nil, nil, list)
+}
@@ -0,0 +1,35 @@
+package ui
+
+import (
+	"errors"
+	"testing"
+
+	"prospect/internal/protobuf"
+)
+
+func TestCompatCorpusIssues_ListsFailedFiles(t *testing.T) {
+	corpus := []protobuf.CorpusResult{
+		{File: "corpus/ok.bin", Report: &protobuf.SchemaReport{}},
+		{File: "corpus/broken.bin", Err: errors.New("failed to decode")},
+		{File: "corpus/unknown.bin", Report: &protobuf.SchemaReport{Issues: []protobuf.SchemaIssue{{Kind: protobuf.SchemaIssueUnknownField}}}},
+	}
+
+	issues := compatCorpusIssues(corpus)
+	if len(issues) != 2 {
+		t.Fatalf("Expected 2 failed corpus files, got %d", len(issues))
+	}
+	if issues[0].Path != "corpus/broken.bin" || issues[0].Message != "failed to decode" || issues[0].Severity != protobuf.CompatError {
+		t.Errorf("Unexpected issue for unreadable file: %+v", issues[0])
+	}
+	if issues[1].Path != "corpus/unknown.bin" || issues[1].Message != corpus[2].Report.Summary() {
+		t.Errorf("Unexpected issue for file with schema mismatches: %+v", issues[1])
+	}
+
+	summary := compatSummary(nil, corpus, len(issues))
+	if summary != "Schemas are wire compatible; 2 of 3 corpus file(s) failed" {
+		t.Errorf("Unexpected summary: %q", summary)
+	}
+	if summary := compatSummary(nil, nil, 0); summary != "Schemas are wire compatible" {
+		t.Errorf("Expected no corpus part without a corpus, got %q", summary)
+	}
+}
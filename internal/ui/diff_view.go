@@ -0,0 +1,173 @@
+package ui
+
+import (
+	"fmt"
+	"image/color"
+
+	"prospect/internal/protobuf"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// diffByPosition - вариант сопоставления элементов repeated поля по позиции
+const diffByPosition = "by position"
+
+var (
+	diffAddedColor       = color.NRGBA{R: 46, G: 160, B: 67, A: 90}
+	diffRemovedColor     = color.NRGBA{R: 248, G: 81, B: 73, A: 90}
+	diffChangedColor     = color.NRGBA{R: 210, G: 153, B: 34, A: 90}
+	diffTypeChangedColor = color.NRGBA{R: 137, G: 87, B: 229, A: 90}
+)
+
+func diffKindColor(kind protobuf.DiffKind) color.Color {
+	switch kind {
+	case protobuf.DiffAdded:
+		return diffAddedColor
+	case protobuf.DiffRemoved:
+		return diffRemovedColor
+	case protobuf.DiffTypeChanged:
+		return diffTypeChangedColor
+	default:
+		return diffChangedColor
+	}
+}
+
+// diffSideText возвращает текст ячейки для левой (old) или правой (new) стороны
+func diffSideText(entry protobuf.DiffEntry, left bool) string {
+	value, fieldType := entry.NewValue, entry.NewType
+	if left {
+		value, fieldType = entry.OldValue, entry.OldType
+	}
+
+	if left && entry.Kind == protobuf.DiffAdded || !left && entry.Kind == protobuf.DiffRemoved {
+		return ""
+	}
+
+	text := fmt.Sprintf("%v", value)
+	if value == nil {
+		text = ""
+	}
+	if _, isMessage := value.(map[string]interface{}); isMessage {
+		text = "{...}"
+	}
+	if entry.Kind == protobuf.DiffTypeChanged {
+		return fmt.Sprintf("(%s) %s", fieldType, text)
+	}
+	return text
+}
+
+// newDiffView строит side-by-side представление результата сравнения двух деревьев
+// showDiffKeysDialog предлагает выбрать ключевые поля для repeated полей-сообщений и
+// передает выбор в onConfirm. Если таких полей нет, сравнение начинается сразу
+func showDiffKeysDialog(parentWindow fyne.Window, candidates []protobuf.RepeatedKeyCandidate, onConfirm func(opts *protobuf.DiffOptions)) {
+	if len(candidates) == 0 {
+		onConfirm(&protobuf.DiffOptions{})
+		return
+	}
+
+	form, selectedKeys := newDiffKeysForm(candidates)
+	content := container.NewBorder(
+		widget.NewLabel("Match elements of repeated fields by key:"), nil, nil, nil,
+		container.NewVScroll(form),
+	)
+	keysDialog := dialog.NewCustomConfirm("Compare", "Compare", "Cancel", content, func(confirmed bool) {
+		if confirmed {
+			onConfirm(&protobuf.DiffOptions{RepeatedKeys: selectedKeys()})
+		}
+	}, parentWindow)
+	keysDialog.Resize(fyne.NewSize(500, 400))
+	keysDialog.Show()
+}
+
+// newDiffKeysForm строит форму выбора ключа для каждого repeated поля и функцию, которая
+// возвращает выбранные ключи в виде DiffOptions.RepeatedKeys
+func newDiffKeysForm(candidates []protobuf.RepeatedKeyCandidate) (*widget.Form, func() map[string]int) {
+	form := widget.NewForm()
+	selects := make([]*widget.Select, len(candidates))
+	for i, candidate := range candidates {
+		options := []string{diffByPosition}
+		for _, field := range candidate.Fields {
+			options = append(options, diffKeyOption(field))
+		}
+		selects[i] = widget.NewSelect(options, nil)
+		selects[i].SetSelected(diffByPosition)
+		form.Append(candidate.Path, selects[i])
+	}
+
+	selectedKeys := func() map[string]int {
+		keys := make(map[string]int)
+		for i, candidate := range candidates {
+			for _, field := range candidate.Fields {
+				if selects[i].Selected == diffKeyOption(field) {
+					keys[candidate.Path] = field.FieldNum
+				}
+			}
+		}
+		return keys
+	}
+	return form, selectedKeys
+}
+
+func diffKeyOption(field protobuf.RepeatedKeyField) string {
+	return fmt.Sprintf("%s (%d)", field.Name, field.FieldNum)
+}
+
+func newDiffView(leftTitle, rightTitle string, entries []protobuf.DiffEntry) fyne.CanvasObject {
+	summary := protobuf.DiffSummary(entries)
+	summaryLabel := widget.NewLabel(fmt.Sprintf("%d added, %d removed, %d changed, %d type changed",
+		summary[protobuf.DiffAdded], summary[protobuf.DiffRemoved], summary[protobuf.DiffChanged], summary[protobuf.DiffTypeChanged]))
+	if len(entries) == 0 {
+		summaryLabel.SetText("Messages are identical")
+	}
+
+	headerPath := widget.NewLabel("Field")
+	headerPath.TextStyle = fyne.TextStyle{Bold: true}
+	headerLeft := widget.NewLabel(leftTitle)
+	headerLeft.TextStyle = fyne.TextStyle{Bold: true}
+	headerRight := widget.NewLabel(rightTitle)
+	headerRight.TextStyle = fyne.TextStyle{Bold: true}
+	header := container.NewGridWithColumns(3, headerPath, headerLeft, headerRight)
+
+	list := widget.NewList(
+		func() int {
+			return len(entries)
+		},
+		func() fyne.CanvasObject {
+			background := canvas.NewRectangle(diffChangedColor)
+			pathLabel := widget.NewLabel("")
+			pathLabel.Truncation = fyne.TextTruncateEllipsis
+			leftLabel := widget.NewLabel("")
+			leftLabel.Truncation = fyne.TextTruncateEllipsis
+			rightLabel := widget.NewLabel("")
+			rightLabel.Truncation = fyne.TextTruncateEllipsis
+			return container.NewStack(background, container.NewGridWithColumns(3, pathLabel, leftLabel, rightLabel))
+		},
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			if id < 0 || id >= len(entries) {
+				return
+			}
+			entry := entries[id]
+			stack := obj.(*fyne.Container)
+			background := stack.Objects[0].(*canvas.Rectangle)
+			columns := stack.Objects[1].(*fyne.Container)
+
+			background.FillColor = diffKindColor(entry.Kind)
+			background.Refresh()
+			columns.Objects[0].(*widget.Label).SetText(entry.Path)
+			columns.Objects[1].(*widget.Label).SetText(diffSideText(entry, true))
+			columns.Objects[2].(*widget.Label).SetText(diffSideText(entry, false))
+		},
+	)
+
+	return container.NewBorder(
+		container.NewVBox(summaryLabel, header),
+		nil,
+		nil,
+		nil,
+		list,
+	)
+}
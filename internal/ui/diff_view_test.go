@@ -0,0 +1,29 @@
+package ui
+
+import (
+	"testing"
+
+	"prospect/internal/protobuf"
+
+	"fyne.io/fyne/v2/test"
+)
+
+func TestNewDiffKeysForm_ReturnsSelectedKeys(t *testing.T) {
+	fyneApp := test.NewApp()
+	defer fyneApp.Quit()
+
+	candidates := []protobuf.RepeatedKeyCandidate{
+		{Path: "orders.items", Fields: []protobuf.RepeatedKeyField{{FieldNum: 1, Name: "id"}, {FieldNum: 2, Name: "qty"}}},
+		{Path: "tags", Fields: []protobuf.RepeatedKeyField{{FieldNum: 1, Name: "name"}}},
+	}
+	form, selectedKeys := newDiffKeysForm(candidates)
+	if keys := selectedKeys(); len(keys) != 0 {
+		t.Errorf("Expected positional matching by default, got %v", keys)
+	}
+
+	form.Items[0].Widget.(interface{ SetSelected(string) }).SetSelected("id (1)")
+	keys := selectedKeys()
+	if len(keys) != 1 || keys["orders.items"] != 1 {
+		t.Errorf("Expected orders.items to be matched by field 1, got %v", keys)
+	}
+}
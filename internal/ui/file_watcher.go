@@ -0,0 +1,77 @@
+package ui
+
+import (
+	"log"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// fileChangeDelay - пауза после последнего события, после которой файл считается записанным.
+// Программы часто пишут файл в несколько приемов или подменяют его переименованием
+const fileChangeDelay = 300 * time.Millisecond
+
+// fileWatcher следит за одним файлом. Отслеживается каталог файла, поэтому замена файла
+// переименованием временного тоже замечается
+type fileWatcher struct {
+	watcher  *fsnotify.Watcher
+	path     string
+	onChange func()
+}
+
+// newFileWatcher начинает следить за path. onChange вызывается из отдельной горутины
+// один раз на серию событий
+func newFileWatcher(path string, onChange func()) (*fileWatcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	path = filepath.Clean(path)
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	w := &fileWatcher{watcher: watcher, path: path, onChange: onChange}
+	go w.run()
+	return w, nil
+}
+
+func (w *fileWatcher) run() {
+	var timer *time.Timer
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Clean(event.Name) != w.path || event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+				continue
+			}
+			if timer == nil {
+				timer = time.AfterFunc(fileChangeDelay, w.onChange)
+			} else {
+				timer.Reset(fileChangeDelay)
+			}
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("File watcher error for %s: %v", w.path, err)
+		}
+	}
+}
+
+// Close прекращает слежение
+func (w *fileWatcher) Close() {
+	if err := w.watcher.Close(); err != nil {
+		log.Printf("Failed to close file watcher for %s: %v", w.path, err)
+	}
+}
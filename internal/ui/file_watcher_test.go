@@ -0,0 +1,56 @@
+package ui
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileWatcher_ReportsWritesAndReplacements(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "message.bin")
+	if err := os.WriteFile(path, []byte{0x08, 0x01}, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	changes := make(chan struct{}, 10)
+	watcher, err := newFileWatcher(path, func() { changes <- struct{}{} })
+	if err != nil {
+		t.Fatalf("newFileWatcher failed: %v", err)
+	}
+	defer watcher.Close()
+
+	waitChange := func(what string) {
+		select {
+		case <-changes:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Expected change notification after %s", what)
+		}
+	}
+
+	// Несколько записей подряд дают одно уведомление
+	for i := 0; i < 3; i++ {
+		if err := os.WriteFile(path, []byte{0x08, byte(i)}, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitChange("write")
+	select {
+	case <-changes:
+		t.Error("Expected a burst of writes to be reported once")
+	case <-time.After(2 * fileChangeDelay):
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "other.bin"), []byte{0x08, 0x02}, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	temp := filepath.Join(dir, "message.tmp")
+	if err := os.WriteFile(temp, []byte{0x08, 0x03}, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(temp, path); err != nil {
+		t.Fatal(err)
+	}
+	waitChange("replacement")
+}
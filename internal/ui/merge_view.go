@@ -0,0 +1,256 @@
+package ui
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"prospect/internal/protobuf"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// showMergeDialog запрашивает base и две измененные версии бинарного файла и открывает
+// результат трехстороннего слияния в новой вкладке
+func showMergeDialog(parentWindow fyne.Window, browserTabs *tabManager, parser *protobuf.Parser, oursPath string, schemaPath string, schemaMessageName string) {
+	baseEntry := widget.NewEntry()
+	oursEntry := widget.NewEntry()
+	oursEntry.SetText(oursPath)
+	theirsEntry := widget.NewEntry()
+
+	form := widget.NewForm(
+		widget.NewFormItem("Base", newBrowseRow(parentWindow, baseEntry, false)),
+		widget.NewFormItem("Ours", newBrowseRow(parentWindow, oursEntry, false)),
+		widget.NewFormItem("Theirs", newBrowseRow(parentWindow, theirsEntry, false)),
+	)
+
+	confirmDialog := dialog.NewCustomConfirm("Three-way merge", "Merge", "Cancel", form, func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+
+		paths := []string{baseEntry.Text, oursEntry.Text, theirsEntry.Text}
+		trees := make([]*protobuf.TreeNode, 0, len(paths))
+		for _, path := range paths {
+			if path == "" {
+				dialog.ShowError(fmt.Errorf("all three files are required"), parentWindow)
+				return
+			}
+			tree, err := loadTreeFromFile(parser, path, schemaPath, schemaMessageName)
+			if err != nil {
+				dialog.ShowError(err, parentWindow)
+				return
+			}
+			trees = append(trees, tree)
+		}
+
+		result := protobuf.MergeTrees(trees[0], trees[1], trees[2])
+		log.Printf("Merged %s: %d conflict(s)", filepath.Base(oursEntry.Text), len(result.Conflicts))
+
+		if browserTabs != nil {
+			title := fmt.Sprintf("merge: %s", filepath.Base(oursEntry.Text))
+			browserTabs.AddTransientTab(title, container.NewPadded(newMergeView(parentWindow, parser, result)))
+		}
+	}, parentWindow)
+
+	confirmDialog.Resize(fyne.NewSize(600, 250))
+	confirmDialog.Show()
+}
+
+// newBrowseRow возвращает поле ввода пути с кнопкой выбора файла. schema выбирает,
+// какой из запомненных каталогов (схем или бинарных файлов) открывать в диалоге.
+func newBrowseRow(parentWindow fyne.Window, entry *widget.Entry, schema bool) fyne.CanvasObject {
+	dialogState := getFileDialogState()
+
+	browseBtn := widget.NewButton("Browse...", func() {
+		fileDialog := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+			if err != nil {
+				dialog.ShowError(err, parentWindow)
+				return
+			}
+			if reader == nil {
+				return
+			}
+			defer reader.Close()
+			if schema {
+				dialogState.setLastSchemaDir(reader.URI())
+			} else {
+				dialogState.setLastOpenDir(reader.URI())
+			}
+			entry.SetText(reader.URI().Path())
+		}, parentWindow)
+
+		lastDir := dialogState.getLastOpenDir()
+		if schema {
+			lastDir = dialogState.getLastSchemaDir()
+		}
+		if lastDir != nil {
+			fileDialog.SetLocation(lastDir)
+		}
+		fileDialog.Resize(dialogState.getDialogSize())
+		fileDialog.Show()
+	})
+	return container.NewBorder(nil, nil, nil, browseBtn, entry)
+}
+
+// newMergeView показывает конфликты слияния с выбором версии и результирующее дерево
+func newMergeView(parentWindow fyne.Window, parser *protobuf.Parser, result *protobuf.MergeResult) fyne.CanvasObject {
+	statusLabel := widget.NewLabel("")
+	updateStatus := func() {
+		if len(result.Conflicts) == 0 {
+			statusLabel.SetText("Merged without conflicts")
+			return
+		}
+		statusLabel.SetText(fmt.Sprintf("%d conflict(s), %d unresolved", len(result.Conflicts), result.UnresolvedCount()))
+	}
+	updateStatus()
+
+	mergedTree := createProtoTree(result.Tree)
+
+	conflictRows := container.NewVBox()
+	for i, conflict := range result.Conflicts {
+		conflictIndex := i
+
+		pathLabel := widget.NewLabel(conflict.Path)
+		pathLabel.TextStyle = fyne.TextStyle{Bold: true}
+
+		sides := []string{
+			fmt.Sprintf("base: %s", mergeNodesText(conflict.Base)),
+			fmt.Sprintf("ours: %s", mergeNodesText(conflict.Ours)),
+			fmt.Sprintf("theirs: %s", mergeNodesText(conflict.Theirs)),
+		}
+		choices := map[string]protobuf.MergeSide{
+			sides[0]: protobuf.MergeBase,
+			sides[1]: protobuf.MergeOurs,
+			sides[2]: protobuf.MergeTheirs,
+		}
+
+		radio := widget.NewRadioGroup(sides, func(selected string) {
+			side, ok := choices[selected]
+			if !ok {
+				return
+			}
+			if err := result.Resolve(conflictIndex, side); err != nil {
+				dialog.ShowError(err, parentWindow)
+				return
+			}
+			updateStatus()
+			mergedTree.Refresh()
+		})
+
+		conflictRows.Add(container.NewVBox(pathLabel, radio, widget.NewSeparator()))
+	}
+
+	saveBtn := widget.NewButton("Save merged...", func() {
+		save := func() {
+			saveTreeAsBinary(result.Tree, parser, parentWindow)
+		}
+		if unresolved := result.UnresolvedCount(); unresolved > 0 {
+			dialog.ShowConfirm("Unresolved conflicts",
+				fmt.Sprintf("%d conflict(s) are not resolved, 'ours' values will be saved for them. Continue?", unresolved),
+				func(confirmed bool) {
+					if confirmed {
+						save()
+					}
+				}, parentWindow)
+			return
+		}
+		save()
+	})
+	saveBtn.Importance = widget.HighImportance
+
+	split := container.NewHSplit(container.NewVScroll(conflictRows), container.NewScroll(mergedTree))
+	split.Offset = 0.4
+
+	return container.NewBorder(statusLabel, container.NewHBox(saveBtn), nil, nil, split)
+}
+
+func mergeNodesText(nodes []*protobuf.TreeNode) string {
+	if len(nodes) == 0 {
+		return "(absent)"
+	}
+
+	values := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		if node.IsMessage() {
+			jsonObj, err := protobuf.TreeNodeToJSON(node)
+			if err == nil {
+				if jsonBytes, err := json.Marshal(jsonObj); err == nil {
+					values = append(values, string(jsonBytes))
+					continue
+				}
+			}
+			values = append(values, "{...}")
+			continue
+		}
+		values = append(values, fmt.Sprintf("%v", node.Value))
+	}
+	return strings.Join(values, ", ")
+}
+
+// loadTreeFromFile декодирует бинарный файл и применяет схему, если она указана
+func loadTreeFromFile(parser *protobuf.Parser, path string, schemaPath string, schemaMessageName string) (*protobuf.TreeNode, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	tree, err := parser.ParseRaw(data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing error in %s: %w", filepath.Base(path), err)
+	}
+
+	if schemaPath != "" {
+		tree, _, err = parser.ApplySchemaWithMessage(tree, schemaPath, schemaMessageName)
+		if err != nil {
+			return nil, fmt.Errorf("error applying schema to %s: %w", filepath.Base(path), err)
+		}
+	}
+	return tree, nil
+}
+
+// saveTreeAsBinary сериализует дерево через Serializer и сохраняет в выбранный файл
+func saveTreeAsBinary(tree *protobuf.TreeNode, parser *protobuf.Parser, parentWindow fyne.Window) {
+	dialogState := getFileDialogState()
+
+	saveDialog := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, parentWindow)
+			return
+		}
+		if writer == nil {
+			return
+		}
+		defer writer.Close()
+
+		dialogState.setLastSaveDir(writer.URI())
+
+		serializer := protobuf.NewSerializer(parser.GetProtocPath())
+		binaryData, err := serializer.SerializeRaw(tree)
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("serialization error: %w", err), parentWindow)
+			return
+		}
+
+		if _, err := writer.Write(binaryData); err != nil {
+			dialog.ShowError(fmt.Errorf("write error: %w", err), parentWindow)
+			return
+		}
+
+		dialog.ShowInformation("Success", "Proto file saved", parentWindow)
+		log.Printf("Proto file saved: %s", writer.URI().Path())
+	}, parentWindow)
+
+	if lastDir := dialogState.getLastSaveDir(); lastDir != nil {
+		saveDialog.SetLocation(lastDir)
+	}
+
+	saveDialog.Resize(dialogState.getDialogSize())
+	saveDialog.Show()
+}
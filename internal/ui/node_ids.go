@@ -0,0 +1,115 @@
+package ui
+
+import (
+	"strconv"
+	"strings"
+
+	"prospect/internal/protobuf"
+
+	"fyne.io/fyne/v2/widget"
+)
+
+// stableUIDPrefix отличает стабильные UID от позиционных ("0:2:p1"). Стабильный UID
+// назначается узлу при первом показе и не меняется при вставке и удалении соседей, поэтому
+// дерево сохраняет раскрытые ветки, а поиск узла по UID не обходит дерево от корня.
+// Позиционные UID по-прежнему принимаются getNodeByUID, например из таблицы
+const stableUIDPrefix = "#"
+
+// nodeRef - узел со стабильным UID и его положение в дереве на момент последнего показа
+type nodeRef struct {
+	node        *protobuf.TreeNode
+	parent      *protobuf.TreeNode
+	placeholder bool
+	children    []widget.TreeNodeID
+}
+
+func isStableUID(uid widget.TreeNodeID) bool {
+	return strings.HasPrefix(uid, stableUIDPrefix)
+}
+
+// isPlaceholder сообщает, что UID указывает на плейсхолдер отсутствующего поля
+func (a *protoTreeAdapter) isPlaceholder(uid widget.TreeNodeID) bool {
+	if isStableUID(uid) {
+		ref, ok := a.nodesByID[uid]
+		return ok && ref.placeholder
+	}
+	return isPlaceholderUID(uid)
+}
+
+// childNodeIDs возвращает стабильные UID дочерних узлов и забывает UID узлов, которых больше
+// нет среди детей, вместе с их поддеревьями, чтобы таблицы не росли при перестройке дерева
+func (a *protoTreeAdapter) childNodeIDs(uid widget.TreeNodeID, node *protobuf.TreeNode, children []*protobuf.TreeNode) []widget.TreeNodeID {
+	ids := make([]widget.TreeNodeID, 0, len(children))
+	current := make(map[widget.TreeNodeID]bool, len(children))
+	for i, child := range children {
+		id := a.stableID(child, node, i >= len(node.Children))
+		ids = append(ids, id)
+		current[id] = true
+	}
+
+	ref, ok := a.nodesByID[uid]
+	if !ok && uid == "root" {
+		ref = &nodeRef{node: node}
+		a.nodesByID[uid] = ref
+	}
+	if ref != nil {
+		for _, old := range ref.children {
+			if !current[old] {
+				a.forgetNodeID(old)
+			}
+		}
+		ref.children = ids
+	}
+	return ids
+}
+
+func (a *protoTreeAdapter) stableID(node *protobuf.TreeNode, parent *protobuf.TreeNode, placeholder bool) widget.TreeNodeID {
+	if id, ok := a.nodeIDs[node]; ok {
+		ref := a.nodesByID[id]
+		ref.parent = parent
+		ref.placeholder = placeholder
+		return id
+	}
+
+	a.nextNodeID++
+	id := stableUIDPrefix + strconv.Itoa(a.nextNodeID)
+	a.nodeIDs[node] = id
+	a.nodesByID[id] = &nodeRef{node: node, parent: parent, placeholder: placeholder}
+	return id
+}
+
+func (a *protoTreeAdapter) forgetNodeID(id widget.TreeNodeID) {
+	ref, ok := a.nodesByID[id]
+	if !ok {
+		return
+	}
+	for _, child := range ref.children {
+		a.forgetNodeID(child)
+	}
+	delete(a.nodesByID, id)
+	delete(a.nodeIDs, ref.node)
+	delete(a.editWidgets, id)
+}
+
+// placeholderPosition возвращает сообщение плейсхолдера и его индекс в Placeholders
+func (a *protoTreeAdapter) placeholderPosition(uid widget.TreeNodeID) (*protobuf.TreeNode, int) {
+	if isStableUID(uid) {
+		ref, ok := a.nodesByID[uid]
+		if !ok || !ref.placeholder || ref.parent == nil {
+			return nil, -1
+		}
+		for i, placeholder := range ref.parent.Placeholders {
+			if placeholder == ref.node {
+				return ref.parent, i
+			}
+		}
+		return nil, -1
+	}
+
+	parts := splitUID(uid)
+	if len(parts) == 0 {
+		return nil, -1
+	}
+	parent := a.getNodeByUID(strings.Join(parts[:len(parts)-1], ":"))
+	return parent, parseInt(strings.TrimPrefix(parts[len(parts)-1], placeholderUIDPrefix))
+}
@@ -0,0 +1,91 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+)
+
+// OpenRequest - файлы, которые нужно открыть в главном окне: из командной строки, от
+// следующего запуска приложения или перетащенные в окно
+type OpenRequest struct {
+	Files       []string
+	SchemaPath  string
+	MessageName string
+}
+
+// isSchemaFile сообщает, что файл открывается как схема, а не как бинарное сообщение
+func isSchemaFile(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".proto")
+}
+
+// openFiles открывает бинарные файлы запроса в новых вкладках (уже открытый файл просто
+// выбирается) и применяет к ним схему запроса. Файлы .proto применяются как схема к текущей
+// вкладке, то есть к последнему открытому файлу. Рабочее пространство заменяет вкладки окна,
+// поэтому открывается раньше остальных файлов
+func openFiles(browserTabs *tabManager, fyneApp fyne.App, window fyne.Window, request OpenRequest) {
+	files := make([]string, 0, len(request.Files))
+	for _, path := range request.Files {
+		if !isWorkspaceFile(path) {
+			files = append(files, path)
+			continue
+		}
+		if browserTabs.hasModifiedTabs() {
+			dialog.ShowInformation("Information", fmt.Sprintf("Save or close modified tabs before opening %s", filepath.Base(path)), window)
+			continue
+		}
+		if err := openWorkspace(browserTabs, fyneApp, window, path); err != nil {
+			dialog.ShowError(fmt.Errorf("failed to open workspace: %w", err), window)
+		}
+	}
+
+	schemas := make([]string, 0)
+	for _, path := range files {
+		if isSchemaFile(path) {
+			schemas = append(schemas, path)
+			continue
+		}
+		if _, err := os.Stat(path); err != nil {
+			dialog.ShowError(fmt.Errorf("cannot open %s: %w", filepath.Base(path), err), window)
+			continue
+		}
+
+		rememberFile(browserTabs, path)
+		if index := browserTabs.findTabByFile(path); index >= 0 {
+			browserTabs.selectTabWithoutSave(index)
+		} else {
+			addViewTab(browserTabs, filepath.Base(path), path, func() fyne.CanvasObject {
+				return protoViewWithFile(fyneApp, window, browserTabs, path, "", "")
+			})
+		}
+		if request.SchemaPath != "" {
+			browserTabs.ApplySchemaToCurrentTab(request.SchemaPath, request.MessageName)
+		}
+	}
+
+	if len(files) == 0 && request.SchemaPath != "" {
+		schemas = append(schemas, request.SchemaPath)
+	}
+	for _, schemaPath := range schemas {
+		if !browserTabs.ApplySchemaToCurrentTab(schemaPath, request.MessageName) {
+			dialog.ShowInformation("Information", fmt.Sprintf("Open a binary file before applying %s", filepath.Base(schemaPath)), window)
+		}
+	}
+
+	saveTabStateInBackground(browserTabs)
+}
+
+// droppedFiles возвращает локальные пути перетащенных в окно элементов
+func droppedFiles(uris []fyne.URI) []string {
+	files := make([]string, 0, len(uris))
+	for _, uri := range uris {
+		if uri.Scheme() == "file" {
+			files = append(files, uri.Path())
+		}
+	}
+	return files
+}
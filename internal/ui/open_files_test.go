@@ -0,0 +1,53 @@
+package ui
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/test"
+)
+
+func TestOpenFiles_OpensBinariesInTabsOnce(t *testing.T) {
+	// Состояние вкладок сохраняется в фоне; оно не должно попасть в настройки пользователя
+	useTempConfigDir(t)
+
+	fyneApp := test.NewApp()
+	defer fyneApp.Quit()
+	window := fyneApp.NewWindow("test")
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "message.bin")
+	if err := os.WriteFile(path, []byte{0x08, 0x01}, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	browserTabs := newTabManager()
+	openFiles(browserTabs, fyneApp, window, OpenRequest{Files: []string{path, filepath.Join(dir, "missing.bin")}})
+	if len(browserTabs.tabs) != 1 {
+		t.Fatalf("Expected one tab for the existing file, got %d", len(browserTabs.tabs))
+	}
+	tab := browserTabs.tabs[0]
+	if tab.title != "message.bin" || tab.filePath != path {
+		t.Errorf("Expected tab for %s, got %q %q", path, tab.title, tab.filePath)
+	}
+	if tab.schemaHandler == nil || tab.toolbarCallbacks == nil {
+		t.Error("Expected the view to register its callbacks on the new tab")
+	}
+
+	browserTabs.addTabWithoutSave("other", container.NewStack())
+	openFiles(browserTabs, fyneApp, window, OpenRequest{Files: []string{path}})
+	if len(browserTabs.tabs) != 2 || browserTabs.CurrentTab() != tab {
+		t.Error("Expected an already open file to be selected instead of opened again")
+	}
+
+	if files := droppedFiles([]fyne.URI{storage.NewFileURI(path)}); len(files) != 1 || files[0] != path {
+		t.Errorf("Expected dropped file path, got %v", files)
+	}
+	if !isSchemaFile("/tmp/a.PROTO") || isSchemaFile(path) {
+		t.Error("Expected only .proto files to be treated as schemas")
+	}
+}
@@ -2,6 +2,7 @@ package ui
 
 import (
 	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 )
 
@@ -20,10 +21,16 @@ type protoFieldEditor struct {
 	adapter        *protoTreeAdapter
 	availableTypes []string
 	showEntry      bool
+	materializeBtn *widget.Button
+	// showMaterialize включается для плейсхолдеров полей, отсутствующих в данных
+	showMaterialize bool
+	// formatBtn переключает представление значения bytes поля
+	formatBtn  *widget.Button
+	showFormat bool
 }
 
 func newProtoFieldEditor(uid widget.TreeNodeID, adapter *protoTreeAdapter, messageTypes []string) *protoFieldEditor {
-	availableTypes := []string{"string", "int32", "int64", "uint32", "uint64", "sint32", "sint64", "bool", "float", "double"}
+	availableTypes := []string{"string", "bytes", "int32", "int64", "uint32", "uint64", "sint32", "sint64", "bool", "float", "double"}
 	availableTypes = append(availableTypes, messageTypes...)
 	nameLabel := widget.NewLabel("")
 	nameLabel.Wrapping = fyne.TextTruncate
@@ -35,6 +42,8 @@ func newProtoFieldEditor(uid widget.TreeNodeID, adapter *protoTreeAdapter, messa
 		entry:          widget.NewEntry(),
 		availableTypes: availableTypes,
 		showEntry:      true,
+		materializeBtn: widget.NewButtonWithIcon("", theme.ContentAddIcon(), nil),
+		formatBtn:      widget.NewButton("", nil),
 	}
 	ew.entry.OnChanged = func(value string) {
 		adapter.updateNodeValue(uid, value, "")
@@ -50,20 +59,38 @@ func (ew *protoFieldEditor) SetEntryVisible(visible bool) {
 	}
 }
 
+func (ew *protoFieldEditor) SetMaterializeVisible(visible bool) {
+	if ew.showMaterialize != visible {
+		ew.showMaterialize = visible
+		ew.Refresh()
+	}
+}
+
+func (ew *protoFieldEditor) SetFormatVisible(visible bool) {
+	if ew.showFormat != visible {
+		ew.showFormat = visible
+		ew.Refresh()
+	}
+}
+
 func (ew *protoFieldEditor) CreateRenderer() fyne.WidgetRenderer {
 	return &protoFieldEditorRenderer{
-		widget:    ew,
-		nameLabel: ew.nameLabel,
-		typeCombo: ew.typeCombo,
-		entry:     ew.entry,
+		widget:         ew,
+		nameLabel:      ew.nameLabel,
+		typeCombo:      ew.typeCombo,
+		entry:          ew.entry,
+		materializeBtn: ew.materializeBtn,
+		formatBtn:      ew.formatBtn,
 	}
 }
 
 type protoFieldEditorRenderer struct {
-	widget    *protoFieldEditor
-	nameLabel *widget.Label
-	typeCombo *widget.Select
-	entry     *widget.Entry
+	widget         *protoFieldEditor
+	nameLabel      *widget.Label
+	typeCombo      *widget.Select
+	entry          *widget.Entry
+	materializeBtn *widget.Button
+	formatBtn      *widget.Button
 }
 
 func (r *protoFieldEditorRenderer) Layout(size fyne.Size) {
@@ -75,9 +102,26 @@ func (r *protoFieldEditorRenderer) Layout(size fyne.Size) {
 	r.typeCombo.Move(typePos)
 	r.typeCombo.Resize(fyne.NewSize(float32(typeColumnWidth), r.typeCombo.MinSize().Height))
 
+	rightEdge := size.Width
+	if r.widget.showMaterialize {
+		btnSize := r.materializeBtn.MinSize()
+		rightEdge -= btnSize.Width
+		r.materializeBtn.Move(fyne.NewPos(rightEdge, (size.Height-btnSize.Height)/2))
+		r.materializeBtn.Resize(btnSize)
+		rightEdge -= float32(columnSpacing)
+	}
+
+	if r.widget.showFormat {
+		btnSize := r.formatBtn.MinSize()
+		rightEdge -= btnSize.Width
+		r.formatBtn.Move(fyne.NewPos(rightEdge, (size.Height-btnSize.Height)/2))
+		r.formatBtn.Resize(btnSize)
+		rightEdge -= float32(columnSpacing)
+	}
+
 	if r.widget.showEntry {
 	entryX := float32(nameColumnWidth + typeColumnWidth + columnSpacing*2)
-	entryWidth := size.Width - entryX
+	entryWidth := rightEdge - entryX
 	entryPos := fyne.NewPos(entryX, (size.Height-r.entry.MinSize().Height)/2)
 	r.entry.Move(entryPos)
 	r.entry.Resize(fyne.NewSize(entryWidth, r.entry.MinSize().Height))
@@ -97,6 +141,18 @@ func (r *protoFieldEditorRenderer) MinSize() fyne.Size {
 		height = fyne.Max(height, entrySize.Height)
 	}
 
+	if r.widget.showMaterialize {
+		btnSize := r.materializeBtn.MinSize()
+		width += btnSize.Width + columnSpacing
+		height = fyne.Max(height, btnSize.Height)
+	}
+
+	if r.widget.showFormat {
+		btnSize := r.formatBtn.MinSize()
+		width += btnSize.Width + columnSpacing
+		height = fyne.Max(height, btnSize.Height)
+	}
+
 	return fyne.NewSize(width, height)
 }
 
@@ -104,6 +160,8 @@ func (r *protoFieldEditorRenderer) Refresh() {
 	r.nameLabel.Refresh()
 	r.typeCombo.Refresh()
 	r.entry.Refresh()
+	r.materializeBtn.Refresh()
+	r.formatBtn.Refresh()
 }
 
 func (r *protoFieldEditorRenderer) Objects() []fyne.CanvasObject {
@@ -111,6 +169,12 @@ func (r *protoFieldEditorRenderer) Objects() []fyne.CanvasObject {
 	if r.widget.showEntry {
 		objects = append(objects, r.entry)
 	}
+	if r.widget.showMaterialize {
+		objects = append(objects, r.materializeBtn)
+	}
+	if r.widget.showFormat {
+		objects = append(objects, r.formatBtn)
+	}
 	return objects
 }
 
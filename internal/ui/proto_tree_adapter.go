@@ -21,13 +21,28 @@ type protoTreeAdapter struct {
 	editWidgets map[widget.TreeNodeID]*protoFieldEditor
 	window      fyne.Window
 	treeWidget  *widget.Tree
+	report      *protobuf.SchemaReport
+	// bytesFormats хранит выбранное пользователем представление bytes полей
+	bytesFormats map[*protobuf.TreeNode]protobuf.BytesFormat
+	// escapedStrings отмечает строки, которые редактируются в экранированном виде
+	escapedStrings map[*protobuf.TreeNode]bool
+	// nodeIDs и nodesByID задают стабильные UID узлов (см. node_ids.go)
+	nodeIDs    map[*protobuf.TreeNode]widget.TreeNodeID
+	nodesByID  map[widget.TreeNodeID]*nodeRef
+	nextNodeID int
+	// onChange вызывается после каждой правки дерева пользователем
+	onChange func()
 }
 
 func newProtoTreeAdapter(tree *protobuf.TreeNode) *protoTreeAdapter {
 	return &protoTreeAdapter{
-		tree:        tree,
-		editWidgets: make(map[widget.TreeNodeID]*protoFieldEditor),
-		window:      nil,
+		tree:           tree,
+		editWidgets:    make(map[widget.TreeNodeID]*protoFieldEditor),
+		nodeIDs:        make(map[*protobuf.TreeNode]widget.TreeNodeID),
+		nodesByID:      make(map[widget.TreeNodeID]*nodeRef),
+		window:         nil,
+		bytesFormats:   make(map[*protobuf.TreeNode]protobuf.BytesFormat),
+		escapedStrings: make(map[*protobuf.TreeNode]bool),
 	}
 }
 
@@ -39,6 +54,23 @@ func (a *protoTreeAdapter) SetTreeWidget(treeWidget *widget.Tree) {
 	a.treeWidget = treeWidget
 }
 
+// SetChangeCallback задает функцию, которая вызывается после каждой правки дерева
+func (a *protoTreeAdapter) SetChangeCallback(onChange func()) {
+	a.onChange = onChange
+}
+
+func (a *protoTreeAdapter) notifyChange() {
+	if a.onChange != nil {
+		a.onChange()
+	}
+}
+
+// SetSchemaReport задает отчет о применении схемы, по которому узлы с расхождениями
+// помечаются значком
+func (a *protoTreeAdapter) SetSchemaReport(report *protobuf.SchemaReport) {
+	a.report = report
+}
+
 func (a *protoTreeAdapter) ChildUIDs(uid widget.TreeNodeID) []widget.TreeNodeID {
 	actualUID := uid
 	if uid == "" {
@@ -49,18 +81,15 @@ func (a *protoTreeAdapter) ChildUIDs(uid widget.TreeNodeID) []widget.TreeNodeID
 	if node == nil {
 		return nil
 	}
+	// Вложенные сообщения ленивого дерева разбираются при первом раскрытии
+	node.Expand()
 
-	children := make([]widget.TreeNodeID, 0, len(node.Children))
-	for i := range node.Children {
-		if actualUID == "root" {
-			childUID := fmt.Sprintf("%d", i)
-			children = append(children, childUID)
-		} else {
-			childUID := fmt.Sprintf("%s:%d", uid, i)
-			children = append(children, childUID)
-		}
+	children := make([]*protobuf.TreeNode, 0, len(node.Children)+len(node.Placeholders))
+	children = append(children, node.Children...)
+	if !a.isPlaceholder(actualUID) {
+		children = append(children, node.Placeholders...)
 	}
-	return children
+	return a.childNodeIDs(actualUID, node, children)
 }
 
 func (a *protoTreeAdapter) IsBranch(uid widget.TreeNodeID) bool {
@@ -69,11 +98,16 @@ func (a *protoTreeAdapter) IsBranch(uid widget.TreeNodeID) bool {
 		actualUID = "root"
 	}
 
+	// Плейсхолдеры отображаются одной строкой, их поля появляются после добавления в сообщение
+	if a.isPlaceholder(actualUID) {
+		return false
+	}
+
 	node := a.getNodeByUID(actualUID)
 	if node == nil {
 		return false
 	}
-	return a.isMessageType(node.Type) || len(node.Children) > 0
+	return a.isMessageType(node.Type) || len(node.Children) > 0 || node.IsLazy()
 }
 
 func (a *protoTreeAdapter) CreateNode(branch bool) fyne.CanvasObject {
@@ -115,7 +149,29 @@ func (a *protoTreeAdapter) UpdateNode(uid widget.TreeNodeID, branch bool, obj fy
 		if nameText == "" {
 			nameText = fmt.Sprintf("field_%d", node.FieldNum)
 		}
+		if node.IsGroup {
+			nameText += " (group)"
+		}
+		if node.IsRequired {
+			nameText += " *"
+		}
+		if issues := a.report.IssuesFor(node); len(issues) > 0 || hasInvalidUTF8(node) {
+			nameText = "⚠ " + nameText
+		}
 		editWidget.nameLabel.SetText(nameText)
+		editWidget.SetFormatVisible(false)
+		editWidget.entry.Validator = nil
+
+		if a.isPlaceholder(actualUID) {
+			a.updatePlaceholderNode(actualUID, node, editWidget)
+			return
+		}
+		editWidget.nameLabel.Importance = widget.MediumImportance
+		if len(a.report.IssuesFor(node)) > 0 || hasInvalidUTF8(node) {
+			editWidget.nameLabel.Importance = widget.WarningImportance
+		}
+		editWidget.typeCombo.Enable()
+		editWidget.SetMaterializeVisible(false)
 
 		allTypes := a.getAvailableTypesForNode(node)
 
@@ -141,6 +197,14 @@ func (a *protoTreeAdapter) UpdateNode(uid widget.TreeNodeID, branch bool, obj fy
 		} else {
 			editWidget.SetEntryVisible(true)
 			editWidget.entry.Enable()
+			if node.Type == "bytes" {
+				a.setupBytesFormat(actualUID, node, editWidget)
+			} else if a.isEscapedString(node) {
+				editWidget.entry.Validator = func(text string) error {
+					_, err := protobuf.UnescapeText(text)
+					return err
+				}
+			}
 			valueStr := a.nodeValueToString(node)
 
 			editWidget.entry.OnChanged = nil
@@ -221,7 +285,12 @@ func (a *protoTreeAdapter) nodeValueToString(node *protobuf.TreeNode) string {
 
 	switch v := node.Value.(type) {
 	case string:
+		if a.isEscapedString(node) {
+			return protobuf.EscapeText(v)
+		}
 		return v
+	case []byte:
+		return protobuf.FormatBytes(v, a.bytesFormatFor(node))
 	case bool:
 		if v {
 			return "1"
@@ -244,6 +313,9 @@ func (a *protoTreeAdapter) validateValue(value string, fieldType string) bool {
 	switch fieldType {
 	case "string":
 		return true
+	case "bytes":
+		// Неполный hex или base64 допустим при вводе, ошибка показывается валидатором поля
+		return true
 	case "int32", "int64", "sint32", "sint64":
 		if value == "-" {
 			return true
@@ -373,7 +445,7 @@ func (a *protoTreeAdapter) detectTypeChange(oldType string, valueStr string) (ne
 		return "string", true
 	}
 
-	if oldType == "string" {
+	if oldType == "string" || oldType == "bytes" {
 		return oldType, false
 	}
 
@@ -437,6 +509,7 @@ func (a *protoTreeAdapter) handleTypeChange(uid widget.TreeNodeID, oldType, newT
 	if node == nil {
 		return
 	}
+	a.notifyChange()
 
 	isMessageType := a.isMessageType(newType)
 	isOldMessageType := a.isMessageType(oldType)
@@ -714,6 +787,7 @@ func (a *protoTreeAdapter) handleTypeChange(uid widget.TreeNodeID, oldType, newT
 						node.Type = newType
 						node.Value = nil
 						node.Children = make([]*protobuf.TreeNode, 0)
+						node.IsGroup = false
 						if editWidget, ok := a.editWidgets[uid]; ok {
 							editWidget.entry.SetText("")
 							editWidget.typeCombo.SetSelected(newType)
@@ -724,6 +798,7 @@ func (a *protoTreeAdapter) handleTypeChange(uid widget.TreeNodeID, oldType, newT
 							field.Type = newType
 							field.Value = nil
 							field.Children = make([]*protobuf.TreeNode, 0)
+							field.IsGroup = false
 						}
 
 						if a.treeWidget != nil {
@@ -743,6 +818,8 @@ func (a *protoTreeAdapter) handleTypeChange(uid widget.TreeNodeID, oldType, newT
 		node.Type = newType
 		node.Value = nil
 		node.Children = make([]*protobuf.TreeNode, 0)
+		// Скалярное значение не может быть закодировано группой
+		node.IsGroup = false
 		delete(a.editWidgets, uid)
 		if a.treeWidget != nil {
 			a.treeWidget.Refresh()
@@ -833,6 +910,11 @@ func (a *protoTreeAdapter) handleTypeChange(uid widget.TreeNodeID, oldType, newT
 		}
 	}
 
+	if (oldType == "string" && newType == "bytes") || (oldType == "bytes" && newType == "string") {
+		a.convertStringBytes(uid, node, newType)
+		return
+	}
+
 	if canSeamlessChange {
 		parentMessage := a.findParentMessage(node)
 		var affectedFields []*protobuf.TreeNode
@@ -1101,6 +1183,7 @@ func (a *protoTreeAdapter) updateNodeValue(uid widget.TreeNodeID, valueStr strin
 	if node == nil {
 		return
 	}
+	a.notifyChange()
 
 	newType, typeChanged := a.detectTypeChange(fieldType, valueStr)
 
@@ -1142,7 +1225,21 @@ func (a *protoTreeAdapter) updateNodeValue(uid widget.TreeNodeID, valueStr strin
 
 	switch newType {
 	case "string":
+		if a.isEscapedString(node) {
+			data, err := protobuf.UnescapeText(valueStr)
+			if err != nil {
+				return
+			}
+			node.Value = string(data)
+			return
+		}
 		node.Value = valueStr
+	case "bytes":
+		data, err := protobuf.ParseBytes(valueStr, a.bytesFormatFor(node))
+		if err != nil {
+			return
+		}
+		node.Value = data
 	case "int32", "int64", "uint32", "uint64", "sint32", "sint64", "float", "double":
 		node.Value = valueStr
 	case "bool":
@@ -1167,6 +1264,12 @@ func (a *protoTreeAdapter) getNodeByUID(uid widget.TreeNodeID) *protobuf.TreeNod
 	if uid == "" || uid == "root" {
 		return a.tree
 	}
+	if isStableUID(uid) {
+		if ref, ok := a.nodesByID[uid]; ok {
+			return ref.node
+		}
+		return nil
+	}
 
 	var parts []string
 	if strings.HasPrefix(uid, "root:") {
@@ -1184,6 +1287,14 @@ func (a *protoTreeAdapter) getNodeByUID(uid widget.TreeNodeID) *protobuf.TreeNod
 
 	current := a.tree
 	for i := 1; i < len(parts); i++ {
+		if strings.HasPrefix(parts[i], placeholderUIDPrefix) {
+			idx := parseInt(strings.TrimPrefix(parts[i], placeholderUIDPrefix))
+			if idx < 0 || idx >= len(current.Placeholders) {
+				return nil
+			}
+			current = current.Placeholders[idx]
+			continue
+		}
 		idx := parseInt(parts[i])
 		if idx < 0 || idx >= len(current.Children) {
 			return nil
@@ -1194,9 +1305,65 @@ func (a *protoTreeAdapter) getNodeByUID(uid widget.TreeNodeID) *protobuf.TreeNod
 	return current
 }
 
+// placeholderUIDPrefix отличает в UID плейсхолдеры отсутствующих полей от обычных дочерних узлов
+const placeholderUIDPrefix = "p"
+
+func isPlaceholderUID(uid widget.TreeNodeID) bool {
+	parts := splitUID(uid)
+	return len(parts) > 0 && strings.HasPrefix(parts[len(parts)-1], placeholderUIDPrefix)
+}
+
+// updatePlaceholderNode показывает поле, отсутствующее в данных, неактивной строкой
+// со значением по умолчанию и кнопкой добавления в сообщение
+func (a *protoTreeAdapter) updatePlaceholderNode(uid widget.TreeNodeID, node *protobuf.TreeNode, editWidget *protoFieldEditor) {
+	editWidget.nameLabel.Importance = widget.LowImportance
+
+	editWidget.typeCombo.OnChanged = nil
+	editWidget.typeCombo.Options = []string{node.Type}
+	editWidget.typeCombo.SetSelected(node.Type)
+	editWidget.typeCombo.Disable()
+
+	editWidget.entry.OnChanged = nil
+	if a.isMessageType(node.Type) {
+		editWidget.SetEntryVisible(false)
+		editWidget.entry.SetText("")
+	} else {
+		editWidget.SetEntryVisible(true)
+		editWidget.entry.SetText(a.nodeValueToString(node))
+		editWidget.entry.Disable()
+	}
+
+	editWidget.materializeBtn.OnTapped = func() {
+		a.materializePlaceholder(uid)
+	}
+	editWidget.SetMaterializeVisible(true)
+	editWidget.Refresh()
+}
+
+// materializePlaceholder добавляет поле плейсхолдера в родительское сообщение
+func (a *protoTreeAdapter) materializePlaceholder(uid widget.TreeNodeID) {
+	parent, index := a.placeholderPosition(uid)
+	if parent == nil {
+		return
+	}
+
+	if _, err := parent.MaterializePlaceholder(index); err != nil {
+		if a.window != nil {
+			dialog.ShowError(err, a.window)
+		}
+		return
+	}
+	a.notifyChange()
+
+	a.editWidgets = make(map[widget.TreeNodeID]*protoFieldEditor)
+	if a.treeWidget != nil {
+		a.treeWidget.Refresh()
+	}
+}
+
 func (a *protoTreeAdapter) getAvailableTypesForNode(node *protobuf.TreeNode) []string {
 	messageTypes := a.getAllMessageTypes()
-	baseTypes := []string{"string", "int32", "int64", "uint32", "uint64", "sint32", "sint64", "bool", "float", "double"}
+	baseTypes := []string{"string", "bytes", "int32", "int64", "uint32", "uint64", "sint32", "sint64", "bool", "float", "double"}
 	allTypes := make([]string, 0, len(baseTypes)+len(messageTypes))
 	allTypes = append(allTypes, baseTypes...)
 
@@ -1313,6 +1480,7 @@ func (a *protoTreeAdapter) copyMessageChildren(source *protobuf.TreeNode) []*pro
 			Value:      child.Value,
 			FieldNum:   child.FieldNum,
 			IsRepeated: child.IsRepeated,
+			IsRequired: child.IsRequired,
 			Children:   a.copyMessageChildren(child),
 		}
 		children = append(children, copied)
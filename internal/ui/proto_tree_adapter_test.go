@@ -1,9 +1,12 @@
 package ui
 
 import (
+	"context"
 	"testing"
 
 	"prospect/internal/protobuf"
+
+	"fyne.io/fyne/v2/test"
 )
 
 func TestHandleTypeChangeToMessageWithExistingChildren(t *testing.T) {
@@ -990,3 +993,163 @@ func TestHandleTypeChangeFromMessageToStandardType_WithFieldSync_ClearsChildren(
 		t.Errorf("Expected field3InMessage2 children to be cleared, got %d children", len(field3InMessage2.Children))
 	}
 }
+
+func TestBytesFieldEditing(t *testing.T) {
+	node := &protobuf.TreeNode{Name: "payload", Type: "string", Value: "\x01A", FieldNum: 1}
+	root := &protobuf.TreeNode{Name: "root", Type: "message", Children: []*protobuf.TreeNode{node}}
+	adapter := newProtoTreeAdapter(root)
+
+	adapter.handleTypeChange("0", "string", "bytes")
+	if data, ok := node.Value.([]byte); node.Type != "bytes" || !ok || string(data) != "\x01A" {
+		t.Fatalf("Expected string to convert to raw bytes, got %s %#v", node.Type, node.Value)
+	}
+	if text := adapter.nodeValueToString(node); text != "0141" {
+		t.Errorf("Expected hex representation by default, got %q", text)
+	}
+
+	adapter.bytesFormats[node] = nextBytesFormat(adapter.bytesFormatFor(node))
+	if text := adapter.nodeValueToString(node); text != "AUE=" {
+		t.Errorf("Expected base64 representation, got %q", text)
+	}
+
+	adapter.bytesFormats[node] = protobuf.BytesHex
+	adapter.updateNodeValue("0", "ff0", "bytes")
+	if string(node.Value.([]byte)) != "\x01A" {
+		t.Errorf("Expected incomplete hex to keep the previous value, got %#v", node.Value)
+	}
+	adapter.updateNodeValue("0", "ff00", "bytes")
+	if string(node.Value.([]byte)) != "\xff\x00" || node.Type != "bytes" {
+		t.Errorf("Expected hex input to update bytes, got %s %#v", node.Type, node.Value)
+	}
+
+	adapter.handleTypeChange("0", "bytes", "string")
+	if node.Type != "string" || node.Value != "\xff\x00" {
+		t.Errorf("Expected bytes to convert back to string, got %s %#v", node.Type, node.Value)
+	}
+}
+
+func TestInvalidUTF8StringIsEditedEscaped(t *testing.T) {
+	node := &protobuf.TreeNode{Name: "name", Type: "string", Value: "caf\xc3", FieldNum: 1}
+	root := &protobuf.TreeNode{Name: "root", Type: "message", Children: []*protobuf.TreeNode{node}}
+	adapter := newProtoTreeAdapter(root)
+
+	if !hasInvalidUTF8(node) {
+		t.Fatal("Expected invalid UTF-8 to be detected")
+	}
+	if text := adapter.nodeValueToString(node); text != `caf\303` {
+		t.Errorf("Expected escaped representation, got %q", text)
+	}
+
+	adapter.updateNodeValue("0", `caf\303\251`, "string")
+	if node.Value != "café" {
+		t.Errorf("Expected escaped input to be decoded, got %q", node.Value)
+	}
+	// Поле остается в экранированном режиме, хотя значение уже корректно
+	if text := adapter.nodeValueToString(node); text != "café" || !adapter.isEscapedString(node) {
+		t.Errorf("Expected field to stay in escaped mode, got %q", text)
+	}
+	adapter.updateNodeValue("0", `bad\q`, "string")
+	if node.Value != "café" {
+		t.Errorf("Expected invalid escape to keep previous value, got %q", node.Value)
+	}
+}
+
+func TestProtoTable_PathsSortingAndEditing(t *testing.T) {
+	test.NewApp()
+
+	item1 := &protobuf.TreeNode{Name: "items", Type: "Item", FieldNum: 2, IsRepeated: true}
+	item1.AddChild(&protobuf.TreeNode{Name: "sku", Type: "string", FieldNum: 1, Value: "b"})
+	item2 := &protobuf.TreeNode{Name: "items", Type: "Item", FieldNum: 2, IsRepeated: true}
+	item2.AddChild(&protobuf.TreeNode{Name: "sku", Type: "string", FieldNum: 1, Value: "a"})
+	count := &protobuf.TreeNode{Name: "count", Type: "int32", FieldNum: 3, Value: "7"}
+	root := &protobuf.TreeNode{Name: "root", Type: "message"}
+	root.AddChild(item1)
+	root.AddChild(item2)
+	root.AddChild(count)
+
+	rows := buildTableData(root)
+	expected := []struct {
+		path string
+		uid  string
+	}{
+		{"items[0]", "0"},
+		{"items[0].sku", "0:0"},
+		{"items[1]", "1"},
+		{"items[1].sku", "1:0"},
+		{"count", "2"},
+	}
+	if len(rows) != len(expected) {
+		t.Fatalf("Expected %d rows, got %d", len(expected), len(rows))
+	}
+	for i, e := range expected {
+		if rows[i].Path != e.path || rows[i].UID != e.uid {
+			t.Errorf("Row %d: expected %s (%s), got %s (%s)", i, e.path, e.uid, rows[i].Path, rows[i].UID)
+		}
+	}
+
+	table := newProtoTable(newProtoTreeAdapter(root))
+	table.sortBy(tableColumnNum)
+	if table.rows[0].Node.FieldNum != 1 || table.rows[len(table.rows)-1].Node != count {
+		t.Errorf("Expected ascending sort by field number, got first %s", table.rows[0].Path)
+	}
+	table.sortBy(tableColumnNum)
+	if table.rows[0].Node != count {
+		t.Errorf("Expected second click to sort descending, got first %s", table.rows[0].Path)
+	}
+	table.sortBy(tableColumnValue)
+	if table.rows[0].Path != "items[0]" || table.cellText(table.rows[2], tableColumnValue) != "7" {
+		t.Errorf("Expected messages with empty values first, got %s", table.rows[0].Path)
+	}
+
+	for _, row := range table.rows {
+		if row.Path == "items[1].sku" && !table.setValue(row, "z") {
+			t.Error("Expected string value to be accepted")
+		}
+		if row.Node == count && table.setValue(row, "abc") {
+			t.Error("Expected non-numeric value to be rejected for int32")
+		}
+	}
+	if item2.Children[0].Value != "z" {
+		t.Errorf("Expected edit to write through to the tree node, got %v", item2.Children[0].Value)
+	}
+	if count.Value != "7" {
+		t.Errorf("Expected rejected edit to keep the value, got %v", count.Value)
+	}
+}
+
+func TestChildUIDs_StableIDsAndLazyExpansion(t *testing.T) {
+	// 1: 1, 2: { 1: 2 }
+	data := []byte{0x08, 0x01, 0x12, 0x02, 0x08, 0x02}
+	root, err := protobuf.DecodeLazy(context.Background(), data, nil)
+	if err != nil {
+		t.Fatalf("DecodeLazy failed: %v", err)
+	}
+	adapter := newProtoTreeAdapter(root)
+
+	ids := adapter.ChildUIDs("root")
+	if len(ids) != 2 {
+		t.Fatalf("Expected 2 top-level IDs, got %v", ids)
+	}
+	message := root.Children[1]
+	if adapter.getNodeByUID(ids[1]) != message {
+		t.Fatal("Expected stable ID to resolve to the message node")
+	}
+	if !adapter.IsBranch(ids[1]) {
+		t.Error("Expected undecoded message to be a branch")
+	}
+	if nested := adapter.ChildUIDs(ids[1]); len(nested) != 1 || message.IsLazy() {
+		t.Fatalf("Expected ChildUIDs to decode the lazy message, got %v", nested)
+	}
+
+	// Вставка соседа не меняет ID существующих узлов, а удаленный узел забывается
+	root.Children = append([]*protobuf.TreeNode{{Name: "field_3", Type: "int64", FieldNum: 3, Value: int64(3)}}, root.Children...)
+	after := adapter.ChildUIDs("root")
+	if len(after) != 3 || after[1] != ids[0] || after[2] != ids[1] {
+		t.Errorf("Expected IDs to survive insertion, got %v then %v", ids, after)
+	}
+	root.Children = root.Children[:2]
+	adapter.ChildUIDs("root")
+	if adapter.getNodeByUID(ids[1]) != nil {
+		t.Error("Expected ID of removed node to be forgotten")
+	}
+}
@@ -1,11 +1,14 @@
 package ui
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
 
 	"prospect/internal/protobuf"
 
@@ -28,15 +31,15 @@ func protoViewWithFile(fyneApp fyne.App, parentWindow fyne.Window, browserTabs *
 		return container.NewPadded(errorLabel)
 	}
 
-	var currentTree *protobuf.TreeNode
-	var currentFilePath string
-	if filePath != "" {
-		currentFilePath = filePath
+	// tab - вкладка этого представления: оно создается, пока вкладка выбрана (см. addViewTab),
+	// и дальше обращается к ней явно, даже если выбрана другая
+	var tab *tabData
+	if browserTabs != nil {
+		tab = browserTabs.CurrentTab()
+		parser.SetIncludePaths(browserTabs.includePaths)
 	}
 
-	treeWidget := createProtoTree(nil)
-
-	treeScrollContainer := container.NewScroll(treeWidget)
+	state := newProtoViewState(filePath)
 
 	dialogState := getFileDialogState()
 
@@ -48,56 +51,172 @@ func protoViewWithFile(fyneApp fyne.App, parentWindow fyne.Window, browserTabs *
 	var openCallback func()
 	var saveCallback func()
 	var applySchemaCallback func()
+	// applySchemaFile применяет схему из файла к дереву вкладки
+	var applySchemaFile func(schemaPath string, messageName string)
 	var exportSchemaCallback func()
+	var editSchemaCallback func()
+	var compatCallback func()
+	var rulesCallback func()
+	var viewModeCallback func()
+	var detectTypeCallback func()
+	// saveSchemaFile сохраняет сгенерированную схему в выбранный пользователем файл
+	var saveSchemaFile func(protoContent string)
 	var exportJSONCallback func()
-
-	if toolbarMgr != nil {
-		openCallback = func() {
-			fileDialog := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+	var compareCallback func()
+	var mergeCallback func()
+	var newMessageCallback func()
+	var showUnsetCallback func()
+	var inferSchemaCallback func()
+
+	// reloadFile перечитывает файл вкладки changed, измененный другой программой, и заново
+	// применяет схему вкладки. Узлы дерева переиспользуются, поэтому раскрытые ветки и
+	// выделение сохраняются
+	reloadFile := func(changed *tabData) {
+		path := browserTabs.GetTabFilePath(changed)
+		schemaPath, messageName := browserTabs.GetTabSchema(changed)
+		rules := tabConstraintRules(browserTabs, changed)
+		reload := func(ctx context.Context, progress protobuf.ProgressFunc) (func(), error) {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("read error: %w", err)
+			}
+			tree, err := decodeData(ctx, parser, data, progress)
+			if err != nil {
+				return nil, fmt.Errorf("parsing error: %w", err)
+			}
+			var report *protobuf.SchemaReport
+			if schemaPath != "" && messageName != "" {
+				tree, report, err = parser.ApplySchemaWithRules(tree, schemaPath, messageName, rules)
 				if err != nil {
-					log.Printf("Dialog error: %v", err)
-					dialog.ShowError(err, parentWindow)
-					return
+					return nil, fmt.Errorf("error applying schema: %w", err)
 				}
-				if reader == nil {
+			}
+
+			return func() {
+				currentTree := state.Tree()
+				if currentTree == nil {
 					return
 				}
-				defer reader.Close()
+				protobuf.UpdateTree(currentTree, tree, report)
+				// Узлы и виджет дерева переиспользуются, поэтому адаптеру передается новый отчет:
+				// в него же попадут расхождения ленивых узлов, разобранных позже
+				state.Adapter().SetSchemaReport(report)
+				browserTabs.SetTabReport(changed, report)
+				browserTabs.SetTabModified(changed, false)
+				state.TreeWidget().Refresh()
+				content := tabViewContent(browserTabs, changed, currentTree, report, parentWindow, container.NewPadded(state.Scroll()))
+				browserTabs.UpdateTabContent(changed, container.NewPadded(wrapWithSchemaReport(content, report)))
+				log.Printf("Reloaded %s after external change", path)
+			}, nil
+		}
+		runInBackground(browserTabs, changed, "Reloading "+filepath.Base(path), reload, func(err error) {
+			dialog.ShowError(fmt.Errorf("reload error: %w", err), parentWindow)
+		})
+	}
 
-				currentFilePath = reader.URI().Path()
+	// fileChanged получает от наблюдателя вкладку, файл которой изменен на диске, и передает
+	// перечитывание в фон. Несохраненные правки теряются при перечитывании, поэтому в этом
+	// случае пользователь подтверждает перечитывание
+	fileChanged := func(changed *tabData) {
+		if !browserTabs.IsTabModified(changed) {
+			reloadFile(changed)
+			return
+		}
+		message := fmt.Sprintf("%s was changed on disk.\nReload it and discard unsaved edits?", filepath.Base(browserTabs.GetTabFilePath(changed)))
+		dialog.ShowConfirm("File changed", message, func(reload bool) {
+			if reload {
+				browserTabs.RunForTab(changed, func() { reloadFile(changed) })
+			}
+		}, parentWindow)
+	}
 
+	// watchFile следит за текущим файлом вкладки; вызывается после каждого открытия и сохранения
+	watchFile := func() {
+		browserTabs.WatchTabFile(tab, fileChanged)
+	}
+
+	// applyResolvedSchema применяет к дереву вкладки схему, которую resolve определяет в фоне,
+	// например загружая ее из реестра схем
+	applyResolvedSchema := func(title string, resolve func(ctx context.Context) (schemaPath string, messageName string, err error)) {
+		// Схема применяется к копии дерева в фоне: при отмене текущее дерево не меняется
+		source := state.Tree().Clone()
+		rules := tabConstraintRules(browserTabs, tab)
+		apply := func(ctx context.Context, progress protobuf.ProgressFunc) (func(), error) {
+			schemaPath, messageName, err := resolve(ctx)
+			if err != nil {
+				return nil, err
+			}
+			// Плейсхолдеры построены по прежней схеме и больше не актуальны
+			protobuf.ClearPlaceholders(source)
+			tree, report, err := parser.ApplySchemaWithRules(source, schemaPath, messageName, rules)
+			if err != nil {
+				return nil, err
+			}
+
+			return func() {
+				adapter := newProtoTreeAdapter(tree)
+				adapter.SetWindow(parentWindow)
+				adapter.SetSchemaReport(report)
+				adapter.SetChangeCallback(tabChangeCallback(browserTabs, tab))
+				newTreeWidget := widget.NewTree(adapter.ChildUIDs, adapter.IsBranch, adapter.CreateNode, adapter.UpdateNode)
+				adapter.SetTreeWidget(newTreeWidget)
+				newTreeWidget.OpenBranch("root")
+				newScrollContainer := container.NewScroll(newTreeWidget)
+				state.SetTree(tree, adapter, newTreeWidget, newScrollContainer)
+				newBorder := container.NewPadded(newScrollContainer)
 				if browserTabs != nil {
-					fileName := filepath.Base(currentFilePath)
-					browserTabs.UpdateTabTitle(fileName)
-					browserTabs.SetTabFilePath(currentFilePath)
+					browserTabs.SetTabReport(tab, report)
+					browserTabs.UpdateTabContent(tab, container.NewPadded(wrapWithSchemaReport(tabViewContent(browserTabs, tab, tree, report, parentWindow, newBorder), report)))
+					browserTabs.SetTabSchema(tab, schemaPath, messageName)
+					rememberSchema(browserTabs, state.FilePath(), schemaPath, messageName)
 				}
+				log.Printf("Schema applied successfully with message '%s', tree updated: %s", messageName, report.Summary())
+			}, nil
+		}
+		runInBackground(browserTabs, tab, title, apply, func(err error) {
+			dialog.ShowError(fmt.Errorf("error applying schema: %w", err), parentWindow)
+		})
+	}
 
-				dialogState.setLastOpenDir(reader.URI())
+	// applySchemaTo применяет сообщение messageName схемы schemaPath к дереву вкладки
+	applySchemaTo := func(schemaPath string, messageName string) {
+		applyResolvedSchema("Applying schema "+filepath.Base(schemaPath), func(context.Context) (string, string, error) {
+			return schemaPath, messageName, nil
+		})
+	}
 
-				data := make([]byte, 0)
-				buf := make([]byte, 4096)
-				for {
-					n, err := reader.Read(buf)
-					if n > 0 {
-						data = append(data, buf[:n]...)
-					}
-					if err != nil {
-						break
-					}
-				}
+	// applyRegistrySchema загружает в фоне и применяет схему, на которую ссылается заголовок
+	// Confluent данных
+	applyRegistrySchema := func(registryURL string, header *protobuf.ConfluentHeader) {
+		applyResolvedSchema(fmt.Sprintf("Fetching schema %d from the registry", header.SchemaID), func(ctx context.Context) (string, string, error) {
+			return registrySchema(ctx, parser, registryURL, header)
+		})
+	}
 
-				log.Printf("Parsing proto file: %s", reader.URI().Path())
+	// loadFile читает (read вызывается в фоновой горутине) и декодирует файл path в фоне,
+	// показывает его дерево во вкладке и применяет схему: из реестра по заголовку Confluent,
+	// schemaPath или запомненную для файла. Схема, выбранная во время декодирования, важнее
+	// их всех. Путь вкладки меняется только после успешного декодирования; затем вызывается
+	// onLoaded, если он задан
+	loadFile := func(path string, read func() ([]byte, error), schemaPath string, messageName string, onLoaded func(), onError func(error)) {
+		state.StartLoading()
+		decode := func(ctx context.Context, progress protobuf.ProgressFunc) (func(), error) {
+			data, err := read()
+			if err != nil {
+				return nil, fmt.Errorf("read error: %w", err)
+			}
 
-				tree, err := parser.ParseRaw(data)
-				if err != nil {
-					dialog.ShowError(fmt.Errorf("parsing error: %w", err), parentWindow)
-					return
-				}
+			log.Printf("Parsing proto file: %s", path)
 
-				currentTree = tree
+			tree, err := decodeData(ctx, parser, data, progress)
+			if err != nil {
+				return nil, fmt.Errorf("parsing error: %w", err)
+			}
 
+			return func() {
 				adapter := newProtoTreeAdapter(tree)
 				adapter.SetWindow(parentWindow)
+				adapter.SetChangeCallback(tabChangeCallback(browserTabs, tab))
 
 				newTreeWidget := widget.NewTree(adapter.ChildUIDs, adapter.IsBranch, adapter.CreateNode, adapter.UpdateNode)
 				adapter.SetTreeWidget(newTreeWidget)
@@ -110,22 +229,123 @@ func protoViewWithFile(fyneApp fyne.App, parentWindow fyne.Window, browserTabs *
 
 				newTreeWidget.Refresh()
 
-				treeWidget = newTreeWidget
-
 				newScrollContainer := container.NewScroll(newTreeWidget)
 				newScrollContainer.Refresh()
-				treeScrollContainer = newScrollContainer
-
-				_ = adapter.ChildUIDs("root")
-				_ = adapter.IsBranch("root")
+				state.SetTree(tree, adapter, newTreeWidget, newScrollContainer)
+				state.SetFilePath(path)
+				pendingSchemaPath, pendingMessageName := state.FinishLoading()
 
-				newBorder := container.NewPadded(newScrollContainer)
 				if browserTabs != nil {
-					browserTabs.UpdateTabContent(container.NewPadded(newBorder))
-				} else {
-					log.Printf("Error: browserTabs is nil")
+					browserTabs.SetTabFilePath(tab, path)
+					browserTabs.SetTabReport(tab, nil)
+					browserTabs.UpdateTabContent(tab, container.NewPadded(tabViewContent(browserTabs, tab, tree, nil, parentWindow, container.NewPadded(newScrollContainer))))
+					browserTabs.SetTabModified(tab, false)
+					watchFile()
 				}
 				log.Printf("Proto file parsed successfully, tree updated")
+				if onLoaded != nil {
+					onLoaded()
+				}
+
+				if pendingSchemaPath != "" {
+					applySchemaFile(pendingSchemaPath, pendingMessageName)
+					return
+				}
+				// Схема из реестра по ID в заголовке Confluent важнее запомненной для файла
+				registryURL := schemaRegistryURL()
+				if schemaPath == "" && tree.Framing != nil && registryURL != "" {
+					applyRegistrySchema(registryURL, tree.Framing)
+					return
+				}
+				if schemaPath == "" {
+					// Схема, которая применялась к этому файлу или соседним, применяется снова
+					schemaPath, messageName, _ = associatedSchema(path)
+				}
+				if schemaPath != "" && messageName != "" {
+					applySchemaTo(schemaPath, messageName)
+				}
+			}, nil
+		}
+		runInBackground(browserTabs, tab, "Decoding "+filepath.Base(path), decode, func(err error) {
+			state.FinishLoading()
+			onError(err)
+		})
+	}
+
+	if toolbarMgr != nil {
+		// Без messageName сообщение выбирается в диалоге, если в схеме их несколько. Пока файл
+		// декодируется, схема откладывается до конца декодирования
+		applySchemaFile = func(schemaPath string, messageName string) {
+			if state.Tree() == nil && state.DeferSchema(schemaPath, messageName) {
+				return
+			}
+			if state.Tree() == nil {
+				dialog.ShowInformation("Information", "Please open a proto file first", parentWindow)
+				return
+			}
+			log.Printf("Applying schema: %s", schemaPath)
+
+			// Получаем список сообщений верхнего уровня из схемы
+			messageNames, err := parser.ParseSchemaFile(schemaPath)
+			if err != nil {
+				dialog.ShowError(fmt.Errorf("error parsing schema file: %w", err), parentWindow)
+				return
+			}
+
+			if len(messageNames) == 0 {
+				dialog.ShowError(fmt.Errorf("schema file does not contain any top-level messages"), parentWindow)
+				return
+			}
+
+			if messageName != "" {
+				for _, name := range messageNames {
+					if name == messageName {
+						applySchemaTo(schemaPath, messageName)
+						return
+					}
+				}
+				dialog.ShowError(fmt.Errorf("message %s not found in %s", messageName, filepath.Base(schemaPath)), parentWindow)
+				return
+			}
+			showMessageSelectDialog(messageNames, "Выберите сообщение для применения схемы:", "Применить", parentWindow, func(messageName string) {
+				applySchemaTo(schemaPath, messageName)
+			})
+		}
+		if browserTabs != nil {
+			browserTabs.SetTabSchemaHandler(tab, applySchemaFile)
+			browserTabs.SetTabTreeState(tab, func() []string {
+				return expandedTreePaths(state.TreeWidget())
+			}, func(paths []string) {
+				expandTreePaths(state.TreeWidget(), paths)
+			})
+		}
+
+		openCallback = func() {
+			fileDialog := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+				if err != nil {
+					log.Printf("Dialog error: %v", err)
+					dialog.ShowError(err, parentWindow)
+					return
+				}
+				if reader == nil {
+					return
+				}
+
+				dialogState.setLastOpenDir(reader.URI())
+
+				filePath := reader.URI().Path()
+				read := func() ([]byte, error) {
+					defer reader.Close()
+					return io.ReadAll(reader)
+				}
+				loadFile(filePath, read, "", "", func() {
+					if browserTabs != nil {
+						browserTabs.UpdateTabTitle(tab, filepath.Base(filePath))
+					}
+					rememberFile(browserTabs, filePath)
+				}, func(err error) {
+					dialog.ShowError(err, parentWindow)
+				})
 			}, parentWindow)
 
 			if lastDir := dialogState.getLastOpenDir(); lastDir != nil {
@@ -138,11 +358,35 @@ func protoViewWithFile(fyneApp fyne.App, parentWindow fyne.Window, browserTabs *
 		toolbarMgr.SetOpenCallback(openCallback)
 
 		applySchemaCallback = func() {
-			if currentTree == nil {
+			if state.Tree() == nil {
 				dialog.ShowInformation("Information", "Please open a proto file first", parentWindow)
 				return
 			}
 
+			fileDialog := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+				if err != nil {
+					dialog.ShowError(err, parentWindow)
+					return
+				}
+				if reader == nil {
+					return
+				}
+				reader.Close()
+
+				dialogState.setLastSchemaDir(reader.URI())
+				applySchemaFile(reader.URI().Path(), "")
+			}, parentWindow)
+
+			if lastDir := dialogState.getLastSchemaDir(); lastDir != nil {
+				fileDialog.SetLocation(lastDir)
+			}
+
+			fileDialog.Resize(dialogState.getDialogSize())
+			fileDialog.Show()
+		}
+		toolbarMgr.SetApplySchemaCallback(applySchemaCallback)
+
+		newMessageCallback = func() {
 			fileDialog := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
 				if err != nil {
 					dialog.ShowError(err, parentWindow)
@@ -156,9 +400,6 @@ func protoViewWithFile(fyneApp fyne.App, parentWindow fyne.Window, browserTabs *
 				dialogState.setLastSchemaDir(reader.URI())
 
 				schemaPath := reader.URI().Path()
-				log.Printf("Applying schema: %s", schemaPath)
-
-				// Получаем список сообщений верхнего уровня из схемы
 				messageNames, err := parser.ParseSchemaFile(schemaPath)
 				if err != nil {
 					dialog.ShowError(fmt.Errorf("error parsing schema file: %w", err), parentWindow)
@@ -170,68 +411,35 @@ func protoViewWithFile(fyneApp fyne.App, parentWindow fyne.Window, browserTabs *
 					return
 				}
 
-				// Вспомогательная функция для применения схемы
-				applySchemaToTree := func(messageName string) {
-					tree, err := parser.ApplySchemaWithMessage(currentTree, schemaPath, messageName)
+				createMessage := func(messageName string) {
+					tree, err := parser.NewMessageFromSchema(schemaPath, messageName)
 					if err != nil {
-						dialog.ShowError(fmt.Errorf("error applying schema: %w", err), parentWindow)
+						dialog.ShowError(fmt.Errorf("error creating message: %w", err), parentWindow)
 						return
 					}
 
-					currentTree = tree
 					adapter := newProtoTreeAdapter(tree)
 					adapter.SetWindow(parentWindow)
+					adapter.SetChangeCallback(tabChangeCallback(browserTabs, tab))
 					newTreeWidget := widget.NewTree(adapter.ChildUIDs, adapter.IsBranch, adapter.CreateNode, adapter.UpdateNode)
-					newTreeWidget.OpenBranch("root")
-					treeWidget = newTreeWidget
+					adapter.SetTreeWidget(newTreeWidget)
+					newTreeWidget.OpenBranch("")
 					newScrollContainer := container.NewScroll(newTreeWidget)
-					treeScrollContainer = newScrollContainer
-					newBorder := container.NewPadded(newScrollContainer)
+					state.SetTree(tree, adapter, newTreeWidget, newScrollContainer)
+					state.SetFilePath("")
 					if browserTabs != nil {
-						browserTabs.UpdateTabContent(container.NewPadded(newBorder))
-						browserTabs.SetTabSchema(schemaPath, messageName)
+						browserTabs.SetTabReport(tab, nil)
+						browserTabs.UpdateTabContent(tab, container.NewPadded(tabViewContent(browserTabs, tab, tree, nil, parentWindow, container.NewPadded(newScrollContainer))))
+						browserTabs.UpdateTabTitle(tab, fmt.Sprintf("new_%s", messageName))
+						browserTabs.SetTabFilePath(tab, "")
+						browserTabs.SetTabSchema(tab, schemaPath, messageName)
+						browserTabs.SetTabModified(tab, false)
+						watchFile()
 					}
-					log.Printf("Schema applied successfully with message '%s', tree updated", messageName)
-				}
-
-				// Если сообщение одно, используем его автоматически
-				if len(messageNames) == 1 {
-					selectedMessageName := messageNames[0]
-					log.Printf("Only one message found in schema, using: %s", selectedMessageName)
-					applySchemaToTree(selectedMessageName)
-					return
+					log.Printf("New message '%s' created from schema %s", messageName, schemaPath)
 				}
 
-				// Если сообщений несколько, показываем диалог выбора
-				selectedMessageName := messageNames[0] // Значение по умолчанию
-
-				selectWidget := widget.NewSelect(messageNames, func(selected string) {
-					selectedMessageName = selected
-				})
-				selectWidget.SetSelected(messageNames[0])
-
-				content := container.NewVBox(
-					widget.NewLabel("Выберите сообщение для применения схемы:"),
-					selectWidget,
-				)
-
-				confirmDialog := dialog.NewCustomConfirm(
-					"Выбор сообщения",
-					"Применить",
-					"Отмена",
-					content,
-					func(confirmed bool) {
-						if !confirmed {
-							return
-						}
-
-						applySchemaToTree(selectedMessageName)
-					},
-					parentWindow,
-				)
-
-				confirmDialog.Resize(fyne.NewSize(400, 150))
-				confirmDialog.Show()
+				showMessageSelectDialog(messageNames, "Выберите сообщение для создания:", "Создать", parentWindow, createMessage)
 			}, parentWindow)
 
 			if lastDir := dialogState.getLastSchemaDir(); lastDir != nil {
@@ -241,10 +449,10 @@ func protoViewWithFile(fyneApp fyne.App, parentWindow fyne.Window, browserTabs *
 			fileDialog.Resize(dialogState.getDialogSize())
 			fileDialog.Show()
 		}
-		toolbarMgr.SetApplySchemaCallback(applySchemaCallback)
+		toolbarMgr.SetNewMessageCallback(newMessageCallback)
 
 		saveCallback = func() {
-			if currentTree == nil {
+			if state.Tree() == nil {
 				dialog.ShowInformation("Information", "Please open a proto file first", parentWindow)
 				return
 			}
@@ -258,37 +466,50 @@ func protoViewWithFile(fyneApp fyne.App, parentWindow fyne.Window, browserTabs *
 				if writer == nil {
 					return
 				}
-				defer writer.Close()
 
-				currentFilePath = writer.URI().Path()
+				savedPath := writer.URI().Path()
+				state.SetFilePath(savedPath)
 
 				if browserTabs != nil {
-					browserTabs.SetTabFilePath(currentFilePath)
-					fileName := filepath.Base(currentFilePath)
-					browserTabs.UpdateTabTitle(fileName)
+					browserTabs.SetTabFilePath(tab, savedPath)
+					browserTabs.UpdateTabTitle(tab, filepath.Base(savedPath))
 				}
 
 				dialogState.setLastSaveDir(writer.URI())
 
-				serializer := protobuf.NewSerializer(parser.GetProtocPath())
-				binaryData, err := serializer.SerializeRaw(currentTree)
-				if err != nil {
-					dialog.ShowError(fmt.Errorf("serialization error: %w", err), parentWindow)
-					return
-				}
+				// Сериализация через protoc идет в фоне по копии дерева, чтобы правки во время
+				// сохранения не попали в файл частично
+				tree := state.Tree().Clone()
+				serialize := func(ctx context.Context, progress protobuf.ProgressFunc) (func(), error) {
+					defer writer.Close()
+					serializer := protobuf.NewSerializer(parser.GetProtocPath())
+					binaryData, err := serializer.SerializeRawContext(ctx, tree)
+					if err != nil {
+						return nil, fmt.Errorf("serialization error: %w", err)
+					}
 
-				if _, err := writer.Write(binaryData); err != nil {
-					dialog.ShowError(fmt.Errorf("write error: %w", err), parentWindow)
-					return
-				}
+					if _, err := writer.Write(binaryData); err != nil {
+						return nil, fmt.Errorf("write error: %w", err)
+					}
 
-				dialog.ShowInformation("Success", "Proto file saved", parentWindow)
-				log.Printf("Proto file saved: %s", currentFilePath)
+					return func() {
+						if browserTabs != nil {
+							browserTabs.SetTabModified(tab, false)
+							watchFile()
+							rememberFile(browserTabs, savedPath)
+						}
+						dialog.ShowInformation("Success", "Proto file saved", parentWindow)
+						log.Printf("Proto file saved: %s", savedPath)
+					}, nil
+				}
+				runInBackground(browserTabs, tab, "Saving "+filepath.Base(savedPath), serialize, func(err error) {
+					dialog.ShowError(err, parentWindow)
+				})
 			}, parentWindow)
 
 			if lastDir := dialogState.getLastSaveDir(); lastDir != nil {
 				saveDialog.SetLocation(lastDir)
-			} else if currentFilePath != "" {
+			} else if currentFilePath := state.FilePath(); currentFilePath != "" {
 				dirPath := filepath.Dir(currentFilePath)
 				uri := storage.NewFileURI(dirPath)
 				if listableURI, err := storage.ListerForURI(uri); err == nil {
@@ -302,14 +523,46 @@ func protoViewWithFile(fyneApp fyne.App, parentWindow fyne.Window, browserTabs *
 		toolbarMgr.SetSaveCallback(saveCallback)
 
 		exportSchemaCallback = func() {
-			if currentTree == nil {
+			if state.Tree() == nil {
 				dialog.ShowInformation("Information", "Please open a proto file first", parentWindow)
 				return
 			}
 
-			serializer := protobuf.NewSerializer(parser.GetProtocPath())
-			protoContent := serializer.GenerateProtoSchema(currentTree)
+			var schemaPath, schemaMessageName string
+			if browserTabs != nil {
+				schemaPath, schemaMessageName = browserTabs.GetTabSchema(tab)
+			}
+
+			syntaxRadio := widget.NewRadioGroup([]string{"proto2", "proto3"}, nil)
+			syntaxRadio.Horizontal = true
+			syntaxRadio.SetSelected("proto2")
+			content := container.NewVBox(widget.NewLabel("Syntax of the exported schema:"), syntaxRadio)
+
+			dialog.ShowCustomConfirm("Export schema", "Export", "Cancel", content, func(confirmed bool) {
+				if !confirmed {
+					return
+				}
+
+				// Экспорт раскрывает ленивые узлы, поэтому идет в фоне по копии дерева
+				source := state.Tree().Clone()
+				syntax := syntaxRadio.Selected
+				export := func(ctx context.Context, progress protobuf.ProgressFunc) (func(), error) {
+					protoContent, err := parser.ExportSchema(source, schemaPath, schemaMessageName, syntax)
+					if err != nil {
+						return nil, err
+					}
+					return func() {
+						saveSchemaFile(protoContent)
+					}, nil
+				}
+				runInBackground(browserTabs, tab, "Exporting schema", export, func(err error) {
+					dialog.ShowError(fmt.Errorf("error exporting schema: %w", err), parentWindow)
+				})
+			}, parentWindow)
+		}
+		toolbarMgr.SetExportSchemaCallback(exportSchemaCallback)
 
+		saveSchemaFile = func(protoContent string) {
 			fileDialog := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
 				if err != nil {
 					dialog.ShowError(err, parentWindow)
@@ -332,7 +585,7 @@ func protoViewWithFile(fyneApp fyne.App, parentWindow fyne.Window, browserTabs *
 
 			if lastDir := dialogState.getLastSaveDir(); lastDir != nil {
 				fileDialog.SetLocation(lastDir)
-			} else if currentFilePath != "" {
+			} else if currentFilePath := state.FilePath(); currentFilePath != "" {
 				dirPath := filepath.Dir(currentFilePath)
 				uri := storage.NewFileURI(dirPath)
 				if listableURI, err := storage.ListerForURI(uri); err == nil {
@@ -343,15 +596,123 @@ func protoViewWithFile(fyneApp fyne.App, parentWindow fyne.Window, browserTabs *
 			fileDialog.Resize(dialogState.getDialogSize())
 			fileDialog.Show()
 		}
-		toolbarMgr.SetExportSchemaCallback(exportSchemaCallback)
+
+		// saveInferredSchema предлагает сохранить схему, выведенную из образцов каталога dir
+		saveInferredSchema := func(dir fyne.ListableURI, inference *protobuf.SchemaInference, protoContent string) {
+			saveDialog := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+				if err != nil {
+					dialog.ShowError(err, parentWindow)
+					return
+				}
+				if writer == nil {
+					return
+				}
+				defer writer.Close()
+
+				dialogState.setLastSaveDir(writer.URI())
+
+				if _, err := writer.Write([]byte(protoContent)); err != nil {
+					dialog.ShowError(fmt.Errorf("write error: %w", err), parentWindow)
+					return
+				}
+
+				message := fmt.Sprintf("Schema inferred from %d sample(s) saved", inference.Samples())
+				if len(inference.Skipped) > 0 {
+					message += fmt.Sprintf(", %d file(s) skipped", len(inference.Skipped))
+				}
+				dialog.ShowInformation("Success", message, parentWindow)
+			}, parentWindow)
+			saveDialog.SetFileName("inferred.proto")
+			saveDialog.SetLocation(dir)
+			saveDialog.Resize(dialogState.getDialogSize())
+			saveDialog.Show()
+		}
+
+		inferSchemaCallback = func() {
+			folderDialog := dialog.NewFolderOpen(func(dir fyne.ListableURI, err error) {
+				if err != nil {
+					dialog.ShowError(err, parentWindow)
+					return
+				}
+				if dir == nil {
+					return
+				}
+
+				infer := func(ctx context.Context, progress protobuf.ProgressFunc) (func(), error) {
+					inference, err := parser.InferSchemaFromDir(dir.Path())
+					if err != nil {
+						return nil, err
+					}
+					for _, skipped := range inference.Skipped {
+						log.Printf("Schema inference skipped %s", skipped)
+					}
+					protoContent := inference.GenerateProto()
+					return func() {
+						saveInferredSchema(dir, inference, protoContent)
+					}, nil
+				}
+				runInBackground(browserTabs, tab, "Inferring schema from "+filepath.Base(dir.Path()), infer, func(err error) {
+					dialog.ShowError(fmt.Errorf("schema inference error: %w", err), parentWindow)
+				})
+			}, parentWindow)
+
+			if lastDir := dialogState.getLastOpenDir(); lastDir != nil {
+				folderDialog.SetLocation(lastDir)
+			}
+
+			folderDialog.Resize(dialogState.getDialogSize())
+			folderDialog.Show()
+		}
+		toolbarMgr.SetInferSchemaCallback(inferSchemaCallback)
+
+		// detectTypeCallback сравнивает дерево с сообщениями библиотеки схем и предлагает
+		// применить одно из лучше всего подходящих
+		detectTypeCallback = func() {
+			if state.Tree() == nil {
+				dialog.ShowInformation("Information", "Please open a proto file first", parentWindow)
+				return
+			}
+			var library *protobuf.SchemaLibrary
+			indexing := false
+			if browserTabs != nil {
+				library, indexing = browserTabs.schemaLibrary.current()
+			}
+			if library == nil || library.Messages() == 0 {
+				message := "The schema library is empty. Add directories with schemas in File > Schema library"
+				if indexing {
+					message = "The schema library is still being indexed, please try again later"
+				}
+				dialog.ShowInformation("Detect type", message, parentWindow)
+				return
+			}
+
+			// Сравнение раскрывает ленивые узлы, поэтому идет по копии дерева
+			source := state.Tree().Clone()
+			detect := func(ctx context.Context, progress protobuf.ProgressFunc) (func(), error) {
+				candidates := library.Detect(source, maxTypeCandidates)
+				return func() {
+					if len(candidates) == 0 {
+						dialog.ShowInformation("Detect type", "No message in the schema library matches the data", parentWindow)
+						return
+					}
+					showTypeCandidatesDialog(candidates, parentWindow, func(candidate protobuf.TypeCandidate) {
+						applySchemaTo(candidate.SchemaPath, candidate.MessageName)
+					})
+				}, nil
+			}
+			runInBackground(browserTabs, tab, "Detecting type", detect, func(err error) {
+				dialog.ShowError(fmt.Errorf("type detection error: %w", err), parentWindow)
+			})
+		}
+		toolbarMgr.SetDetectTypeCallback(detectTypeCallback)
 
 		exportJSONCallback = func() {
-			if currentTree == nil {
+			if state.Tree() == nil {
 				dialog.ShowInformation("Information", "Please open a proto file first", parentWindow)
 				return
 			}
 
-			jsonContent, err := protobuf.TreeNodeToJSONString(currentTree)
+			jsonContent, err := protobuf.TreeNodeToJSONString(state.Tree())
 			if err != nil {
 				dialog.ShowError(fmt.Errorf("error converting to JSON: %w", err), parentWindow)
 				return
@@ -379,7 +740,7 @@ func protoViewWithFile(fyneApp fyne.App, parentWindow fyne.Window, browserTabs *
 
 			if lastDir := dialogState.getLastSaveDir(); lastDir != nil {
 				fileDialog.SetLocation(lastDir)
-			} else if currentFilePath != "" {
+			} else if currentFilePath := state.FilePath(); currentFilePath != "" {
 				dirPath := filepath.Dir(currentFilePath)
 				uri := storage.NewFileURI(dirPath)
 				if listableURI, err := storage.ListerForURI(uri); err == nil {
@@ -392,226 +753,455 @@ func protoViewWithFile(fyneApp fyne.App, parentWindow fyne.Window, browserTabs *
 		}
 		toolbarMgr.SetExportJSONCallback(exportJSONCallback)
 
-		if browserTabs != nil {
-			callbacks := &toolbarCallbacks{
-				openCallback:         openCallback,
-				saveCallback:         saveCallback,
-				applySchemaCallback:  applySchemaCallback,
-				exportSchemaCallback: exportSchemaCallback,
-				exportJSONCallback:   exportJSONCallback,
+		compareCallback = func() {
+			if state.Tree() == nil {
+				dialog.ShowInformation("Information", "Please open a proto file first", parentWindow)
+				return
 			}
-			browserTabs.SetCurrentTabToolbarCallbacks(callbacks)
-		}
-	}
 
-	if filePath != "" {
-		if err := loadFileIntoView(filePath, parser, &currentTree, &treeWidget, &treeScrollContainer, parentWindow, browserTabs, dialogState, &currentFilePath); err != nil {
-			log.Printf("Failed to load file %s: %v", filePath, err)
-		} else if schemaPath != "" && schemaMessageName != "" {
-			applySchemaToLoadedTree(parser, schemaPath, schemaMessageName, &currentTree, &treeWidget, &treeScrollContainer, parentWindow, browserTabs)
-		}
-	}
-
-	return container.NewPadded(treeScrollContainer)
-}
+			var schemaPath, schemaMessageName string
+			if browserTabs != nil {
+				schemaPath, schemaMessageName = browserTabs.GetTabSchema(tab)
+			}
 
-func applySchemaToLoadedTree(parser *protobuf.Parser, schemaPath string, messageName string, currentTree **protobuf.TreeNode, treeWidget **widget.Tree, treeScrollContainer **container.Scroll, parentWindow fyne.Window, browserTabs *tabManager) {
-	if *currentTree == nil {
-		log.Printf("Cannot apply schema: tree is nil")
-		return
-	}
+			fileDialog := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+				if err != nil {
+					dialog.ShowError(err, parentWindow)
+					return
+				}
+				if reader == nil {
+					return
+				}
+				dialogState.setLastOpenDir(reader.URI())
 
-	tree, err := parser.ApplySchemaWithMessage(*currentTree, schemaPath, messageName)
-	if err != nil {
-		log.Printf("Failed to apply schema: %v", err)
-		return
-	}
+				leftTitle := "current"
+				if currentFilePath := state.FilePath(); currentFilePath != "" {
+					leftTitle = filepath.Base(currentFilePath)
+				}
+				rightTitle := filepath.Base(reader.URI().Path())
+
+				// Сравнение раскрывает ленивые узлы, поэтому идет в фоне по копии дерева
+				baseTree := state.Tree().Clone()
+				// diff сравнивает деревья по ключам, выбранным в диалоге, и показывает различия
+				// в отдельной вкладке
+				diff := func(otherTree *protobuf.TreeNode, opts *protobuf.DiffOptions) {
+					compare := func(ctx context.Context, progress protobuf.ProgressFunc) (func(), error) {
+						entries := protobuf.DiffTrees(baseTree, otherTree, opts)
+						return func() {
+							if browserTabs != nil {
+								browserTabs.AddTransientTab(fmt.Sprintf("diff: %s ↔ %s", leftTitle, rightTitle), container.NewPadded(newDiffView(leftTitle, rightTitle, entries)))
+							}
+							log.Printf("Compared %s with %s: %d difference(s)", leftTitle, rightTitle, len(entries))
+						}, nil
+					}
+					runInBackground(browserTabs, tab, "Comparing with "+rightTitle, compare, func(err error) {
+						dialog.ShowError(err, parentWindow)
+					})
+				}
+				decode := func(ctx context.Context, progress protobuf.ProgressFunc) (func(), error) {
+					defer reader.Close()
+					data, err := io.ReadAll(reader)
+					if err != nil {
+						return nil, fmt.Errorf("read error: %w", err)
+					}
 
-	*currentTree = tree
-	adapter := newProtoTreeAdapter(tree)
-	adapter.SetWindow(parentWindow)
-	newTreeWidget := widget.NewTree(adapter.ChildUIDs, adapter.IsBranch, adapter.CreateNode, adapter.UpdateNode)
-	newTreeWidget.OpenBranch("root")
-	*treeWidget = newTreeWidget
-	newScrollContainer := container.NewScroll(newTreeWidget)
-	*treeScrollContainer = newScrollContainer
-	newBorder := container.NewPadded(newScrollContainer)
-	if browserTabs != nil {
-		browserTabs.UpdateTabContent(container.NewPadded(newBorder))
-		browserTabs.SetTabSchema(schemaPath, messageName)
-	}
-	log.Printf("Schema applied successfully on load with message '%s'", messageName)
-}
+					otherTree, err := decodeData(ctx, parser, data, progress)
+					if err != nil {
+						return nil, fmt.Errorf("parsing error: %w", err)
+					}
 
-func loadFileIntoView(filePath string, parser *protobuf.Parser, currentTree **protobuf.TreeNode, treeWidget **widget.Tree, treeScrollContainer **container.Scroll, parentWindow fyne.Window, browserTabs *tabManager, dialogState *fileDialogState, currentFilePath *string) error {
-	data, err := os.ReadFile(filePath)
-	if err != nil {
-		return fmt.Errorf("failed to read file: %w", err)
-	}
+					// Применяем к сравниваемому файлу ту же схему, что и к текущей вкладке
+					if schemaPath != "" {
+						otherTree, _, err = parser.ApplySchemaWithMessage(otherTree, schemaPath, schemaMessageName)
+						if err != nil {
+							return nil, fmt.Errorf("error applying schema: %w", err)
+						}
+					}
 
-	*currentFilePath = filePath
+					candidates := protobuf.RepeatedKeyCandidates(baseTree, otherTree)
+					return func() {
+						showDiffKeysDialog(parentWindow, candidates, func(opts *protobuf.DiffOptions) {
+							diff(otherTree, opts)
+						})
+					}, nil
+				}
+				runInBackground(browserTabs, tab, "Decoding "+rightTitle, decode, func(err error) {
+					dialog.ShowError(err, parentWindow)
+				})
+			}, parentWindow)
 
-	if browserTabs != nil {
-		browserTabs.SetTabFilePath(filePath)
-	}
+			if lastDir := dialogState.getLastOpenDir(); lastDir != nil {
+				fileDialog.SetLocation(lastDir)
+			}
 
-	dialogState.setLastOpenDir(storage.NewFileURI(filePath))
+			fileDialog.Resize(dialogState.getDialogSize())
+			fileDialog.Show()
+		}
+		toolbarMgr.SetCompareCallback(compareCallback)
 
-	log.Printf("Parsing proto file: %s", filePath)
+		showUnsetCallback = func() {
+			currentTree := state.Tree()
+			if currentTree == nil {
+				return
+			}
 
-	tree, err := parser.ParseRaw(data)
-	if err != nil {
-		return fmt.Errorf("parsing error: %w", err)
-	}
+			// Повторное нажатие скрывает отсутствующие поля
+			if protobuf.HasPlaceholders(currentTree) {
+				protobuf.ClearPlaceholders(currentTree)
+				state.TreeWidget().Refresh()
+				return
+			}
 
-	*currentTree = tree
+			var schemaPath, schemaMessageName string
+			if browserTabs != nil {
+				schemaPath, schemaMessageName = browserTabs.GetTabSchema(tab)
+			}
+			if schemaPath == "" {
+				dialog.ShowInformation("Unset fields", "Apply a schema to show fields that are absent from the message", parentWindow)
+				return
+			}
 
-	adapter := newProtoTreeAdapter(tree)
-	adapter.SetWindow(parentWindow)
+			if err := parser.AddSchemaPlaceholders(currentTree, schemaPath, schemaMessageName); err != nil {
+				dialog.ShowError(fmt.Errorf("error reading schema: %w", err), parentWindow)
+				return
+			}
+			state.TreeWidget().Refresh()
+		}
+		toolbarMgr.SetShowUnsetCallback(showUnsetCallback)
 
-	newTreeWidget := widget.NewTree(adapter.ChildUIDs, adapter.IsBranch, adapter.CreateNode, adapter.UpdateNode)
-	adapter.SetTreeWidget(newTreeWidget)
+		editSchemaCallback = func() {
+			if state.Tree() == nil {
+				dialog.ShowInformation("Information", "Please open a proto file first", parentWindow)
+				return
+			}
 
-	rootChildren := adapter.ChildUIDs("")
+			var schemaPath, schemaMessageName string
+			if browserTabs != nil {
+				schemaPath, schemaMessageName = browserTabs.GetTabSchema(tab)
+			}
 
-	if len(rootChildren) > 0 {
-		newTreeWidget.OpenBranch("")
-	}
+			// showSchemaEditor показывает панель редактора модели рядом с деревом. Модель
+			// применяется к копии дерева в фоне после каждой правки
+			showSchemaEditor := func(model *protobuf.SchemaModel) {
+				var editor *schemaEditor
+				// applied - номер последнего применения модели: результат более раннего
+				// применения, закончившегося позже, отбрасывается
+				var applied atomic.Int64
+				// showTreeWithEditor показывает дерево рядом с панелью редактора схемы
+				showTreeWithEditor := func(tree *protobuf.TreeNode, report *protobuf.SchemaReport) {
+					adapter := newProtoTreeAdapter(tree)
+					adapter.SetWindow(parentWindow)
+					adapter.SetSchemaReport(report)
+					adapter.SetChangeCallback(tabChangeCallback(browserTabs, tab))
+					newTreeWidget := widget.NewTree(adapter.ChildUIDs, adapter.IsBranch, adapter.CreateNode, adapter.UpdateNode)
+					adapter.SetTreeWidget(newTreeWidget)
+					newTreeWidget.OpenBranch("root")
+					newScrollContainer := container.NewScroll(newTreeWidget)
+					state.SetTree(tree, adapter, newTreeWidget, newScrollContainer)
 
-	newTreeWidget.Refresh()
+					treeContent := tabViewContent(browserTabs, tab, tree, report, parentWindow, container.NewPadded(newScrollContainer))
+					split := container.NewHSplit(wrapWithSchemaReport(treeContent, report), editor.content)
+					split.Offset = 0.6
+					if browserTabs != nil {
+						browserTabs.SetTabReport(tab, report)
+						browserTabs.UpdateTabContent(tab, container.NewPadded(split))
+					}
+				}
 
-	*treeWidget = newTreeWidget
+				applyModel := func() error {
+					if err := model.Validate(); err != nil {
+						return err
+					}
+					// Модель меняется в редакторе, поэтому в фон передается ее текст
+					schemaSource := model.Render()
+					root := model.Root
+					source := state.Tree().Clone()
+					generation := applied.Add(1)
+					apply := func(ctx context.Context, progress protobuf.ProgressFunc) (func(), error) {
+						tree, report, err := parser.ApplySchemaSource(source, schemaSource, root)
+						if err != nil {
+							return nil, err
+						}
+						return func() {
+							if applied.Load() != generation {
+								return
+							}
+							showTreeWithEditor(tree, report)
+							editor.setStatus("Schema applied")
+						}, nil
+					}
+					runInBackground(browserTabs, tab, "Applying schema", apply, func(err error) {
+						editor.setStatus(err.Error())
+						dialog.ShowError(fmt.Errorf("error applying schema: %w", err), parentWindow)
+					})
+					return nil
+				}
 
-	newScrollContainer := container.NewScroll(newTreeWidget)
-	newScrollContainer.Refresh()
-	*treeScrollContainer = newScrollContainer
+				saveModel := func() {
+					saveDialog := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+						if err != nil {
+							dialog.ShowError(err, parentWindow)
+							return
+						}
+						if writer == nil {
+							return
+						}
+						writer.Close()
 
-	return nil
-}
+						dialogState.setLastSchemaDir(writer.URI())
 
-func formatTree(node *protobuf.TreeNode, indent int) string {
-	if node == nil {
-		return "(empty tree)\n"
-	}
+						savedPath := writer.URI().Path()
+						if err := model.Save(savedPath); err != nil {
+							dialog.ShowError(err, parentWindow)
+							return
+						}
+						// Вкладка привязывается к сохраненной схеме
+						if browserTabs != nil {
+							browserTabs.SetTabSchema(tab, savedPath, model.Root)
+						}
+						log.Printf("Schema saved: %s", savedPath)
+					}, parentWindow)
+					saveDialog.SetFileName(strings.ToLower(model.Root) + ".proto")
+					if lastDir := dialogState.getLastSchemaDir(); lastDir != nil {
+						saveDialog.SetLocation(lastDir)
+					}
+					saveDialog.Resize(dialogState.getDialogSize())
+					saveDialog.Show()
+				}
 
-	indentStr := ""
-	for i := 0; i < indent; i++ {
-		indentStr += "  "
-	}
+				editor = newSchemaEditor(model, parentWindow, applyModel, saveModel)
+				if err := applyModel(); err != nil {
+					dialog.ShowError(fmt.Errorf("error applying schema: %w", err), parentWindow)
+				}
+			}
 
-	result := ""
-	if node.Name == "root" {
-		result += "Proto Root\n"
-		result += strings.Repeat("=", 50) + "\n"
-		if len(node.Children) == 0 {
-			result += "(no data)\n"
-			return result
-		}
-	} else {
-		result += fmt.Sprintf("%s%s (field_%d, %s)", indentStr, node.Name, node.FieldNum, node.Type)
-		if node.Value != nil {
-			result += fmt.Sprintf(": %v", node.Value)
+			// Построение модели раскрывает ленивые узлы, поэтому идет в фоне по копии дерева
+			source := state.Tree().Clone()
+			load := func(ctx context.Context, progress protobuf.ProgressFunc) (func(), error) {
+				model, err := parser.SchemaModelForTree(source, schemaPath, schemaMessageName)
+				if err != nil {
+					return nil, fmt.Errorf("error reading schema: %w", err)
+				}
+				return func() {
+					showSchemaEditor(model)
+				}, nil
+			}
+			runInBackground(browserTabs, tab, "Reading schema", load, func(err error) {
+				dialog.ShowError(err, parentWindow)
+			})
 		}
-		if node.IsRepeated {
-			result += " [repeated]"
+		toolbarMgr.SetEditSchemaCallback(editSchemaCallback)
+
+		mergeCallback = func() {
+			var schemaPath, schemaMessageName string
+			if browserTabs != nil {
+				schemaPath, schemaMessageName = browserTabs.GetTabSchema(tab)
+			}
+			showMergeDialog(parentWindow, browserTabs, parser, state.FilePath(), schemaPath, schemaMessageName)
 		}
-		if len(node.Children) > 0 {
-			result += fmt.Sprintf(" [%d children]", len(node.Children))
+		toolbarMgr.SetMergeCallback(mergeCallback)
+
+		compatCallback = func() {
+			var schemaPath, schemaMessageName string
+			if browserTabs != nil {
+				schemaPath, schemaMessageName = browserTabs.GetTabSchema(tab)
+			}
+			showCompatDialog(parentWindow, browserTabs, parser, schemaPath, schemaMessageName)
 		}
-		result += "\n"
-	}
+		toolbarMgr.SetCompatCallback(compatCallback)
 
-	for _, child := range node.Children {
-		result += formatTree(child, indent+1)
-	}
+		rulesCallback = func() {
+			if state.Tree() == nil {
+				dialog.ShowInformation("Information", "Please open a proto file first", parentWindow)
+				return
+			}
 
-	return result
-}
+			var schemaPath, schemaMessageName string
+			if browserTabs != nil {
+				schemaPath, schemaMessageName = browserTabs.GetTabSchema(tab)
+			}
+			if schemaPath == "" {
+				dialog.ShowInformation("Rules", "Apply a schema before loading constraint rules", parentWindow)
+				return
+			}
 
-type tableRow struct {
-	FieldName string
-	FieldNum  int
-	Type      string
-	Value     string
-	Children  int
-}
+			fileDialog := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+				if err != nil {
+					dialog.ShowError(err, parentWindow)
+					return
+				}
+				if reader == nil {
+					return
+				}
+				defer reader.Close()
+
+				rulesPath := reader.URI().Path()
+				if _, err := protobuf.LoadConstraintRules(rulesPath); err != nil {
+					dialog.ShowError(err, parentWindow)
+					return
+				}
 
-func buildTableData(node *protobuf.TreeNode) []tableRow {
-	var rows []tableRow
+				// Правила проверяются при каждом применении схемы к вкладке, нарушения
+				// показываются в дереве и в панели отчета
+				browserTabs.SetTabRules(tab, rulesPath)
+				applySchemaTo(schemaPath, schemaMessageName)
+			}, parentWindow)
 
-	var traverse func(*protobuf.TreeNode, int)
-	traverse = func(n *protobuf.TreeNode, level int) {
-		if n == nil {
-			return
+			fileDialog.SetFilter(storage.NewExtensionFileFilter([]string{".json"}))
+			if lastDir := dialogState.getLastSchemaDir(); lastDir != nil {
+				fileDialog.SetLocation(lastDir)
+			}
+			fileDialog.Resize(dialogState.getDialogSize())
+			fileDialog.Show()
 		}
+		toolbarMgr.SetRulesCallback(rulesCallback)
 
-		if n.Name != "root" {
-			valueStr := ""
-			if n.Value != nil {
-				valueStr = fmt.Sprintf("%v", n.Value)
-			}
-			if n.IsRepeated {
-				valueStr += " [repeated]"
+		viewModeCallback = func() {
+			currentTree := state.Tree()
+			if currentTree == nil || browserTabs == nil {
+				dialog.ShowInformation("Information", "Please open a proto file first", parentWindow)
+				return
 			}
 
-			rows = append(rows, tableRow{
-				FieldName: n.Name,
-				FieldNum:  n.FieldNum,
-				Type:      n.Type,
-				Value:     valueStr,
-				Children:  len(n.Children),
-			})
+			browserTabs.SetTabViewMode(tab, nextViewMode(browserTabs.GetTabViewMode(tab)))
+
+			// Дерево переиспользуется, чтобы сохранить раскрытые ветки; правки из таблицы
+			// и текста видны в нем после обновления
+			state.TreeWidget().Refresh()
+			report := browserTabs.GetTabReport(tab)
+			content := tabViewContent(browserTabs, tab, currentTree, report, parentWindow, container.NewPadded(state.Scroll()))
+			browserTabs.UpdateTabContent(tab, container.NewPadded(wrapWithSchemaReport(content, report)))
 		}
+		toolbarMgr.SetViewModeCallback(viewModeCallback)
 
-		for _, child := range n.Children {
-			traverse(child, level+1)
+		if browserTabs != nil {
+			callbacks := &toolbarCallbacks{
+				openCallback:         openCallback,
+				saveCallback:         saveCallback,
+				applySchemaCallback:  applySchemaCallback,
+				exportSchemaCallback: exportSchemaCallback,
+				exportJSONCallback:   exportJSONCallback,
+				compareCallback:      compareCallback,
+				mergeCallback:        mergeCallback,
+				newMessageCallback:   newMessageCallback,
+				showUnsetCallback:    showUnsetCallback,
+				inferSchemaCallback:  inferSchemaCallback,
+				editSchemaCallback:   editSchemaCallback,
+				compatCallback:       compatCallback,
+				rulesCallback:        rulesCallback,
+				viewModeCallback:     viewModeCallback,
+				detectTypeCallback:   detectTypeCallback,
+			}
+			browserTabs.SetTabToolbarCallbacks(tab, callbacks)
+		}
+	}
+
+	if filePath != "" {
+		dialogState.setLastOpenDir(storage.NewFileURI(filePath))
+		// Прогресс декодирования показывается поверх пустого дерева, оно же остается во
+		// вкладке при ошибке
+		if browserTabs != nil {
+			browserTabs.UpdateTabContent(tab, container.NewPadded(state.Scroll()))
+		}
+		read := func() ([]byte, error) {
+			return os.ReadFile(filePath)
 		}
+		loadFile(filePath, read, schemaPath, schemaMessageName, nil, func(err error) {
+			log.Printf("Failed to load file %s: %v", filePath, err)
+		})
 	}
 
-	traverse(node, 0)
-	return rows
+	return container.NewPadded(state.Scroll())
 }
 
-func createTableWidget(data []tableRow) fyne.CanvasObject {
-	if len(data) == 0 {
-		return widget.NewLabel("(no data)")
+// showMessageSelectDialog вызывает onSelect с выбранным сообщением схемы. Если сообщение одно,
+// оно используется без диалога.
+func showMessageSelectDialog(messageNames []string, prompt string, confirmText string, parentWindow fyne.Window, onSelect func(string)) {
+	// Если сообщение одно, используем его автоматически
+	if len(messageNames) == 1 {
+		log.Printf("Only one message found in schema, using: %s", messageNames[0])
+		onSelect(messageNames[0])
+		return
 	}
 
-	headerName := widget.NewLabel("Field Name")
-	headerName.TextStyle = fyne.TextStyle{Bold: true}
-	headerNum := widget.NewLabel("Field #")
-	headerNum.TextStyle = fyne.TextStyle{Bold: true}
-	headerType := widget.NewLabel("Type")
-	headerType.TextStyle = fyne.TextStyle{Bold: true}
-	headerValue := widget.NewLabel("Value")
-	headerValue.TextStyle = fyne.TextStyle{Bold: true}
-	headerChildren := widget.NewLabel("Children")
-	headerChildren.TextStyle = fyne.TextStyle{Bold: true}
-
-	header := container.NewGridWithColumns(5,
-		headerName,
-		headerNum,
-		headerType,
-		headerValue,
-		headerChildren,
+	// Если сообщений несколько, показываем диалог выбора
+	selectedMessageName := messageNames[0] // Значение по умолчанию
+
+	selectWidget := widget.NewSelect(messageNames, func(selected string) {
+		selectedMessageName = selected
+	})
+	selectWidget.SetSelected(messageNames[0])
+
+	content := container.NewVBox(
+		widget.NewLabel(prompt),
+		selectWidget,
 	)
 
-	rows := make([]fyne.CanvasObject, 0, len(data))
-	for _, row := range data {
-		rowContainer := container.NewGridWithColumns(5,
-			widget.NewLabel(row.FieldName),
-			widget.NewLabel(fmt.Sprintf("%d", row.FieldNum)),
-			widget.NewLabel(row.Type),
-			widget.NewLabel(row.Value),
-			widget.NewLabel(fmt.Sprintf("%d", row.Children)),
-		)
-		rows = append(rows, rowContainer)
+	confirmDialog := dialog.NewCustomConfirm(
+		"Выбор сообщения",
+		confirmText,
+		"Отмена",
+		content,
+		func(confirmed bool) {
+			if !confirmed {
+				return
+			}
+
+			onSelect(selectedMessageName)
+		},
+		parentWindow,
+	)
+
+	confirmDialog.Resize(fyne.NewSize(400, 150))
+	confirmDialog.Show()
+}
+
+// tabChangeCallback возвращает функцию, которая отмечает вкладку tab как измененную.
+// Передается в редакторы дерева, таблицы и текста
+func tabChangeCallback(browserTabs *tabManager, tab *tabData) func() {
+	if browserTabs == nil {
+		return nil
 	}
+	return func() {
+		browserTabs.SetTabModified(tab, true)
+	}
+}
 
-	content := container.NewVBox(header)
-	for _, row := range rows {
-		content.Add(row)
+// tabConstraintRules загружает файл бизнес-правил, выбранный для вкладки tab
+func tabConstraintRules(browserTabs *tabManager, tab *tabData) protobuf.ConstraintRules {
+	if browserTabs == nil || browserTabs.GetTabRules(tab) == "" {
+		return nil
+	}
+	rules, err := protobuf.LoadConstraintRules(browserTabs.GetTabRules(tab))
+	if err != nil {
+		log.Printf("Failed to load rules: %v", err)
+		return nil
 	}
+	return rules
+}
 
-	return content
+// decodeData декодирует содержимое файла. Большие файлы разбираются лениво, без protoc:
+// вложенные сообщения декодируются при раскрытии в дереве. Заголовок Confluent wire format
+// отделяется от сообщения и сохраняется в корне дерева
+func decodeData(ctx context.Context, parser *protobuf.Parser, data []byte, progress protobuf.ProgressFunc) (*protobuf.TreeNode, error) {
+	header, data, err := protobuf.SplitConfluentHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	if header != nil {
+		log.Printf("Confluent wire header: %s", header)
+	}
+
+	var tree *protobuf.TreeNode
+	if len(data) >= protobuf.LazyDecodeThreshold {
+		log.Printf("Decoding %d bytes lazily", len(data))
+		tree, err = protobuf.DecodeLazy(ctx, data, progress)
+	} else {
+		tree, err = parser.ParseRawContext(ctx, data, progress)
+	}
+	if err != nil {
+		return nil, err
+	}
+	tree.Framing = header
+	return tree, nil
 }
@@ -0,0 +1,122 @@
+package ui
+
+import (
+	"sync"
+
+	"prospect/internal/protobuf"
+
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+// protoViewState - дерево, файл и виджеты представления protoViewWithFile. Результаты
+// фоновых задач применяются из их горутин, а обработчики кнопок читают состояние из
+// горутины Fyne, поэтому доступ к нему идет под мьютексом
+type protoViewState struct {
+	mu         sync.Mutex
+	tree       *protobuf.TreeNode
+	filePath   string
+	adapter    *protoTreeAdapter
+	treeWidget *widget.Tree
+	scroll     *container.Scroll
+
+	// Схема, выбранная пока файл декодируется, применяется после декодирования
+	loading            bool
+	pendingSchemaPath  string
+	pendingMessageName string
+}
+
+func newProtoViewState(filePath string) *protoViewState {
+	treeWidget := createProtoTree(nil)
+	return &protoViewState{
+		filePath:   filePath,
+		treeWidget: treeWidget,
+		scroll:     container.NewScroll(treeWidget),
+	}
+}
+
+// Tree возвращает дерево представления или nil, если файл еще не открыт
+func (s *protoViewState) Tree() *protobuf.TreeNode {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tree
+}
+
+// FilePath возвращает путь файла представления; у нового сообщения он пустой
+func (s *protoViewState) FilePath() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.filePath
+}
+
+func (s *protoViewState) SetFilePath(filePath string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.filePath = filePath
+}
+
+// Adapter возвращает адаптер виджета дерева или nil, если файл еще не открыт
+func (s *protoViewState) Adapter() *protoTreeAdapter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.adapter
+}
+
+// TreeWidget возвращает виджет, который показывает дерево
+func (s *protoViewState) TreeWidget() *widget.Tree {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.treeWidget
+}
+
+// Scroll возвращает прокрутку с виджетом дерева
+func (s *protoViewState) Scroll() *container.Scroll {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.scroll
+}
+
+// SetTree заменяет дерево и его виджеты одним шагом, чтобы обработчики не увидели новое
+// дерево со старым виджетом
+func (s *protoViewState) SetTree(tree *protobuf.TreeNode, adapter *protoTreeAdapter, treeWidget *widget.Tree, scroll *container.Scroll) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tree = tree
+	s.adapter = adapter
+	s.treeWidget = treeWidget
+	s.scroll = scroll
+}
+
+// StartLoading отмечает, что файл декодируется, и забывает схему, отложенную для прежнего
+// файла
+func (s *protoViewState) StartLoading() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loading = true
+	s.pendingSchemaPath = ""
+	s.pendingMessageName = ""
+}
+
+// DeferSchema откладывает применение схемы до конца декодирования. Возвращает false, если
+// файл не декодируется
+func (s *protoViewState) DeferSchema(schemaPath string, messageName string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.loading {
+		return false
+	}
+	s.pendingSchemaPath = schemaPath
+	s.pendingMessageName = messageName
+	return true
+}
+
+// FinishLoading снимает отметку декодирования и возвращает отложенную схему
+func (s *protoViewState) FinishLoading() (schemaPath string, messageName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	schemaPath, messageName = s.pendingSchemaPath, s.pendingMessageName
+	s.loading = false
+	s.pendingSchemaPath = ""
+	s.pendingMessageName = ""
+	return schemaPath, messageName
+}
@@ -0,0 +1,59 @@
+package ui
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"prospect/internal/protobuf"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/test"
+)
+
+func TestProtoView_AppliesSchemaChosenWhileDecoding(t *testing.T) {
+	useTempConfigDir(t)
+
+	fyneApp := test.NewApp()
+	defer fyneApp.Quit()
+	window := fyneApp.NewWindow("test")
+
+	dir := t.TempDir()
+	schemaPath := filepath.Join(dir, "blob.proto")
+	schema := "syntax = \"proto2\";\nmessage Blob {\n  optional int64 id = 1;\n  optional bytes data = 2;\n}\n"
+	if err := os.WriteFile(schemaPath, []byte(schema), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Файл больше порога ленивого декодирования разбирается без protoc
+	payload := []byte{0x08, 0x01, 0x12, 0x80, 0x80, 0x80, 0x10}
+	payload = append(payload, make([]byte, protobuf.LazyDecodeThreshold)...)
+	path := filepath.Join(dir, "blob.bin")
+	if err := os.WriteFile(path, payload, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	browserTabs := newTabManager()
+	tab := addViewTab(browserTabs, "blob.bin", path, func() fyne.CanvasObject {
+		return protoViewWithFile(fyneApp, window, browserTabs, path, "", "")
+	})
+	// Схема выбрана сразу после открытия, пока файл, скорее всего, еще декодируется
+	if !browserTabs.ApplySchemaToCurrentTab(schemaPath, "Blob") {
+		t.Fatal("Expected the tab to accept a schema")
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		if appliedPath, messageName := browserTabs.GetTabSchema(tab); appliedPath == schemaPath && messageName == "Blob" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the schema chosen while decoding to be applied to the decoded tree")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := browserTabs.GetTabFilePath(tab); got != path {
+		t.Errorf("Expected the tab to keep file %s, got %s", path, got)
+	}
+}
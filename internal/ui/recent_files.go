@@ -0,0 +1,129 @@
+package ui
+
+import (
+	"log"
+	"path/filepath"
+
+	"fyne.io/fyne/v2"
+)
+
+// maxRecentItems - длина списков недавних файлов и схем
+const maxRecentItems = 10
+
+// maxSchemaAssociations ограничивает число запомненных привязок схем к файлам
+const maxSchemaAssociations = 200
+
+// schemaAssociation связывает бинарные файлы со схемой и сообщением. Pattern - путь файла
+// или шаблон filepath.Match, например /data/orders/*.bin для соседних файлов того же сервиса
+type schemaAssociation struct {
+	Pattern     string `json:"pattern"`
+	SchemaPath  string `json:"schemaPath"`
+	MessageName string `json:"messageName"`
+}
+
+// addRecent переносит item в начало списка и обрезает список до maxRecentItems
+func addRecent(list []string, item string) []string {
+	result := []string{item}
+	for _, existing := range list {
+		if existing != item && len(result) < maxRecentItems {
+			result = append(result, existing)
+		}
+	}
+	return result
+}
+
+// siblingPattern возвращает шаблон для файлов с тем же расширением в том же каталоге
+func siblingPattern(filePath string) string {
+	return filepath.Join(filepath.Dir(filePath), "*"+filepath.Ext(filePath))
+}
+
+// rememberSchema запоминает схему для файла и для соседних файлов с тем же расширением.
+// Новые привязки вытесняют прежние с тем же шаблоном
+func (s *appState) rememberSchema(filePath string, schemaPath string, messageName string) {
+	associations := []schemaAssociation{
+		{Pattern: filePath, SchemaPath: schemaPath, MessageName: messageName},
+		{Pattern: siblingPattern(filePath), SchemaPath: schemaPath, MessageName: messageName},
+	}
+	for _, existing := range s.SchemaAssociations {
+		if existing.Pattern == associations[0].Pattern || existing.Pattern == associations[1].Pattern {
+			continue
+		}
+		if len(associations) < maxSchemaAssociations {
+			associations = append(associations, existing)
+		}
+	}
+	s.SchemaAssociations = associations
+}
+
+// schemaFor возвращает схему, запомненную для файла. Привязка к самому файлу важнее шаблонов,
+// среди шаблонов выигрывает применявшийся последним
+func (s *appState) schemaFor(filePath string) (schemaAssociation, bool) {
+	for _, association := range s.SchemaAssociations {
+		if association.Pattern == filePath {
+			return association, true
+		}
+	}
+	for _, association := range s.SchemaAssociations {
+		if matched, err := filepath.Match(association.Pattern, filePath); err == nil && matched {
+			return association, true
+		}
+	}
+	return schemaAssociation{}, false
+}
+
+// rememberFile добавляет файл в недавние
+func rememberFile(browserTabs *tabManager, filePath string) {
+	if filePath == "" {
+		return
+	}
+	updateHistory(browserTabs, func(state *appState) {
+		state.RecentFiles = addRecent(state.RecentFiles, filePath)
+	})
+}
+
+// rememberSchema добавляет схему в недавние и привязывает ее к файлу вкладки
+func rememberSchema(browserTabs *tabManager, filePath string, schemaPath string, messageName string) {
+	updateHistory(browserTabs, func(state *appState) {
+		state.RecentSchemas = addRecent(state.RecentSchemas, schemaPath)
+		if filePath != "" {
+			state.rememberSchema(filePath, schemaPath, messageName)
+		}
+	})
+}
+
+// associatedSchema возвращает схему и сообщение, которые применялись к файлу или соседним файлам
+func associatedSchema(filePath string) (string, string, bool) {
+	state, err := readAppState()
+	if err != nil || filePath == "" {
+		return "", "", false
+	}
+	association, ok := state.schemaFor(filePath)
+	return association.SchemaPath, association.MessageName, ok
+}
+
+func updateHistory(browserTabs *tabManager, update func(state *appState)) {
+	if err := updateAppState(update); err != nil {
+		log.Printf("Failed to save history: %v", err)
+		return
+	}
+	if browserTabs != nil && browserTabs.historyCallback != nil {
+		browserTabs.historyCallback()
+	}
+}
+
+// recentMenu создает подменю недавних файлов или схем
+func recentMenu(items []string, onSelect func(path string)) *fyne.Menu {
+	menu := fyne.NewMenu("")
+	for _, item := range items {
+		path := item
+		menu.Items = append(menu.Items, fyne.NewMenuItem(path, func() {
+			onSelect(path)
+		}))
+	}
+	if len(menu.Items) == 0 {
+		empty := fyne.NewMenuItem("No recent items", nil)
+		empty.Disabled = true
+		menu.Items = append(menu.Items, empty)
+	}
+	return menu
+}
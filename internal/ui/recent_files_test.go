@@ -0,0 +1,51 @@
+package ui
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRecentFilesAndSchemaAssociations(t *testing.T) {
+	useTempConfigDir(t)
+
+	recent := []string{"/a.bin", "/b.bin"}
+	for i := 0; i < maxRecentItems; i++ {
+		recent = addRecent(recent, fmt.Sprintf("/%d.bin", i))
+	}
+	recent = addRecent(recent, "/3.bin")
+	if len(recent) != maxRecentItems || recent[0] != "/3.bin" || recent[1] != "/9.bin" {
+		t.Errorf("Expected most recent first without duplicates, got %v", recent)
+	}
+
+	browserTabs := newTabManager()
+	rememberSchema(browserTabs, "/data/orders/order-1.bin", "/schemas/order.proto", "Order")
+	rememberSchema(browserTabs, "/data/orders/special.bin", "/schemas/special.proto", "Special")
+	rememberFile(browserTabs, "/data/orders/order-1.bin")
+
+	// Состояние вкладок сохраняется поверх истории, не стирая ее
+	if err := saveTabState(browserTabs); err != nil {
+		t.Fatalf("saveTabState failed: %v", err)
+	}
+	state, err := readAppState()
+	if err != nil {
+		t.Fatalf("readAppState failed: %v", err)
+	}
+	if len(state.RecentFiles) != 1 || len(state.RecentSchemas) != 2 || state.RecentSchemas[0] != "/schemas/special.proto" {
+		t.Errorf("Expected history to survive saving tabs, got %v %v", state.RecentFiles, state.RecentSchemas)
+	}
+
+	cases := map[string]string{
+		"/data/orders/order-1.bin": "Order",
+		"/data/orders/special.bin": "Special",
+		// Соседний файл получает схему, применявшуюся в каталоге последней
+		"/data/orders/order-2.bin": "Special",
+	}
+	for path, message := range cases {
+		if _, got, ok := associatedSchema(path); !ok || got != message {
+			t.Errorf("Expected %s for %s, got %q (%v)", message, path, got, ok)
+		}
+	}
+	if _, _, ok := associatedSchema("/data/users/user.bin"); ok {
+		t.Error("Expected no schema for a file from another directory")
+	}
+}
@@ -0,0 +1,307 @@
+package ui
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"prospect/internal/protobuf"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+var schemaScalarTypes = []string{"string", "bytes", "bool", "int32", "int64", "uint32", "uint64", "sint32", "sint64", "fixed32", "fixed64", "sfixed32", "sfixed64", "float", "double"}
+
+var schemaFieldLabels = []string{"optional", "required", "repeated"}
+
+// schemaEditor - панель редактирования модели схемы, привязанная к вкладке.
+// После каждой правки вызывается onChange, который запускает применение модели к дереву;
+// результат применения показывается через setStatus.
+type schemaEditor struct {
+	model    *protobuf.SchemaModel
+	window   fyne.Window
+	onChange func() error
+	onSave   func()
+
+	messageSelect *widget.Select
+	fieldsBox     *fyne.Container
+	statusLabel   *widget.Label
+	selected      *protobuf.SchemaMessage
+	content       fyne.CanvasObject
+}
+
+func newSchemaEditor(model *protobuf.SchemaModel, window fyne.Window, onChange func() error, onSave func()) *schemaEditor {
+	e := &schemaEditor{
+		model:       model,
+		window:      window,
+		onChange:    onChange,
+		onSave:      onSave,
+		fieldsBox:   container.NewVBox(),
+		statusLabel: widget.NewLabel(""),
+	}
+	e.statusLabel.Wrapping = fyne.TextWrapWord
+
+	e.messageSelect = widget.NewSelect(nil, func(fullName string) {
+		e.selected = e.model.FindMessageByFullName(fullName)
+		e.refreshFields()
+	})
+
+	renameBtn := widget.NewButton("Rename", e.showRenameMessageDialog)
+	addMessageBtn := widget.NewButton("New message", e.showAddMessageDialog)
+	addEnumBtn := widget.NewButton("New enum", e.showAddEnumDialog)
+	addFieldBtn := widget.NewButton("Add field", e.showAddFieldDialog)
+	saveBtn := widget.NewButton("Save .proto...", func() {
+		if err := e.model.Validate(); err != nil {
+			dialog.ShowError(err, e.window)
+			return
+		}
+		e.onSave()
+	})
+	saveBtn.Importance = widget.HighImportance
+
+	header := container.NewVBox(
+		widget.NewLabelWithStyle("Schema editor", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		container.NewBorder(nil, nil, widget.NewLabel("Message"), renameBtn, e.messageSelect),
+		container.NewHBox(addMessageBtn, addEnumBtn, addFieldBtn),
+		container.NewGridWithColumns(4,
+			widget.NewLabel("#"), widget.NewLabel("Name"), widget.NewLabel("Type"), widget.NewLabel("Label")),
+	)
+	footer := container.NewVBox(e.statusLabel, container.NewHBox(saveBtn))
+
+	e.content = container.NewBorder(header, footer, nil, nil, container.NewVScroll(e.fieldsBox))
+	e.refreshMessages(model.Root)
+	return e
+}
+
+func (e *schemaEditor) refreshMessages(selectName string) {
+	names := e.model.MessageNames()
+	e.messageSelect.Options = names
+	if selectName == "" && len(names) > 0 {
+		selectName = names[0]
+	}
+	for _, name := range names {
+		if name == selectName || strings.HasSuffix(name, "."+selectName) {
+			e.messageSelect.SetSelected(name)
+			return
+		}
+	}
+	e.messageSelect.Refresh()
+}
+
+func (e *schemaEditor) refreshFields() {
+	e.fieldsBox.Objects = nil
+	if e.selected == nil {
+		e.fieldsBox.Refresh()
+		return
+	}
+
+	typeOptions := append(append([]string{}, schemaScalarTypes...), e.model.TypeNames()...)
+	for _, field := range e.selected.Fields {
+		field := field
+
+		nameEntry := widget.NewEntry()
+		nameEntry.SetText(field.Name)
+		nameEntry.OnSubmitted = func(name string) {
+			oldName := field.Name
+			field.Name = name
+			if !e.commit() {
+				field.Name = oldName
+				nameEntry.SetText(oldName)
+			}
+		}
+
+		typeSelect := widget.NewSelect(typeOptions, nil)
+		typeSelect.SetSelected(field.Type)
+		typeSelect.OnChanged = func(fieldType string) {
+			oldType := field.Type
+			field.Type = fieldType
+			if !e.commit() {
+				field.Type = oldType
+			}
+		}
+
+		labelSelect := widget.NewSelect(schemaFieldLabels, nil)
+		labelSelect.SetSelected(field.Label)
+		if field.Oneof != "" {
+			labelSelect.Disable()
+		}
+		labelSelect.OnChanged = func(label string) {
+			oldLabel := field.Label
+			field.Label = label
+			if !e.commit() {
+				field.Label = oldLabel
+			}
+		}
+
+		e.fieldsBox.Add(container.NewGridWithColumns(4, widget.NewLabel(strconv.Itoa(field.Number)), nameEntry, typeSelect, labelSelect))
+	}
+	e.fieldsBox.Refresh()
+}
+
+// commit заново применяет модель и показывает результат в строке состояния
+func (e *schemaEditor) commit() bool {
+	if err := e.model.Validate(); err != nil {
+		e.statusLabel.SetText(err.Error())
+		return false
+	}
+	if err := e.onChange(); err != nil {
+		e.statusLabel.SetText(err.Error())
+		return false
+	}
+	e.statusLabel.SetText("Applying schema...")
+	return true
+}
+
+// setStatus показывает в строке состояния результат применения модели
+func (e *schemaEditor) setStatus(text string) {
+	e.statusLabel.SetText(text)
+}
+
+func (e *schemaEditor) showRenameMessageDialog() {
+	if e.selected == nil {
+		return
+	}
+
+	nameEntry := widget.NewEntry()
+	nameEntry.SetText(e.selected.Name)
+	dialog.ShowForm("Rename message", "Rename", "Cancel", []*widget.FormItem{
+		widget.NewFormItem("Name", nameEntry),
+	}, func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+
+		wasRoot := e.model.Root == e.selected.Name
+		if err := e.model.RenameMessage(e.selected, nameEntry.Text); err != nil {
+			dialog.ShowError(err, e.window)
+			return
+		}
+		if wasRoot {
+			e.model.Root = e.selected.Name
+		}
+		e.refreshMessages(e.selected.Name)
+		e.commit()
+	}, e.window)
+}
+
+func (e *schemaEditor) showAddMessageDialog() {
+	nameEntry := widget.NewEntry()
+	nestedCheck := widget.NewCheck("Nested in the selected message", nil)
+	dialog.ShowForm("New message", "Create", "Cancel", []*widget.FormItem{
+		widget.NewFormItem("Name", nameEntry),
+		widget.NewFormItem("", nestedCheck),
+	}, func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+
+		var parent *protobuf.SchemaMessage
+		if nestedCheck.Checked {
+			parent = e.selected
+		}
+		msg, err := e.model.AddMessage(parent, nameEntry.Text)
+		if err != nil {
+			dialog.ShowError(err, e.window)
+			return
+		}
+		e.refreshMessages(msg.Name)
+		e.commit()
+	}, e.window)
+}
+
+func (e *schemaEditor) showAddEnumDialog() {
+	nameEntry := widget.NewEntry()
+	valuesEntry := widget.NewEntry()
+	valuesEntry.SetPlaceHolder("UNKNOWN=0, ACTIVE=1")
+	nestedCheck := widget.NewCheck("Nested in the selected message", nil)
+	dialog.ShowForm("New enum", "Create", "Cancel", []*widget.FormItem{
+		widget.NewFormItem("Name", nameEntry),
+		widget.NewFormItem("Values", valuesEntry),
+		widget.NewFormItem("", nestedCheck),
+	}, func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+
+		values, err := parseEnumValues(valuesEntry.Text)
+		if err != nil {
+			dialog.ShowError(err, e.window)
+			return
+		}
+
+		var parent *protobuf.SchemaMessage
+		if nestedCheck.Checked {
+			parent = e.selected
+		}
+		if _, err := e.model.AddEnum(parent, nameEntry.Text, values); err != nil {
+			dialog.ShowError(err, e.window)
+			return
+		}
+		e.refreshFields()
+		e.commit()
+	}, e.window)
+}
+
+func (e *schemaEditor) showAddFieldDialog() {
+	if e.selected == nil {
+		return
+	}
+
+	numberEntry := widget.NewEntry()
+	nameEntry := widget.NewEntry()
+	typeSelect := widget.NewSelect(append(append([]string{}, schemaScalarTypes...), e.model.TypeNames()...), nil)
+	typeSelect.SetSelected("string")
+	labelSelect := widget.NewSelect(schemaFieldLabels, nil)
+	labelSelect.SetSelected("optional")
+
+	dialog.ShowForm("Add field", "Add", "Cancel", []*widget.FormItem{
+		widget.NewFormItem("Number", numberEntry),
+		widget.NewFormItem("Name", nameEntry),
+		widget.NewFormItem("Type", typeSelect),
+		widget.NewFormItem("Label", labelSelect),
+	}, func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+
+		number, err := strconv.Atoi(strings.TrimSpace(numberEntry.Text))
+		if err != nil || number <= 0 {
+			dialog.ShowError(fmt.Errorf("invalid field number %q", numberEntry.Text), e.window)
+			return
+		}
+		field := &protobuf.SchemaField{Name: nameEntry.Text, Type: typeSelect.Selected, Label: labelSelect.Selected, Number: number}
+		if err := e.selected.AddField(field); err != nil {
+			dialog.ShowError(err, e.window)
+			return
+		}
+		if !e.commit() {
+			e.selected.Fields = e.selected.Fields[:len(e.selected.Fields)-1]
+			return
+		}
+		e.refreshFields()
+	}, e.window)
+}
+
+// parseEnumValues разбирает значения перечисления в формате "A=0, B=1"
+func parseEnumValues(text string) ([]protobuf.SchemaEnumValue, error) {
+	values := make([]protobuf.SchemaEnumValue, 0)
+	for _, part := range strings.Split(text, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		nameAndNumber := strings.SplitN(part, "=", 2)
+		if len(nameAndNumber) != 2 {
+			return nil, fmt.Errorf("invalid enum value %q, expected NAME=number", part)
+		}
+		number, err := strconv.Atoi(strings.TrimSpace(nameAndNumber[1]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid enum value number in %q", part)
+		}
+		values = append(values, protobuf.SchemaEnumValue{Name: strings.TrimSpace(nameAndNumber[0]), Number: number})
+	}
+	return values, nil
+}
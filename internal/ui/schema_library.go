@@ -0,0 +1,167 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"prospect/internal/protobuf"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// maxTypeCandidates - сколько кандидатов показывает определение типа
+const maxTypeCandidates = 10
+
+// schemaLibraryIndex хранит проиндексированную библиотеку схем. Индексация идет в фоне, поэтому
+// доступ к библиотеке защищен мьютексом, а результат устаревшей индексации отбрасывается
+type schemaLibraryIndex struct {
+	mu      sync.Mutex
+	library *protobuf.SchemaLibrary
+	version int
+	// indexing отмечает, что библиотека еще загружается
+	indexing bool
+}
+
+// current возвращает библиотеку и признак того, что индексация еще не закончена
+func (i *schemaLibraryIndex) current() (*protobuf.SchemaLibrary, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.library, i.indexing
+}
+
+// indexSchemaLibrary загружает схемы из путей библиотеки в фоне. Ошибки отдельных схем
+// только пишутся в лог
+func indexSchemaLibrary(browserTabs *tabManager, paths []string) {
+	index := &browserTabs.schemaLibrary
+	index.mu.Lock()
+	index.version++
+	version := index.version
+	index.indexing = len(paths) > 0
+	if len(paths) == 0 {
+		index.library = nil
+	}
+	index.mu.Unlock()
+	if len(paths) == 0 {
+		return
+	}
+
+	go func() {
+		var library *protobuf.SchemaLibrary
+		parser, err := protobuf.NewParser()
+		if err == nil {
+			library, err = parser.LoadSchemaLibrary(context.Background(), paths)
+		}
+		if err != nil {
+			log.Printf("Failed to index schema library: %v", err)
+		} else {
+			for _, skipped := range library.Skipped {
+				log.Printf("Schema library skipped %s", skipped)
+			}
+			log.Printf("Schema library indexed: %d message(s)", library.Messages())
+		}
+
+		index.mu.Lock()
+		defer index.mu.Unlock()
+		if index.version == version {
+			index.library = library
+			index.indexing = false
+		}
+	}()
+}
+
+// showSchemaLibraryDialog позволяет изменить пути библиотеки схем. После сохранения библиотека
+// индексируется заново
+func showSchemaLibraryDialog(browserTabs *tabManager, parentWindow fyne.Window) {
+	state, err := readAppState()
+	if err != nil {
+		dialog.ShowError(err, parentWindow)
+		return
+	}
+
+	pathsEntry := widget.NewMultiLineEntry()
+	pathsEntry.SetPlaceHolder("/path/to/protos\n/path/to/descriptors.pb")
+	pathsEntry.SetText(strings.Join(state.SchemaLibrary, "\n"))
+	pathsEntry.SetMinRowsVisible(6)
+
+	addFolderButton := widget.NewButton("Add folder...", func() {
+		dialog.ShowFolderOpen(func(dir fyne.ListableURI, err error) {
+			if err != nil || dir == nil {
+				return
+			}
+			text := strings.TrimRight(pathsEntry.Text, "\n")
+			if text != "" {
+				text += "\n"
+			}
+			pathsEntry.SetText(text + dir.Path())
+		}, parentWindow)
+	})
+
+	content := container.NewBorder(
+		widget.NewLabel("Directories with .proto files and descriptor sets, one per line:"),
+		container.NewHBox(addFolderButton),
+		nil, nil,
+		pathsEntry,
+	)
+	libraryDialog := dialog.NewCustomConfirm("Schema library", "Save", "Cancel", content, func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+		paths := make([]string, 0)
+		for _, line := range strings.Split(pathsEntry.Text, "\n") {
+			if path := strings.TrimSpace(line); path != "" {
+				paths = append(paths, path)
+			}
+		}
+		if err := updateAppState(func(state *appState) {
+			state.SchemaLibrary = paths
+		}); err != nil {
+			dialog.ShowError(err, parentWindow)
+			return
+		}
+		indexSchemaLibrary(browserTabs, paths)
+	}, parentWindow)
+	libraryDialog.Resize(fyne.NewSize(600, 350))
+	libraryDialog.Show()
+}
+
+// showTypeCandidatesDialog показывает кандидатов определения типа и применяет выбранного
+func showTypeCandidatesDialog(candidates []protobuf.TypeCandidate, parentWindow fyne.Window, onSelect func(protobuf.TypeCandidate)) {
+	selected := 0
+	list := widget.NewList(
+		func() int { return len(candidates) },
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(id widget.ListItemID, item fyne.CanvasObject) {
+			item.(*widget.Label).SetText(formatTypeCandidate(candidates[id]))
+		},
+	)
+	list.OnSelected = func(id widget.ListItemID) {
+		selected = id
+	}
+	list.Select(0)
+
+	content := container.NewBorder(widget.NewLabel("Messages that best match the data:"), nil, nil, nil, list)
+	candidatesDialog := dialog.NewCustomConfirm("Detect type", "Apply", "Cancel", content, func(confirmed bool) {
+		if confirmed {
+			onSelect(candidates[selected])
+		}
+	}, parentWindow)
+	candidatesDialog.Resize(fyne.NewSize(600, 400))
+	candidatesDialog.Show()
+}
+
+// formatTypeCandidate возвращает строку вида "92%  pkg.Message (3/3 fields) - file.proto"
+func formatTypeCandidate(candidate protobuf.TypeCandidate) string {
+	name := candidate.MessageName
+	if candidate.Package != "" {
+		name = candidate.Package + "." + name
+	}
+	return fmt.Sprintf("%3.0f%%  %s (%d/%d fields) - %s",
+		candidate.Score*100, name, candidate.Matched, candidate.Total, filepath.Base(candidate.SchemaPath))
+}
@@ -0,0 +1,53 @@
+package ui
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"prospect/internal/protobuf"
+)
+
+func TestIndexSchemaLibrary_ReplacesLibraryInBackground(t *testing.T) {
+	dir := t.TempDir()
+	schema := "syntax = \"proto3\";\npackage people;\nmessage User {\n  string name = 1;\n  int64 id = 2;\n}\n"
+	if err := os.WriteFile(filepath.Join(dir, "user.proto"), []byte(schema), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	browserTabs := newTabManager()
+	indexSchemaLibrary(browserTabs, []string{dir})
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		library, indexing := browserTabs.schemaLibrary.current()
+		if !indexing {
+			if library == nil || library.Messages() != 1 {
+				t.Fatalf("Expected one indexed message, got %+v", library)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Schema library was not indexed in time")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	library, _ := browserTabs.schemaLibrary.current()
+	tree := &protobuf.TreeNode{Name: "root", Type: "message", Children: []*protobuf.TreeNode{
+		{Name: "field_1", Type: "string", FieldNum: 1, Value: "Ann"},
+		{Name: "field_2", Type: "int64", FieldNum: 2, Value: "7"},
+	}}
+	candidates := library.Detect(tree, maxTypeCandidates)
+	if len(candidates) != 1 {
+		t.Fatalf("Expected one candidate, got %+v", candidates)
+	}
+	if got := formatTypeCandidate(candidates[0]); got != "100%  people.User (2/2 fields) - user.proto" {
+		t.Errorf("Unexpected candidate label %q", got)
+	}
+
+	indexSchemaLibrary(browserTabs, nil)
+	if library, indexing := browserTabs.schemaLibrary.current(); library != nil || indexing {
+		t.Error("Expected an empty library list to clear the library")
+	}
+}
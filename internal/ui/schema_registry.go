@@ -0,0 +1,80 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"prospect/internal/protobuf"
+	"prospect/internal/registry"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// schemaRegistryURL возвращает адрес реестра схем из настроек или пустую строку
+func schemaRegistryURL() string {
+	state, err := readAppState()
+	if err != nil {
+		log.Printf("Failed to load settings: %v", err)
+		return ""
+	}
+	return state.RegistryURL
+}
+
+// registrySchema загружает (или берет из кэша) схему с ID из заголовка Confluent и возвращает
+// путь к ней и имя сообщения по индексам заголовка
+func registrySchema(ctx context.Context, parser *protobuf.Parser, registryURL string, header *protobuf.ConfluentHeader) (string, string, error) {
+	cacheDir, err := registry.DefaultCacheDir()
+	if err != nil {
+		return "", "", err
+	}
+	client, err := registry.NewClient(registryURL, cacheDir)
+	if err != nil {
+		return "", "", err
+	}
+	schemaPath, err := client.SchemaPath(ctx, header.SchemaID)
+	if err != nil {
+		return "", "", fmt.Errorf("schema %d: %w", header.SchemaID, err)
+	}
+	messageName, err := parser.MessageAtIndexes(schemaPath, header.MessageIndexes)
+	if err != nil {
+		return "", "", fmt.Errorf("schema %d: %w", header.SchemaID, err)
+	}
+	return schemaPath, messageName, nil
+}
+
+// showSchemaRegistryDialog задает адрес реестра, из которого загружаются схемы для данных
+// с заголовком Confluent. Пустой адрес отключает реестр
+func showSchemaRegistryDialog(parentWindow fyne.Window) {
+	urlEntry := widget.NewEntry()
+	urlEntry.SetPlaceHolder("http://localhost:8081")
+	urlEntry.SetText(schemaRegistryURL())
+
+	content := container.NewVBox(
+		widget.NewLabel("Schema registry URL (Confluent REST API), credentials may be included in the URL:"),
+		urlEntry,
+	)
+	registryDialog := dialog.NewCustomConfirm("Schema registry", "Save", "Cancel", content, func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+		registryURL := strings.TrimSpace(urlEntry.Text)
+		if registryURL != "" {
+			if _, err := registry.NewClient(registryURL, ""); err != nil {
+				dialog.ShowError(err, parentWindow)
+				return
+			}
+		}
+		if err := updateAppState(func(state *appState) {
+			state.RegistryURL = registryURL
+		}); err != nil {
+			dialog.ShowError(err, parentWindow)
+		}
+	}, parentWindow)
+	registryDialog.Resize(fyne.NewSize(500, 150))
+	registryDialog.Show()
+}
@@ -0,0 +1,106 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"prospect/internal/protobuf"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/test"
+)
+
+func TestRegistrySchema_ResolvesMessageFromConfluentHeader(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	schema := "syntax = \"proto3\";\nmessage Key {\n  string id = 1;\n}\nmessage Order {\n  message Line {\n    int64 sku = 1;\n  }\n}\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/schemas/ids/5" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `{"schemaType": "PROTOBUF", "schema": %q}`, schema)
+	}))
+	defer server.Close()
+
+	header, _, err := protobuf.SplitConfluentHeader([]byte{0, 0, 0, 0, 5, 4, 2, 0, 0x08, 0x01})
+	if err != nil {
+		t.Fatal(err)
+	}
+	schemaPath, messageName, err := registrySchema(context.Background(), &protobuf.Parser{}, server.URL, header)
+	if err != nil {
+		t.Fatalf("registrySchema failed: %v", err)
+	}
+	if messageName != "Order.Line" {
+		t.Errorf("Expected message [1, 0] to be Order.Line, got %s", messageName)
+	}
+	if _, err := os.Stat(schemaPath); err != nil {
+		t.Errorf("Expected the schema to be cached: %v", err)
+	}
+
+	header.SchemaID = 6
+	if _, _, err := registrySchema(context.Background(), &protobuf.Parser{}, server.URL, header); err == nil {
+		t.Error("Expected an error for an unknown schema ID")
+	}
+}
+
+func TestRegistrySchema_FetchesInBackgroundWhenTabOpens(t *testing.T) {
+	useTempConfigDir(t)
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	// Реестр отвечает только после того, как вкладка создана
+	requested := make(chan struct{}, 1)
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested <- struct{}{}
+		<-release
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+	defer close(release)
+	if err := updateAppState(func(state *appState) { state.RegistryURL = server.URL }); err != nil {
+		t.Fatal(err)
+	}
+
+	fyneApp := test.NewApp()
+	defer fyneApp.Quit()
+	window := fyneApp.NewWindow("test")
+
+	// Файл больше порога ленивого декодирования разбирается без protoc; вложенное
+	// сообщение декодируется только при раскрытии
+	payload := []byte{0x0a, 0x85, 0x80, 0x80, 0x10, 0x0a, 0x80, 0x80, 0x80, 0x10}
+	payload = append(payload, make([]byte, protobuf.LazyDecodeThreshold)...)
+	path := filepath.Join(t.TempDir(), "framed.bin")
+	if err := os.WriteFile(path, append([]byte{0, 0, 0, 0, 5, 0}, payload...), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	browserTabs := newTabManager()
+	opened := make(chan *tabData)
+	go func() {
+		opened <- addViewTab(browserTabs, "framed.bin", path, func() fyne.CanvasObject {
+			return protoViewWithFile(fyneApp, window, browserTabs, path, "", "")
+		})
+	}()
+	var tab *tabData
+	select {
+	case tab = <-opened:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the tab to open without waiting for the schema registry")
+	}
+	select {
+	case <-requested:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the schema to be requested from the registry")
+	}
+	if browserTabs.GetTabContent(tab) == nil {
+		t.Error("Expected the tab to show the decoded tree while the schema is fetched")
+	}
+}
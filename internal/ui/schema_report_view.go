@@ -0,0 +1,49 @@
+package ui
+
+import (
+	"fmt"
+
+	"prospect/internal/protobuf"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+const schemaReportListHeight = 150
+
+// newSchemaReportPanel показывает сводку расхождений данных со схемой и раскрываемый список деталей
+func newSchemaReportPanel(report *protobuf.SchemaReport) fyne.CanvasObject {
+	issues := report.Issues
+
+	list := widget.NewList(
+		func() int {
+			return len(issues)
+		},
+		func() fyne.CanvasObject {
+			return widget.NewLabel("")
+		},
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			label := obj.(*widget.Label)
+			issue := issues[id]
+			path := issue.Path
+			if path == "" {
+				path = "(root)"
+			}
+			label.SetText(fmt.Sprintf("%s: %s", path, issue.Message()))
+		},
+	)
+
+	listContainer := container.NewGridWrap(fyne.NewSize(800, schemaReportListHeight), list)
+	accordion := widget.NewAccordion(widget.NewAccordionItem(fmt.Sprintf("⚠ Schema report: %s", report.Summary()), listContainer))
+	return accordion
+}
+
+// wrapWithSchemaReport добавляет под деревом панель отчета, если при применении схемы
+// найдены расхождения
+func wrapWithSchemaReport(content fyne.CanvasObject, report *protobuf.SchemaReport) fyne.CanvasObject {
+	if !report.HasIssues() {
+		return content
+	}
+	return container.NewBorder(nil, newSchemaReportPanel(report), nil, nil, content)
+}
@@ -2,6 +2,11 @@ package ui
 
 import (
 	"log"
+	"os"
+	"sync"
+	"time"
+
+	"prospect/internal/protobuf"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/widget"
@@ -9,10 +14,22 @@ import (
 
 type tabManager struct {
 	widget.BaseWidget
+	// mu защищает tabs, selectedTab и поля вкладок: результаты фоновых операций и
+	// сохранение состояния обращаются к ним из горутин. Refresh вызывается без mu,
+	// потому что отрисовка сама читает вкладки
+	mu          sync.Mutex
 	tabs        []*tabData
 	selectedTab int
 	addCallback func()
 	toolbarMgr  *toolbarManager
+	// historyCallback вызывается после изменения списков недавних файлов и схем
+	historyCallback func()
+	// schemaLibrary - схемы для определения типа данных, общие для всех вкладок
+	schemaLibrary schemaLibraryIndex
+	// workspacePath - открытый файл рабочего пространства, пустой вне рабочего пространства
+	workspacePath string
+	// includePaths - каталоги импортов схем из рабочего пространства
+	includePaths []string
 }
 
 type tabData struct {
@@ -21,7 +38,25 @@ type tabData struct {
 	filePath          string
 	schemaPath        string
 	schemaMessageName string
-	toolbarCallbacks  *toolbarCallbacks
+	// rulesPath - файл бизнес-правил, проверяемых вместе со схемой
+	rulesPath string
+	// viewMode - режим отображения вкладки: дерево или таблица
+	viewMode string
+	// report - отчет последнего применения схемы, нужен при переключении режима отображения
+	report           *protobuf.SchemaReport
+	toolbarCallbacks *toolbarCallbacks
+	// transient вкладки (например, результат сравнения) не сохраняются между запусками
+	transient bool
+	// modified отмечает правки, сделанные после открытия или сохранения файла
+	modified bool
+	// modTime - время изменения файла, которое уже показано во вкладке
+	modTime time.Time
+	watcher *fileWatcher
+	// schemaHandler применяет схему из файла к дереву вкладки, например перетащенную в окно
+	schemaHandler func(schemaPath string, messageName string)
+	// expandedNodes и expandNodes возвращают и восстанавливают раскрытые ветки дерева вкладки
+	expandedNodes func() []string
+	expandNodes   func(paths []string)
 }
 
 type toolbarCallbacks struct {
@@ -30,6 +65,16 @@ type toolbarCallbacks struct {
 	applySchemaCallback  func()
 	exportSchemaCallback func()
 	exportJSONCallback   func()
+	compareCallback      func()
+	mergeCallback        func()
+	newMessageCallback   func()
+	showUnsetCallback    func()
+	inferSchemaCallback  func()
+	editSchemaCallback   func()
+	compatCallback       func()
+	rulesCallback        func()
+	viewModeCallback     func()
+	detectTypeCallback   func()
 }
 
 func newTabManager() *tabManager {
@@ -44,14 +89,14 @@ func newTabManager() *tabManager {
 
 func (tm *tabManager) AddTab(title string, content fyne.CanvasObject) {
 	tm.addTabWithoutSave(title, content)
-	go saveTabState(tm)
+	saveTabStateInBackground(tm)
 }
 
-func (tm *tabManager) addTabWithoutSave(title string, content fyne.CanvasObject) {
-	tm.addTabWithPathWithoutSave(title, content, "")
+func (tm *tabManager) addTabWithoutSave(title string, content fyne.CanvasObject) *tabData {
+	return tm.addTabWithPathWithoutSave(title, content, "")
 }
 
-func (tm *tabManager) addTabWithPathWithoutSave(title string, content fyne.CanvasObject, filePath string) {
+func (tm *tabManager) addTabWithPathWithoutSave(title string, content fyne.CanvasObject, filePath string) *tabData {
 	tabCounter++
 	if title == "" {
 		title = "Tab"
@@ -62,19 +107,39 @@ func (tm *tabManager) addTabWithPathWithoutSave(title string, content fyne.Canva
 		content:  content,
 		filePath: filePath,
 	}
+	tm.mu.Lock()
 	tm.tabs = append(tm.tabs, tab)
 	tm.selectedTab = len(tm.tabs) - 1
+	tm.mu.Unlock()
 	tm.Refresh()
 	log.Printf("Tab added: %s", title)
+	return tab
+}
+
+// AddTransientTab добавляет вкладку с результатом (сравнения, слияния, проверки
+// совместимости). У такой вкладки нет дерева, поэтому кнопки панели для нее недоступны
+func (tm *tabManager) AddTransientTab(title string, content fyne.CanvasObject) {
+	tab := tm.addTabWithoutSave(title, content)
+	tm.updateTab(tab, func() {
+		tab.transient = true
+		tab.toolbarCallbacks = &toolbarCallbacks{}
+	})
+	tm.selectTabWithoutSave(tm.tabCount() - 1)
+	saveTabStateInBackground(tm)
 }
 
 func (tm *tabManager) RemoveTab(index int) {
+	tm.mu.Lock()
 	if index < 0 || index >= len(tm.tabs) {
 		log.Printf("Error: attempt to remove non-existent tab: index %d, total tabs: %d", index, len(tm.tabs))
+		tm.mu.Unlock()
 		return
 	}
 
 	title := tm.tabs[index].title
+	if tm.tabs[index].watcher != nil {
+		tm.tabs[index].watcher.Close()
+	}
 
 	tm.tabs = append(tm.tabs[:index], tm.tabs[index+1:]...)
 
@@ -91,92 +156,347 @@ func (tm *tabManager) RemoveTab(index int) {
 			tm.selectedTab--
 		}
 	}
+	remaining := len(tm.tabs)
+	tm.mu.Unlock()
 
-	log.Printf("Tab '%s' removed, remaining tabs: %d", title, len(tm.tabs))
+	log.Printf("Tab '%s' removed, remaining tabs: %d", title, remaining)
 	tm.Refresh()
-	go saveTabState(tm)
+	saveTabStateInBackground(tm)
 }
 
 func (tm *tabManager) SelectTab(index int) {
 	tm.selectTabWithoutSave(index)
-	go saveTabState(tm)
+	saveTabStateInBackground(tm)
 }
 
 func (tm *tabManager) selectTabWithoutSave(index int) {
-	if index >= 0 && index < len(tm.tabs) {
-		tm.selectedTab = index
-		if tm.tabs[index].toolbarCallbacks != nil {
-			callbacks := tm.tabs[index].toolbarCallbacks
-			if callbacks.openCallback != nil {
-				tm.toolbarMgr.SetOpenCallback(callbacks.openCallback)
-			}
-			if callbacks.saveCallback != nil {
-				tm.toolbarMgr.SetSaveCallback(callbacks.saveCallback)
-			}
-			if callbacks.applySchemaCallback != nil {
-				tm.toolbarMgr.SetApplySchemaCallback(callbacks.applySchemaCallback)
-			}
-			if callbacks.exportSchemaCallback != nil {
-				tm.toolbarMgr.SetExportSchemaCallback(callbacks.exportSchemaCallback)
-			}
-		}
-		tm.Refresh()
+	tm.mu.Lock()
+	if index < 0 || index >= len(tm.tabs) {
+		tm.mu.Unlock()
+		return
+	}
+	tm.selectedTab = index
+	viewMode := tm.tabs[index].viewMode
+	callbacks := tm.tabs[index].toolbarCallbacks
+	tm.mu.Unlock()
+
+	tm.toolbarMgr.SetViewMode(viewMode)
+	if callbacks != nil {
+		tm.toolbarMgr.SetCallbacks(callbacks)
 	}
+	tm.Refresh()
 }
 
 func (tm *tabManager) SetAddButtonCallback(callback func()) {
 	tm.addCallback = callback
 }
 
-func (tm *tabManager) UpdateTabContent(content fyne.CanvasObject) {
-	if tm.selectedTab >= 0 && tm.selectedTab < len(tm.tabs) {
-		tm.tabs[tm.selectedTab].content = content
-		tm.Refresh()
-	} else {
-		log.Printf("Error: failed to update content: selectedTab=%d, len(tabs)=%d", tm.selectedTab, len(tm.tabs))
+func (tm *tabManager) SetHistoryCallback(callback func()) {
+	tm.historyCallback = callback
+}
+
+// indexOf возвращает индекс открытой вкладки tab или -1. Вызывается под mu
+func (tm *tabManager) indexOf(tab *tabData) int {
+	for i, t := range tm.tabs {
+		if t == tab {
+			return i
+		}
 	}
+	return -1
 }
 
-func (tm *tabManager) UpdateTabTitle(title string) {
-	if tm.selectedTab >= 0 && tm.selectedTab < len(tm.tabs) {
-		tm.tabs[tm.selectedTab].title = title
-		tm.Refresh()
-		go saveTabState(tm)
-	} else {
-		log.Printf("Error: failed to update title: selectedTab=%d, len(tabs)=%d", tm.selectedTab, len(tm.tabs))
+// updateTab выполняет fn под mu, если вкладка tab еще открыта. Возвращает false для
+// закрытой вкладки или nil
+func (tm *tabManager) updateTab(tab *tabData, fn func()) bool {
+	if tab == nil {
+		return false
+	}
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	if tm.indexOf(tab) < 0 {
+		return false
 	}
+	fn()
+	return true
 }
 
-func (tm *tabManager) SetTabFilePath(filePath string) {
-	if tm.selectedTab >= 0 && tm.selectedTab < len(tm.tabs) {
-		tm.tabs[tm.selectedTab].filePath = filePath
-		go saveTabState(tm)
+// readTab выполняет fn под mu для чтения полей вкладки tab; для nil ничего не делает
+func (tm *tabManager) readTab(tab *tabData, fn func()) {
+	if tab == nil {
+		return
 	}
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	fn()
 }
 
-func (tm *tabManager) SetTabSchema(schemaPath string, messageName string) {
-	if tm.selectedTab >= 0 && tm.selectedTab < len(tm.tabs) {
-		tm.tabs[tm.selectedTab].schemaPath = schemaPath
-		tm.tabs[tm.selectedTab].schemaMessageName = messageName
-		go saveTabState(tm)
+func (tm *tabManager) UpdateTabContent(tab *tabData, content fyne.CanvasObject) {
+	if !tm.updateTab(tab, func() { tab.content = content }) {
+		log.Printf("Error: failed to update content: tab is not open")
+		return
+	}
+	tm.Refresh()
+}
+
+func (tm *tabManager) GetTabContent(tab *tabData) fyne.CanvasObject {
+	var content fyne.CanvasObject
+	tm.readTab(tab, func() { content = tab.content })
+	return content
+}
+
+func (tm *tabManager) UpdateTabTitle(tab *tabData, title string) {
+	if !tm.updateTab(tab, func() { tab.title = title }) {
+		log.Printf("Error: failed to update title: tab is not open")
+		return
+	}
+	tm.Refresh()
+	saveTabStateInBackground(tm)
+}
+
+func (tm *tabManager) SetTabFilePath(tab *tabData, filePath string) {
+	if tm.updateTab(tab, func() { tab.filePath = filePath }) {
+		saveTabStateInBackground(tm)
+	}
+}
+
+func (tm *tabManager) GetTabFilePath(tab *tabData) string {
+	var filePath string
+	tm.readTab(tab, func() { filePath = tab.filePath })
+	return filePath
+}
+
+func (tm *tabManager) SetTabSchema(tab *tabData, schemaPath string, messageName string) {
+	if tm.updateTab(tab, func() {
+		tab.schemaPath = schemaPath
+		tab.schemaMessageName = messageName
+	}) {
+		saveTabStateInBackground(tm)
+	}
+}
+
+func (tm *tabManager) GetTabSchema(tab *tabData) (string, string) {
+	var schemaPath, messageName string
+	tm.readTab(tab, func() {
+		schemaPath, messageName = tab.schemaPath, tab.schemaMessageName
+	})
+	return schemaPath, messageName
+}
+
+func (tm *tabManager) SetTabRules(tab *tabData, rulesPath string) {
+	tm.updateTab(tab, func() { tab.rulesPath = rulesPath })
+}
+
+func (tm *tabManager) GetTabRules(tab *tabData) string {
+	var rulesPath string
+	tm.readTab(tab, func() { rulesPath = tab.rulesPath })
+	return rulesPath
+}
+
+// SetTabViewMode меняет режим отображения вкладки; кнопка панели переключается, только
+// если вкладка выбрана
+func (tm *tabManager) SetTabViewMode(tab *tabData, viewMode string) {
+	selected := false
+	tm.updateTab(tab, func() {
+		tab.viewMode = viewMode
+		selected = tm.indexOf(tab) == tm.selectedTab
+	})
+	if selected {
+		tm.toolbarMgr.SetViewMode(viewMode)
 	}
 }
 
-func (tm *tabManager) GetTabSchema() (string, string) {
+func (tm *tabManager) GetTabViewMode(tab *tabData) string {
+	viewMode := viewModeTree
+	tm.readTab(tab, func() { viewMode = tab.viewMode })
+	return viewMode
+}
+
+func (tm *tabManager) SetTabReport(tab *tabData, report *protobuf.SchemaReport) {
+	tm.updateTab(tab, func() { tab.report = report })
+}
+
+func (tm *tabManager) GetTabReport(tab *tabData) *protobuf.SchemaReport {
+	var report *protobuf.SchemaReport
+	tm.readTab(tab, func() { report = tab.report })
+	return report
+}
+
+func (tm *tabManager) SetTabModified(tab *tabData, modified bool) {
+	tm.updateTab(tab, func() { tab.modified = modified })
+}
+
+func (tm *tabManager) IsTabModified(tab *tabData) bool {
+	var modified bool
+	tm.readTab(tab, func() { modified = tab.modified })
+	return modified
+}
+
+// WatchTabFile следит за файлом вкладки tab вместо прежнего и вызывает onChange с этой
+// вкладкой, когда время изменения файла отличается от уже показанного. onChange вызывается
+// из горутины наблюдателя и только для открытой вкладки; перечитывание файла она передает
+// интерфейсу вкладки (например, через runInBackground). WatchTabFile вызывается после
+// каждого чтения и сохранения файла, чтобы собственная запись не считалась внешним изменением
+func (tm *tabManager) WatchTabFile(tab *tabData, onChange func(tab *tabData)) {
+	var filePath string
+	if !tm.updateTab(tab, func() {
+		if tab.watcher != nil {
+			tab.watcher.Close()
+			tab.watcher = nil
+		}
+		tab.modTime = time.Time{}
+		filePath = tab.filePath
+	}) || filePath == "" {
+		return
+	}
+	if info, err := os.Stat(filePath); err == nil {
+		tm.updateTab(tab, func() { tab.modTime = info.ModTime() })
+	}
+
+	watcher, err := newFileWatcher(filePath, func() {
+		if tm.tabFileChanged(tab, filePath) {
+			onChange(tab)
+		}
+	})
+	if err != nil {
+		log.Printf("Failed to watch %s: %v", filePath, err)
+		return
+	}
+	if !tm.updateTab(tab, func() { tab.watcher = watcher }) {
+		watcher.Close()
+	}
+}
+
+// tabFileChanged запоминает новое время изменения файла filePath открытой вкладки tab и
+// сообщает, отличается ли оно от уже показанного
+func (tm *tabManager) tabFileChanged(tab *tabData, filePath string) bool {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return false
+	}
+	changed := false
+	tm.updateTab(tab, func() {
+		if tab.filePath == filePath && !info.ModTime().Equal(tab.modTime) {
+			tab.modTime = info.ModTime()
+			changed = true
+		}
+	})
+	return changed
+}
+
+// findTabByFile возвращает индекс вкладки, в которой открыт файл, или -1
+func (tm *tabManager) findTabByFile(filePath string) int {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	for i, tab := range tm.tabs {
+		if tab.filePath != "" && tab.filePath == filePath {
+			return i
+		}
+	}
+	return -1
+}
+
+// CurrentTab возвращает выбранную вкладку или nil, если вкладок нет
+func (tm *tabManager) CurrentTab() *tabData {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
 	if tm.selectedTab >= 0 && tm.selectedTab < len(tm.tabs) {
-		return tm.tabs[tm.selectedTab].schemaPath, tm.tabs[tm.selectedTab].schemaMessageName
+		return tm.tabs[tm.selectedTab]
+	}
+	return nil
+}
+
+// RunForTab выполняет fn, если вкладка tab еще открыта, и возвращает false для закрытой.
+// Выбор вкладки не меняется: fn сама передает tab сеттерам и UpdateTabContent
+func (tm *tabManager) RunForTab(tab *tabData, fn func()) bool {
+	tm.mu.Lock()
+	open := tm.indexOf(tab) >= 0
+	tm.mu.Unlock()
+	if !open {
+		return false
 	}
-	return "", ""
+	fn()
+	return true
 }
 
 func (tm *tabManager) GetToolbarManager() *toolbarManager {
 	return tm.toolbarMgr
 }
 
-func (tm *tabManager) SetCurrentTabToolbarCallbacks(callbacks *toolbarCallbacks) {
-	if tm.selectedTab >= 0 && tm.selectedTab < len(tm.tabs) {
-		tm.tabs[tm.selectedTab].toolbarCallbacks = callbacks
+func (tm *tabManager) SetTabToolbarCallbacks(tab *tabData, callbacks *toolbarCallbacks) {
+	tm.updateTab(tab, func() { tab.toolbarCallbacks = callbacks })
+}
+
+func (tm *tabManager) SetTabSchemaHandler(tab *tabData, handler func(schemaPath string, messageName string)) {
+	tm.updateTab(tab, func() { tab.schemaHandler = handler })
+}
+
+// SetTabTreeState регистрирует функции сохранения и восстановления раскрытых веток
+// дерева вкладки
+func (tm *tabManager) SetTabTreeState(tab *tabData, expanded func() []string, expand func(paths []string)) {
+	tm.updateTab(tab, func() {
+		tab.expandedNodes = expanded
+		tab.expandNodes = expand
+	})
+}
+
+// removeAllTabs закрывает все вкладки, например перед открытием рабочего пространства
+func (tm *tabManager) removeAllTabs() {
+	tm.mu.Lock()
+	for _, tab := range tm.tabs {
+		if tab.watcher != nil {
+			tab.watcher.Close()
+		}
+	}
+	tm.tabs = make([]*tabData, 0)
+	tm.selectedTab = -1
+	tm.mu.Unlock()
+	tm.Refresh()
+}
+
+// hasModifiedTabs сообщает, что в какой-либо вкладке есть несохраненные правки
+func (tm *tabManager) hasModifiedTabs() bool {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	for _, tab := range tm.tabs {
+		if tab.modified {
+			return true
+		}
+	}
+	return false
+}
+
+// tabCount возвращает число открытых вкладок
+func (tm *tabManager) tabCount() int {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	return len(tm.tabs)
+}
+
+// getWorkspacePath возвращает открытый файл рабочего пространства. Путь читается и при
+// сохранении состояния из фоновых горутин, поэтому доступ к нему идет под mu
+func (tm *tabManager) getWorkspacePath() string {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	return tm.workspacePath
+}
+
+func (tm *tabManager) setWorkspacePath(path string) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	tm.workspacePath = path
+}
+
+// ApplySchemaToCurrentTab применяет схему к текущей вкладке. Возвращает false, если во вкладке
+// нет дерева, к которому можно применить схему
+func (tm *tabManager) ApplySchemaToCurrentTab(schemaPath string, messageName string) bool {
+	tab := tm.CurrentTab()
+	var handler func(schemaPath string, messageName string)
+	tm.readTab(tab, func() { handler = tab.schemaHandler })
+	if handler == nil {
+		return false
 	}
+	handler(schemaPath, messageName)
+	return true
 }
 
 func (tm *tabManager) CreateRenderer() fyne.WidgetRenderer {
@@ -35,23 +35,32 @@ func (r *tabManagerRenderer) MinSize() fyne.Size {
 }
 
 func (r *tabManagerRenderer) Refresh() {
-	tabButtons := make([]fyne.CanvasObject, 0)
+	// Заголовки и содержимое копируются под mu: вкладки меняются и из фоновых горутин
+	r.tabs.mu.Lock()
+	titles := make([]string, len(r.tabs.tabs))
 	for i, tab := range r.tabs.tabs {
+		titles[i] = tab.title
+	}
+	selectedTab := r.tabs.selectedTab
+	var selectedContent fyne.CanvasObject
+	if selectedTab >= 0 && selectedTab < len(r.tabs.tabs) {
+		selectedContent = r.tabs.tabs[selectedTab].content
+	}
+	r.tabs.mu.Unlock()
+
+	tabButtons := make([]fyne.CanvasObject, 0)
+	for i, title := range titles {
 		tabIndex := i
-		isSelected := r.tabs.selectedTab == tabIndex
+		isSelected := selectedTab == tabIndex
 
 		tabHeader := newTabHeader(
-			tab.title,
+			title,
 			isSelected,
 			func() {
-				if tabIndex >= 0 && tabIndex < len(r.tabs.tabs) {
-					r.tabs.SelectTab(tabIndex)
-				}
+				r.tabs.SelectTab(tabIndex)
 			},
 			func() {
-				if tabIndex >= 0 && tabIndex < len(r.tabs.tabs) {
-					r.tabs.RemoveTab(tabIndex)
-				}
+				r.tabs.RemoveTab(tabIndex)
 			},
 		)
 
@@ -65,8 +74,8 @@ func (r *tabManagerRenderer) Refresh() {
 	headerContent = append(headerContent, r.addButton)
 	r.header = container.NewHBox(headerContent...)
 
-	if r.tabs.selectedTab >= 0 && r.tabs.selectedTab < len(r.tabs.tabs) {
-		r.contentArea = r.tabs.tabs[r.tabs.selectedTab].content
+	if selectedTab >= 0 && selectedTab < len(titles) {
+		r.contentArea = selectedContent
 		if r.contentArea != nil {
 			r.contentArea.Refresh()
 		}
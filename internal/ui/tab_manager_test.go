@@ -0,0 +1,150 @@
+package ui
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/test"
+	"fyne.io/fyne/v2/widget"
+)
+
+func TestRunForTab_UpdatesTargetTabWithoutChangingSelection(t *testing.T) {
+	// Закрытие вкладки сохраняет состояние в фоне; оно не должно попасть в настройки пользователя
+	useTempConfigDir(t)
+
+	fyneApp := test.NewApp()
+	defer fyneApp.Quit()
+
+	browserTabs := newTabManager()
+	first := browserTabs.addTabWithoutSave("first", container.NewStack())
+	second := browserTabs.addTabWithoutSave("second", container.NewStack())
+
+	// Результат фоновой операции применяется к первой вкладке, пока выбрана вторая и
+	// интерфейс перерисовывает вкладки и сохраняет их состояние
+	result := widget.NewLabel("decoded")
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		browserTabs.RunForTab(first, func() {
+			browserTabs.UpdateTabContent(first, result)
+			browserTabs.SetTabModified(first, true)
+			browserTabs.SetTabViewMode(first, viewModeTable)
+		})
+	}()
+	for i := 0; i < 10; i++ {
+		browserTabs.Refresh()
+		collectTabStates(browserTabs, false)
+	}
+	wg.Wait()
+
+	if browserTabs.CurrentTab() != second {
+		t.Error("Expected the selection to stay on the second tab")
+	}
+	if browserTabs.GetTabContent(first) != result || !browserTabs.IsTabModified(first) || browserTabs.GetTabViewMode(first) != viewModeTable {
+		t.Error("Expected the result to be applied to the first tab")
+	}
+	if browserTabs.IsTabModified(second) || browserTabs.GetTabViewMode(second) != "" {
+		t.Error("Expected the second tab to stay unchanged")
+	}
+	if browserTabs.GetToolbarManager().viewModeBtn.Text != "Table" {
+		t.Error("Expected the toolbar to keep the view mode of the selected tab")
+	}
+
+	browserTabs.RemoveTab(0)
+	if browserTabs.RunForTab(first, func() { t.Error("Expected no call for a closed tab") }) {
+		t.Error("Expected RunForTab to report a closed tab")
+	}
+}
+
+func TestWatchTabFile_NotifiesWithChangedTab(t *testing.T) {
+	useTempConfigDir(t)
+
+	fyneApp := test.NewApp()
+	defer fyneApp.Quit()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "message.bin")
+	if err := os.WriteFile(path, []byte{0x08, 0x01}, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	browserTabs := newTabManager()
+	watched := browserTabs.addTabWithPathWithoutSave("message.bin", container.NewStack(), path)
+	changes := make(chan *tabData, 10)
+	browserTabs.WatchTabFile(watched, func(tab *tabData) { changes <- tab })
+	browserTabs.addTabWithoutSave("other", container.NewStack())
+
+	if browserTabs.tabFileChanged(watched, path) {
+		t.Error("Expected the file read by the tab not to count as changed")
+	}
+
+	if err := os.WriteFile(path, []byte{0x08, 0x02}, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// Время изменения сдвигается явно: запись в пределах одного тика его может не изменить
+	if err := os.Chtimes(path, time.Now(), time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case tab := <-changes:
+		if tab != watched {
+			t.Error("Expected the notification to target the watched tab")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected change notification for the watched tab")
+	}
+	if browserTabs.CurrentTab() == watched {
+		t.Error("Expected the notification not to change the selected tab")
+	}
+	if browserTabs.tabFileChanged(watched, path) {
+		t.Error("Expected the notified modification time to be remembered")
+	}
+
+	browserTabs.RemoveTab(0)
+	if err := os.Chtimes(path, time.Now(), time.Now().Add(2*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if browserTabs.tabFileChanged(watched, path) {
+		t.Error("Expected no changes to be reported for a closed tab")
+	}
+}
+
+func TestAddTransientTab_DisablesToolbarOfPreviousTab(t *testing.T) {
+	useTempConfigDir(t)
+
+	fyneApp := test.NewApp()
+	defer fyneApp.Quit()
+
+	browserTabs := newTabManager()
+	toolbar := browserTabs.GetToolbarManager()
+	saved := false
+	view := browserTabs.addTabWithoutSave("message.bin", container.NewStack())
+	browserTabs.SetTabToolbarCallbacks(view, &toolbarCallbacks{
+		saveCallback:       func() { saved = true },
+		exportJSONCallback: func() {},
+	})
+	browserTabs.selectTabWithoutSave(0)
+
+	browserTabs.AddTransientTab("diff: a ↔ b", container.NewStack())
+	if !toolbar.saveBtn.Disabled() || !toolbar.exportJSONBtn.Disabled() {
+		t.Error("Expected toolbar buttons to be disabled for a transient tab")
+	}
+	test.Tap(toolbar.saveBtn)
+	if saved {
+		t.Error("Expected the transient tab not to run callbacks of the previous tab")
+	}
+
+	browserTabs.selectTabWithoutSave(0)
+	if toolbar.saveBtn.Disabled() || toolbar.exportJSONBtn.Disabled() || !toolbar.openBtn.Disabled() {
+		t.Error("Expected the tab's own callbacks to be enabled and missing ones disabled")
+	}
+	test.Tap(toolbar.saveBtn)
+	if !saved {
+		t.Error("Expected the save button to run the callback of the selected tab")
+	}
+}
@@ -0,0 +1,310 @@
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"prospect/internal/protobuf"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+const (
+	tableColumnPath = iota
+	tableColumnName
+	tableColumnNum
+	tableColumnType
+	tableColumnValue
+	tableColumnChildren
+)
+
+var tableColumnTitles = []string{"Path", "Field Name", "Field #", "Type", "Value", "Children"}
+
+var tableColumnWidths = []float32{240, 160, 70, 120, 280, 80}
+
+// tableRow - строка таблицы: узел дерева и его UID в адаптере. Значения читаются из узла при
+// отрисовке, поэтому правки в таблице и в дереве относятся к одним и тем же TreeNode
+type tableRow struct {
+	Path string
+	UID  widget.TreeNodeID
+	Node *protobuf.TreeNode
+}
+
+// buildTableData разворачивает дерево в строки в порядке обхода. Путь строится так же, как в
+// отчете схемы и сравнении: повторяющиеся поля получают индекс, например "items[1].name".
+// Неразобранные сообщения ленивого дерева дают одну строку, их поля появляются после выбора
+// этой строки
+func buildTableData(node *protobuf.TreeNode) []tableRow {
+	var rows []tableRow
+
+	var traverse func(*protobuf.TreeNode, string, string)
+	traverse = func(n *protobuf.TreeNode, uid string, path string) {
+		counts := make(map[string]int)
+		for _, child := range n.Children {
+			counts[child.Name]++
+		}
+
+		indexes := make(map[string]int)
+		for i, child := range n.Children {
+			childUID := strconv.Itoa(i)
+			if uid != "" {
+				childUID = uid + ":" + childUID
+			}
+			childPath := child.Name
+			if path != "" {
+				childPath = path + "." + child.Name
+			}
+			if counts[child.Name] > 1 || child.IsRepeated {
+				childPath = fmt.Sprintf("%s[%d]", childPath, indexes[child.Name])
+				indexes[child.Name]++
+			}
+
+			rows = append(rows, tableRow{Path: childPath, UID: childUID, Node: child})
+			traverse(child, childUID, childPath)
+		}
+	}
+
+	if node != nil {
+		traverse(node, "", "")
+	}
+	return rows
+}
+
+// protoTable - табличное представление дерева на виртуализированном widget.Table.
+// Правки значений проходят через адаптер дерева с теми же проверками, что и в дереве
+type protoTable struct {
+	adapter    *protoTreeAdapter
+	rows       []tableRow
+	sortColumn int
+	ascending  bool
+	table      *widget.Table
+}
+
+func newProtoTable(adapter *protoTreeAdapter) *protoTable {
+	t := &protoTable{
+		adapter:    adapter,
+		rows:       buildTableData(adapter.tree),
+		sortColumn: -1,
+	}
+
+	t.table = widget.NewTableWithHeaders(
+		func() (int, int) {
+			return len(t.rows), len(tableColumnTitles)
+		},
+		func() fyne.CanvasObject {
+			entry := widget.NewEntry()
+			entry.Hide()
+			return container.NewStack(widget.NewLabel(""), entry)
+		},
+		t.updateCell,
+	)
+	t.table.ShowHeaderColumn = false
+	t.table.CreateHeader = func() fyne.CanvasObject {
+		return widget.NewButton("", nil)
+	}
+	t.table.UpdateHeader = t.updateHeader
+	t.table.OnSelected = t.expandRow
+	for column, width := range tableColumnWidths {
+		t.table.SetColumnWidth(column, width)
+	}
+	return t
+}
+
+// createTableWidget строит таблицу полей дерева адаптера
+func createTableWidget(adapter *protoTreeAdapter) fyne.CanvasObject {
+	t := newProtoTable(adapter)
+	if len(t.rows) == 0 {
+		return widget.NewLabel("(no data)")
+	}
+	return t.table
+}
+
+func (t *protoTable) updateHeader(id widget.TableCellID, obj fyne.CanvasObject) {
+	button := obj.(*widget.Button)
+	if id.Row >= 0 || id.Col < 0 || id.Col >= len(tableColumnTitles) {
+		button.SetText("")
+		button.OnTapped = nil
+		return
+	}
+
+	title := tableColumnTitles[id.Col]
+	if id.Col == t.sortColumn {
+		if t.ascending {
+			title += " ▲"
+		} else {
+			title += " ▼"
+		}
+	}
+	button.SetText(title)
+	column := id.Col
+	button.OnTapped = func() {
+		t.sortBy(column)
+	}
+}
+
+// sortBy сортирует строки по колонке; повторный выбор той же колонки меняет направление
+func (t *protoTable) sortBy(column int) {
+	if t.sortColumn == column {
+		t.ascending = !t.ascending
+	} else {
+		t.sortColumn = column
+		t.ascending = true
+	}
+	sortTableRows(t.rows, column, t.ascending, t.cellText)
+	if t.table != nil {
+		t.table.Refresh()
+	}
+}
+
+// sortTableRows упорядочивает строки по тексту колонки, номера полей и число детей
+// сравниваются как числа. Сортировка устойчивая, равные строки сохраняют порядок дерева
+func sortTableRows(rows []tableRow, column int, ascending bool, cellText func(tableRow, int) string) {
+	less := func(a, b tableRow) bool {
+		switch column {
+		case tableColumnNum:
+			return a.Node.FieldNum < b.Node.FieldNum
+		case tableColumnChildren:
+			return len(a.Node.Children) < len(b.Node.Children)
+		default:
+			return strings.ToLower(cellText(a, column)) < strings.ToLower(cellText(b, column))
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		if ascending {
+			return less(rows[i], rows[j])
+		}
+		return less(rows[j], rows[i])
+	})
+}
+
+func (t *protoTable) cellText(row tableRow, column int) string {
+	node := row.Node
+	switch column {
+	case tableColumnPath:
+		return row.Path
+	case tableColumnName:
+		name := node.Name
+		if node.IsGroup {
+			name += " (group)"
+		}
+		if len(t.adapter.report.IssuesFor(node)) > 0 || hasInvalidUTF8(node) {
+			name = "⚠ " + name
+		}
+		return name
+	case tableColumnNum:
+		return strconv.Itoa(node.FieldNum)
+	case tableColumnType:
+		if node.IsRepeated {
+			return node.Type + " [repeated]"
+		}
+		return node.Type
+	case tableColumnValue:
+		if t.isEditable(node) {
+			return t.adapter.nodeValueToString(node)
+		}
+		return ""
+	case tableColumnChildren:
+		if node.IsLazy() {
+			return "…"
+		}
+		return strconv.Itoa(len(node.Children))
+	}
+	return ""
+}
+
+// expandRow разбирает неразобранное сообщение выбранной строки и добавляет строки его полей
+func (t *protoTable) expandRow(id widget.TableCellID) {
+	if id.Row < 0 || id.Row >= len(t.rows) || !t.rows[id.Row].Node.IsLazy() {
+		return
+	}
+	t.rows[id.Row].Node.Expand()
+	t.rows = buildTableData(t.adapter.tree)
+	if t.sortColumn >= 0 {
+		sortTableRows(t.rows, t.sortColumn, t.ascending, t.cellText)
+	}
+	t.table.UnselectAll()
+	t.table.Refresh()
+}
+
+// isEditable сообщает, что значение узла можно править в ячейке: сообщения редактируются в дереве
+func (t *protoTable) isEditable(node *protobuf.TreeNode) bool {
+	return len(node.Children) == 0 && !t.adapter.isMessageType(node.Type)
+}
+
+func (t *protoTable) updateCell(id widget.TableCellID, obj fyne.CanvasObject) {
+	cell := obj.(*fyne.Container)
+	label := cell.Objects[0].(*widget.Label)
+	entry := cell.Objects[1].(*widget.Entry)
+	entry.OnChanged = nil
+
+	if id.Row < 0 || id.Row >= len(t.rows) {
+		return
+	}
+	row := t.rows[id.Row]
+
+	if id.Col == tableColumnValue && t.isEditable(row.Node) {
+		label.Hide()
+		t.bindValueEntry(entry, row)
+		entry.Show()
+		return
+	}
+
+	entry.Hide()
+	label.Importance = widget.MediumImportance
+	if id.Col == tableColumnName && (len(t.adapter.report.IssuesFor(row.Node)) > 0 || hasInvalidUTF8(row.Node)) {
+		label.Importance = widget.WarningImportance
+	}
+	label.SetText(t.cellText(row, id.Col))
+	label.Show()
+}
+
+// bindValueEntry связывает поле ввода с узлом строки; некорректный ввод откатывается
+// к последнему допустимому значению, как в дереве
+func (t *protoTable) bindValueEntry(entry *widget.Entry, row tableRow) {
+	node := row.Node
+	entry.Validator = nil
+	if node.Type == "bytes" {
+		format := t.adapter.bytesFormatFor(node)
+		entry.Validator = func(text string) error {
+			_, err := protobuf.ParseBytes(text, format)
+			return err
+		}
+	} else if t.adapter.isEscapedString(node) {
+		entry.Validator = func(text string) error {
+			_, err := protobuf.UnescapeText(text)
+			return err
+		}
+	}
+
+	valueStr := t.adapter.nodeValueToString(node)
+	entry.SetText(valueStr)
+
+	lastValidValue := valueStr
+	var isUpdating bool
+	entry.OnChanged = func(value string) {
+		if isUpdating {
+			return
+		}
+		if !t.setValue(row, value) {
+			isUpdating = true
+			entry.SetText(lastValidValue)
+			isUpdating = false
+			return
+		}
+		lastValidValue = value
+	}
+}
+
+// setValue записывает значение в узел строки и возвращает false, если оно не подходит к типу поля
+func (t *protoTable) setValue(row tableRow, value string) bool {
+	fieldType := row.Node.Type
+	if !t.adapter.validateValue(value, fieldType) {
+		return false
+	}
+	t.adapter.updateNodeValue(row.UID, value, fieldType)
+	return true
+}
@@ -0,0 +1,182 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+
+	"prospect/internal/protobuf"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// textView - текстовое представление дерева вкладки. Слева редактируемый текст, справа
+// подсвеченная копия с номерами строк и отметкой строки с ошибкой. Правки переносятся в дерево
+// кнопкой Apply: некорректный текст оставляет дерево без изменений
+type textView struct {
+	tree *protobuf.TreeNode
+	// reference - копия дерева на момент открытия представления. Из нее берутся имена и типы
+	// полей схемы: после применения текста в дереве их уже нет у измененных полей
+	reference *protobuf.TreeNode
+	entry     *widget.Entry
+	grid      *widget.TextGrid
+	status    *widget.Label
+	applyBtn  *widget.Button
+	content   fyne.CanvasObject
+	// onChange вызывается после каждой правки, перенесенной в дерево
+	onChange func()
+}
+
+func newTextView(tree *protobuf.TreeNode) *textView {
+	v := &textView{
+		tree:   tree,
+		entry:  widget.NewMultiLineEntry(),
+		grid:   widget.NewTextGrid(),
+		status: widget.NewLabel(""),
+	}
+
+	serializer := &protobuf.Serializer{}
+	text := serializer.TreeToTextFormatWithNames(tree)
+	v.reference = tree.Clone()
+
+	v.entry.TextStyle = fyne.TextStyle{Monospace: true}
+	v.entry.Wrapping = fyne.TextWrapOff
+	v.entry.SetText(text)
+	v.entry.OnChanged = func(string) {
+		v.applyBtn.Enable()
+		v.status.Importance = widget.MediumImportance
+		v.status.SetText("Text changed, press Apply to update the tree")
+	}
+	v.highlight(text, 0)
+
+	v.applyBtn = widget.NewButtonWithIcon("Apply", theme.ConfirmIcon(), func() {
+		v.apply(v.entry.Text)
+	})
+	v.applyBtn.Disable()
+
+	split := container.NewHSplit(v.entry, container.NewScroll(v.grid))
+	split.Offset = 0.5
+	bottom := container.NewBorder(nil, nil, nil, v.applyBtn, v.status)
+	v.content = container.NewBorder(nil, bottom, nil, nil, split)
+	return v
+}
+
+// apply разбирает текст и при успехе заменяет содержимое дерева вкладки
+func (v *textView) apply(text string) {
+	parsed, err := protobuf.ParseTextTree(text, v.reference)
+	if err != nil {
+		errorLine := 0
+		var syntaxErr *protobuf.TextSyntaxError
+		if errors.As(err, &syntaxErr) {
+			errorLine = syntaxErr.Line
+		}
+		v.status.Importance = widget.DangerImportance
+		v.status.SetText(fmt.Sprintf("⚠ %v (changes not applied)", err))
+		v.highlight(text, errorLine)
+		return
+	}
+
+	protobuf.ReplaceTreeContent(v.tree, parsed)
+	if v.onChange != nil {
+		v.onChange()
+	}
+	v.applyBtn.Disable()
+	v.status.Importance = widget.MediumImportance
+	v.status.SetText("")
+	v.highlight(text, 0)
+}
+
+// highlight перерисовывает подсвеченную копию текста; errorLine (с 1) отмечается в колонке номеров
+func (v *textView) highlight(text string, errorLine int) {
+	v.grid.SetText(text)
+	for i, row := range v.grid.Rows {
+		v.grid.SetRow(i, highlightTextRow(string(rowRunes(row)), i+1, i+1 == errorLine))
+	}
+}
+
+func rowRunes(row widget.TextGridRow) []rune {
+	runes := make([]rune, len(row.Cells))
+	for i, cell := range row.Cells {
+		runes[i] = cell.Rune
+	}
+	return runes
+}
+
+// highlightTextRow раскрашивает строку текстового формата: номер поля, строку в кавычках,
+// скалярное значение и комментарий. Перед строкой выводится ее номер
+func highlightTextRow(line string, lineNum int, isError bool) widget.TextGridRow {
+	gutterStyle := &widget.CustomTextGridStyle{FGColor: theme.DisabledColor()}
+	fieldStyle := &widget.CustomTextGridStyle{FGColor: theme.PrimaryColor()}
+	stringStyle := &widget.CustomTextGridStyle{FGColor: theme.SuccessColor()}
+	valueStyle := &widget.CustomTextGridStyle{FGColor: theme.WarningColor()}
+	commentStyle := &widget.CustomTextGridStyle{FGColor: theme.DisabledColor()}
+
+	var row widget.TextGridRow
+	marker := ' '
+	if isError {
+		marker = '✗'
+		gutterStyle = &widget.CustomTextGridStyle{FGColor: theme.ErrorColor()}
+	}
+	for _, r := range fmt.Sprintf("%4d%c ", lineNum, marker) {
+		row.Cells = append(row.Cells, widget.TextGridCell{Rune: r, Style: gutterStyle})
+	}
+
+	runes := []rune(line)
+	var style widget.TextGridStyle
+	inString := false
+	afterField := false
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case inString:
+			style = stringStyle
+			if r == '\\' && i+1 < len(runes) {
+				row.Cells = append(row.Cells, widget.TextGridCell{Rune: r, Style: style})
+				i++
+				r = runes[i]
+			} else if r == '"' {
+				inString = false
+			}
+		case r == '#':
+			for _, rest := range runes[i:] {
+				row.Cells = append(row.Cells, widget.TextGridCell{Rune: rest, Style: commentStyle})
+			}
+			i = len(runes)
+			continue
+		case r == '"':
+			inString = true
+			style = stringStyle
+		case r == ':' || r == '{' || r == '}' || r == ' ' || r == '\t':
+			style = nil
+			if r != ' ' && r != '\t' {
+				afterField = true
+			}
+		case !afterField:
+			style = fieldStyle
+		default:
+			style = valueStyle
+		}
+		row.Cells = append(row.Cells, widget.TextGridCell{Rune: r, Style: style})
+	}
+
+	if isError {
+		background := errorBackground()
+		for i := range row.Cells {
+			cellStyle := &widget.CustomTextGridStyle{BGColor: background}
+			if row.Cells[i].Style != nil {
+				cellStyle.FGColor = row.Cells[i].Style.TextColor()
+			}
+			row.Cells[i].Style = cellStyle
+		}
+	}
+	return row
+}
+
+// errorBackground - полупрозрачный цвет ошибки темы для фона строки
+func errorBackground() color.Color {
+	r, g, b, _ := theme.ErrorColor().RGBA()
+	return color.NRGBA{R: uint8(r >> 8), G: uint8(g >> 8), B: uint8(b >> 8), A: 0x40}
+}
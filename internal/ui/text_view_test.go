@@ -0,0 +1,46 @@
+package ui
+
+import (
+	"testing"
+
+	"prospect/internal/protobuf"
+
+	"fyne.io/fyne/v2/test"
+)
+
+func TestTextView_AppliesOnButtonWithOpeningSnapshot(t *testing.T) {
+	fyneApp := test.NewApp()
+	defer fyneApp.Quit()
+
+	tree := &protobuf.TreeNode{Name: "root", Type: "message"}
+	tree.AddChild(&protobuf.TreeNode{Name: "id", Type: "string", FieldNum: 1, Value: "A-1"})
+	tree.AddChild(&protobuf.TreeNode{Name: "qty", Type: "int32", FieldNum: 2, Value: "3"})
+
+	serializer := &protobuf.Serializer{}
+	original := serializer.TreeToTextFormatWithNames(tree)
+	withoutID := tree.Clone()
+	withoutID.Children = withoutID.Children[1:]
+
+	view := newTextView(tree)
+	changes := 0
+	view.onChange = func() { changes++ }
+
+	view.entry.SetText(serializer.TreeToTextFormatWithNames(withoutID))
+	if len(tree.Children) != 2 || changes != 0 {
+		t.Fatalf("Expected typing not to change the tree before Apply, got %d children", len(tree.Children))
+	}
+	test.Tap(view.applyBtn)
+	if len(tree.Children) != 1 || changes != 1 {
+		t.Fatalf("Expected Apply to remove field 1, got %d children", len(tree.Children))
+	}
+
+	// Поле, удаленное предыдущей правкой, снова получает имя из копии дерева на момент открытия
+	view.entry.SetText(original)
+	test.Tap(view.applyBtn)
+	if len(tree.Children) != 2 || tree.Children[0].Name != "id" || tree.Children[1].Type != "int32" {
+		t.Errorf("Expected names and types from the opening snapshot, got %+v", tree.Children)
+	}
+	if !view.applyBtn.Disabled() {
+		t.Error("Expected Apply to be disabled once the text is applied")
+	}
+}
@@ -8,12 +8,22 @@ import (
 )
 
 type toolbarManager struct {
-	toolbar          fyne.CanvasObject
-	openBtn          *widget.Button
-	saveBtn          *widget.Button
-	applySchemaBtn   *widget.Button
-	exportSchemaBtn  *widget.Button
-	exportJSONBtn    *widget.Button
+	toolbar         fyne.CanvasObject
+	openBtn         *widget.Button
+	saveBtn         *widget.Button
+	applySchemaBtn  *widget.Button
+	exportSchemaBtn *widget.Button
+	exportJSONBtn   *widget.Button
+	compareBtn      *widget.Button
+	mergeBtn        *widget.Button
+	newMessageBtn   *widget.Button
+	showUnsetBtn    *widget.Button
+	inferSchemaBtn  *widget.Button
+	editSchemaBtn   *widget.Button
+	compatBtn       *widget.Button
+	rulesBtn        *widget.Button
+	viewModeBtn     *widget.Button
+	detectTypeBtn   *widget.Button
 }
 
 func newToolbarManager() *toolbarManager {
@@ -34,34 +44,159 @@ func newToolbarManager() *toolbarManager {
 	tm.exportJSONBtn = widget.NewButtonWithIcon("Export JSON", theme.DocumentIcon(), func() {})
 	tm.exportJSONBtn.Importance = widget.LowImportance
 
+	tm.compareBtn = widget.NewButtonWithIcon("Compare", theme.ListIcon(), func() {})
+	tm.compareBtn.Importance = widget.LowImportance
+
+	tm.mergeBtn = widget.NewButtonWithIcon("Merge", theme.ContentCopyIcon(), func() {})
+	tm.mergeBtn.Importance = widget.LowImportance
+
+	tm.newMessageBtn = widget.NewButtonWithIcon("New message", theme.DocumentCreateIcon(), func() {})
+	tm.newMessageBtn.Importance = widget.LowImportance
+
+	tm.showUnsetBtn = widget.NewButtonWithIcon("Unset fields", theme.VisibilityIcon(), func() {})
+	tm.showUnsetBtn.Importance = widget.LowImportance
+
+	tm.inferSchemaBtn = widget.NewButtonWithIcon("Infer schema", theme.SearchIcon(), func() {})
+	tm.inferSchemaBtn.Importance = widget.LowImportance
+
+	tm.editSchemaBtn = widget.NewButtonWithIcon("Edit schema", theme.DocumentIcon(), func() {})
+	tm.editSchemaBtn.Importance = widget.LowImportance
+
+	tm.compatBtn = widget.NewButtonWithIcon("Compatibility", theme.ConfirmIcon(), func() {})
+	tm.compatBtn.Importance = widget.LowImportance
+
+	tm.rulesBtn = widget.NewButtonWithIcon("Rules", theme.ListIcon(), func() {})
+	tm.rulesBtn.Importance = widget.LowImportance
+
+	tm.viewModeBtn = widget.NewButtonWithIcon("Table", theme.GridIcon(), func() {})
+	tm.viewModeBtn.Importance = widget.LowImportance
+
+	tm.detectTypeBtn = widget.NewButtonWithIcon("Detect type", theme.SearchIcon(), func() {})
+	tm.detectTypeBtn.Importance = widget.LowImportance
+
 	tm.toolbar = container.NewHBox(
+		tm.newMessageBtn,
 		tm.openBtn,
 		tm.saveBtn,
 		tm.applySchemaBtn,
 		tm.exportSchemaBtn,
 		tm.exportJSONBtn,
+		tm.compareBtn,
+		tm.mergeBtn,
+		tm.showUnsetBtn,
+		tm.inferSchemaBtn,
+		tm.editSchemaBtn,
+		tm.compatBtn,
+		tm.rulesBtn,
+		tm.viewModeBtn,
+		tm.detectTypeBtn,
 	)
 	return tm
 }
 
 func (tm *toolbarManager) SetOpenCallback(callback func()) {
-	tm.openBtn.OnTapped = callback
+	setButtonCallback(tm.openBtn, callback)
 }
 
 func (tm *toolbarManager) SetSaveCallback(callback func()) {
-	tm.saveBtn.OnTapped = callback
+	setButtonCallback(tm.saveBtn, callback)
 }
 
 func (tm *toolbarManager) SetApplySchemaCallback(callback func()) {
-	tm.applySchemaBtn.OnTapped = callback
+	setButtonCallback(tm.applySchemaBtn, callback)
 }
 
 func (tm *toolbarManager) SetExportSchemaCallback(callback func()) {
-	tm.exportSchemaBtn.OnTapped = callback
+	setButtonCallback(tm.exportSchemaBtn, callback)
 }
 
 func (tm *toolbarManager) SetExportJSONCallback(callback func()) {
-	tm.exportJSONBtn.OnTapped = callback
+	setButtonCallback(tm.exportJSONBtn, callback)
+}
+
+func (tm *toolbarManager) SetCompareCallback(callback func()) {
+	setButtonCallback(tm.compareBtn, callback)
+}
+
+func (tm *toolbarManager) SetMergeCallback(callback func()) {
+	setButtonCallback(tm.mergeBtn, callback)
+}
+
+func (tm *toolbarManager) SetNewMessageCallback(callback func()) {
+	setButtonCallback(tm.newMessageBtn, callback)
+}
+
+func (tm *toolbarManager) SetShowUnsetCallback(callback func()) {
+	setButtonCallback(tm.showUnsetBtn, callback)
+}
+
+func (tm *toolbarManager) SetInferSchemaCallback(callback func()) {
+	setButtonCallback(tm.inferSchemaBtn, callback)
+}
+
+func (tm *toolbarManager) SetEditSchemaCallback(callback func()) {
+	setButtonCallback(tm.editSchemaBtn, callback)
+}
+
+func (tm *toolbarManager) SetCompatCallback(callback func()) {
+	setButtonCallback(tm.compatBtn, callback)
+}
+
+func (tm *toolbarManager) SetRulesCallback(callback func()) {
+	setButtonCallback(tm.rulesBtn, callback)
+}
+
+func (tm *toolbarManager) SetViewModeCallback(callback func()) {
+	setButtonCallback(tm.viewModeBtn, callback)
+}
+
+// SetViewMode подписывает переключатель режимом, в который он переведет вкладку
+func (tm *toolbarManager) SetViewMode(viewMode string) {
+	switch nextViewMode(viewMode) {
+	case viewModeTable:
+		tm.viewModeBtn.SetText("Table")
+		tm.viewModeBtn.SetIcon(theme.GridIcon())
+	case viewModeText:
+		tm.viewModeBtn.SetText("Text")
+		tm.viewModeBtn.SetIcon(theme.DocumentIcon())
+	default:
+		tm.viewModeBtn.SetText("Tree")
+		tm.viewModeBtn.SetIcon(theme.ListIcon())
+	}
+}
+
+func (tm *toolbarManager) SetDetectTypeCallback(callback func()) {
+	setButtonCallback(tm.detectTypeBtn, callback)
+}
+
+// setButtonCallback назначает действие кнопки. Кнопка без действия недоступна, чтобы
+// вкладка без дерева (например, результат сравнения) не вызывала действия прежней вкладки
+func setButtonCallback(button *widget.Button, callback func()) {
+	button.OnTapped = callback
+	if callback == nil {
+		button.Disable()
+	} else {
+		button.Enable()
+	}
+}
+
+// SetCallbacks назначает кнопкам действия вкладки; кнопки без действия становятся недоступны
+func (tm *toolbarManager) SetCallbacks(callbacks *toolbarCallbacks) {
+	tm.SetOpenCallback(callbacks.openCallback)
+	tm.SetSaveCallback(callbacks.saveCallback)
+	tm.SetApplySchemaCallback(callbacks.applySchemaCallback)
+	tm.SetExportSchemaCallback(callbacks.exportSchemaCallback)
+	tm.SetExportJSONCallback(callbacks.exportJSONCallback)
+	tm.SetCompareCallback(callbacks.compareCallback)
+	tm.SetMergeCallback(callbacks.mergeCallback)
+	tm.SetNewMessageCallback(callbacks.newMessageCallback)
+	tm.SetShowUnsetCallback(callbacks.showUnsetCallback)
+	tm.SetInferSchemaCallback(callbacks.inferSchemaCallback)
+	tm.SetEditSchemaCallback(callbacks.editSchemaCallback)
+	tm.SetCompatCallback(callbacks.compatCallback)
+	tm.SetRulesCallback(callbacks.rulesCallback)
+	tm.SetViewModeCallback(callbacks.viewModeCallback)
+	tm.SetDetectTypeCallback(callbacks.detectTypeCallback)
 }
 
 func (tm *toolbarManager) GetToolbar() fyne.CanvasObject {
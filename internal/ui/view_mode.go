@@ -0,0 +1,49 @@
+package ui
+
+import (
+	"prospect/internal/protobuf"
+
+	"fyne.io/fyne/v2"
+)
+
+// Режимы отображения дерева во вкладке
+const (
+	viewModeTree  = ""
+	viewModeTable = "table"
+	viewModeText  = "text"
+)
+
+// nextViewMode возвращает режим, в который переключатель переводит вкладку: дерево -> таблица -> текст
+func nextViewMode(viewMode string) string {
+	switch viewMode {
+	case viewModeTree:
+		return viewModeTable
+	case viewModeTable:
+		return viewModeText
+	default:
+		return viewModeTree
+	}
+}
+
+// tabViewContent возвращает содержимое вкладки в выбранном для нее режиме: таблицу полей,
+// текстовое представление или переданное представление дерева
+func tabViewContent(browserTabs *tabManager, tab *tabData, tree *protobuf.TreeNode, report *protobuf.SchemaReport, window fyne.Window, treeContent fyne.CanvasObject) fyne.CanvasObject {
+	if browserTabs == nil || tree == nil {
+		return treeContent
+	}
+
+	switch browserTabs.GetTabViewMode(tab) {
+	case viewModeTable:
+		adapter := newProtoTreeAdapter(tree)
+		adapter.SetWindow(window)
+		adapter.SetSchemaReport(report)
+		adapter.SetChangeCallback(tabChangeCallback(browserTabs, tab))
+		return createTableWidget(adapter)
+	case viewModeText:
+		view := newTextView(tree)
+		view.onChange = tabChangeCallback(browserTabs, tab)
+		return view.content
+	default:
+		return treeContent
+	}
+}
@@ -2,15 +2,19 @@ package ui
 
 import (
 	"fmt"
+	"log"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
 )
 
 var tabCounter int = 0
 var undefinedTabCounter int = 0
 
-func NewMainWindow(fyneApp fyne.App) fyne.Window {
+// NewMainWindow создает главное окно и открывает в нем файлы запроса. Возвращаемая функция
+// открывает файлы в уже созданном окне, например переданные следующим запуском приложения
+func NewMainWindow(fyneApp fyne.App, request OpenRequest) (fyne.Window, func(OpenRequest)) {
 	window := fyneApp.NewWindow("prospect")
 	window.Resize(fyne.NewSize(800, 600))
 	window.CenterOnScreen()
@@ -26,11 +30,83 @@ func NewMainWindow(fyneApp fyne.App) fyne.Window {
 	})
 
 	if err := loadTabState(browserTabs, fyneApp, window); err != nil {
+		log.Printf("Failed to restore tabs: %v", err)
+	}
+	open := func(request OpenRequest) {
+		openFiles(browserTabs, fyneApp, window, request)
+	}
+	if len(request.Files) > 0 || request.SchemaPath != "" {
+		open(request)
+	}
+	if browserTabs.tabCount() == 0 {
 		createProtoTab(browserTabs, fyneApp, window)
-	} else if len(browserTabs.tabs) == 0 {
-		createProtoTab(browserTabs, fyneApp, window)
 	}
 
+	recentFiles := fyne.NewMenuItem("Recent files", nil)
+	recentSchemas := fyne.NewMenuItem("Recent schemas", nil)
+	schemaLibrary := fyne.NewMenuItem("Schema library...", func() {
+		showSchemaLibraryDialog(browserTabs, window)
+	})
+	schemaRegistry := fyne.NewMenuItem("Schema registry...", func() {
+		showSchemaRegistryDialog(window)
+	})
+	openWorkspaceItem := fyne.NewMenuItem("Open workspace...", func() {
+		showOpenWorkspaceDialog(browserTabs, fyneApp, window)
+	})
+	saveWorkspaceItem := fyne.NewMenuItem("Save workspace", func() {
+		workspacePath := browserTabs.getWorkspacePath()
+		if workspacePath == "" {
+			saveWorkspaceAs(browserTabs, window)
+			return
+		}
+		if err := saveWorkspace(browserTabs, window, workspacePath); err != nil {
+			dialog.ShowError(fmt.Errorf("failed to save workspace: %w", err), window)
+		}
+	})
+	saveWorkspaceAsItem := fyne.NewMenuItem("Save workspace as...", func() {
+		saveWorkspaceAs(browserTabs, window)
+	})
+	closeWorkspaceItem := fyne.NewMenuItem("Close workspace", func() {
+		if browserTabs.getWorkspacePath() == "" {
+			return
+		}
+		confirmDiscardChanges(browserTabs, window, func() {
+			closeWorkspace(browserTabs, fyneApp, window)
+		})
+	})
+	mainMenu := fyne.NewMainMenu(fyne.NewMenu("File",
+		openWorkspaceItem, saveWorkspaceItem, saveWorkspaceAsItem, closeWorkspaceItem, fyne.NewMenuItemSeparator(),
+		recentFiles, recentSchemas, fyne.NewMenuItemSeparator(),
+		schemaLibrary, schemaRegistry,
+	))
+	updateRecentMenus := func() {
+		state, err := readAppState()
+		if err != nil {
+			log.Printf("Failed to load history: %v", err)
+			return
+		}
+		recentFiles.ChildMenu = recentMenu(state.RecentFiles, func(path string) {
+			open(OpenRequest{Files: []string{path}})
+		})
+		recentSchemas.ChildMenu = recentMenu(state.RecentSchemas, func(path string) {
+			if !browserTabs.ApplySchemaToCurrentTab(path, "") {
+				dialog.ShowInformation("Information", "Please open a proto file first", window)
+			}
+		})
+		mainMenu.Refresh()
+	}
+	updateRecentMenus()
+	if state, err := readAppState(); err == nil {
+		indexSchemaLibrary(browserTabs, state.SchemaLibrary)
+	}
+	browserTabs.SetHistoryCallback(updateRecentMenus)
+	window.SetMainMenu(mainMenu)
+
+	// Бинарные файлы открываются в новых вкладках, схемы применяются к текущей
+	window.SetOnDropped(func(_ fyne.Position, uris []fyne.URI) {
+		open(OpenRequest{Files: droppedFiles(uris)})
+	})
+
 	window.SetOnClosed(func() {
 		saveTabState(browserTabs)
 	})
@@ -45,7 +121,20 @@ func NewMainWindow(fyneApp fyne.App) fyne.Window {
 	)
 
 	window.SetContent(mainContent)
-	return window
+	return window, open
+}
+
+// addViewTab добавляет вкладку и создает ее содержимое, когда вкладка уже выбрана: при
+// создании представление привязывает к текущей вкладке кнопки панели, схему и слежение за
+// файлом. Если build уже обновил содержимое вкладки, возвращенное значение не используется
+func addViewTab(browserTabs *tabManager, title string, filePath string, build func() fyne.CanvasObject) *tabData {
+	placeholder := container.NewStack()
+	tab := browserTabs.addTabWithPathWithoutSave(title, placeholder, filePath)
+	content := build()
+	if browserTabs.GetTabContent(tab) == placeholder {
+		browserTabs.UpdateTabContent(tab, content)
+	}
+	return tab
 }
 
 func createTabWithClose(browserTabs *tabManager) {
@@ -61,16 +150,14 @@ func createTabWithClose(browserTabs *tabManager) {
 		parentWindow = fyneApp.NewWindow("")
 	}
 
-	content := protoView(fyneApp, parentWindow, browserTabs)
-
-	undefinedTabCounter++
-	tabTitle := fmt.Sprintf("undefined_%d", undefinedTabCounter)
-	browserTabs.AddTab(tabTitle, content)
+	createProtoTab(browserTabs, fyneApp, parentWindow)
 }
 
 func createProtoTab(browserTabs *tabManager, fyneApp fyne.App, parentWindow fyne.Window) {
-	content := protoView(fyneApp, parentWindow, browserTabs)
 	undefinedTabCounter++
 	tabTitle := fmt.Sprintf("undefined_%d", undefinedTabCounter)
-	browserTabs.AddTab(tabTitle, content)
+	addViewTab(browserTabs, tabTitle, "", func() fyne.CanvasObject {
+		return protoView(fyneApp, parentWindow, browserTabs)
+	})
+	saveTabStateInBackground(browserTabs)
 }
@@ -0,0 +1,293 @@
+package ui
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/widget"
+)
+
+// workspaceExt - расширение файлов рабочих пространств
+const workspaceExt = ".prospect.json"
+
+// workspace - именованный набор вкладок с привязками схем, правилами, режимами отображения
+// и раскрытыми ветками. Пути внутри каталога файла хранятся относительными, поэтому рабочее
+// пространство можно положить в репозиторий рядом с бинарными образцами. Заметок к узлам
+// в приложении нет, поэтому рабочее пространство их не хранит.
+//
+// Вкладки открытого рабочего пространства не попадают в state.json: там запоминается только
+// путь к нему, и при следующем запуске оно открывается снова
+type workspace struct {
+	Tabs        []tabState `json:"tabs"`
+	SelectedTab int        `json:"selectedTab"`
+	// IncludePaths - каталоги для поиска импортов схем вкладок, как -I у protoc
+	IncludePaths []string `json:"includePaths,omitempty"`
+}
+
+// isWorkspaceFile сообщает, что файл - рабочее пространство
+func isWorkspaceFile(path string) bool {
+	return strings.HasSuffix(strings.ToLower(path), workspaceExt)
+}
+
+// workspaceRelPath возвращает путь относительно каталога рабочего пространства, если файл
+// лежит внутри него, иначе абсолютный путь
+func workspaceRelPath(dir string, path string) string {
+	if path == "" {
+		return ""
+	}
+	rel, err := filepath.Rel(dir, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
+
+// workspaceAbsPath разрешает путь из рабочего пространства относительно его каталога
+func workspaceAbsPath(dir string, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, filepath.FromSlash(path))
+}
+
+// mapTabPaths заменяет пути файла, схемы и правил каждой вкладки через convert
+func mapTabPaths(tabs []tabState, convert func(string) string) []tabState {
+	result := make([]tabState, len(tabs))
+	for i, tab := range tabs {
+		tab.FilePath = convert(tab.FilePath)
+		tab.SchemaPath = convert(tab.SchemaPath)
+		tab.RulesPath = convert(tab.RulesPath)
+		result[i] = tab
+	}
+	return result
+}
+
+func readWorkspace(path string) (*workspace, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var ws workspace
+	if err := json.Unmarshal(data, &ws); err != nil {
+		return nil, fmt.Errorf("invalid workspace %s: %w", filepath.Base(path), err)
+	}
+	dir := filepath.Dir(path)
+	ws.Tabs = mapTabPaths(ws.Tabs, func(p string) string { return workspaceAbsPath(dir, p) })
+	for i, includePath := range ws.IncludePaths {
+		ws.IncludePaths[i] = workspaceAbsPath(dir, includePath)
+	}
+	return &ws, nil
+}
+
+func writeWorkspace(path string, ws *workspace) error {
+	dir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	stored := workspace{
+		Tabs:        mapTabPaths(ws.Tabs, func(p string) string { return workspaceRelPath(dir, p) }),
+		SelectedTab: ws.SelectedTab,
+	}
+	for _, includePath := range ws.IncludePaths {
+		stored.IncludePaths = append(stored.IncludePaths, workspaceRelPath(dir, includePath))
+	}
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// openWorkspace заменяет вкладки окна вкладками рабочего пространства
+func openWorkspace(browserTabs *tabManager, fyneApp fyne.App, window fyne.Window, path string) error {
+	ws, err := readWorkspace(path)
+	if err != nil {
+		return err
+	}
+
+	browserTabs.removeAllTabs()
+	// Путь задается до восстановления вкладок: сохранения состояния, запущенные при их
+	// открытии, не должны записать вкладки рабочего пространства в state.json
+	setWorkspacePath(browserTabs, window, path)
+	// Представления берут include paths при создании, поэтому они задаются до восстановления вкладок
+	browserTabs.includePaths = ws.IncludePaths
+	restoreTabs(browserTabs, fyneApp, window, ws.Tabs, ws.SelectedTab)
+	if browserTabs.tabCount() == 0 {
+		createProtoTab(browserTabs, fyneApp, window)
+	}
+	saveTabStateInBackground(browserTabs)
+	log.Printf("Workspace opened: %s", path)
+	return nil
+}
+
+// closeWorkspace возвращает окно к вкладкам вне рабочего пространства, сохраненным в state.json
+func closeWorkspace(browserTabs *tabManager, fyneApp fyne.App, window fyne.Window) {
+	state, err := readAppState()
+	if err != nil {
+		log.Printf("Failed to restore tabs: %v", err)
+	}
+
+	browserTabs.removeAllTabs()
+	setWorkspacePath(browserTabs, window, "")
+	browserTabs.includePaths = nil
+	if state != nil {
+		restoreTabs(browserTabs, fyneApp, window, state.Tabs, state.SelectedTab)
+	}
+	if browserTabs.tabCount() == 0 {
+		createProtoTab(browserTabs, fyneApp, window)
+	}
+	saveTabStateInBackground(browserTabs)
+}
+
+// saveWorkspace записывает вкладки окна в файл рабочего пространства
+func saveWorkspace(browserTabs *tabManager, window fyne.Window, path string) error {
+	tabs, selectedTab := collectTabStates(browserTabs, true)
+	if err := writeWorkspace(path, &workspace{Tabs: tabs, SelectedTab: selectedTab, IncludePaths: browserTabs.includePaths}); err != nil {
+		return err
+	}
+	setWorkspacePath(browserTabs, window, path)
+	// При следующем запуске откроется сохраненное рабочее пространство
+	saveTabStateInBackground(browserTabs)
+	log.Printf("Workspace saved: %s", path)
+	return nil
+}
+
+func setWorkspacePath(browserTabs *tabManager, window fyne.Window, path string) {
+	browserTabs.setWorkspacePath(path)
+	if window == nil {
+		return
+	}
+	if path == "" {
+		window.SetTitle("prospect")
+		return
+	}
+	window.SetTitle("prospect - " + strings.TrimSuffix(filepath.Base(path), workspaceExt))
+}
+
+// showOpenWorkspaceDialog выбирает и открывает рабочее пространство. Несохраненные правки
+// во вкладках требуют подтверждения
+func showOpenWorkspaceDialog(browserTabs *tabManager, fyneApp fyne.App, window fyne.Window) {
+	open := func() {
+		fileDialog := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+			if err != nil {
+				dialog.ShowError(err, window)
+				return
+			}
+			if reader == nil {
+				return
+			}
+			reader.Close()
+			if err := openWorkspace(browserTabs, fyneApp, window, reader.URI().Path()); err != nil {
+				dialog.ShowError(fmt.Errorf("failed to open workspace: %w", err), window)
+			}
+		}, window)
+		fileDialog.SetFilter(storage.NewExtensionFileFilter([]string{".json"}))
+		if lastDir := getFileDialogState().getLastOpenDir(); lastDir != nil {
+			fileDialog.SetLocation(lastDir)
+		}
+		fileDialog.Resize(getFileDialogState().getDialogSize())
+		fileDialog.Show()
+	}
+	confirmDiscardChanges(browserTabs, window, open)
+}
+
+// confirmDiscardChanges вызывает proceed сразу или после подтверждения, если во вкладках есть
+// несохраненные правки
+func confirmDiscardChanges(browserTabs *tabManager, window fyne.Window, proceed func()) {
+	if !browserTabs.hasModifiedTabs() {
+		proceed()
+		return
+	}
+	dialog.ShowConfirm("Unsaved changes", "Some tabs have unsaved changes. Discard them?", func(confirmed bool) {
+		if confirmed {
+			proceed()
+		}
+	}, window)
+}
+
+// saveWorkspaceAs сохраняет рабочее пространство в выбранный файл
+func saveWorkspaceAs(browserTabs *tabManager, window fyne.Window) {
+	saveDialog := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
+		if writer == nil {
+			return
+		}
+		writer.Close()
+
+		path := writer.URI().Path()
+		if !isWorkspaceFile(path) {
+			// Диалог создал файл под введенным именем; рабочее пространство получает свое расширение
+			os.Remove(path)
+			path = strings.TrimSuffix(path, filepath.Ext(path)) + workspaceExt
+		}
+		if err := saveWorkspace(browserTabs, window, path); err != nil {
+			dialog.ShowError(fmt.Errorf("failed to save workspace: %w", err), window)
+		}
+	}, window)
+	saveDialog.SetFileName("workspace" + workspaceExt)
+	if workspacePath := browserTabs.getWorkspacePath(); workspacePath != "" {
+		if dir, err := storage.ListerForURI(storage.NewFileURI(filepath.Dir(workspacePath))); err == nil {
+			saveDialog.SetLocation(dir)
+		}
+	} else if lastDir := getFileDialogState().getLastSaveDir(); lastDir != nil {
+		saveDialog.SetLocation(lastDir)
+	}
+	saveDialog.Resize(getFileDialogState().getDialogSize())
+	saveDialog.Show()
+}
+
+// expandedTreePaths возвращает раскрытые ветки дерева как пути индексов дочерних узлов от корня.
+// В отличие от UID, такие пути сохраняют смысл после повторного открытия файла
+func expandedTreePaths(tree *widget.Tree) []string {
+	paths := make([]string, 0)
+	if tree == nil || tree.ChildUIDs == nil {
+		return paths
+	}
+	var walk func(uid widget.TreeNodeID, prefix string)
+	walk = func(uid widget.TreeNodeID, prefix string) {
+		for i, child := range tree.ChildUIDs(uid) {
+			if !tree.IsBranchOpen(child) {
+				continue
+			}
+			path := prefix + strconv.Itoa(i)
+			paths = append(paths, path)
+			walk(child, path+"/")
+		}
+	}
+	walk("", "")
+	return paths
+}
+
+// expandTreePaths раскрывает ветки по путям из expandedTreePaths. Пути, которых больше нет
+// в данных, пропускаются
+func expandTreePaths(tree *widget.Tree, paths []string) {
+	if tree == nil || tree.ChildUIDs == nil {
+		return
+	}
+	for _, path := range paths {
+		uid := widget.TreeNodeID("")
+		for _, part := range strings.Split(path, "/") {
+			index, err := strconv.Atoi(part)
+			children := tree.ChildUIDs(uid)
+			if err != nil || index < 0 || index >= len(children) {
+				break
+			}
+			uid = children[index]
+			if tree.IsBranch == nil || !tree.IsBranch(uid) {
+				break
+			}
+			tree.OpenBranch(uid)
+		}
+	}
+}
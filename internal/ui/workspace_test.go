@@ -0,0 +1,187 @@
+package ui
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"prospect/internal/protobuf"
+
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/test"
+	"fyne.io/fyne/v2/widget"
+)
+
+func TestWorkspace_SavesAndRestoresTabs(t *testing.T) {
+	useTempConfigDir(t)
+
+	fyneApp := test.NewApp()
+	defer fyneApp.Quit()
+	window := fyneApp.NewWindow("test")
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "fixtures", "message.bin")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte{0x08, 0x01}, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	outside := filepath.Join(t.TempDir(), "shared.proto")
+	workspacePath := filepath.Join(dir, "case"+workspaceExt)
+
+	ws := &workspace{
+		Tabs: []tabState{{
+			Title:             "message.bin",
+			FilePath:          path,
+			SchemaPath:        outside,
+			SchemaMessageName: "Message",
+			RulesPath:         filepath.Join(dir, "rules.json"),
+			ViewMode:          viewModeText,
+			ExpandedNodes:     []string{"1", "1/0"},
+		}},
+		SelectedTab:  0,
+		IncludePaths: []string{filepath.Join(dir, "protos"), filepath.Dir(outside)},
+	}
+	if err := writeWorkspace(workspacePath, ws); err != nil {
+		t.Fatalf("writeWorkspace failed: %v", err)
+	}
+	data, err := os.ReadFile(workspacePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored workspace
+	if err := json.Unmarshal(data, &stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored.Tabs[0].FilePath != "fixtures/message.bin" || stored.Tabs[0].RulesPath != "rules.json" || stored.Tabs[0].SchemaPath != outside {
+		t.Errorf("Expected paths inside the workspace directory to be relative, got %+v", stored.Tabs[0])
+	}
+	if len(stored.IncludePaths) != 2 || stored.IncludePaths[0] != "protos" || stored.IncludePaths[1] != filepath.Dir(outside) {
+		t.Errorf("Expected include paths inside the workspace directory to be relative, got %v", stored.IncludePaths)
+	}
+
+	browserTabs := newTabManager()
+	browserTabs.addTabWithoutSave("previous", container.NewStack())
+	openFiles(browserTabs, fyneApp, window, OpenRequest{Files: []string{workspacePath}})
+	if len(browserTabs.tabs) != 1 {
+		t.Fatalf("Expected the workspace to replace open tabs, got %d tabs", len(browserTabs.tabs))
+	}
+	tab := browserTabs.tabs[0]
+	if tab.filePath != path || tab.schemaPath != outside || tab.schemaMessageName != "Message" {
+		t.Errorf("Expected file and schema binding to be restored, got %q %q %q", tab.filePath, tab.schemaPath, tab.schemaMessageName)
+	}
+	if tab.viewMode != viewModeText || tab.rulesPath != filepath.Join(dir, "rules.json") {
+		t.Errorf("Expected view mode and rules to be restored, got %q %q", tab.viewMode, tab.rulesPath)
+	}
+	if tab.expandNodes == nil || browserTabs.workspacePath != workspacePath {
+		t.Error("Expected the view to register tree state and the workspace to become current")
+	}
+	if len(browserTabs.includePaths) != 2 || browserTabs.includePaths[0] != filepath.Join(dir, "protos") {
+		t.Errorf("Expected include paths to be restored as absolute paths, got %v", browserTabs.includePaths)
+	}
+
+	savedPath := filepath.Join(dir, "copy"+workspaceExt)
+	if err := saveWorkspace(browserTabs, window, savedPath); err != nil {
+		t.Fatalf("saveWorkspace failed: %v", err)
+	}
+	saved, err := readWorkspace(savedPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saved.Tabs) != 1 || saved.Tabs[0].FilePath != path || saved.Tabs[0].ViewMode != viewModeText || saved.SelectedTab != 0 || len(saved.IncludePaths) != 2 {
+		t.Errorf("Expected saved workspace to round-trip, got %+v", saved)
+	}
+}
+
+func TestWorkspace_KeepsGlobalTabsAndReopensOnRestart(t *testing.T) {
+	useTempConfigDir(t)
+
+	fyneApp := test.NewApp()
+	defer fyneApp.Quit()
+	window := fyneApp.NewWindow("test")
+
+	dir := t.TempDir()
+	globalPath := filepath.Join(dir, "global.bin")
+	workspaceFile := filepath.Join(dir, "fixture.bin")
+	for _, path := range []string{globalPath, workspaceFile} {
+		if err := os.WriteFile(path, []byte{0x08, 0x01}, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	workspacePath := filepath.Join(dir, "case"+workspaceExt)
+	if err := writeWorkspace(workspacePath, &workspace{Tabs: []tabState{{Title: "fixture.bin", FilePath: workspaceFile}}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := updateAppState(func(state *appState) {
+		state.Tabs = []tabState{{Title: "global.bin", FilePath: globalPath}}
+		state.SelectedTab = 0
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	browserTabs := newTabManager()
+	if err := openWorkspace(browserTabs, fyneApp, window, workspacePath); err != nil {
+		t.Fatalf("openWorkspace failed: %v", err)
+	}
+	pendingStateSaves.Wait()
+	if err := saveTabState(browserTabs); err != nil {
+		t.Fatal(err)
+	}
+	state, err := readAppState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.WorkspacePath != workspacePath || len(state.Tabs) != 1 || state.Tabs[0].FilePath != globalPath {
+		t.Fatalf("Expected state.json to keep the tabs outside the workspace and remember its path, got %+v", state)
+	}
+
+	// Следующий запуск открывает рабочее пространство
+	restarted := newTabManager()
+	if err := loadTabState(restarted, fyneApp, window); err != nil {
+		t.Fatalf("loadTabState failed: %v", err)
+	}
+	if restarted.getWorkspacePath() != workspacePath || restarted.tabCount() != 1 || restarted.tabs[0].filePath != workspaceFile {
+		t.Fatalf("Expected the workspace to be reopened on restart, got %q with %d tabs", restarted.getWorkspacePath(), restarted.tabCount())
+	}
+
+	closeWorkspace(restarted, fyneApp, window)
+	pendingStateSaves.Wait()
+	if restarted.getWorkspacePath() != "" || restarted.tabCount() != 1 || restarted.tabs[0].filePath != globalPath {
+		t.Errorf("Expected closing the workspace to restore the tabs outside it, got %q with %d tabs", restarted.getWorkspacePath(), restarted.tabCount())
+	}
+	if state, err := readAppState(); err != nil || state.WorkspacePath != "" {
+		t.Errorf("Expected the closed workspace to be forgotten, got %+v, %v", state, err)
+	}
+}
+
+func TestExpandedTreePaths_RoundTrip(t *testing.T) {
+	// 1: 1, 2: { 1: 2, 3: { 1: 3 } }
+	data := []byte{0x08, 0x01, 0x12, 0x06, 0x08, 0x02, 0x1a, 0x02, 0x08, 0x03}
+	newTree := func() *widget.Tree {
+		root, err := protobuf.DecodeLazy(context.Background(), data, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		adapter := newProtoTreeAdapter(root)
+		return widget.NewTree(adapter.ChildUIDs, adapter.IsBranch, adapter.CreateNode, adapter.UpdateNode)
+	}
+
+	tree := newTree()
+	message := tree.ChildUIDs("")[1]
+	tree.OpenBranch(message)
+	tree.OpenBranch(tree.ChildUIDs(message)[1])
+	paths := expandedTreePaths(tree)
+	if fmt.Sprint(paths) != "[1 1/1]" {
+		t.Fatalf("Expected index paths of open branches, got %v", paths)
+	}
+
+	restored := newTree()
+	expandTreePaths(restored, append(paths, "0", "5/1", "x"))
+	if got := expandedTreePaths(restored); fmt.Sprint(got) != "[1 1/1]" {
+		t.Errorf("Expected branches to be reopened and invalid paths skipped, got %v", got)
+	}
+}